@@ -9,6 +9,10 @@ import (
 	"github.com/bertoxic/graphqlChat/router"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -36,8 +40,29 @@ func main() {
 	fmt.Println("Application started successfully")
 	// http.HandleFunc("/", handlers.Repo.HomePage)
 	mux := router.Routes(app)
-	err = http.ListenAndServe(":8080", mux)
-	if err != nil {
+	server := &http.Server{Addr: ":8080", Handler: mux}
+
+	// stop taking requests and stop the background workers on interrupt
+	stop, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-stop.Done()
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancelShutdown()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("error shutting down server: %v", err)
+		}
+	}()
+
+	err = server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		// Shutdown returns once the requests in flight are done
+		<-stopped
+	}
+	app.Shutdown()
+	if err != nil && err != http.ErrServerClosed {
 		err = errorx.New(errorx.ErrInternal.Code, "", err)
 		// fmt.Printf("%v", err.(*errorx.AppError).Details)
 	}
@@ -9,16 +9,106 @@ import (
 	"time"
 )
 
+type AnalyticsPoint struct {
+	Start        time.Time `json:"start"`
+	Views        int       `json:"views"`
+	Reach        int       `json:"reach"`
+	Likes        int       `json:"likes"`
+	NewFollowers int       `json:"newFollowers"`
+}
+
+type Attachment struct {
+	MediaID      string    `json:"mediaId"`
+	Kind         MediaKind `json:"kind"`
+	MimeType     string    `json:"mimeType"`
+	URL          string    `json:"url"`
+	ThumbnailURL *string   `json:"thumbnailUrl,omitempty"`
+	Width        *int      `json:"width,omitempty"`
+	Height       *int      `json:"height,omitempty"`
+	Blurhash     *string   `json:"blurhash,omitempty"`
+	AltText      *string   `json:"altText,omitempty"`
+	Position     int       `json:"position"`
+}
+
+type AttachmentInput struct {
+	MediaID string  `json:"mediaId"`
+	AltText *string `json:"altText,omitempty"`
+}
+
 type AuthResponse struct {
 	AccessToken string `json:"accessToken"`
 	User        *User  `json:"user"`
 }
 
+type Bookmark struct {
+	ID           string    `json:"id"`
+	Post         *Post     `json:"post"`
+	CollectionID string    `json:"collectionId"`
+	Note         *string   `json:"note,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type BookmarkCollection struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Position      int       `json:"position"`
+	IsDefault     bool      `json:"isDefault"`
+	BookmarkCount int       `json:"bookmarkCount"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type BookmarkPage struct {
+	Bookmarks  []*Bookmark `json:"bookmarks"`
+	HasMore    bool        `json:"hasMore"`
+	NextCursor *string     `json:"nextCursor,omitempty"`
+}
+
+type ContentLabels struct {
+	Category    *ContentWarningCategory `json:"category,omitempty"`
+	Warning     *string                 `json:"warning,omitempty"`
+	IsSensitive bool                    `json:"isSensitive"`
+}
+
+type ContentLabelsInput struct {
+	Category    *ContentWarningCategory `json:"category,omitempty"`
+	Warning     *string                 `json:"warning,omitempty"`
+	IsSensitive *bool                   `json:"isSensitive,omitempty"`
+}
+
+type CreatePollInput struct {
+	Options        []string  `json:"options"`
+	ClosesAt       time.Time `json:"closesAt"`
+	MultipleChoice *bool     `json:"multipleChoice,omitempty"`
+}
+
 type CreatePostInput struct {
-	Title    *string `json:"title,omitempty"`
-	Content  string  `json:"content"`
-	ImageURL *string `json:"imageUrl,omitempty"`
-	AudioURL *string `json:"audioUrl,omitempty"`
+	Title       *string             `json:"title,omitempty"`
+	Content     string              `json:"content"`
+	ImageURL    *string             `json:"imageUrl,omitempty"`
+	AudioURL    *string             `json:"audioUrl,omitempty"`
+	IsDraft     *bool               `json:"isDraft,omitempty"`
+	PublishAt   *time.Time          `json:"publishAt,omitempty"`
+	Poll        *CreatePollInput    `json:"poll,omitempty"`
+	Attachments []*AttachmentInput  `json:"attachments,omitempty"`
+	Audience    *PostAudience       `json:"audience,omitempty"`
+	Labels      *ContentLabelsInput `json:"labels,omitempty"`
+}
+
+type CreateWebhookInput struct {
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"eventTypes"`
+	Secret     string             `json:"secret"`
+}
+
+type CreatorAnalytics struct {
+	From        time.Time            `json:"from"`
+	To          time.Time            `json:"to"`
+	Granularity AnalyticsGranularity `json:"granularity"`
+	Series      []*AnalyticsPoint    `json:"series"`
+	Totals      *AnalyticsPoint      `json:"totals"`
+	Posts       []*PostPeriodStats   `json:"posts"`
+	TopPosts    []*PostPeriodStats   `json:"topPosts"`
 }
 
 type LoginInput struct {
@@ -26,6 +116,19 @@ type LoginInput struct {
 	Password string `json:"password"`
 }
 
+type Media struct {
+	ID           string    `json:"id"`
+	Kind         MediaKind `json:"kind"`
+	MimeType     string    `json:"mimeType"`
+	Size         int       `json:"size"`
+	URL          string    `json:"url"`
+	ThumbnailURL *string   `json:"thumbnailUrl,omitempty"`
+	Width        *int      `json:"width,omitempty"`
+	Height       *int      `json:"height,omitempty"`
+	Blurhash     *string   `json:"blurhash,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
 type Mutation struct {
 }
 
@@ -39,31 +142,103 @@ type Notification struct {
 	CreatedAt time.Time        `json:"createdAt"`
 }
 
+type Poll struct {
+	ID              string        `json:"id"`
+	Options         []*PollOption `json:"options"`
+	MultipleChoice  bool          `json:"multipleChoice"`
+	ClosesAt        time.Time     `json:"closesAt"`
+	IsClosed        bool          `json:"isClosed"`
+	ResultsVisible  bool          `json:"resultsVisible"`
+	TotalVotes      *int          `json:"totalVotes,omitempty"`
+	ViewerOptionIds []string      `json:"viewerOptionIds"`
+}
+
+type PollOption struct {
+	ID       string `json:"id"`
+	Position int    `json:"position"`
+	Text     string `json:"text"`
+	Votes    *int   `json:"votes,omitempty"`
+}
+
 type Post struct {
-	ID        string         `json:"id"`
-	UserID    string         `json:"userId"`
-	Title     *string        `json:"title,omitempty"`
-	Content   string         `json:"content"`
-	ImageURL  *string        `json:"imageUrl,omitempty"`
-	VideoURL  *string        `json:"videoUrl,omitempty"`
-	AudioURL  *string        `json:"audioUrl,omitempty"`
-	IsEdited  *bool          `json:"isEdited,omitempty"`
-	IsDraft   *bool          `json:"isDraft,omitempty"`
-	ParentID  *string        `json:"parentId,omitempty"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
-	Likes     int            `json:"likes"`
-	Reposts   int            `json:"reposts"`
-	Tags      []string       `json:"tags"`
-	Children  []*Post        `json:"children,omitempty"`
-	Analytics *PostAnalytics `json:"analytics,omitempty"`
+	ID             string           `json:"id"`
+	UserID         string           `json:"userId"`
+	Title          *string          `json:"title,omitempty"`
+	Content        string           `json:"content"`
+	ImageURL       *string          `json:"imageUrl,omitempty"`
+	AudioURL       *string          `json:"audioUrl,omitempty"`
+	Attachments    []*Attachment    `json:"attachments"`
+	IsEdited       *bool            `json:"isEdited,omitempty"`
+	IsDraft        *bool            `json:"isDraft,omitempty"`
+	PublishAt      *time.Time       `json:"publishAt,omitempty"`
+	ParentID       *string          `json:"parentId,omitempty"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	UpdatedAt      time.Time        `json:"updatedAt"`
+	Likes          int              `json:"likes"`
+	ReactionCounts []*ReactionCount `json:"reactionCounts"`
+	ViewerReaction *ReactionKind    `json:"viewerReaction,omitempty"`
+	Reposts        int              `json:"reposts"`
+	Tags           []string         `json:"tags"`
+	Entities       []*PostEntity    `json:"entities"`
+	Revisions      []*PostRevision  `json:"revisions"`
+	QuotedPost     *Post            `json:"quotedPost,omitempty"`
+	QuoteCount     int              `json:"quoteCount"`
+	RepostOf       *Post            `json:"repostOf,omitempty"`
+	Labels         *PostLabels      `json:"labels,omitempty"`
+	Poll           *Poll            `json:"poll,omitempty"`
+	IsDeleted      bool             `json:"isDeleted"`
+	DeletedAt      *time.Time       `json:"deletedAt,omitempty"`
+	IsPinned       bool             `json:"isPinned"`
+	Audience       PostAudience     `json:"audience"`
+	Children       []*Post          `json:"children,omitempty"`
+	Analytics      *PostAnalytics   `json:"analytics,omitempty"`
 }
 
 type PostAnalytics struct {
-	Views         int `json:"views"`
-	Reach         int `json:"reach"`
-	CommentsCount int `json:"commentsCount"`
-	Shares        int `json:"shares"`
+	Views          int     `json:"views"`
+	Reach          int     `json:"reach"`
+	CommentsCount  int     `json:"commentsCount"`
+	Shares         int     `json:"shares"`
+	EngagementRate float64 `json:"engagementRate"`
+}
+
+type PostEntity struct {
+	Type   PostEntityType `json:"type"`
+	Text   string         `json:"text"`
+	Start  int            `json:"start"`
+	End    int            `json:"end"`
+	UserID *string        `json:"userId,omitempty"`
+}
+
+type PostLabelChange struct {
+	ID          string         `json:"id"`
+	PostID      string         `json:"postId"`
+	ActorID     string         `json:"actorId"`
+	ByModerator bool           `json:"byModerator"`
+	Before      *ContentLabels `json:"before"`
+	After       *ContentLabels `json:"after"`
+	CreatedAt   time.Time      `json:"createdAt"`
+}
+
+type PostLabels struct {
+	Category    *ContentWarningCategory `json:"category,omitempty"`
+	Warning     *string                 `json:"warning,omitempty"`
+	IsSensitive bool                    `json:"isSensitive"`
+	ByModerator bool                    `json:"byModerator"`
+	Filter      ContentFilter           `json:"filter"`
+}
+
+type PostPage struct {
+	Posts      []*Post `json:"posts"`
+	HasMore    bool    `json:"hasMore"`
+	NextCursor *string `json:"nextCursor,omitempty"`
+}
+
+type PostPeriodStats struct {
+	Post  *Post `json:"post"`
+	Views int   `json:"views"`
+	Reach int   `json:"reach"`
+	Likes int   `json:"likes"`
 }
 
 type PostResponse struct {
@@ -71,24 +246,108 @@ type PostResponse struct {
 	Message *string `json:"message,omitempty"`
 }
 
+type PostRevision struct {
+	ID        string    `json:"id"`
+	PostID    string    `json:"postId"`
+	EditorID  string    `json:"editorId"`
+	Title     *string   `json:"title,omitempty"`
+	Content   string    `json:"content"`
+	ImageURL  *string   `json:"imageUrl,omitempty"`
+	AudioURL  *string   `json:"audioUrl,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PostSearchFilter struct {
+	AuthorID *string    `json:"authorId,omitempty"`
+	Tag      *string    `json:"tag,omitempty"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	HasMedia *bool      `json:"hasMedia,omitempty"`
+}
+
+type PostSearchPage struct {
+	Results    []*PostSearchResult `json:"results"`
+	HasMore    bool                `json:"hasMore"`
+	NextCursor *string             `json:"nextCursor,omitempty"`
+}
+
+type PostSearchResult struct {
+	Post           *Post   `json:"post"`
+	Rank           float64 `json:"rank"`
+	TitleHighlight *string `json:"titleHighlight,omitempty"`
+	Snippet        string  `json:"snippet"`
+}
+
 type Query struct {
 }
 
+type Reaction struct {
+	UserID    string       `json:"userId"`
+	Kind      ReactionKind `json:"kind"`
+	ReactedAt time.Time    `json:"reactedAt"`
+}
+
+type ReactionCount struct {
+	Kind  ReactionKind `json:"kind"`
+	Count int          `json:"count"`
+}
+
+type ReactionPage struct {
+	Reactions  []*Reaction `json:"reactions"`
+	HasMore    bool        `json:"hasMore"`
+	NextCursor *string     `json:"nextCursor,omitempty"`
+}
+
 type RegisterInput struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Email    string `json:"email"`
 }
 
+type Reposter struct {
+	UserID     string    `json:"userId"`
+	RepostID   string    `json:"repostId"`
+	RepostedAt time.Time `json:"repostedAt"`
+}
+
+type ReposterPage struct {
+	Reposters  []*Reposter `json:"reposters"`
+	HasMore    bool        `json:"hasMore"`
+	NextCursor *string     `json:"nextCursor,omitempty"`
+}
+
+type SearchHit struct {
+	Type  SearchHitType `json:"type"`
+	Score float64       `json:"score"`
+	User  *User         `json:"user,omitempty"`
+	Post  *Post         `json:"post,omitempty"`
+	Tag   *string       `json:"tag,omitempty"`
+}
+
 type SearchResult struct {
-	Users []*User  `json:"users"`
-	Posts []*Post  `json:"posts"`
-	Tags  []string `json:"tags"`
+	Users []*User      `json:"users"`
+	Posts []*Post      `json:"posts"`
+	Tags  []string     `json:"tags"`
+	Top   []*SearchHit `json:"top"`
 }
 
 type Subscription struct {
 }
 
+type ThreadNode struct {
+	Post              *Post         `json:"post"`
+	ReplyCount        int           `json:"replyCount"`
+	Replies           []*ThreadNode `json:"replies"`
+	HasMoreReplies    bool          `json:"hasMoreReplies"`
+	MoreRepliesCursor *string       `json:"moreRepliesCursor,omitempty"`
+}
+
+type TrendingTag struct {
+	Name      string  `json:"name"`
+	Score     float64 `json:"score"`
+	PostCount int     `json:"postCount"`
+}
+
 type UpdateUserInput struct {
 	FullName          *string    `json:"fullName,omitempty"`
 	UserName          *string    `json:"userName,omitempty"`
@@ -103,6 +362,13 @@ type UpdateUserInput struct {
 	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
 }
 
+type UpdateWebhookInput struct {
+	URL        *string            `json:"url,omitempty"`
+	EventTypes []WebhookEventType `json:"eventTypes,omitempty"`
+	Secret     *string            `json:"secret,omitempty"`
+	IsActive   *bool              `json:"isActive,omitempty"`
+}
+
 type User struct {
 	ID                string    `json:"id"`
 	Username          string    `json:"username"`
@@ -120,6 +386,7 @@ type User struct {
 	Followers         []*User   `json:"followers,omitempty"`
 	Following         []*User   `json:"following,omitempty"`
 	Posts             []*Post   `json:"posts,omitempty"`
+	PinnedPosts       []*Post   `json:"pinnedPosts"`
 	BookmarkedPosts   []*Post   `json:"bookmarkedPosts,omitempty"`
 }
 
@@ -138,6 +405,25 @@ type UserDetails struct {
 	UpdatedAt         time.Time `json:"updatedAt"`
 }
 
+type UserList struct {
+	ID            string    `json:"id"`
+	OwnerID       string    `json:"ownerId"`
+	Name          string    `json:"name"`
+	Description   *string   `json:"description,omitempty"`
+	IsPrivate     bool      `json:"isPrivate"`
+	MemberCount   int       `json:"memberCount"`
+	FollowerCount int       `json:"followerCount"`
+	IsFollowing   bool      `json:"isFollowing"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type UserListInput struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	IsPrivate   *bool   `json:"isPrivate,omitempty"`
+}
+
 type UserPostStats struct {
 	TotalPosts   int `json:"totalPosts"`
 	TotalLikes   int `json:"totalLikes"`
@@ -155,20 +441,232 @@ type UserResponseData struct {
 	UserDetails *UserDetails `json:"userDetails,omitempty"`
 }
 
+type UserSearchPage struct {
+	Users      []*User `json:"users"`
+	HasMore    bool    `json:"hasMore"`
+	NextCursor *string `json:"nextCursor,omitempty"`
+}
+
 type UserStats struct {
 	TotalPosts     int `json:"totalPosts"`
 	TotalFollowers int `json:"totalFollowers"`
 	TotalFollowing int `json:"totalFollowing"`
 }
 
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	SubscriptionID string                `json:"subscriptionId"`
+	EventType      WebhookEventType      `json:"eventType"`
+	Payload        string                `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	LastStatusCode *int                  `json:"lastStatusCode,omitempty"`
+	LastError      *string               `json:"lastError,omitempty"`
+	LastAttemptAt  *time.Time            `json:"lastAttemptAt,omitempty"`
+	NextAttemptAt  *time.Time            `json:"nextAttemptAt,omitempty"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty"`
+	DeadAt         *time.Time            `json:"deadAt,omitempty"`
+	CreatedAt      time.Time             `json:"createdAt"`
+}
+
+type WebhookSubscription struct {
+	ID         string             `json:"id"`
+	URL        string             `json:"url"`
+	EventTypes []WebhookEventType `json:"eventTypes"`
+	IsActive   bool               `json:"isActive"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt"`
+}
+
+type AnalyticsGranularity string
+
+const (
+	AnalyticsGranularityDay   AnalyticsGranularity = "DAY"
+	AnalyticsGranularityWeek  AnalyticsGranularity = "WEEK"
+	AnalyticsGranularityMonth AnalyticsGranularity = "MONTH"
+)
+
+var AllAnalyticsGranularity = []AnalyticsGranularity{
+	AnalyticsGranularityDay,
+	AnalyticsGranularityWeek,
+	AnalyticsGranularityMonth,
+}
+
+func (e AnalyticsGranularity) IsValid() bool {
+	switch e {
+	case AnalyticsGranularityDay, AnalyticsGranularityWeek, AnalyticsGranularityMonth:
+		return true
+	}
+	return false
+}
+
+func (e AnalyticsGranularity) String() string {
+	return string(e)
+}
+
+func (e *AnalyticsGranularity) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AnalyticsGranularity(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AnalyticsGranularity", str)
+	}
+	return nil
+}
+
+func (e AnalyticsGranularity) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ContentFilter string
+
+const (
+	ContentFilterHide ContentFilter = "HIDE"
+	ContentFilterBlur ContentFilter = "BLUR"
+	ContentFilterShow ContentFilter = "SHOW"
+)
+
+var AllContentFilter = []ContentFilter{
+	ContentFilterHide,
+	ContentFilterBlur,
+	ContentFilterShow,
+}
+
+func (e ContentFilter) IsValid() bool {
+	switch e {
+	case ContentFilterHide, ContentFilterBlur, ContentFilterShow:
+		return true
+	}
+	return false
+}
+
+func (e ContentFilter) String() string {
+	return string(e)
+}
+
+func (e *ContentFilter) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ContentFilter(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ContentFilter", str)
+	}
+	return nil
+}
+
+func (e ContentFilter) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ContentWarningCategory string
+
+const (
+	ContentWarningCategorySpoiler    ContentWarningCategory = "SPOILER"
+	ContentWarningCategoryViolence   ContentWarningCategory = "VIOLENCE"
+	ContentWarningCategoryNudity     ContentWarningCategory = "NUDITY"
+	ContentWarningCategorySelfHarm   ContentWarningCategory = "SELF_HARM"
+	ContentWarningCategoryDisturbing ContentWarningCategory = "DISTURBING"
+	ContentWarningCategoryOther      ContentWarningCategory = "OTHER"
+)
+
+var AllContentWarningCategory = []ContentWarningCategory{
+	ContentWarningCategorySpoiler,
+	ContentWarningCategoryViolence,
+	ContentWarningCategoryNudity,
+	ContentWarningCategorySelfHarm,
+	ContentWarningCategoryDisturbing,
+	ContentWarningCategoryOther,
+}
+
+func (e ContentWarningCategory) IsValid() bool {
+	switch e {
+	case ContentWarningCategorySpoiler, ContentWarningCategoryViolence, ContentWarningCategoryNudity, ContentWarningCategorySelfHarm, ContentWarningCategoryDisturbing, ContentWarningCategoryOther:
+		return true
+	}
+	return false
+}
+
+func (e ContentWarningCategory) String() string {
+	return string(e)
+}
+
+func (e *ContentWarningCategory) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ContentWarningCategory(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ContentWarningCategory", str)
+	}
+	return nil
+}
+
+func (e ContentWarningCategory) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type MediaKind string
+
+const (
+	MediaKindImage MediaKind = "IMAGE"
+	MediaKindAudio MediaKind = "AUDIO"
+	MediaKindVideo MediaKind = "VIDEO"
+)
+
+var AllMediaKind = []MediaKind{
+	MediaKindImage,
+	MediaKindAudio,
+	MediaKindVideo,
+}
+
+func (e MediaKind) IsValid() bool {
+	switch e {
+	case MediaKindImage, MediaKindAudio, MediaKindVideo:
+		return true
+	}
+	return false
+}
+
+func (e MediaKind) String() string {
+	return string(e)
+}
+
+func (e *MediaKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MediaKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MediaKind", str)
+	}
+	return nil
+}
+
+func (e MediaKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 type NotificationType string
 
 const (
-	NotificationTypeLike    NotificationType = "LIKE"
-	NotificationTypeComment NotificationType = "COMMENT"
-	NotificationTypeFollow  NotificationType = "FOLLOW"
-	NotificationTypeMention NotificationType = "MENTION"
-	NotificationTypeRetweet NotificationType = "RETWEET"
+	NotificationTypeLike       NotificationType = "LIKE"
+	NotificationTypeComment    NotificationType = "COMMENT"
+	NotificationTypeFollow     NotificationType = "FOLLOW"
+	NotificationTypeMention    NotificationType = "MENTION"
+	NotificationTypeRetweet    NotificationType = "RETWEET"
+	NotificationTypeQuote      NotificationType = "QUOTE"
+	NotificationTypePollClosed NotificationType = "POLL_CLOSED"
+	NotificationTypeReaction   NotificationType = "REACTION"
 )
 
 var AllNotificationType = []NotificationType{
@@ -177,11 +675,14 @@ var AllNotificationType = []NotificationType{
 	NotificationTypeFollow,
 	NotificationTypeMention,
 	NotificationTypeRetweet,
+	NotificationTypeQuote,
+	NotificationTypePollClosed,
+	NotificationTypeReaction,
 }
 
 func (e NotificationType) IsValid() bool {
 	switch e {
-	case NotificationTypeLike, NotificationTypeComment, NotificationTypeFollow, NotificationTypeMention, NotificationTypeRetweet:
+	case NotificationTypeLike, NotificationTypeComment, NotificationTypeFollow, NotificationTypeMention, NotificationTypeRetweet, NotificationTypeQuote, NotificationTypePollClosed, NotificationTypeReaction:
 		return true
 	}
 	return false
@@ -207,3 +708,314 @@ func (e *NotificationType) UnmarshalGQL(v interface{}) error {
 func (e NotificationType) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
+
+type PostAudience string
+
+const (
+	PostAudiencePublic       PostAudience = "PUBLIC"
+	PostAudienceFollowers    PostAudience = "FOLLOWERS"
+	PostAudienceMentioned    PostAudience = "MENTIONED"
+	PostAudienceCloseFriends PostAudience = "CLOSE_FRIENDS"
+)
+
+var AllPostAudience = []PostAudience{
+	PostAudiencePublic,
+	PostAudienceFollowers,
+	PostAudienceMentioned,
+	PostAudienceCloseFriends,
+}
+
+func (e PostAudience) IsValid() bool {
+	switch e {
+	case PostAudiencePublic, PostAudienceFollowers, PostAudienceMentioned, PostAudienceCloseFriends:
+		return true
+	}
+	return false
+}
+
+func (e PostAudience) String() string {
+	return string(e)
+}
+
+func (e *PostAudience) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostAudience(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostAudience", str)
+	}
+	return nil
+}
+
+func (e PostAudience) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PostEntityType string
+
+const (
+	PostEntityTypeHashtag PostEntityType = "HASHTAG"
+	PostEntityTypeMention PostEntityType = "MENTION"
+	PostEntityTypeURL     PostEntityType = "URL"
+)
+
+var AllPostEntityType = []PostEntityType{
+	PostEntityTypeHashtag,
+	PostEntityTypeMention,
+	PostEntityTypeURL,
+}
+
+func (e PostEntityType) IsValid() bool {
+	switch e {
+	case PostEntityTypeHashtag, PostEntityTypeMention, PostEntityTypeURL:
+		return true
+	}
+	return false
+}
+
+func (e PostEntityType) String() string {
+	return string(e)
+}
+
+func (e *PostEntityType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostEntityType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostEntityType", str)
+	}
+	return nil
+}
+
+func (e PostEntityType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReactionKind string
+
+const (
+	ReactionKindLike  ReactionKind = "LIKE"
+	ReactionKindLove  ReactionKind = "LOVE"
+	ReactionKindLaugh ReactionKind = "LAUGH"
+	ReactionKindWow   ReactionKind = "WOW"
+	ReactionKindSad   ReactionKind = "SAD"
+	ReactionKindAngry ReactionKind = "ANGRY"
+)
+
+var AllReactionKind = []ReactionKind{
+	ReactionKindLike,
+	ReactionKindLove,
+	ReactionKindLaugh,
+	ReactionKindWow,
+	ReactionKindSad,
+	ReactionKindAngry,
+}
+
+func (e ReactionKind) IsValid() bool {
+	switch e {
+	case ReactionKindLike, ReactionKindLove, ReactionKindLaugh, ReactionKindWow, ReactionKindSad, ReactionKindAngry:
+		return true
+	}
+	return false
+}
+
+func (e ReactionKind) String() string {
+	return string(e)
+}
+
+func (e *ReactionKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReactionKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReactionKind", str)
+	}
+	return nil
+}
+
+func (e ReactionKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type SearchHitType string
+
+const (
+	SearchHitTypeUser SearchHitType = "USER"
+	SearchHitTypePost SearchHitType = "POST"
+	SearchHitTypeTag  SearchHitType = "TAG"
+)
+
+var AllSearchHitType = []SearchHitType{
+	SearchHitTypeUser,
+	SearchHitTypePost,
+	SearchHitTypeTag,
+}
+
+func (e SearchHitType) IsValid() bool {
+	switch e {
+	case SearchHitTypeUser, SearchHitTypePost, SearchHitTypeTag:
+		return true
+	}
+	return false
+}
+
+func (e SearchHitType) String() string {
+	return string(e)
+}
+
+func (e *SearchHitType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SearchHitType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SearchHitType", str)
+	}
+	return nil
+}
+
+func (e SearchHitType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type TrendingWindow string
+
+const (
+	TrendingWindowHour TrendingWindow = "HOUR"
+	TrendingWindowDay  TrendingWindow = "DAY"
+	TrendingWindowWeek TrendingWindow = "WEEK"
+)
+
+var AllTrendingWindow = []TrendingWindow{
+	TrendingWindowHour,
+	TrendingWindowDay,
+	TrendingWindowWeek,
+}
+
+func (e TrendingWindow) IsValid() bool {
+	switch e {
+	case TrendingWindowHour, TrendingWindowDay, TrendingWindowWeek:
+		return true
+	}
+	return false
+}
+
+func (e TrendingWindow) String() string {
+	return string(e)
+}
+
+func (e *TrendingWindow) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TrendingWindow(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TrendingWindow", str)
+	}
+	return nil
+}
+
+func (e TrendingWindow) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryStatusDead      WebhookDeliveryStatus = "DEAD"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusDelivered,
+	WebhookDeliveryStatusDead,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusDelivered, WebhookDeliveryStatusDead:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookEventType string
+
+const (
+	WebhookEventTypePostCreated  WebhookEventType = "POST_CREATED"
+	WebhookEventTypePostLiked    WebhookEventType = "POST_LIKED"
+	WebhookEventTypeUserFollowed WebhookEventType = "USER_FOLLOWED"
+	WebhookEventTypeMessageSent  WebhookEventType = "MESSAGE_SENT"
+)
+
+var AllWebhookEventType = []WebhookEventType{
+	WebhookEventTypePostCreated,
+	WebhookEventTypePostLiked,
+	WebhookEventTypeUserFollowed,
+	WebhookEventTypeMessageSent,
+}
+
+func (e WebhookEventType) IsValid() bool {
+	switch e {
+	case WebhookEventTypePostCreated, WebhookEventTypePostLiked, WebhookEventTypeUserFollowed, WebhookEventTypeMessageSent:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEventType) String() string {
+	return string(e)
+}
+
+func (e *WebhookEventType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEventType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEventType", str)
+	}
+	return nil
+}
+
+func (e WebhookEventType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,48 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.55
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/bertoxic/graphqlChat/graph/model"
+	"github.com/bertoxic/graphqlChat/internal/media"
+	"github.com/bertoxic/graphqlChat/internal/middlewares"
+)
+
+// UploadMedia is the resolver for the uploadMedia field.
+func (r *mutationResolver) UploadMedia(ctx context.Context, files []*graphql.Upload) ([]*model.Media, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	uploads := make([]media.UploadFile, len(files))
+	for i, file := range files {
+		uploads[i] = media.UploadFile{File: file.File, Filename: file.Filename, Size: file.Size}
+	}
+	stored, err := r.MediaService.Upload(ctx, userID, uploads)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelMedia := make([]*model.Media, len(stored))
+	for i, m := range stored {
+		modelMedia[i] = &model.Media{
+			ID:           m.ID,
+			Kind:         model.MediaKind(m.Kind),
+			MimeType:     m.MimeType,
+			Size:         int(m.Size),
+			URL:          m.URL,
+			ThumbnailURL: m.ThumbnailURL,
+			Width:        m.Width,
+			Height:       m.Height,
+			Blurhash:     m.Blurhash,
+			CreatedAt:    m.CreatedAt,
+		}
+	}
+	return modelMedia, nil
+}
@@ -46,7 +46,9 @@ func (r *queryResolver) GetUserNotifications(ctx context.Context, limit *int, of
 	for _, notification := range notifications {
 		result = append(result, &model.Notification{
 			ID:        notification.ID,
-			Type:      model.NotificationType(notification.Type),
+			UserID:    notification.UserID,
+			Type:      model.NotificationType(notification.Type.String()),
+			Title:     notification.Title,
 			Content:   notification.Content,
 			IsRead:    notification.IsRead,
 			CreatedAt: notification.CreatedAt,
@@ -5,8 +5,14 @@ import (
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/bertoxic/graphqlChat/graph/model"
 	"github.com/bertoxic/graphqlChat/internal/auth"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/media"
+	"github.com/bertoxic/graphqlChat/internal/middlewares"
+	"github.com/bertoxic/graphqlChat/internal/models"
 	"github.com/bertoxic/graphqlChat/internal/posts"
+	"github.com/bertoxic/graphqlChat/internal/search"
 	"github.com/bertoxic/graphqlChat/internal/user"
+	"github.com/bertoxic/graphqlChat/internal/webhooks"
 	"github.com/vektah/gqlparser/v2/gqlerror"
 	"net/http"
 )
@@ -22,6 +28,9 @@ type Resolver struct {
 	AuthUserService auth.UserRepository
 	PostService     posts.PostService
 	UserService     user.Service
+	MediaService    media.MediaService
+	SearchService   search.SearchService
+	WebhookService  webhooks.WebhookService
 }
 
 func NewResolver(authService auth.AuthService, userService auth.UserRepository, postService posts.PostService) *Resolver {
@@ -44,6 +53,18 @@ func buildBadRequestError(ctx context.Context, err error) error {
 	}
 }
 
+// requireSignedInUser only lets the signed in user read data kept for userID
+func requireSignedInUser(ctx context.Context, userID string) error {
+	currentUserID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if currentUserID != userID {
+		return errorx.New(errorx.ErrCodeForbidden, "only available to the signed in user", nil)
+	}
+	return nil
+}
+
 // Helper function to get current user ID from context
 func getCurrentUserID(ctx context.Context) string {
 	// This is just a placeholder
@@ -66,11 +87,352 @@ func convertToModelPost(post *posts.Post) *model.Post {
 		Content:   post.Content,
 		ImageURL:  post.ImageURL,
 		AudioURL:  post.AudioURL,
+		IsEdited:  post.IsEdited,
+		IsDraft:   post.IsDraft,
+		PublishAt: post.PublishAt,
 		ParentID:  post.ParentID,
 		CreatedAt: post.CreatedAt,
 		UpdatedAt: post.UpdatedAt,
 		Likes:     post.Likes,
 		Reposts:   post.Reposts,
+		IsDeleted: post.DeletedAt != nil,
+		DeletedAt: post.DeletedAt,
+		IsPinned:  post.IsPinned,
+		Audience:  model.PostAudience(post.Audience),
 		Children:  childrenPosts,
 	}
 }
+
+func convertToModelThread(node *posts.ThreadNode, svc posts.PostService) *model.ThreadNode {
+	svc.HandleNullablePostFields(node.Post)
+	replies := make([]*model.ThreadNode, len(node.Replies))
+	for i, reply := range node.Replies {
+		replies[i] = convertToModelThread(reply, svc)
+	}
+	return &model.ThreadNode{
+		Post:              convertToModelPost(node.Post),
+		ReplyCount:        node.ReplyCount,
+		Replies:           replies,
+		HasMoreReplies:    node.HasMoreReplies,
+		MoreRepliesCursor: node.MoreRepliesCursor,
+	}
+}
+
+func convertToAttachmentInputs(inputs []*model.AttachmentInput) []posts.AttachmentInput {
+	attachments := make([]posts.AttachmentInput, len(inputs))
+	for i, input := range inputs {
+		attachments[i] = posts.AttachmentInput{MediaID: input.MediaID, AltText: input.AltText}
+	}
+	return attachments
+}
+
+func convertToPollInput(input *model.CreatePollInput) *posts.CreatePollInput {
+	if input == nil {
+		return nil
+	}
+	return &posts.CreatePollInput{
+		Options:        input.Options,
+		ClosesAt:       input.ClosesAt,
+		MultipleChoice: input.MultipleChoice != nil && *input.MultipleChoice,
+	}
+}
+
+func convertToModelPoll(poll *posts.Poll) *model.Poll {
+	if poll == nil {
+		return nil
+	}
+
+	options := make([]*model.PollOption, len(poll.Options))
+	for i, option := range poll.Options {
+		options[i] = &model.PollOption{
+			ID:       option.ID,
+			Position: option.Position,
+			Text:     option.Text,
+			Votes:    option.Votes,
+		}
+	}
+
+	return &model.Poll{
+		ID:              poll.ID,
+		Options:         options,
+		MultipleChoice:  poll.MultipleChoice,
+		ClosesAt:        poll.ClosesAt,
+		IsClosed:        poll.IsClosed(),
+		ResultsVisible:  poll.ResultsVisible,
+		TotalVotes:      poll.TotalVotes,
+		ViewerOptionIds: poll.ViewerOptionIDs,
+	}
+}
+
+func convertToModelAnalyticsPoint(point *posts.AnalyticsPoint) *model.AnalyticsPoint {
+	return &model.AnalyticsPoint{
+		Start:        point.Start,
+		Views:        point.Views,
+		Reach:        point.Reach,
+		Likes:        point.Likes,
+		NewFollowers: point.NewFollowers,
+	}
+}
+
+func convertToModelPostPeriodStats(stats []*posts.PostPeriodStats, svc posts.PostService) []*model.PostPeriodStats {
+	modelStats := make([]*model.PostPeriodStats, len(stats))
+	for i, s := range stats {
+		svc.HandleNullablePostFields(s.Post)
+		modelStats[i] = &model.PostPeriodStats{
+			Post:  convertToModelPost(s.Post),
+			Views: s.Views,
+			Reach: s.Reach,
+			Likes: s.Likes,
+		}
+	}
+	return modelStats
+}
+
+func convertToModelCreatorAnalytics(analytics *posts.CreatorAnalytics, svc posts.PostService) *model.CreatorAnalytics {
+	series := make([]*model.AnalyticsPoint, len(analytics.Series))
+	for i, point := range analytics.Series {
+		series[i] = convertToModelAnalyticsPoint(point)
+	}
+	return &model.CreatorAnalytics{
+		From:        analytics.From,
+		To:          analytics.To,
+		Granularity: model.AnalyticsGranularity(analytics.Granularity),
+		Series:      series,
+		Totals:      convertToModelAnalyticsPoint(analytics.Totals),
+		Posts:       convertToModelPostPeriodStats(analytics.Posts, svc),
+		TopPosts:    convertToModelPostPeriodStats(analytics.TopPosts, svc),
+	}
+}
+
+func convertToModelBookmarkCollections(collections []*posts.BookmarkCollection) []*model.BookmarkCollection {
+	modelCollections := make([]*model.BookmarkCollection, len(collections))
+	for i, c := range collections {
+		modelCollections[i] = convertToModelBookmarkCollection(c)
+	}
+	return modelCollections
+}
+
+func convertToModelBookmarkCollection(collection *posts.BookmarkCollection) *model.BookmarkCollection {
+	if collection == nil {
+		return nil
+	}
+	return &model.BookmarkCollection{
+		ID:            collection.ID,
+		Name:          collection.Name,
+		Position:      collection.Position,
+		IsDefault:     collection.IsDefault,
+		BookmarkCount: collection.BookmarkCount,
+		CreatedAt:     collection.CreatedAt,
+		UpdatedAt:     collection.UpdatedAt,
+	}
+}
+
+func convertToModelBookmark(bookmark *posts.Bookmark, svc posts.PostService) *model.Bookmark {
+	if bookmark == nil {
+		return nil
+	}
+	svc.HandleNullablePostFields(bookmark.Post)
+	return &model.Bookmark{
+		ID:           bookmark.ID,
+		Post:         convertToModelPost(bookmark.Post),
+		CollectionID: bookmark.CollectionID,
+		Note:         bookmark.Note,
+		CreatedAt:    bookmark.CreatedAt,
+	}
+}
+
+func convertToPostSearchFilter(filter *model.PostSearchFilter) posts.PostSearchFilter {
+	if filter == nil {
+		return posts.PostSearchFilter{}
+	}
+	return posts.PostSearchFilter{
+		AuthorID: filter.AuthorID,
+		Tag:      filter.Tag,
+		From:     filter.From,
+		To:       filter.To,
+		HasMedia: filter.HasMedia,
+	}
+}
+
+// convertToModelSearchUser leaves out email, search results are visible to anyone
+func convertToModelSearchUser(user *models.User) *model.User {
+	return &model.User{
+		ID:                user.ID,
+		Username:          user.UserName,
+		FullName:          user.FullName,
+		Bio:               user.Bio,
+		ProfilePictureURL: user.ProfilePictureURL,
+		IsPrivate:         user.IsPrivate,
+	}
+}
+
+func convertToModelSearchResult(result *search.Result, svc posts.PostService) *model.SearchResult {
+	modelUsers := make(map[*models.User]*model.User)
+	modelPosts := make(map[*posts.Post]*model.Post)
+	out := &model.SearchResult{Users: []*model.User{}, Posts: []*model.Post{}, Tags: result.Tags, Top: []*model.SearchHit{}}
+	for _, user := range result.Users {
+		modelUsers[user] = convertToModelSearchUser(user)
+		out.Users = append(out.Users, modelUsers[user])
+	}
+	for _, post := range result.Posts {
+		svc.HandleNullablePostFields(post)
+		modelPosts[post] = convertToModelPost(post)
+		out.Posts = append(out.Posts, modelPosts[post])
+	}
+	for _, hit := range result.Top {
+		out.Top = append(out.Top, &model.SearchHit{
+			Type:  model.SearchHitType(hit.Type),
+			Score: hit.Score,
+			User:  modelUsers[hit.User],
+			Post:  modelPosts[hit.Post],
+			Tag:   hit.Tag,
+		})
+	}
+	return out
+}
+
+func convertToModelUserLists(lists []*posts.UserList) []*model.UserList {
+	modelLists := make([]*model.UserList, len(lists))
+	for i, l := range lists {
+		modelLists[i] = convertToModelUserList(l)
+	}
+	return modelLists
+}
+
+func convertToModelUserList(list *posts.UserList) *model.UserList {
+	if list == nil {
+		return nil
+	}
+	return &model.UserList{
+		ID:            list.ID,
+		OwnerID:       list.OwnerID,
+		Name:          list.Name,
+		Description:   list.Description,
+		IsPrivate:     list.IsPrivate,
+		MemberCount:   list.MemberCount,
+		FollowerCount: list.FollowerCount,
+		IsFollowing:   list.IsFollowing,
+		CreatedAt:     list.CreatedAt,
+		UpdatedAt:     list.UpdatedAt,
+	}
+}
+
+func convertToUserListInput(input model.UserListInput) posts.UserListInput {
+	listInput := posts.UserListInput{Name: input.Name, Description: input.Description}
+	if input.IsPrivate != nil {
+		listInput.IsPrivate = *input.IsPrivate
+	}
+	return listInput
+}
+
+func convertToModelPostPage(page *posts.PostPage, svc posts.PostService) *model.PostPage {
+	modelPosts := make([]*model.Post, len(page.Posts))
+	for i, post := range page.Posts {
+		svc.HandleNullablePostFields(post)
+		modelPosts[i] = convertToModelPost(post)
+	}
+	return &model.PostPage{Posts: modelPosts, HasMore: page.HasMore, NextCursor: page.NextCursor}
+}
+
+func convertToAudience(audience *model.PostAudience) *posts.Audience {
+	if audience == nil {
+		return nil
+	}
+	a := posts.Audience(*audience)
+	return &a
+}
+
+func convertToContentLabels(input *model.ContentLabelsInput) *posts.ContentLabels {
+	if input == nil {
+		return nil
+	}
+	labels := &posts.ContentLabels{Warning: input.Warning}
+	if input.Category != nil {
+		category := posts.WarningCategory(*input.Category)
+		labels.Category = &category
+	}
+	if input.IsSensitive != nil {
+		labels.IsSensitive = *input.IsSensitive
+	}
+	return labels
+}
+
+func convertToModelWarningCategory(category *posts.WarningCategory) *model.ContentWarningCategory {
+	if category == nil {
+		return nil
+	}
+	c := model.ContentWarningCategory(*category)
+	return &c
+}
+
+func convertToModelContentLabels(labels posts.ContentLabels) *model.ContentLabels {
+	return &model.ContentLabels{
+		Category:    convertToModelWarningCategory(labels.Category),
+		Warning:     labels.Warning,
+		IsSensitive: labels.IsSensitive,
+	}
+}
+
+func convertToModelPostLabels(labels *posts.PostLabels) *model.PostLabels {
+	if labels == nil {
+		return nil
+	}
+	return &model.PostLabels{
+		Category:    convertToModelWarningCategory(labels.Category),
+		Warning:     labels.Warning,
+		IsSensitive: labels.IsSensitive,
+		ByModerator: labels.ByModerator,
+		Filter:      model.ContentFilter(labels.Filter),
+	}
+}
+
+func convertToTrendingWindow(window *model.TrendingWindow) posts.TrendingWindow {
+	if window == nil {
+		return ""
+	}
+	return posts.TrendingWindow(*window)
+}
+
+func convertToWebhookEventTypes(types []model.WebhookEventType) []webhooks.EventType {
+	if types == nil {
+		return nil
+	}
+	eventTypes := make([]webhooks.EventType, len(types))
+	for i, t := range types {
+		eventTypes[i] = webhooks.EventType(t)
+	}
+	return eventTypes
+}
+
+func convertToModelWebhookSubscription(subscription *webhooks.Subscription) *model.WebhookSubscription {
+	eventTypes := make([]model.WebhookEventType, len(subscription.EventTypes))
+	for i, t := range subscription.EventTypes {
+		eventTypes[i] = model.WebhookEventType(t)
+	}
+	return &model.WebhookSubscription{
+		ID:         subscription.ID,
+		URL:        subscription.URL,
+		EventTypes: eventTypes,
+		IsActive:   subscription.IsActive,
+		CreatedAt:  subscription.CreatedAt,
+		UpdatedAt:  subscription.UpdatedAt,
+	}
+}
+
+func convertToModelWebhookDelivery(delivery *webhooks.Delivery) *model.WebhookDelivery {
+	return &model.WebhookDelivery{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventType:      model.WebhookEventType(delivery.EventType),
+		Payload:        string(delivery.Payload),
+		Status:         model.WebhookDeliveryStatus(delivery.Status),
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		LastAttemptAt:  delivery.LastAttemptAt,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		DeliveredAt:    delivery.DeliveredAt,
+		DeadAt:         delivery.DeadAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+}
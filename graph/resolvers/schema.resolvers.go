@@ -7,6 +7,7 @@ package resolvers
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/bertoxic/graphqlChat/graph"
 	"github.com/bertoxic/graphqlChat/graph/model"
@@ -15,12 +16,23 @@ import (
 )
 
 // CreatePost is the resolver for the createPost field.
-func (r *mutationResolver) CreatePost(ctx context.Context, input model.CreatePostInput, userID string, parentID *string) (*model.Post, error) {
+func (r *mutationResolver) CreatePost(ctx context.Context, input model.CreatePostInput, parentID *string) (*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
 	inputPost := posts.CreatePostInput{
-		Title:    input.Title,
-		Content:  input.Content,
-		ImageURL: input.ImageURL, // No need to create a new string, can pass nil directly
-		AudioURL: input.AudioURL, // Fixed: was using ImageURL instead of AudioURL
+		Title:       input.Title,
+		Content:     input.Content,
+		ImageURL:    input.ImageURL, // No need to create a new string, can pass nil directly
+		AudioURL:    input.AudioURL, // Fixed: was using ImageURL instead of AudioURL
+		IsDraft:     input.IsDraft,
+		PublishAt:   input.PublishAt,
+		Poll:        convertToPollInput(input.Poll),
+		Attachments: convertToAttachmentInputs(input.Attachments),
+		Audience:    convertToAudience(input.Audience),
+		Labels:      convertToContentLabels(input.Labels),
 	}
 
 	post, err := r.PostService.CreatePost(ctx, inputPost, userID, parentID)
@@ -33,7 +45,7 @@ func (r *mutationResolver) CreatePost(ctx context.Context, input model.CreatePos
 
 // UpdatePost is the resolver for the updatePost field.
 func (r *mutationResolver) UpdatePost(ctx context.Context, postID string, input model.CreatePostInput) (*model.Post, error) {
-	_, err := middlewares.GetUserIDFromContext(ctx)
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
@@ -43,9 +55,11 @@ func (r *mutationResolver) UpdatePost(ctx context.Context, postID string, input
 		Content:  input.Content,
 		ImageURL: input.ImageURL,
 		AudioURL: input.AudioURL,
+		Audience: convertToAudience(input.Audience),
+		Labels:   convertToContentLabels(input.Labels),
 	}
 
-	updatedPost, err := r.PostService.UpdatePost(ctx, postID, updateInput)
+	updatedPost, err := r.PostService.UpdatePost(ctx, postID, updateInput, userID)
 	r.PostService.HandleNullablePostFields(updatedPost)
 
 	if err != nil {
@@ -57,14 +71,14 @@ func (r *mutationResolver) UpdatePost(ctx context.Context, postID string, input
 
 // DeletePost is the resolver for the deletePost field.
 func (r *mutationResolver) DeletePost(ctx context.Context, postID string) (*model.PostResponse, error) {
-	_, err := middlewares.GetUserIDFromContext(ctx)
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return &model.PostResponse{
 			Success: false,
 		}, buildBadRequestError(ctx, err)
 	}
 
-	postresp, err := r.PostService.DeletePost(ctx, postID)
+	postresp, err := r.PostService.DeletePost(ctx, postID, userID)
 	response := &model.PostResponse{
 		Success: postresp.Success,
 		Message: &postresp.Message,
@@ -76,8 +90,68 @@ func (r *mutationResolver) DeletePost(ctx context.Context, postID string) (*mode
 	return response, nil
 }
 
+// RestorePost is the resolver for the restorePost field.
+func (r *mutationResolver) RestorePost(ctx context.Context, postID string) (*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	post, err := r.PostService.RestorePost(ctx, postID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	r.PostService.HandleNullablePostFields(post)
+
+	return convertToModelPost(post), nil
+}
+
+// RecordPostViews is the resolver for the recordPostViews field.
+func (r *mutationResolver) RecordPostViews(ctx context.Context, postIds []string) (bool, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	if err := r.PostService.RecordViews(ctx, postIds, viewerID); err != nil {
+		return false, buildBadRequestError(ctx, err)
+	}
+	return true, nil
+}
+
+// PinPost is the resolver for the pinPost field.
+func (r *mutationResolver) PinPost(ctx context.Context, postID string) (*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	post, err := r.PostService.PinPost(ctx, postID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	r.PostService.HandleNullablePostFields(post)
+	return convertToModelPost(post), nil
+}
+
+// UnpinPost is the resolver for the unpinPost field.
+func (r *mutationResolver) UnpinPost(ctx context.Context, postID string) (*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	post, err := r.PostService.UnpinPost(ctx, postID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	r.PostService.HandleNullablePostFields(post)
+	return convertToModelPost(post), nil
+}
+
 // Repost is the resolver for the repost field.
-func (r *mutationResolver) Repost(ctx context.Context, postID string, userID string) (*model.Post, error) {
+func (r *mutationResolver) Repost(ctx context.Context, postID string) (*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
 	repostedPost, err := r.PostService.Repost(ctx, postID, userID)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
@@ -86,13 +160,35 @@ func (r *mutationResolver) Repost(ctx context.Context, postID string, userID str
 	return convertToModelPost(repostedPost), nil
 }
 
+// UndoRepost is the resolver for the undoRepost field.
+func (r *mutationResolver) UndoRepost(ctx context.Context, postID string) (*model.PostResponse, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+
+	if err := r.PostService.UndoRepost(ctx, postID, userID); err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+	message := "repost undone"
+	return &model.PostResponse{Success: true, Message: &message}, nil
+}
+
 // AddComment is the resolver for the addComment field.
-func (r *mutationResolver) AddComment(ctx context.Context, postID string, input model.CreatePostInput, userID string) (*model.Post, error) {
+func (r *mutationResolver) AddComment(ctx context.Context, postID string, input model.CreatePostInput) (*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
 	commentInput := posts.CreatePostInput{
-		Title:    input.Title,
-		Content:  input.Content,
-		ImageURL: input.ImageURL,
-		AudioURL: input.AudioURL,
+		Title:       input.Title,
+		Content:     input.Content,
+		ImageURL:    input.ImageURL,
+		AudioURL:    input.AudioURL,
+		Attachments: convertToAttachmentInputs(input.Attachments),
+		Audience:    convertToAudience(input.Audience),
+		Labels:      convertToContentLabels(input.Labels),
 	}
 
 	comment, err := r.PostService.AddComment(ctx, postID, commentInput, userID)
@@ -104,7 +200,14 @@ func (r *mutationResolver) AddComment(ctx context.Context, postID string, input
 }
 
 // LikePost is the resolver for the likePost field.
-func (r *mutationResolver) LikePost(ctx context.Context, postID string, userID string) (*model.PostResponse, error) {
+func (r *mutationResolver) LikePost(ctx context.Context, postID string) (*model.PostResponse, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{
+			Success: false,
+		}, buildBadRequestError(ctx, err)
+	}
+
 	postresp, err := r.PostService.LikePost(ctx, postID, userID)
 	response := &model.PostResponse{
 		Success: postresp.Success,
@@ -118,7 +221,14 @@ func (r *mutationResolver) LikePost(ctx context.Context, postID string, userID s
 }
 
 // UnlikePost is the resolver for the unlikePost field.
-func (r *mutationResolver) UnlikePost(ctx context.Context, postID string, userID string) (*model.PostResponse, error) {
+func (r *mutationResolver) UnlikePost(ctx context.Context, postID string) (*model.PostResponse, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{
+			Success: false,
+		}, buildBadRequestError(ctx, err)
+	}
+
 	postresp, err := r.PostService.UnlikePost(ctx, postID, userID)
 	response := &model.PostResponse{
 		Success: postresp.Success,
@@ -131,9 +241,46 @@ func (r *mutationResolver) UnlikePost(ctx context.Context, postID string, userID
 	return response, nil
 }
 
+// React is the resolver for the react field.
+func (r *mutationResolver) React(ctx context.Context, postID string, kind model.ReactionKind) (*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	post, err := r.PostService.React(ctx, postID, userID, posts.ReactionKind(kind))
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	r.PostService.HandleNullablePostFields(post)
+
+	return convertToModelPost(post), nil
+}
+
+// Unreact is the resolver for the unreact field.
+func (r *mutationResolver) Unreact(ctx context.Context, postID string) (*model.PostResponse, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+
+	if err := r.PostService.Unreact(ctx, postID, userID); err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+	message := "reaction removed"
+	return &model.PostResponse{Success: true, Message: &message}, nil
+}
+
 // TagUserInPost is the resolver for the tagUserInPost field.
 func (r *mutationResolver) TagUserInPost(ctx context.Context, postID string, taggedUserID string) (*model.PostResponse, error) {
-	postresp, err := r.PostService.TagUserInPost(ctx, postID, taggedUserID)
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{
+			Success: false,
+		}, buildBadRequestError(ctx, err)
+	}
+
+	postresp, err := r.PostService.TagUserInPost(ctx, postID, userID, taggedUserID)
 	response := &model.PostResponse{
 		Success: postresp.Success,
 		Message: &postresp.Message,
@@ -146,7 +293,14 @@ func (r *mutationResolver) TagUserInPost(ctx context.Context, postID string, tag
 }
 
 // BookmarkPost is the resolver for the bookmarkPost field.
-func (r *mutationResolver) BookmarkPost(ctx context.Context, postID string, userID string) (*model.PostResponse, error) {
+func (r *mutationResolver) BookmarkPost(ctx context.Context, postID string) (*model.PostResponse, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{
+			Success: false,
+		}, buildBadRequestError(ctx, err)
+	}
+
 	// Assuming PostService has a method `BookmarkPost` that returns a boolean indicating success and an error
 	postresp, err := r.PostService.BookmarkPost(ctx, postID, userID)
 	response := &model.PostResponse{
@@ -161,7 +315,14 @@ func (r *mutationResolver) BookmarkPost(ctx context.Context, postID string, user
 }
 
 // RemoveBookmark is the resolver for the removeBookmark field.
-func (r *mutationResolver) RemoveBookmark(ctx context.Context, postID string, userID string) (*model.PostResponse, error) {
+func (r *mutationResolver) RemoveBookmark(ctx context.Context, postID string) (*model.PostResponse, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{
+			Success: false,
+		}, buildBadRequestError(ctx, err)
+	}
+
 	postresp, err := r.PostService.RemoveBookmark(ctx, postID, userID)
 	response := &model.PostResponse{
 		Success: postresp.Success,
@@ -174,190 +335,1052 @@ func (r *mutationResolver) RemoveBookmark(ctx context.Context, postID string, us
 	return response, nil
 }
 
-// GetUserByEmail is the resolver for the getUserByEmail field.
-func (r *queryResolver) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+// CreateBookmarkCollection is the resolver for the createBookmarkCollection field.
+func (r *mutationResolver) CreateBookmarkCollection(ctx context.Context, name string) (*model.BookmarkCollection, error) {
 	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	if r.AuthUserService == nil {
-		return nil, fmt.Errorf("AuthUserService is not initialized")
-	}
-
-	user, err := r.AuthUserService.GetUserByEmail(ctx, email)
+	collection, err := r.PostService.CreateBookmarkCollection(ctx, userID, name)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
-
-	return &model.User{
-		ID:       userID,
-		Username: user.UserName,
-		Email:    user.Email,
-	}, nil
+	return convertToModelBookmarkCollection(collection), nil
 }
 
-// GetPost is the resolver for the getPost field.
-func (r *queryResolver) GetPost(ctx context.Context, postID string) (*model.Post, error) {
-	post, err := r.PostService.GetPost(ctx, postID)
+// RenameBookmarkCollection is the resolver for the renameBookmarkCollection field.
+func (r *mutationResolver) RenameBookmarkCollection(ctx context.Context, collectionID string, name string) (*model.BookmarkCollection, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	return convertToModelPost(post), nil
+	collection, err := r.PostService.RenameBookmarkCollection(ctx, collectionID, userID, name)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelBookmarkCollection(collection), nil
 }
 
-// GetAllUserPosts is the resolver for the getAllUserPosts field.
-func (r *queryResolver) GetAllUserPosts(ctx context.Context, userID string) ([]*model.Post, error) {
-	panic(fmt.Errorf("not implemented: GetAllUserPosts - getAllUserPosts"))
+// DeleteBookmarkCollection is the resolver for the deleteBookmarkCollection field.
+func (r *mutationResolver) DeleteBookmarkCollection(ctx context.Context, collectionID string) (*model.PostResponse, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+
+	if err := r.PostService.DeleteBookmarkCollection(ctx, collectionID, userID); err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+	message := "collection deleted"
+	return &model.PostResponse{Success: true, Message: &message}, nil
 }
 
-// GetPostComments is the resolver for the getPostComments field.
-func (r *queryResolver) GetPostComments(ctx context.Context, postID string) ([]*model.Post, error) {
-	comments, err := r.PostService.GetPostComments(ctx, postID)
+// ReorderBookmarkCollections is the resolver for the reorderBookmarkCollections field.
+func (r *mutationResolver) ReorderBookmarkCollections(ctx context.Context, collectionIds []string) ([]*model.BookmarkCollection, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	modelComments := make([]*model.Post, len(comments))
-	for i, comment := range comments {
-		modelComments[i] = convertToModelPost(comment)
+	collections, err := r.PostService.ReorderBookmarkCollections(ctx, userID, collectionIds)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
 	}
-
-	return modelComments, nil
+	return convertToModelBookmarkCollections(collections), nil
 }
 
-// GetUserFeed is the resolver for the getUserFeed field.
-func (r *queryResolver) GetUserFeed(ctx context.Context, userID string) ([]*model.Post, error) {
-	posts, err := r.PostService.GetUserFeed(ctx, userID)
+// MoveBookmark is the resolver for the moveBookmark field.
+func (r *mutationResolver) MoveBookmark(ctx context.Context, postID string, collectionID string) (*model.Bookmark, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	modelPosts := make([]*model.Post, len(posts))
-	for i, post := range posts {
-		modelPosts[i] = convertToModelPost(post)
+	bookmark, err := r.PostService.MoveBookmark(ctx, postID, userID, collectionID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
 	}
-
-	return modelPosts, nil
+	return convertToModelBookmark(bookmark, r.PostService), nil
 }
 
-// GetUsersWhoLikedPost is the resolver for the getUsersWhoLikedPost field.
-func (r *queryResolver) GetUsersWhoLikedPost(ctx context.Context, postID string) ([]string, error) {
-	users, err := r.PostService.GetUsersWhoLikedPost(ctx, postID)
+// SetBookmarkNote is the resolver for the setBookmarkNote field.
+func (r *mutationResolver) SetBookmarkNote(ctx context.Context, postID string, note *string) (*model.Bookmark, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	return users, nil
+	bookmark, err := r.PostService.SetBookmarkNote(ctx, postID, userID, note)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelBookmark(bookmark, r.PostService), nil
 }
 
-// SearchPosts is the resolver for the searchPosts field.
-func (r *queryResolver) SearchPosts(ctx context.Context, query string) ([]*model.Post, error) {
-	posts, err := r.PostService.SearchPosts(ctx, query)
+// QuotePost is the resolver for the quotePost field.
+func (r *mutationResolver) QuotePost(ctx context.Context, postID string, input model.CreatePostInput) (*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	modelPosts := make([]*model.Post, len(posts))
-	for i, post := range posts {
-		modelPosts[i] = convertToModelPost(post)
+	quoteInput := posts.CreatePostInput{
+		Title:       input.Title,
+		Content:     input.Content,
+		ImageURL:    input.ImageURL,
+		AudioURL:    input.AudioURL,
+		Poll:        convertToPollInput(input.Poll),
+		Attachments: convertToAttachmentInputs(input.Attachments),
+		Audience:    convertToAudience(input.Audience),
+		Labels:      convertToContentLabels(input.Labels),
 	}
 
-	return modelPosts, nil
+	quote, err := r.PostService.QuotePost(ctx, postID, userID, quoteInput)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	r.PostService.HandleNullablePostFields(quote)
+
+	return convertToModelPost(quote), nil
 }
 
-// GetTrendingPosts is the resolver for the getTrendingPosts field.
-func (r *queryResolver) GetTrendingPosts(ctx context.Context, limit int) ([]*model.Post, error) {
-	posts, err := r.PostService.GetTrendingPosts(ctx, limit)
+// VotePoll is the resolver for the votePoll field.
+func (r *mutationResolver) VotePoll(ctx context.Context, postID string, optionIds []string) (*model.Poll, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	modelPosts := make([]*model.Post, len(posts))
-	for i, post := range posts {
-		modelPosts[i] = convertToModelPost(post)
+	poll, err := r.PostService.VotePoll(ctx, postID, userID, optionIds)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
 	}
-
-	return modelPosts, nil
+	return convertToModelPoll(poll), nil
 }
 
-// GetPostsByTag is the resolver for the getPostsByTag field.
-func (r *queryResolver) GetPostsByTag(ctx context.Context, tag string) ([]*model.Post, error) {
-	posts, err := r.PostService.GetPostsByTag(ctx, tag)
+// ChangePollVote is the resolver for the changePollVote field.
+func (r *mutationResolver) ChangePollVote(ctx context.Context, postID string, optionIds []string) (*model.Poll, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	modelPosts := make([]*model.Post, len(posts))
-	for i, post := range posts {
-		modelPosts[i] = convertToModelPost(post)
+	poll, err := r.PostService.ChangePollVote(ctx, postID, userID, optionIds)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
 	}
-
-	return modelPosts, nil
+	return convertToModelPoll(poll), nil
 }
 
-// GetUserBookmarkedPosts is the resolver for the getUserBookmarkedPosts field.
-func (r *queryResolver) GetUserBookmarkedPosts(ctx context.Context, userID string) ([]*model.Post, error) {
-	posts, err := r.PostService.GetUserBookmarkedPosts(ctx, userID)
+// AutosaveDraft is the resolver for the autosaveDraft field.
+func (r *mutationResolver) AutosaveDraft(ctx context.Context, postID string, input model.CreatePostInput) (*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	modelPosts := make([]*model.Post, len(posts))
-	for i, post := range posts {
-		modelPosts[i] = convertToModelPost(post)
+	draftInput := posts.CreatePostInput{
+		Title:     input.Title,
+		Content:   input.Content,
+		ImageURL:  input.ImageURL,
+		AudioURL:  input.AudioURL,
+		PublishAt: input.PublishAt,
+		Audience:  convertToAudience(input.Audience),
+		Labels:    convertToContentLabels(input.Labels),
 	}
 
-	return modelPosts, nil
+	draft, err := r.PostService.AutosaveDraft(ctx, postID, userID, draftInput)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	r.PostService.HandleNullablePostFields(draft)
+
+	return convertToModelPost(draft), nil
 }
 
-// GetDrafts is the resolver for the getDrafts field.
-func (r *queryResolver) GetDrafts(ctx context.Context, userID string) ([]*model.Post, error) {
-	drafts, err := r.PostService.GetDrafts(ctx, userID)
+// PublishDraft is the resolver for the publishDraft field.
+func (r *mutationResolver) PublishDraft(ctx context.Context, postID string) (*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	modelDrafts := make([]*model.Post, len(drafts))
-	for i, draft := range drafts {
-		modelDrafts[i] = convertToModelPost(draft)
+	post, err := r.PostService.PublishDraft(ctx, postID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
 	}
+	r.PostService.HandleNullablePostFields(post)
 
-	return modelDrafts, nil
+	return convertToModelPost(post), nil
 }
 
-// GetPostAnalytics is the resolver for the getPostAnalytics field.
-func (r *queryResolver) GetPostAnalytics(ctx context.Context, postID string) (*model.PostAnalytics, error) {
-	analytics, err := r.PostService.GetPostAnalytics(ctx, postID)
+// SetContentFilter is the resolver for the setContentFilter field.
+func (r *mutationResolver) SetContentFilter(ctx context.Context, filter model.ContentFilter) (model.ContentFilter, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
-		return nil, buildBadRequestError(ctx, err)
+		return "", buildBadRequestError(ctx, err)
 	}
-	postanalytics := &model.PostAnalytics{
-		Views:         analytics.Views,
-		Reach:         analytics.Reach,
-		CommentsCount: analytics.CommentsCount,
-		Shares:        analytics.Shares,
+
+	updated, err := r.PostService.SetContentFilter(ctx, userID, posts.ContentFilter(filter))
+	if err != nil {
+		return "", buildBadRequestError(ctx, err)
 	}
-	return postanalytics, nil
+	return model.ContentFilter(updated), nil
 }
 
-// GetUserPostStats is the resolver for the getUserPostStats field.
-func (r *queryResolver) GetUserPostStats(ctx context.Context, userID string) (*model.UserPostStats, error) {
-	stats, err := r.PostService.GetUserPostStats(ctx, userID)
+// LabelPost is the resolver for the labelPost field.
+func (r *mutationResolver) LabelPost(ctx context.Context, postID string, labels model.ContentLabelsInput) (*model.PostLabels, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, buildBadRequestError(ctx, err)
 	}
 
-	postanalytics := &model.UserPostStats{
-		TotalPosts:   stats.TotalPosts,
-		TotalLikes:   stats.TotalLikes,
-		TotalReposts: stats.TotalReposts,
+	updated, err := r.PostService.LabelPost(ctx, postID, userID, *convertToContentLabels(&labels))
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
 	}
-	return postanalytics, nil
+	return convertToModelPostLabels(updated), nil
 }
 
-// Query returns graph.QueryResolver implementation.
-func (r *Resolver) Query() graph.QueryResolver { return &queryResolver{r} }
+// AddCloseFriend is the resolver for the addCloseFriend field.
+func (r *mutationResolver) AddCloseFriend(ctx context.Context, userID string) (*model.PostResponse, error) {
+	currentUserID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+
+	if err := r.PostService.AddCloseFriend(ctx, currentUserID, userID); err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+	message := "added to close friends"
+	return &model.PostResponse{Success: true, Message: &message}, nil
+}
+
+// RemoveCloseFriend is the resolver for the removeCloseFriend field.
+func (r *mutationResolver) RemoveCloseFriend(ctx context.Context, userID string) (*model.PostResponse, error) {
+	currentUserID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+
+	if err := r.PostService.RemoveCloseFriend(ctx, currentUserID, userID); err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+	message := "removed from close friends"
+	return &model.PostResponse{Success: true, Message: &message}, nil
+}
+
+// CreateList is the resolver for the createList field.
+func (r *mutationResolver) CreateList(ctx context.Context, input model.UserListInput) (*model.UserList, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	list, err := r.PostService.CreateList(ctx, userID, convertToUserListInput(input))
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelUserList(list), nil
+}
+
+// UpdateList is the resolver for the updateList field.
+func (r *mutationResolver) UpdateList(ctx context.Context, listID string, input model.UserListInput) (*model.UserList, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	list, err := r.PostService.UpdateList(ctx, listID, userID, convertToUserListInput(input))
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelUserList(list), nil
+}
+
+// DeleteList is the resolver for the deleteList field.
+func (r *mutationResolver) DeleteList(ctx context.Context, listID string) (*model.PostResponse, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+
+	if err := r.PostService.DeleteList(ctx, listID, userID); err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+	message := "list deleted"
+	return &model.PostResponse{Success: true, Message: &message}, nil
+}
+
+// AddListMember is the resolver for the addListMember field.
+func (r *mutationResolver) AddListMember(ctx context.Context, listID string, userID string) (*model.UserList, error) {
+	currentUserID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	list, err := r.PostService.AddListMember(ctx, listID, currentUserID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelUserList(list), nil
+}
+
+// RemoveListMember is the resolver for the removeListMember field.
+func (r *mutationResolver) RemoveListMember(ctx context.Context, listID string, userID string) (*model.UserList, error) {
+	currentUserID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	list, err := r.PostService.RemoveListMember(ctx, listID, currentUserID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelUserList(list), nil
+}
+
+// FollowList is the resolver for the followList field.
+func (r *mutationResolver) FollowList(ctx context.Context, listID string) (*model.UserList, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	list, err := r.PostService.FollowList(ctx, listID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelUserList(list), nil
+}
+
+// UnfollowList is the resolver for the unfollowList field.
+func (r *mutationResolver) UnfollowList(ctx context.Context, listID string) (*model.PostResponse, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+
+	if err := r.PostService.UnfollowList(ctx, listID, userID); err != nil {
+		return &model.PostResponse{Success: false}, buildBadRequestError(ctx, err)
+	}
+	message := "list unfollowed"
+	return &model.PostResponse{Success: true, Message: &message}, nil
+}
+
+// Attachments is the resolver for the attachments field.
+func (r *postResolver) Attachments(ctx context.Context, obj *model.Post) ([]*model.Attachment, error) {
+	// deleted posts are placeholders, their media is hidden with the rest of the content
+	if obj.IsDeleted {
+		return []*model.Attachment{}, nil
+	}
+	attachments, err := r.PostService.GetPostAttachments(ctx, obj.ID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelAttachments := make([]*model.Attachment, len(attachments))
+	for i, a := range attachments {
+		modelAttachments[i] = &model.Attachment{
+			MediaID:      a.MediaID,
+			Kind:         model.MediaKind(a.Kind),
+			MimeType:     a.MimeType,
+			URL:          a.URL,
+			ThumbnailURL: a.ThumbnailURL,
+			Width:        a.Width,
+			Height:       a.Height,
+			Blurhash:     a.Blurhash,
+			AltText:      a.AltText,
+			Position:     a.Position,
+		}
+	}
+
+	return modelAttachments, nil
+}
+
+// ReactionCounts is the resolver for the reactionCounts field.
+func (r *postResolver) ReactionCounts(ctx context.Context, obj *model.Post) ([]*model.ReactionCount, error) {
+	counts, err := r.PostService.GetReactionCounts(ctx, obj.ID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	result := make([]*model.ReactionCount, len(counts))
+	for i, count := range counts {
+		result[i] = &model.ReactionCount{Kind: model.ReactionKind(count.Kind), Count: count.Count}
+	}
+	return result, nil
+}
+
+// ViewerReaction is the resolver for the viewerReaction field.
+func (r *postResolver) ViewerReaction(ctx context.Context, obj *model.Post) (*model.ReactionKind, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+
+	kind, err := r.PostService.GetViewerReaction(ctx, obj.ID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	if kind == nil {
+		return nil, nil
+	}
+	reaction := model.ReactionKind(*kind)
+	return &reaction, nil
+}
+
+// Entities is the resolver for the entities field.
+func (r *postResolver) Entities(ctx context.Context, obj *model.Post) ([]*model.PostEntity, error) {
+	// deleted posts kept as placeholders don't show what they said
+	if obj.IsDeleted {
+		return []*model.PostEntity{}, nil
+	}
+	entities, err := r.PostService.GetPostEntities(ctx, obj.ID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelEntities := make([]*model.PostEntity, len(entities))
+	for i, entity := range entities {
+		modelEntities[i] = &model.PostEntity{
+			Type:   model.PostEntityType(entity.Type),
+			Text:   entity.Text,
+			Start:  entity.Start,
+			End:    entity.End,
+			UserID: entity.UserID,
+		}
+	}
+
+	return modelEntities, nil
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post) ([]*model.PostRevision, error) {
+	if obj.IsDeleted {
+		return []*model.PostRevision{}, nil
+	}
+	revisions, err := r.PostService.GetPostRevisions(ctx, obj.ID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelRevisions := make([]*model.PostRevision, len(revisions))
+	for i, revision := range revisions {
+		modelRevisions[i] = &model.PostRevision{
+			ID:        revision.ID,
+			PostID:    revision.PostID,
+			EditorID:  revision.EditorID,
+			Title:     revision.Title,
+			Content:   revision.Content,
+			ImageURL:  revision.ImageURL,
+			AudioURL:  revision.AudioURL,
+			CreatedAt: revision.CreatedAt,
+		}
+	}
+	return modelRevisions, nil
+}
+
+// QuotedPost is the resolver for the quotedPost field.
+func (r *postResolver) QuotedPost(ctx context.Context, obj *model.Post) (*model.Post, error) {
+	// anonymous viewers only see quotes of public authors
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+
+	quoted, err := r.PostService.GetQuotedPost(ctx, obj.ID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	r.PostService.HandleNullablePostFields(quoted)
+
+	return convertToModelPost(quoted), nil
+}
+
+// QuoteCount is the resolver for the quoteCount field.
+func (r *postResolver) QuoteCount(ctx context.Context, obj *model.Post) (int, error) {
+	count, err := r.PostService.GetQuoteCount(ctx, obj.ID)
+	if err != nil {
+		return 0, buildBadRequestError(ctx, err)
+	}
+	return count, nil
+}
+
+// RepostOf is the resolver for the repostOf field.
+func (r *postResolver) RepostOf(ctx context.Context, obj *model.Post) (*model.Post, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+
+	original, err := r.PostService.GetRepostedPost(ctx, obj.ID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	r.PostService.HandleNullablePostFields(original)
+
+	return convertToModelPost(original), nil
+}
+
+// Labels is the resolver for the labels field.
+func (r *postResolver) Labels(ctx context.Context, obj *model.Post) (*model.PostLabels, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+
+	labels, err := r.PostService.GetPostLabels(ctx, obj.ID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelPostLabels(labels), nil
+}
+
+// Poll is the resolver for the poll field.
+func (r *postResolver) Poll(ctx context.Context, obj *model.Post) (*model.Poll, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+
+	poll, err := r.PostService.GetPoll(ctx, obj.ID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelPoll(poll), nil
+}
+
+// GetUserByEmail is the resolver for the getUserByEmail field.
+func (r *queryResolver) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	if r.AuthUserService == nil {
+		return nil, fmt.Errorf("AuthUserService is not initialized")
+	}
+
+	user, err := r.AuthUserService.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	return &model.User{
+		ID:       userID,
+		Username: user.UserName,
+		Email:    user.Email,
+	}, nil
+}
+
+// GetPost is the resolver for the getPost field.
+func (r *queryResolver) GetPost(ctx context.Context, postID string) (*model.Post, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	post, err := r.PostService.GetPost(ctx, postID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	r.PostService.RecordViews(ctx, []string{postID}, viewerID)
+
+	return convertToModelPost(post), nil
+}
+
+// GetAllUserPosts is the resolver for the getAllUserPosts field.
+func (r *queryResolver) GetAllUserPosts(ctx context.Context, userID string) ([]*model.Post, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	userPosts, err := r.PostService.GetAllUserPosts(ctx, userID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelPosts := make([]*model.Post, len(userPosts))
+	for i, post := range userPosts {
+		r.PostService.HandleNullablePostFields(post)
+		modelPosts[i] = convertToModelPost(post)
+	}
+
+	return modelPosts, nil
+}
+
+// GetPostComments is the resolver for the getPostComments field.
+func (r *queryResolver) GetPostComments(ctx context.Context, postID string) ([]*model.Post, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	comments, err := r.PostService.GetPostComments(ctx, postID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelComments := make([]*model.Post, len(comments))
+	for i, comment := range comments {
+		modelComments[i] = convertToModelPost(comment)
+	}
+
+	return modelComments, nil
+}
+
+// Thread is the resolver for the thread field.
+func (r *queryResolver) Thread(ctx context.Context, postID string, depth *int, first *int, after *string) (*model.ThreadNode, error) {
+	d, f := -1, -1
+	if depth != nil {
+		d = *depth
+	}
+	if first != nil {
+		f = *first
+	}
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	thread, err := r.PostService.GetThread(ctx, postID, viewerID, d, f, after)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelThread(thread, r.PostService), nil
+}
+
+// GetUserFeed is the resolver for the getUserFeed field.
+func (r *queryResolver) GetUserFeed(ctx context.Context, userID string) ([]*model.Post, error) {
+	if err := requireSignedInUser(ctx, userID); err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	posts, err := r.PostService.GetUserFeed(ctx, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelPosts := make([]*model.Post, len(posts))
+	for i, post := range posts {
+		modelPosts[i] = convertToModelPost(post)
+	}
+
+	return modelPosts, nil
+}
+
+// GetUsersWhoLikedPost is the resolver for the getUsersWhoLikedPost field.
+func (r *queryResolver) GetUsersWhoLikedPost(ctx context.Context, postID string) ([]string, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	users, err := r.PostService.GetUsersWhoLikedPost(ctx, postID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	return users, nil
+}
+
+// SearchPosts is the resolver for the searchPosts field.
+func (r *queryResolver) SearchPosts(ctx context.Context, query string, filter *model.PostSearchFilter, first *int, after *string) (*model.PostSearchPage, error) {
+	f := -1
+	if first != nil {
+		f = *first
+	}
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	page, err := r.PostService.SearchPosts(ctx, query, convertToPostSearchFilter(filter), viewerID, f, after)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	results := make([]*model.PostSearchResult, len(page.Results))
+	for i, result := range page.Results {
+		r.PostService.HandleNullablePostFields(result.Post)
+		results[i] = &model.PostSearchResult{
+			Post:           convertToModelPost(result.Post),
+			Rank:           result.Rank,
+			TitleHighlight: result.TitleHighlight,
+			Snippet:        result.Snippet,
+		}
+	}
+	return &model.PostSearchPage{
+		Results:    results,
+		HasMore:    page.HasMore,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// GetTrendingPosts is the resolver for the getTrendingPosts field.
+func (r *queryResolver) GetTrendingPosts(ctx context.Context, limit int, window *model.TrendingWindow) ([]*model.Post, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	posts, err := r.PostService.GetTrendingPosts(ctx, convertToTrendingWindow(window), limit, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelPosts := make([]*model.Post, len(posts))
+	for i, post := range posts {
+		modelPosts[i] = convertToModelPost(post)
+	}
+
+	return modelPosts, nil
+}
+
+// TrendingTags is the resolver for the trendingTags field.
+func (r *queryResolver) TrendingTags(ctx context.Context, window *model.TrendingWindow, limit *int) ([]*model.TrendingTag, error) {
+	l := 10 // default limit
+	if limit != nil {
+		l = *limit
+	}
+
+	tags, err := r.PostService.GetTrendingTags(ctx, convertToTrendingWindow(window), l)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelTags := make([]*model.TrendingTag, len(tags))
+	for i, tag := range tags {
+		modelTags[i] = &model.TrendingTag{
+			Name:      tag.Name,
+			Score:     tag.Score,
+			PostCount: tag.PostCount,
+		}
+	}
+
+	return modelTags, nil
+}
+
+// GetPostsByTag is the resolver for the getPostsByTag field.
+func (r *queryResolver) GetPostsByTag(ctx context.Context, tag string) ([]*model.Post, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	posts, err := r.PostService.GetPostsByTag(ctx, tag, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelPosts := make([]*model.Post, len(posts))
+	for i, post := range posts {
+		modelPosts[i] = convertToModelPost(post)
+	}
+
+	return modelPosts, nil
+}
+
+// GetUserBookmarkedPosts is the resolver for the getUserBookmarkedPosts field.
+func (r *queryResolver) GetUserBookmarkedPosts(ctx context.Context, userID string) ([]*model.Post, error) {
+	if err := requireSignedInUser(ctx, userID); err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	posts, err := r.PostService.GetUserBookmarkedPosts(ctx, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelPosts := make([]*model.Post, len(posts))
+	for i, post := range posts {
+		modelPosts[i] = convertToModelPost(post)
+	}
+
+	return modelPosts, nil
+}
+
+// GetDrafts is the resolver for the getDrafts field.
+func (r *queryResolver) GetDrafts(ctx context.Context, userID string) ([]*model.Post, error) {
+	if err := requireSignedInUser(ctx, userID); err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	drafts, err := r.PostService.GetDrafts(ctx, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelDrafts := make([]*model.Post, len(drafts))
+	for i, draft := range drafts {
+		modelDrafts[i] = convertToModelPost(draft)
+	}
+
+	return modelDrafts, nil
+}
+
+// GetPostAnalytics is the resolver for the getPostAnalytics field.
+func (r *queryResolver) GetPostAnalytics(ctx context.Context, postID string) (*model.PostAnalytics, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	analytics, err := r.PostService.GetPostAnalytics(ctx, postID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	postanalytics := &model.PostAnalytics{
+		Views:          analytics.Views,
+		Reach:          analytics.Reach,
+		CommentsCount:  analytics.CommentsCount,
+		Shares:         analytics.Shares,
+		EngagementRate: analytics.EngagementRate,
+	}
+	return postanalytics, nil
+}
+
+// GetUserPostStats is the resolver for the getUserPostStats field.
+func (r *queryResolver) GetUserPostStats(ctx context.Context, userID string) (*model.UserPostStats, error) {
+	stats, err := r.PostService.GetUserPostStats(ctx, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	postanalytics := &model.UserPostStats{
+		TotalPosts:   stats.TotalPosts,
+		TotalLikes:   stats.TotalLikes,
+		TotalReposts: stats.TotalReposts,
+	}
+	return postanalytics, nil
+}
+
+// CreatorAnalytics is the resolver for the creatorAnalytics field.
+func (r *queryResolver) CreatorAnalytics(ctx context.Context, from time.Time, to time.Time, granularity *model.AnalyticsGranularity) (*model.CreatorAnalytics, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	var g posts.AnalyticsGranularity
+	if granularity != nil {
+		g = posts.AnalyticsGranularity(*granularity)
+	}
+	analytics, err := r.PostService.GetCreatorAnalytics(ctx, userID, from, to, g)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelCreatorAnalytics(analytics, r.PostService), nil
+}
+
+// ContentFilter is the resolver for the contentFilter field.
+func (r *queryResolver) ContentFilter(ctx context.Context) (model.ContentFilter, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return "", buildBadRequestError(ctx, err)
+	}
+
+	filter, err := r.PostService.GetContentFilter(ctx, userID)
+	if err != nil {
+		return "", buildBadRequestError(ctx, err)
+	}
+	return model.ContentFilter(filter), nil
+}
+
+// PostLabelHistory is the resolver for the postLabelHistory field.
+func (r *queryResolver) PostLabelHistory(ctx context.Context, postID string) ([]*model.PostLabelChange, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	changes, err := r.PostService.GetLabelHistory(ctx, postID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	result := make([]*model.PostLabelChange, len(changes))
+	for i, change := range changes {
+		result[i] = &model.PostLabelChange{
+			ID:          change.ID,
+			PostID:      change.PostID,
+			ActorID:     change.ActorID,
+			ByModerator: change.ByModerator,
+			Before:      convertToModelContentLabels(change.Before),
+			After:       convertToModelContentLabels(change.After),
+			CreatedAt:   change.CreatedAt,
+		}
+	}
+	return result, nil
+}
+
+// CloseFriends is the resolver for the closeFriends field.
+func (r *queryResolver) CloseFriends(ctx context.Context) ([]string, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	friendIDs, err := r.PostService.GetCloseFriends(ctx, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return friendIDs, nil
+}
+
+// DeletedPosts is the resolver for the deletedPosts field.
+func (r *queryResolver) DeletedPosts(ctx context.Context) ([]*model.Post, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	deleted, err := r.PostService.GetDeletedPosts(ctx, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelPosts := make([]*model.Post, len(deleted))
+	for i, post := range deleted {
+		r.PostService.HandleNullablePostFields(post)
+		modelPosts[i] = convertToModelPost(post)
+	}
+	return modelPosts, nil
+}
+
+// BookmarkCollections is the resolver for the bookmarkCollections field.
+func (r *queryResolver) BookmarkCollections(ctx context.Context) ([]*model.BookmarkCollection, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	collections, err := r.PostService.GetBookmarkCollections(ctx, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelBookmarkCollections(collections), nil
+}
+
+// BookmarkCollection is the resolver for the bookmarkCollection field.
+func (r *queryResolver) BookmarkCollection(ctx context.Context, collectionID string, first *int, after *string) (*model.BookmarkPage, error) {
+	f := -1
+	if first != nil {
+		f = *first
+	}
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	page, err := r.PostService.GetBookmarkCollectionPage(ctx, collectionID, userID, f, after)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	bookmarks := make([]*model.Bookmark, len(page.Bookmarks))
+	for i, bookmark := range page.Bookmarks {
+		bookmarks[i] = convertToModelBookmark(bookmark, r.PostService)
+	}
+	return &model.BookmarkPage{
+		Bookmarks:  bookmarks,
+		HasMore:    page.HasMore,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// List is the resolver for the list field.
+func (r *queryResolver) List(ctx context.Context, listID string) (*model.UserList, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	list, err := r.PostService.GetList(ctx, listID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelUserList(list), nil
+}
+
+// UserLists is the resolver for the userLists field.
+func (r *queryResolver) UserLists(ctx context.Context, userID string) ([]*model.UserList, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	lists, err := r.PostService.GetUserLists(ctx, userID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelUserLists(lists), nil
+}
+
+// FollowedLists is the resolver for the followedLists field.
+func (r *queryResolver) FollowedLists(ctx context.Context) ([]*model.UserList, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	lists, err := r.PostService.GetFollowedLists(ctx, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelUserLists(lists), nil
+}
+
+// ListMembers is the resolver for the listMembers field.
+func (r *queryResolver) ListMembers(ctx context.Context, listID string) ([]string, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	memberIDs, err := r.PostService.GetListMembers(ctx, listID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return memberIDs, nil
+}
+
+// ListTimeline is the resolver for the listTimeline field.
+func (r *queryResolver) ListTimeline(ctx context.Context, listID string, first *int, after *string) (*model.PostPage, error) {
+	f := -1
+	if first != nil {
+		f = *first
+	}
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+
+	page, err := r.PostService.GetListTimeline(ctx, listID, viewerID, f, after)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelPostPage(page, r.PostService), nil
+}
+
+// RepostedBy is the resolver for the repostedBy field.
+func (r *queryResolver) RepostedBy(ctx context.Context, postID string, first *int, after *string) (*model.ReposterPage, error) {
+	f := -1
+	if first != nil {
+		f = *first
+	}
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+
+	page, err := r.PostService.GetReposters(ctx, postID, viewerID, f, after)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	reposters := make([]*model.Reposter, len(page.Reposters))
+	for i, reposter := range page.Reposters {
+		reposters[i] = &model.Reposter{UserID: reposter.UserID, RepostID: reposter.RepostID, RepostedAt: reposter.RepostedAt}
+	}
+	return &model.ReposterPage{
+		Reposters:  reposters,
+		HasMore:    page.HasMore,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// Reactions is the resolver for the reactions field.
+func (r *queryResolver) Reactions(ctx context.Context, postID string, kind *model.ReactionKind, first *int, after *string) (*model.ReactionPage, error) {
+	f := -1
+	if first != nil {
+		f = *first
+	}
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+
+	var reactionKind *posts.ReactionKind
+	if kind != nil {
+		k := posts.ReactionKind(*kind)
+		reactionKind = &k
+	}
+
+	page, err := r.PostService.GetReactions(ctx, postID, viewerID, reactionKind, f, after)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	reactions := make([]*model.Reaction, len(page.Reactions))
+	for i, reaction := range page.Reactions {
+		reactions[i] = &model.Reaction{UserID: reaction.UserID, Kind: model.ReactionKind(reaction.Kind), ReactedAt: reaction.CreatedAt}
+	}
+	return &model.ReactionPage{
+		Reactions:  reactions,
+		HasMore:    page.HasMore,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// PinnedPosts is the resolver for the pinnedPosts field.
+func (r *userResolver) PinnedPosts(ctx context.Context, obj *model.User) ([]*model.Post, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	pinned, err := r.PostService.GetPinnedPosts(ctx, obj.ID, viewerID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelPosts := make([]*model.Post, len(pinned))
+	for i, post := range pinned {
+		r.PostService.HandleNullablePostFields(post)
+		modelPosts[i] = convertToModelPost(post)
+	}
+
+	return modelPosts, nil
+}
+
+// Post returns graph.PostResolver implementation.
+func (r *Resolver) Post() graph.PostResolver { return &postResolver{r} }
+
+// Query returns graph.QueryResolver implementation.
+func (r *Resolver) Query() graph.QueryResolver { return &queryResolver{r} }
+
+// User returns graph.UserResolver implementation.
+func (r *Resolver) User() graph.UserResolver { return &userResolver{r} }
 
+type postResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
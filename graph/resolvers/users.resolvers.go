@@ -10,6 +10,7 @@ import (
 
 	"github.com/bertoxic/graphqlChat/graph"
 	"github.com/bertoxic/graphqlChat/graph/model"
+	"github.com/bertoxic/graphqlChat/internal/middlewares"
 	"github.com/bertoxic/graphqlChat/internal/models"
 )
 
@@ -52,8 +53,10 @@ func (r *mutationResolver) UpdateUser(ctx context.Context, input model.UpdateUse
 
 // FollowUser is the resolver for the followUser field.
 func (r *mutationResolver) FollowUser(ctx context.Context, userID string) (*model.UserResponse, error) {
-	// Assuming we can get the current user's ID from the context
-	currentUserID := getCurrentUserID(ctx)
+	currentUserID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
 
 	userResponse, err := r.UserService.FollowUser(ctx, currentUserID, userID)
 	if err != nil {
@@ -69,8 +72,10 @@ func (r *mutationResolver) FollowUser(ctx context.Context, userID string) (*mode
 
 // UnfollowUser is the resolver for the unfollowUser field.
 func (r *mutationResolver) UnfollowUser(ctx context.Context, userID string) (*model.UserResponse, error) {
-	// Assuming we can get the current user's ID from the context
-	currentUserID := getCurrentUserID(ctx)
+	currentUserID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
 
 	userResponse, err := r.UserService.UnfollowUser(ctx, currentUserID, userID)
 	if err != nil {
@@ -84,6 +89,42 @@ func (r *mutationResolver) UnfollowUser(ctx context.Context, userID string) (*mo
 	}, nil
 }
 
+// ApproveFollowRequest is the resolver for the approveFollowRequest field.
+func (r *mutationResolver) ApproveFollowRequest(ctx context.Context, userID string) (*model.UserResponse, error) {
+	currentUserID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	userResponse, err := r.UserService.ApproveFollowRequest(ctx, currentUserID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	return &model.UserResponse{
+		Success: userResponse.Success,
+		Message: &userResponse.Message,
+	}, nil
+}
+
+// DeclineFollowRequest is the resolver for the declineFollowRequest field.
+func (r *mutationResolver) DeclineFollowRequest(ctx context.Context, userID string) (*model.UserResponse, error) {
+	currentUserID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	userResponse, err := r.UserService.DeclineFollowRequest(ctx, currentUserID, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	return &model.UserResponse{
+		Success: userResponse.Success,
+		Message: &userResponse.Message,
+	}, nil
+}
+
 // BlockUser is the resolver for the blockUser field.
 func (r *mutationResolver) BlockUser(ctx context.Context, userID string) (*model.UserResponse, error) {
 	panic(fmt.Errorf("not implemented: BlockUser - blockUser"))
@@ -235,52 +276,56 @@ func (r *queryResolver) GetUserFollowing(ctx context.Context, userID string, lim
 	return result, nil
 }
 
-// SearchUsers is the resolver for the searchUsers field.
-func (r *queryResolver) SearchUsers(ctx context.Context, query string, limit *int) ([]*model.User, error) {
+// FollowRequests is the resolver for the followRequests field.
+func (r *queryResolver) FollowRequests(ctx context.Context, limit *int, offset *int) ([]*model.User, error) {
+	currentUserID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
 	l := 10 // default limit
+	o := 0  // default offset
 	if limit != nil {
 		l = *limit
 	}
+	if offset != nil {
+		o = *offset
+	}
 
-	users, err := r.UserService.SearchUsers(ctx, query, l)
+	requests, err := r.UserService.GetFollowRequests(ctx, currentUserID, l, o)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search users: %w", err)
+		return nil, buildBadRequestError(ctx, err)
 	}
 
-	if len(users) == 0 {
-		return []*model.User{}, nil
+	result := make([]*model.User, 0, len(requests))
+	for _, requester := range requests {
+		result = append(result, &model.User{
+			ID:       requester.ID,
+			Username: requester.UserName,
+			Email:    requester.Email,
+		})
 	}
 
-	result := make([]*model.User, 0, len(users))
-
-	for _, user := range users {
-		if user == nil {
-			continue
-		}
+	return result, nil
+}
 
-		var formattedDOB *string
-		if user.DateOfBirth != nil {
-			formatted := user.DateOfBirth.Format("2006-01-02 15:04:05")
-			formattedDOB = &formatted
-		}
+// SearchUsers is the resolver for the searchUsers field.
+func (r *queryResolver) SearchUsers(ctx context.Context, query string, first *int, after *string) (*model.UserSearchPage, error) {
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	f := 0
+	if first != nil {
+		f = *first
+	}
 
-		result = append(result, &model.User{
-			ID:                user.ID,
-			Username:          user.UserName,
-			Email:             user.Email,
-			FullName:          user.FullName,
-			Bio:               user.Bio,
-			DateOfBirth:       formattedDOB,
-			ProfilePictureURL: user.ProfilePictureURL,
-			CoverPictureURL:   user.CoverPictureURL,
-			Location:          user.Location,
-			Website:           user.Website,
-			IsPrivate:         user.IsPrivate,
-			CreatedAt:         user.CreatedAt,
-			UpdatedAt:         user.UpdatedAt,
-		})
+	page, err := r.UserService.SearchUsers(ctx, query, viewerID, f, after)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
 	}
 
+	result := &model.UserSearchPage{Users: []*model.User{}, HasMore: page.HasMore, NextCursor: page.NextCursor}
+	for _, user := range page.Users {
+		result.Users = append(result.Users, convertToModelSearchUser(user))
+	}
 	return result, nil
 }
 
@@ -305,7 +350,17 @@ func (r *queryResolver) GetUserStats(ctx context.Context, userID string) (*model
 
 // SearchAll is the resolver for the searchAll field.
 func (r *queryResolver) SearchAll(ctx context.Context, query string, limit *int) (*model.SearchResult, error) {
-	panic(fmt.Errorf("not implemented: SearchAll - searchAll"))
+	viewerID, _ := middlewares.GetUserIDFromContext(ctx)
+	l := 0
+	if limit != nil {
+		l = *limit
+	}
+
+	result, err := r.SearchService.SearchAll(ctx, query, viewerID, l)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelSearchResult(result, r.PostService), nil
 }
 
 // GetSuggestedUsers is the resolver for the getSuggestedUsers field.
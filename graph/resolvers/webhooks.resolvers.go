@@ -0,0 +1,128 @@
+package resolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.55
+
+import (
+	"context"
+
+	"github.com/bertoxic/graphqlChat/graph/model"
+	"github.com/bertoxic/graphqlChat/internal/middlewares"
+	"github.com/bertoxic/graphqlChat/internal/webhooks"
+)
+
+// CreateWebhook is the resolver for the createWebhook field.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, input model.CreateWebhookInput) (*model.WebhookSubscription, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	subscription, err := r.WebhookService.CreateSubscription(ctx, userID, webhooks.SubscriptionInput{
+		URL:        input.URL,
+		EventTypes: convertToWebhookEventTypes(input.EventTypes),
+		Secret:     input.Secret,
+	})
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelWebhookSubscription(subscription), nil
+}
+
+// UpdateWebhook is the resolver for the updateWebhook field.
+func (r *mutationResolver) UpdateWebhook(ctx context.Context, id string, input model.UpdateWebhookInput) (*model.WebhookSubscription, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	subscription, err := r.WebhookService.UpdateSubscription(ctx, userID, id, webhooks.UpdateSubscriptionInput{
+		URL:        input.URL,
+		EventTypes: convertToWebhookEventTypes(input.EventTypes),
+		Secret:     input.Secret,
+		IsActive:   input.IsActive,
+	})
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelWebhookSubscription(subscription), nil
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook field.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return false, buildBadRequestError(ctx, err)
+	}
+
+	if err := r.WebhookService.DeleteSubscription(ctx, userID, id); err != nil {
+		return false, buildBadRequestError(ctx, err)
+	}
+	return true, nil
+}
+
+// RedeliverWebhook is the resolver for the redeliverWebhook field.
+func (r *mutationResolver) RedeliverWebhook(ctx context.Context, deliveryID string) (*model.WebhookDelivery, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	delivery, err := r.WebhookService.Redeliver(ctx, userID, deliveryID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	return convertToModelWebhookDelivery(delivery), nil
+}
+
+// Webhooks is the resolver for the webhooks field.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	subscriptions, err := r.WebhookService.GetSubscriptions(ctx, userID)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelSubscriptions := make([]*model.WebhookSubscription, len(subscriptions))
+	for i, subscription := range subscriptions {
+		modelSubscriptions[i] = convertToModelWebhookSubscription(subscription)
+	}
+	return modelSubscriptions, nil
+}
+
+// WebhookDeliveries is the resolver for the webhookDeliveries field.
+func (r *queryResolver) WebhookDeliveries(ctx context.Context, subscriptionID string, status *model.WebhookDeliveryStatus, limit *int, offset *int) ([]*model.WebhookDelivery, error) {
+	userID, err := middlewares.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+	l, o := 0, 0
+	if limit != nil {
+		l = *limit
+	}
+	if offset != nil {
+		o = *offset
+	}
+
+	var deliveryStatus *webhooks.DeliveryStatus
+	if status != nil {
+		s := webhooks.DeliveryStatus(*status)
+		deliveryStatus = &s
+	}
+
+	deliveries, err := r.WebhookService.GetDeliveries(ctx, userID, subscriptionID, deliveryStatus, l, o)
+	if err != nil {
+		return nil, buildBadRequestError(ctx, err)
+	}
+
+	modelDeliveries := make([]*model.WebhookDelivery, len(deliveries))
+	for i, delivery := range deliveries {
+		modelDeliveries[i] = convertToModelWebhookDelivery(delivery)
+	}
+	return modelDeliveries, nil
+}
@@ -8,14 +8,20 @@ import (
 	"github.com/bertoxic/graphqlChat/internal/database/postgres"
 	"github.com/bertoxic/graphqlChat/internal/drivers"
 	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/federation"
 	"github.com/bertoxic/graphqlChat/internal/handlers"
 	"github.com/bertoxic/graphqlChat/internal/jwt"
+	"github.com/bertoxic/graphqlChat/internal/media"
+	"github.com/bertoxic/graphqlChat/internal/posts"
 	"github.com/bertoxic/graphqlChat/internal/render"
+	"github.com/bertoxic/graphqlChat/internal/search"
 	"github.com/bertoxic/graphqlChat/internal/user"
+	"github.com/bertoxic/graphqlChat/internal/webhooks"
 	"github.com/bertoxic/graphqlChat/pkg/config"
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/joho/godotenv"
 
@@ -34,6 +40,20 @@ type ServicesContainer struct {
 	UserAuthService auth.UserRepository
 	UserService     *user.Service
 	ChatService     *chats.HubInterface
+	PostService     posts.PostService
+	Trending        *posts.TrendingAggregator
+	DraftScheduler  *posts.DraftScheduler
+	PollCloser      *posts.PollCloser
+	ViewFlusher     *posts.ViewFlusher
+	CreatorStats    *posts.CreatorStatsAggregator
+	TrashPurger     *posts.TrashPurger
+	MediaService    media.MediaService
+	SearchService   search.SearchService
+	// Federation is nil when no public base URL is configured
+	Federation         *federation.Service
+	FederationDelivery *federation.DeliveryWorker
+	Webhooks           *webhooks.Service
+	WebhookDelivery    *webhooks.DeliveryWorker
 }
 
 //
@@ -90,11 +110,53 @@ func (a *App) initializeServices() error {
 	userRepo := user.NewUserRepo(a.DB)
 	userService := user.NewService(userRepo)
 	a.Services.UserService = userService
+	postRepo := posts.NewPostRepo(a.DB, a.RDB)
+	postService := posts.NewPostServiceImpl(postRepo)
+	postService.EditWindow = a.Config.PostEditWindow
+	a.Services.PostService = postService
+	a.Services.Trending = posts.NewTrendingAggregator(postRepo, time.Minute)
+	a.Services.DraftScheduler = posts.NewDraftScheduler(postRepo, time.Minute)
+	a.Services.PollCloser = posts.NewPollCloser(postRepo, time.Minute)
+	a.Services.ViewFlusher = posts.NewViewFlusher(postRepo, time.Minute)
+	a.Services.CreatorStats = posts.NewCreatorStatsAggregator(postRepo, time.Hour)
+	a.Services.TrashPurger = posts.NewTrashPurger(postRepo, time.Hour)
+	a.Services.SearchService = search.NewSearchServiceImpl(userService, postService)
+	if a.Config.PublicBaseURL != "" {
+		federationService, err := federation.NewService(a.Config.PublicBaseURL, federation.NewRepository(a.DB), postService)
+		if err != nil {
+			return err
+		}
+		a.Services.Federation = federationService
+		a.Services.FederationDelivery = federation.NewDeliveryWorker(federationService, postRepo, 15*time.Second)
+	}
+	a.Services.Webhooks = webhooks.NewService(webhooks.NewRepository(a.DB))
+	a.Services.WebhookDelivery = webhooks.NewDeliveryWorker(a.Services.Webhooks, 15*time.Second)
+	mediaStorage, err := media.NewLocalStorage(a.Config.MediaDir, a.Config.MediaBaseURL)
+	if err != nil {
+		return err
+	}
+	a.Services.MediaService = media.NewMediaServiceImpl(a.DB, mediaStorage)
 	newChatHub := chats.NewHub(a.DB, *a.RDB)
 	a.Services.ChatService = &newChatHub
 	return nil
 }
 
+// Shutdown stops the background workers. Work they are in the middle of is cancelled and
+// picked up again on the next start.
+func (a *App) Shutdown() {
+	services := a.Services
+	services.Trending.Stop()
+	services.DraftScheduler.Stop()
+	services.PollCloser.Stop()
+	services.ViewFlusher.Stop()
+	services.CreatorStats.Stop()
+	services.TrashPurger.Stop()
+	if services.FederationDelivery != nil {
+		services.FederationDelivery.Stop()
+	}
+	services.WebhookDelivery.Stop()
+}
+
 func (a *App) initializeDB(ctx context.Context) error {
 	newDatabase, err := database.NewDatabase(ctx, a.Config.DataBaseINFO.URL, "pgx")
 	if err != nil {
@@ -134,6 +196,12 @@ func (a *App) initializeHandlers() error {
 	}
 	newChatrep := handlers.NewChatRepository(a.Config, a.DB, *a.RDB, hub)
 	handlers.NewChatRepoInit(newChatrep)
+	handlers.NewFeedRepoInit(handlers.NewFeedRepository(a.Config, a.Services.PostService))
+	var federationService handlers.FederationService
+	if a.Services.Federation != nil {
+		federationService = a.Services.Federation
+	}
+	handlers.NewFederationRepoInit(handlers.NewFederationRepository(a.Config, federationService))
 	return nil
 }
 
@@ -7,6 +7,7 @@ import (
 	"github.com/bertoxic/graphqlChat/internal/database"
 	"github.com/bertoxic/graphqlChat/internal/database/postgres"
 	"github.com/bertoxic/graphqlChat/internal/models"
+	"github.com/bertoxic/graphqlChat/internal/webhooks"
 	"github.com/bertoxic/graphqlChat/pkg/config"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -134,6 +135,7 @@ func (h *Hub) handlePrivateMessage(message *models.Message) {
 	if message == nil {
 		return
 	}
+	go h.recordMessageSent(message)
 
 	h.mu.RLock()
 	recipient, exists := h.Clients[message.ToID]
@@ -155,6 +157,66 @@ func (h *Hub) handlePrivateMessage(message *models.Message) {
 	}
 }
 
+// recordMessageSent tells the webhooks of the sender and the recipient about a private message.
+// Messages to anyone but another existing user are not reported, the recipient comes from the client.
+func (h *Hub) recordMessageSent(message *models.Message) {
+	if _, err := uuid.Parse(message.ToID); err != nil || message.ToID == message.FromID {
+		return
+	}
+	db, ok := h.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		log.Printf("Database type assertion failed")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(h.ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// messages to unknown users, or between users who blocked each other, are not reported
+	var deliverable bool
+	err = tx.QueryRow(ctx, `
+        SELECT EXISTS(SELECT 1 FROM users WHERE id = $2)
+           AND NOT EXISTS(
+               SELECT 1 FROM blocked_users
+               WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+           )
+    `, message.FromID, message.ToID).Scan(&deliverable)
+	if err != nil {
+		log.Printf("Failed to check message recipient: %v", err)
+		return
+	}
+	if !deliverable {
+		return
+	}
+
+	err = webhooks.RecordEventTx(ctx, tx, webhooks.Event{
+		Type:    webhooks.EventMessageSent,
+		UserIDs: []string{message.FromID, message.ToID},
+		Data: webhooks.MessageSent{
+			MessageID: message.ID,
+			FromID:    message.FromID,
+			ToID:      message.ToID,
+			Content:   message.Content,
+			Type:      message.Type,
+			SentAt:    message.Timestamp,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to record message webhook event: %v", err)
+		return
+	}
+	if err = tx.Commit(ctx); err != nil {
+		log.Printf("Failed to record message webhook event: %v", err)
+	}
+}
+
 func (h *Hub) handlePublicMessage(message *models.Message) {
 
 	if message == nil {
@@ -466,7 +528,7 @@ func (h *Hub) GetFollowers(ctx context.Context, id string) ([]models.User, error
                u.is_verified, u.follower_count, u.following_count
         FROM follows f 
         JOIN users u ON f.follower_id = u.id 
-        WHERE f.follower_id = $1
+        WHERE f.follower_id = $1 AND f.status = 'ACCEPTED'
         ORDER BY u.username`
 
 	rows, err := db.DB.Query(ctx, query, id)
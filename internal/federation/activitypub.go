@@ -0,0 +1,178 @@
+package federation
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ContentType is the media type ActivityPub documents are served and delivered with
+	ContentType = "application/activity+json"
+	// ldContentType is the other media type servers ask for when fetching documents
+	ldContentType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+	// PublicCollection addresses an object to everyone
+	PublicCollection = "https://www.w3.org/ns/activitystreams#Public"
+
+	activityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	securityContext        = "https://w3id.org/security/v1"
+)
+
+// PublicKey is the key an actor signs its requests with
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// Endpoints holds the shared inbox servers deliver to once for all their followers
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Image struct {
+	Type      string `json:"type"`
+	MediaType string `json:"mediaType,omitempty"`
+	URL       string `json:"url"`
+}
+
+// Actor is a user as other servers see it
+type Actor struct {
+	Context           interface{} `json:"@context,omitempty"`
+	ID                string      `json:"id"`
+	Type              string      `json:"type"`
+	PreferredUsername string      `json:"preferredUsername"`
+	Name              string      `json:"name,omitempty"`
+	Summary           string      `json:"summary,omitempty"`
+	URL               string      `json:"url,omitempty"`
+	Inbox             string      `json:"inbox"`
+	Outbox            string      `json:"outbox,omitempty"`
+	Endpoints         *Endpoints  `json:"endpoints,omitempty"`
+	Icon              *Image      `json:"icon,omitempty"`
+	PublicKey         PublicKey   `json:"publicKey"`
+}
+
+// Tag is a hashtag of a note
+type Tag struct {
+	Type string `json:"type"`
+	Href string `json:"href,omitempty"`
+	Name string `json:"name"`
+}
+
+// Note is a post as other servers see it, Content is HTML
+type Note struct {
+	Context      interface{} `json:"@context,omitempty"`
+	ID           string      `json:"id"`
+	Type         string      `json:"type"`
+	AttributedTo string      `json:"attributedTo"`
+	Content      string      `json:"content"`
+	Summary      *string     `json:"summary,omitempty"` // Content warning shown before the content
+	Sensitive    bool        `json:"sensitive,omitempty"`
+	InReplyTo    *string     `json:"inReplyTo,omitempty"`
+	Published    string      `json:"published,omitempty"`
+	URL          string      `json:"url,omitempty"`
+	To           []string    `json:"to,omitempty"`
+	Cc           []string    `json:"cc,omitempty"`
+	Tag          []Tag       `json:"tag,omitempty"`
+	Attachment   []Image     `json:"attachment,omitempty"`
+}
+
+// Activity is something an actor did. Object is kept raw, it is either the id of an
+// object or the object itself depending on the activity and the server that sent it.
+type Activity struct {
+	Context   interface{}     `json:"@context,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Object    json.RawMessage `json:"object,omitempty"`
+	To        []string        `json:"to,omitempty"`
+	Cc        []string        `json:"cc,omitempty"`
+	Published string          `json:"published,omitempty"`
+}
+
+// OrderedCollection is a list of activities, the latest first
+type OrderedCollection struct {
+	Context      interface{} `json:"@context,omitempty"`
+	ID           string      `json:"id"`
+	Type         string      `json:"type"`
+	TotalItems   int         `json:"totalItems"`
+	OrderedItems []*Activity `json:"orderedItems"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+// WebFinger resolves an acct: address such as alice@example.com to the actor behind it
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+// objectID returns the id of an object that is either embedded or referred to by its id
+func objectID(raw json.RawMessage) string {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return id
+	}
+	var object struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &object); err == nil {
+		return object.ID
+	}
+	return ""
+}
+
+// addressedToPublic reports whether everyone may read an object with these recipients.
+// Servers also write the collection as "Public" or "as:Public".
+func addressedToPublic(recipients ...[]string) bool {
+	for _, list := range recipients {
+		for _, to := range list {
+			if to == PublicCollection || to == "Public" || to == "as:Public" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contentHTML turns the plain text of a post into the HTML notes carry, blank lines
+// separate paragraphs
+func contentHTML(text string) string {
+	var b strings.Builder
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		lines := strings.Split(paragraph, "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(line)
+		}
+		b.WriteString("<p>" + strings.Join(lines, "<br>") + "</p>")
+	}
+	return b.String()
+}
+
+var (
+	lineBreakTags = regexp.MustCompile(`(?i)<br\s*/?>`)
+	paragraphTags = regexp.MustCompile(`(?i)</p>\s*<p[^>]*>`)
+	htmlTags      = regexp.MustCompile(`<[^>]*>`)
+	blankLineRuns = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText turns the HTML content of a remote note into the plain text posts are
+// stored as. Markup is dropped, links keep their text.
+func htmlToText(content string) string {
+	text := paragraphTags.ReplaceAllString(content, "\n\n")
+	text = lineBreakTags.ReplaceAllString(text, "\n")
+	text = htmlTags.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = blankLineRuns.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,184 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bertoxic/graphqlChat/internal/posts"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// postClaimBatch is how many new posts are queued for delivery per transaction
+	postClaimBatch = 100
+	// deliveryBatch is how many deliveries are claimed at once
+	deliveryBatch = 50
+	// deliveryLease is how long a claimed delivery is skipped by other workers
+	deliveryLease = 5 * time.Minute
+	// maxDeliveryAttempts is how often a delivery is tried before it is given up
+	maxDeliveryAttempts = 10
+	// maxRetryDelay caps the backoff between attempts
+	maxRetryDelay = 2 * time.Hour
+)
+
+// deliveryError is a delivery the remote inbox refused. Refusals other than timeouts and
+// rate limits won't change on retry.
+type deliveryError struct {
+	status int
+}
+
+func (e *deliveryError) Error() string {
+	return fmt.Sprintf("inbox answered %d %s", e.status, http.StatusText(e.status))
+}
+
+func (e *deliveryError) permanent() bool {
+	return e.status >= 400 && e.status < 500 && e.status != http.StatusRequestTimeout && e.status != http.StatusTooManyRequests
+}
+
+// retryAt is when a delivery that failed for the given time is tried again, doubling the
+// wait from a minute. It is nil once the delivery should be given up.
+func retryAt(now time.Time, attempts int, err error) *time.Time {
+	if refused, ok := err.(*deliveryError); ok && refused.permanent() {
+		return nil
+	}
+	if attempts >= maxDeliveryAttempts {
+		return nil
+	}
+	delay := time.Minute << (attempts - 1)
+	if delay > maxRetryDelay || delay <= 0 {
+		delay = maxRetryDelay
+	}
+	next := now.Add(delay)
+	return &next
+}
+
+// deliver posts the activity to the remote inbox, signed by the local sender
+func (s *Service) deliver(ctx context.Context, d *Delivery) error {
+	key, err := s.store.GetActorKey(ctx, d.SenderID)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Inbox, bytes.NewReader(d.Activity))
+	if err != nil {
+		return fmt.Errorf("invalid inbox URL: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Accept", ContentType)
+	if err = SignRequest(req, d.Activity, s.keyID(d.SenderUsername), key); err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDocumentSize))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &deliveryError{status: resp.StatusCode}
+	}
+	return nil
+}
+
+// DeliverDue posts the deliveries that are due and records how each went. It returns how
+// many were claimed.
+func (s *Service) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.store.ClaimDeliveries(ctx, deliveryBatch, deliveryLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range deliveries {
+		if err := s.deliver(ctx, d); err != nil {
+			next := retryAt(s.now(), d.Attempts+1, err)
+			if next == nil {
+				log.Printf("giving up delivery %s to %s: %v", d.ID, d.Inbox, err)
+			}
+			if err = s.store.RetryDelivery(ctx, d.ID, err.Error(), next); err != nil {
+				return len(deliveries), err
+			}
+			continue
+		}
+		if err := s.store.CompleteDelivery(ctx, d.ID); err != nil {
+			return len(deliveries), err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// postClaimer hands over posts that were published since the last run
+type postClaimer interface {
+	ClaimPostsToFederate(ctx context.Context, limit int, deliver func(ctx context.Context, tx pgx.Tx, post *posts.FederatedPost) error) (int, error)
+}
+
+// DeliveryWorker periodically queues newly published public posts for remote followers and
+// posts due deliveries, failed deliveries are retried with backoff
+type DeliveryWorker struct {
+	Service  *Service
+	Posts    postClaimer
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewDeliveryWorker(service *Service, posts *posts.PostRepo, interval time.Duration) *DeliveryWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	worker := &DeliveryWorker{
+		Service:  service,
+		Posts:    posts,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	go worker.run()
+	return worker
+}
+
+func (dw *DeliveryWorker) Stop() {
+	dw.cancel()
+}
+
+func (dw *DeliveryWorker) run() {
+	ticker := time.NewTicker(dw.interval)
+	defer ticker.Stop()
+
+	dw.work()
+	for {
+		select {
+		case <-dw.ctx.Done():
+			return
+		case <-ticker.C:
+			dw.work()
+		}
+	}
+}
+
+func (dw *DeliveryWorker) work() {
+	for {
+		claimed, err := dw.Posts.ClaimPostsToFederate(dw.ctx, postClaimBatch, dw.Service.queuePost)
+		if err != nil {
+			log.Printf("failed to queue posts for federation: %v", err)
+			break
+		}
+		if claimed < postClaimBatch {
+			break
+		}
+	}
+
+	for {
+		claimed, err := dw.Service.DeliverDue(dw.ctx)
+		if err != nil {
+			log.Printf("failed to deliver activities: %v", err)
+			return
+		}
+		if claimed < deliveryBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package federation
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/netutil"
+	"github.com/bertoxic/graphqlChat/internal/posts"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// outboxSize is how many of the latest posts an outbox lists
+	outboxSize = 20
+	// requestTimeout bounds fetches of remote documents and deliveries to remote inboxes
+	requestTimeout = 10 * time.Second
+	// maxDocumentSize bounds activities received in an inbox and documents fetched from other servers
+	maxDocumentSize = 1 << 20
+)
+
+// PostSource is the part of the post service federation shares posts through
+type PostSource interface {
+	LikePost(ctx context.Context, postID string, userID string) (posts.PostResponse, error)
+	UnlikePost(ctx context.Context, postID string, userID string) (posts.PostResponse, error)
+	GetFederatedPost(ctx context.Context, postID string) (*posts.FederatedPost, error)
+	GetFederatedPosts(ctx context.Context, userID string, limit int) ([]*posts.FederatedPost, error)
+	GetPostIDByObjectURI(ctx context.Context, objectURI string) (string, error)
+	SaveRemotePost(ctx context.Context, input posts.RemotePostInput) (string, error)
+}
+
+// store is what the service keeps in the database, Repository implements it
+type store interface {
+	GetLocalActor(ctx context.Context, username string) (*LocalActor, error)
+	GetActorKey(ctx context.Context, userID string) (*rsa.PrivateKey, error)
+	GetRemoteActorByKeyID(ctx context.Context, keyID string) (*RemoteActor, error)
+	SaveRemoteActor(ctx context.Context, actor *RemoteActor) error
+	Follow(ctx context.Context, follower *RemoteActor, followedID string, accept []byte) error
+	Unfollow(ctx context.Context, followerID, followedID string) error
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*Delivery, error)
+	CompleteDelivery(ctx context.Context, deliveryID string) error
+	RetryDelivery(ctx context.Context, deliveryID string, reason string, retryAt *time.Time) error
+}
+
+// Service exposes public local accounts as ActivityPub actors. Remote servers can follow
+// them, like their posts and send posts of their own, public local posts are delivered to
+// remote followers by a DeliveryWorker.
+type Service struct {
+	store   store
+	posts   PostSource
+	client  *http.Client
+	baseURL string
+	host    string
+	now     func() time.Time
+}
+
+// NewService creates the service for the site at baseURL, which every actor and object id
+// starts with so it has to stay the same
+func NewService(baseURL string, repo *Repository, posts PostSource) (*Service, error) {
+	return newService(baseURL, repo, posts, netutil.NewPublicClient(requestTimeout))
+}
+
+func newService(baseURL string, store store, posts PostSource, client *http.Client) (*Service, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || base.Host == "" || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("federation needs an absolute base URL, got %q", baseURL)
+	}
+	return &Service{
+		store:   store,
+		posts:   posts,
+		client:  client,
+		baseURL: base.String(),
+		host:    base.Host,
+		now:     time.Now,
+	}, nil
+}
+
+func (s *Service) actorURL(username string) string {
+	return s.baseURL + "/users/" + url.PathEscape(username)
+}
+
+func (s *Service) keyID(username string) string {
+	return s.actorURL(username) + "#main-key"
+}
+
+func (s *Service) postURL(postID string) string {
+	return s.baseURL + "/posts/" + postID
+}
+
+// absolute turns a stored URL, such as a media path, into one other servers can fetch
+func (s *Service) absolute(raw string) string {
+	if strings.HasPrefix(raw, "http://") || strings.HasPrefix(raw, "https://") {
+		return raw
+	}
+	return s.baseURL + "/" + strings.TrimPrefix(raw, "/")
+}
+
+// WebFinger resolves acct:username@host, or an actor URL, to the local actor
+func (s *Service) WebFinger(ctx context.Context, resource string) (*WebFinger, error) {
+	username := ""
+	if account, ok := strings.CutPrefix(resource, "acct:"); ok {
+		name, host, found := strings.Cut(strings.TrimPrefix(account, "@"), "@")
+		if !found || !strings.EqualFold(host, s.host) {
+			return nil, errorx.New(errorx.ErrCodeNotFound, "user not found", nil)
+		}
+		username = name
+	} else if name, ok := strings.CutPrefix(resource, s.baseURL+"/users/"); ok {
+		username, _ = url.PathUnescape(name)
+	}
+	if username == "" {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "user not found", nil)
+	}
+
+	actor, err := s.store.GetLocalActor(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	actorURL := s.actorURL(actor.Username)
+	return &WebFinger{
+		Subject: "acct:" + actor.Username + "@" + s.host,
+		Aliases: []string{actorURL},
+		Links: []WebFingerLink{
+			{Rel: "self", Type: ContentType, Href: actorURL},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: actorURL},
+		},
+	}, nil
+}
+
+// Actor returns the actor document of a public local account, with the key its requests
+// are signed with
+func (s *Service) Actor(ctx context.Context, username string) (*Actor, error) {
+	local, err := s.store.GetLocalActor(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	key, err := s.store.GetActorKey(ctx, local.UserID)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := encodePublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	actorURL := s.actorURL(local.Username)
+	actor := &Actor{
+		Context:           []string{activityStreamsContext, securityContext},
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: local.Username,
+		URL:               actorURL,
+		Inbox:             actorURL + "/inbox",
+		Outbox:            actorURL + "/outbox",
+		PublicKey: PublicKey{
+			ID:           s.keyID(local.Username),
+			Owner:        actorURL,
+			PublicKeyPem: publicKey,
+		},
+	}
+	if local.FullName != nil {
+		actor.Name = *local.FullName
+	}
+	if local.Bio != nil {
+		actor.Summary = contentHTML(*local.Bio)
+	}
+	if local.AvatarURL != nil && *local.AvatarURL != "" {
+		actor.Icon = &Image{Type: "Image", MediaType: mime.TypeByExtension(path.Ext(*local.AvatarURL)), URL: s.absolute(*local.AvatarURL)}
+	}
+	return actor, nil
+}
+
+// Outbox lists Create activities for the latest public posts of the account
+func (s *Service) Outbox(ctx context.Context, username string) (*OrderedCollection, error) {
+	local, err := s.store.GetLocalActor(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	federated, err := s.posts.GetFederatedPosts(ctx, local.UserID, outboxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*Activity, 0, len(federated))
+	for _, fp := range federated {
+		activity, err := s.createActivity(fp)
+		if err != nil {
+			return nil, err
+		}
+		activity.Context = nil
+		items = append(items, activity)
+	}
+	return &OrderedCollection{
+		Context:      activityStreamsContext,
+		ID:           s.actorURL(local.Username) + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   len(items),
+		OrderedItems: items,
+	}, nil
+}
+
+// Note returns a public local post as a note
+func (s *Service) Note(ctx context.Context, postID string) (*Note, error) {
+	fp, err := s.posts.GetFederatedPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	note := s.note(fp)
+	note.Context = activityStreamsContext
+	return note, nil
+}
+
+// note turns a post into a note. Content warnings become the summary servers show before
+// the content, labeled posts are marked sensitive so the content starts hidden.
+func (s *Service) note(fp *posts.FederatedPost) *Note {
+	post := fp.Post
+	content := contentHTML(post.Content)
+	if post.Title != nil && strings.TrimSpace(*post.Title) != "" {
+		content = "<p><strong>" + html.EscapeString(strings.TrimSpace(*post.Title)) + "</strong></p>" + content
+	}
+
+	note := &Note{
+		ID:           s.postURL(post.ID),
+		Type:         "Note",
+		AttributedTo: s.actorURL(fp.Username),
+		Content:      content,
+		Sensitive:    fp.Labels.IsLabeled(),
+		Published:    post.CreatedAt.UTC().Format(time.RFC3339),
+		URL:          s.postURL(post.ID),
+		To:           []string{PublicCollection},
+	}
+	if fp.Labels.Warning != nil {
+		note.Summary = fp.Labels.Warning
+	} else if fp.Labels.Category != nil {
+		summary := strings.ToLower(strings.ReplaceAll(string(*fp.Labels.Category), "_", " "))
+		note.Summary = &summary
+	}
+	if fp.ParentObjectURI != nil {
+		note.InReplyTo = fp.ParentObjectURI
+	} else if post.ParentID != nil {
+		parent := s.postURL(*post.ParentID)
+		note.InReplyTo = &parent
+	}
+	for _, tag := range post.Tags {
+		note.Tag = append(note.Tag, Tag{Type: "Hashtag", Name: "#" + tag})
+	}
+	for _, media := range []*string{post.ImageURL, post.AudioURL} {
+		if media != nil && *media != "" {
+			note.Attachment = append(note.Attachment, Image{
+				Type:      "Document",
+				MediaType: mime.TypeByExtension(path.Ext(*media)),
+				URL:       s.absolute(*media),
+			})
+		}
+	}
+	return note
+}
+
+func (s *Service) createActivity(fp *posts.FederatedPost) (*Activity, error) {
+	note := s.note(fp)
+	object, err := json.Marshal(note)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding note: %w", err)
+	}
+	return &Activity{
+		Context:   activityStreamsContext,
+		ID:        note.ID + "/activity",
+		Type:      "Create",
+		Actor:     note.AttributedTo,
+		Object:    object,
+		To:        note.To,
+		Published: note.Published,
+	}, nil
+}
+
+// queuePost queues the Create of a newly published post for the inboxes of the author's
+// remote followers, and for the author of the remote post it replies to. It is called by
+// ClaimPostsToFederate within its transaction.
+func (s *Service) queuePost(ctx context.Context, tx pgx.Tx, fp *posts.FederatedPost) error {
+	inboxes, err := FollowerInboxesTx(ctx, tx, fp.Post.UserID)
+	if err != nil {
+		return err
+	}
+	if fp.ParentInbox != nil && !contains(inboxes, *fp.ParentInbox) {
+		inboxes = append(inboxes, *fp.ParentInbox)
+	}
+	if len(inboxes) == 0 {
+		return nil
+	}
+
+	activity, err := s.createActivity(fp)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("error encoding activity: %w", err)
+	}
+	for _, inbox := range inboxes {
+		if err = EnqueueDeliveryTx(ctx, tx, fp.Post.UserID, inbox, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchActor fetches the actor document at uri. The request is signed as signer, servers
+// that only serve signed fetches answer it too.
+func (s *Service) fetchActor(ctx context.Context, uri string, signer *LocalActor) (*Actor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeBadRequest, "invalid actor URL", err)
+	}
+	req.Header.Set("Accept", ContentType+", "+ldContentType)
+	key, err := s.store.GetActorKey(ctx, signer.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err = SignRequest(req, nil, s.keyID(signer.Username), key); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeThirdPartyService, "could not fetch actor", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorx.New(errorx.ErrCodeThirdPartyService, fmt.Sprintf("fetching actor returned %s", resp.Status), nil)
+	}
+
+	var actor Actor
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(&actor); err != nil {
+		return nil, errorx.New(errorx.ErrCodeThirdPartyService, "actor is not valid JSON", err)
+	}
+	return &actor, nil
+}
+
+// refreshActor fetches the actor owning the key and stores it with the key. The key must
+// be published by its owner on the owner's server.
+func (s *Service) refreshActor(ctx context.Context, keyID string, signer *LocalActor) (*RemoteActor, error) {
+	keyURL, err := url.Parse(keyID)
+	if err != nil || keyURL.Host == "" || keyURL.Scheme != "https" {
+		return nil, errorx.New(errorx.ErrCodeUnauthorized, "signature key id is not an https URL", err)
+	}
+	if strings.EqualFold(keyURL.Host, s.host) {
+		return nil, errorx.New(errorx.ErrCodeUnauthorized, "local keys can't sign remote activities", nil)
+	}
+	actorURL := *keyURL
+	actorURL.Fragment = ""
+
+	doc, err := s.fetchActor(ctx, actorURL.String(), signer)
+	if err != nil {
+		return nil, err
+	}
+	docURL, err := url.Parse(doc.ID)
+	if err != nil || docURL.Host != keyURL.Host || doc.PublicKey.ID != keyID || doc.PublicKey.Owner != doc.ID || doc.Inbox == "" || doc.PreferredUsername == "" {
+		return nil, errorx.New(errorx.ErrCodeUnauthorized, "actor does not publish the signing key", err)
+	}
+	publicKey, err := parsePublicKey(doc.PublicKey.PublicKeyPem)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeUnauthorized, "actor key is invalid", err)
+	}
+
+	remote := &RemoteActor{
+		ActorURI:  doc.ID,
+		Username:  doc.PreferredUsername + "@" + docURL.Host,
+		Inbox:     doc.Inbox,
+		KeyID:     keyID,
+		PublicKey: publicKey,
+	}
+	if doc.Endpoints != nil && doc.Endpoints.SharedInbox != "" {
+		remote.SharedInbox = &doc.Endpoints.SharedInbox
+	}
+	if err = s.store.SaveRemoteActor(ctx, remote); err != nil {
+		return nil, err
+	}
+	return remote, nil
+}
+
+// verifySender checks the signature of an inbox request and returns the remote actor that
+// made it. A stored key that no longer matches is refreshed once, actors rotate keys.
+func (s *Service) verifySender(ctx context.Context, r *http.Request, body []byte, recipient *LocalActor) (*RemoteActor, error) {
+	sig, err := parseSignature(r)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := s.store.GetRemoteActorByKeyID(ctx, sig.keyID)
+	fresh := false
+	if isCode(err, errorx.ErrCodeNotFound) {
+		remote, err = s.refreshActor(ctx, sig.keyID, recipient)
+		fresh = true
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = sig.verify(r, body, remote.PublicKey, s.now())
+	if err != nil && !fresh {
+		if remote, err = s.refreshActor(ctx, sig.keyID, recipient); err != nil {
+			return nil, err
+		}
+		err = sig.verify(r, body, remote.PublicKey, s.now())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return remote, nil
+}
+
+func isCode(err error, code errorx.ErrorCode) bool {
+	var appErr *errorx.AppError
+	return errors.As(err, &appErr) && appErr.Code == code
+}
@@ -0,0 +1,205 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/posts"
+	"github.com/google/uuid"
+)
+
+// remoteWarningMaxLength is how much of a remote content warning is kept, the same limit
+// local warnings have
+const remoteWarningMaxLength = 200
+
+// HandleInbox processes an activity posted to the inbox of a local account. The request
+// must be signed by the activity's actor. Follow, Undo of a follow or like, Like and
+// Create of a note are handled, other activities are accepted and ignored.
+func (s *Service) HandleInbox(ctx context.Context, username string, r *http.Request, body []byte) error {
+	recipient, err := s.store.GetLocalActor(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	var activity Activity
+	if err = json.Unmarshal(body, &activity); err != nil {
+		return errorx.New(errorx.ErrCodeBadRequest, "activity is not valid JSON", err)
+	}
+
+	sender, err := s.verifySender(ctx, r, body, recipient)
+	if err != nil {
+		return err
+	}
+	if activity.Actor != sender.ActorURI {
+		return errorx.New(errorx.ErrCodeUnauthorized, "activity was not signed by its actor", nil)
+	}
+
+	switch activity.Type {
+	case "Follow":
+		return s.handleFollow(ctx, sender, recipient, &activity, body)
+	case "Undo":
+		return s.handleUndo(ctx, sender, recipient, &activity)
+	case "Like":
+		return s.handleLike(ctx, sender, objectID(activity.Object))
+	case "Create":
+		return s.handleCreate(ctx, sender, &activity)
+	}
+	return nil
+}
+
+// handleFollow accepts follows of the inbox's owner right away, only public accounts are
+// actors so there is nothing to approve
+func (s *Service) handleFollow(ctx context.Context, sender *RemoteActor, recipient *LocalActor, follow *Activity, body []byte) error {
+	if objectID(follow.Object) != s.actorURL(recipient.Username) {
+		return errorx.New(errorx.ErrCodeBadRequest, "follow is not for the owner of this inbox", nil)
+	}
+
+	actorURL := s.actorURL(recipient.Username)
+	accept, err := json.Marshal(&Activity{
+		Context: activityStreamsContext,
+		ID:      actorURL + "#accepts/" + uuid.NewString(),
+		Type:    "Accept",
+		Actor:   actorURL,
+		Object:  json.RawMessage(body),
+	})
+	if err != nil {
+		return err
+	}
+	return s.store.Follow(ctx, sender, recipient.UserID, accept)
+}
+
+// handleUndo reverses a follow or a like. Servers embed the activity being undone, an
+// Undo that only names it is ignored.
+func (s *Service) handleUndo(ctx context.Context, sender *RemoteActor, recipient *LocalActor, undo *Activity) error {
+	var undone Activity
+	if err := json.Unmarshal(undo.Object, &undone); err != nil {
+		return nil
+	}
+	if undone.Actor != sender.ActorURI {
+		return errorx.New(errorx.ErrCodeUnauthorized, "only the actor of an activity can undo it", nil)
+	}
+
+	switch undone.Type {
+	case "Follow":
+		if objectID(undone.Object) != s.actorURL(recipient.Username) {
+			return nil
+		}
+		return s.store.Unfollow(ctx, sender.UserID, recipient.UserID)
+	case "Like":
+		postID, err := s.resolvePost(ctx, objectID(undone.Object))
+		if err != nil {
+			return ignoreNotFound(err)
+		}
+		_, err = s.posts.UnlikePost(ctx, postID, sender.UserID)
+		return ignoreNotFound(err)
+	}
+	return nil
+}
+
+// handleLike likes a post for the remote actor, liking twice or liking an unknown post
+// changes nothing
+func (s *Service) handleLike(ctx context.Context, sender *RemoteActor, object string) error {
+	postID, err := s.resolvePost(ctx, object)
+	if err != nil {
+		return ignoreNotFound(err)
+	}
+	_, err = s.posts.LikePost(ctx, postID, sender.UserID)
+	if isCode(err, errorx.ErrCodeConflict) {
+		return nil
+	}
+	return ignoreNotFound(err)
+}
+
+// handleCreate stores a public note of the remote actor as a post. Replies are only kept
+// when the post they reply to is known here, a reply out of its thread would read as a
+// post of its own. Notes not addressed to everyone are ignored.
+func (s *Service) handleCreate(ctx context.Context, sender *RemoteActor, create *Activity) error {
+	var note Note
+	if err := json.Unmarshal(create.Object, &note); err != nil || note.Type != "Note" {
+		return nil
+	}
+	if note.AttributedTo != sender.ActorURI || !sameHost(note.ID, sender.ActorURI) {
+		return errorx.New(errorx.ErrCodeUnauthorized, "notes can only be created by their author", nil)
+	}
+	if !addressedToPublic(note.To, note.Cc) {
+		return nil
+	}
+
+	input := posts.RemotePostInput{
+		ObjectURI:   note.ID,
+		UserID:      sender.UserID,
+		Content:     htmlToText(note.Content),
+		Labels:      posts.ContentLabels{IsSensitive: note.Sensitive},
+		PublishedAt: s.now(),
+	}
+	if input.Content == "" {
+		return nil
+	}
+	if note.InReplyTo != nil {
+		parentID, err := s.resolvePost(ctx, *note.InReplyTo)
+		if err != nil {
+			return ignoreNotFound(err)
+		}
+		input.ParentID = &parentID
+	}
+	if note.Summary != nil && strings.TrimSpace(*note.Summary) != "" {
+		warning := truncateRunes(htmlToText(*note.Summary), remoteWarningMaxLength)
+		category := posts.WarningOther
+		input.Labels.Category = &category
+		input.Labels.Warning = &warning
+	}
+	// a note can't be published in the future or before it reached the server it came from
+	if published, err := time.Parse(time.RFC3339, note.Published); err == nil && published.Before(input.PublishedAt) {
+		input.PublishedAt = published
+	}
+
+	_, err := s.posts.SaveRemotePost(ctx, input)
+	return ignoreNotFound(err)
+}
+
+// resolvePost returns the local id of the post an ActivityPub id stands for, a local post
+// URL or the id of a remote post received earlier
+func (s *Service) resolvePost(ctx context.Context, object string) (string, error) {
+	if postID, ok := strings.CutPrefix(object, s.baseURL+"/posts/"); ok {
+		if _, err := uuid.Parse(postID); err != nil {
+			return "", errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+		}
+		return postID, nil
+	}
+	if object == "" {
+		return "", errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	return s.posts.GetPostIDByObjectURI(ctx, object)
+}
+
+func ignoreNotFound(err error) error {
+	if isCode(err, errorx.ErrCodeNotFound) {
+		return nil
+	}
+	return err
+}
+
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Host != "" && strings.EqualFold(ua.Host, ub.Host)
+}
+
+func truncateRunes(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max])
+}
@@ -0,0 +1,416 @@
+package federation
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/bertoxic/graphqlChat/internal/database"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/models"
+	"github.com/bertoxic/graphqlChat/internal/netutil"
+	"github.com/bertoxic/graphqlChat/internal/notifications"
+	"github.com/bertoxic/graphqlChat/internal/webhooks"
+	"github.com/jackc/pgx/v4"
+)
+
+// LocalActor is a public local account other servers can follow
+type LocalActor struct {
+	UserID    string
+	Username  string
+	FullName  *string
+	Bio       *string
+	AvatarURL *string
+}
+
+// RemoteActor is an account on another server, stored as a user that can't sign in
+type RemoteActor struct {
+	UserID      string
+	ActorURI    string
+	Username    string // preferredUsername@host
+	Inbox       string
+	SharedInbox *string
+	KeyID       string
+	PublicKey   *rsa.PublicKey
+}
+
+// deliveryInbox is where activities for the actor go, the shared inbox when it has one
+func (a *RemoteActor) deliveryInbox() string {
+	if a.SharedInbox != nil && *a.SharedInbox != "" {
+		return *a.SharedInbox
+	}
+	return a.Inbox
+}
+
+// validate checks the inboxes activities for the actor are posted to, they come from the
+// actor document and must not point the delivery worker at our own network
+func (a *RemoteActor) validate() error {
+	if err := validateInboxURL(a.Inbox); err != nil {
+		return err
+	}
+	if a.SharedInbox != nil && *a.SharedInbox != "" {
+		return validateInboxURL(*a.SharedInbox)
+	}
+	return nil
+}
+
+func validateInboxURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.User != nil || !netutil.IsPublicHost(u) {
+		return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("actor inbox %q is not a public https URL", raw), err)
+	}
+	return nil
+}
+
+// Delivery is an activity waiting to be posted to a remote inbox
+type Delivery struct {
+	ID             string
+	SenderID       string
+	SenderUsername string
+	Inbox          string
+	Activity       []byte
+	Attempts       int // failed attempts so far
+}
+
+type Repository struct {
+	DB database.DatabaseRepo
+}
+
+func NewRepository(db database.DatabaseRepo) *Repository {
+	return &Repository{DB: db}
+}
+
+func (r *Repository) pool() (*postgres.PostgresDBRepo, error) {
+	db, ok := r.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("r.DB does not implement database.Database")
+	}
+	return db, nil
+}
+
+// GetLocalActor returns the local account with the username. Private and remote accounts
+// are not found, like they are for profile feeds.
+func (r *Repository) GetLocalActor(ctx context.Context, username string) (*LocalActor, error) {
+	db, err := r.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	var actor LocalActor
+	var isPrivate bool
+	err = db.DB.QueryRow(ctx, `
+        SELECT id, username, full_name, bio, profile_picture_url, COALESCE(is_private, FALSE)
+        FROM users
+        WHERE username = $1 AND actor_uri IS NULL
+    `, username).Scan(&actor.UserID, &actor.Username, &actor.FullName, &actor.Bio, &actor.AvatarURL, &isPrivate)
+	if err == pgx.ErrNoRows || (err == nil && isPrivate) {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "user not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching local actor: %w", err)
+	}
+	return &actor, nil
+}
+
+// GetActorKey returns the private key of a local user, creating the keypair the first time
+func (r *Repository) GetActorKey(ctx context.Context, userID string) (*rsa.PrivateKey, error) {
+	db, err := r.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	var private *string
+	err = db.DB.QueryRow(ctx, `SELECT private_key_pem FROM actor_keys WHERE user_id = $1`, userID).Scan(&private)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("error fetching actor key: %w", err)
+	}
+	if err == nil {
+		if private == nil {
+			return nil, fmt.Errorf("user %s has no private key", userID)
+		}
+		return parsePrivateKey(*private)
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	public, err := encodePublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	// a concurrent request may have stored a key first, the stored one wins
+	var stored string
+	err = db.DB.QueryRow(ctx, `
+        INSERT INTO actor_keys (user_id, public_key_pem, private_key_pem) VALUES ($1, $2, $3)
+        ON CONFLICT (user_id) DO UPDATE SET user_id = actor_keys.user_id
+        RETURNING private_key_pem
+    `, userID, public, encodePrivateKey(key)).Scan(&stored)
+	if err != nil {
+		return nil, fmt.Errorf("error storing actor key: %w", err)
+	}
+	return parsePrivateKey(stored)
+}
+
+// GetRemoteActorByKeyID returns the remote actor that signs with the key
+func (r *Repository) GetRemoteActorByKeyID(ctx context.Context, keyID string) (*RemoteActor, error) {
+	db, err := r.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	var actor RemoteActor
+	var publicKey string
+	err = db.DB.QueryRow(ctx, `
+        SELECT u.id, u.actor_uri, u.username, u.inbox_url, u.shared_inbox_url, k.key_id, k.public_key_pem
+        FROM actor_keys k
+        JOIN users u ON u.id = k.user_id
+        WHERE k.key_id = $1 AND u.actor_uri IS NOT NULL
+    `, keyID).Scan(&actor.UserID, &actor.ActorURI, &actor.Username, &actor.Inbox, &actor.SharedInbox, &actor.KeyID, &publicKey)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "actor not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching remote actor: %w", err)
+	}
+	if actor.PublicKey, err = parsePublicKey(publicKey); err != nil {
+		return nil, err
+	}
+	return &actor, nil
+}
+
+// SaveRemoteActor creates or refreshes the user standing for a remote actor, with the key
+// it signs with. The id of the stored user is set on the actor. Actors with inboxes that
+// aren't public https URLs are refused.
+func (r *Repository) SaveRemoteActor(ctx context.Context, actor *RemoteActor) error {
+	if err := actor.validate(); err != nil {
+		return err
+	}
+	db, err := r.pool()
+	if err != nil {
+		return err
+	}
+	publicKey, err := encodePublicKey(actor.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// remote users have no password, the actor URI stands in for the unique email
+	err = tx.QueryRow(ctx, `
+        INSERT INTO users (username, email, password, actor_uri, inbox_url, shared_inbox_url)
+        VALUES ($1, $2, '', $2, $3, $4)
+        ON CONFLICT (actor_uri) DO UPDATE SET
+            username = EXCLUDED.username, inbox_url = EXCLUDED.inbox_url,
+            shared_inbox_url = EXCLUDED.shared_inbox_url, updated_at = NOW()
+        RETURNING id
+    `, actor.Username, actor.ActorURI, actor.Inbox, actor.SharedInbox).Scan(&actor.UserID)
+	if err != nil {
+		return fmt.Errorf("error saving remote actor: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO actor_keys (user_id, key_id, public_key_pem) VALUES ($1, $2, $3)
+        ON CONFLICT (user_id) DO UPDATE SET key_id = EXCLUDED.key_id, public_key_pem = EXCLUDED.public_key_pem, updated_at = NOW()
+    `, actor.UserID, actor.KeyID, publicKey)
+	if err != nil {
+		return fmt.Errorf("error saving remote actor key: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Follow records the remote actor following the local user and queues the Accept telling
+// its server. Following again only queues another Accept.
+func (r *Repository) Follow(ctx context.Context, follower *RemoteActor, followedID string, accept []byte) error {
+	db, err := r.pool()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+        INSERT INTO follows (follower_id, followed_id, status) VALUES ($1, $2, 'ACCEPTED')
+        ON CONFLICT (follower_id, followed_id) DO NOTHING
+    `, follower.UserID, followedID)
+	if err != nil {
+		return fmt.Errorf("failed to follow user: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		err = notifications.CreateNotificationTx(ctx, tx, &models.Notification{
+			UserID:  followedID,
+			Type:    models.Follow,
+			Title:   "You have a new follower",
+			Content: follower.Username,
+		})
+		if err != nil {
+			return err
+		}
+		err = webhooks.RecordEventTx(ctx, tx, webhooks.Event{
+			Type:    webhooks.EventUserFollowed,
+			UserIDs: []string{follower.UserID, followedID},
+			Data:    webhooks.UserFollowed{FollowerID: follower.UserID, FollowedID: followedID},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = EnqueueDeliveryTx(ctx, tx, followedID, follower.deliveryInbox(), accept); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Unfollow removes the remote actor's follow of the local user, if there is one
+func (r *Repository) Unfollow(ctx context.Context, followerID, followedID string) error {
+	db, err := r.pool()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec(ctx, `DELETE FROM follows WHERE follower_id = $1 AND followed_id = $2`, followerID, followedID)
+	if err != nil {
+		return fmt.Errorf("failed to unfollow user: %w", err)
+	}
+	return nil
+}
+
+// FollowerInboxesTx returns the inboxes of the user's remote followers, a shared inbox
+// only once
+func FollowerInboxesTx(ctx context.Context, tx pgx.Tx, userID string) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+        SELECT DISTINCT COALESCE(u.shared_inbox_url, u.inbox_url)
+        FROM follows f
+        JOIN users u ON u.id = f.follower_id
+        WHERE f.followed_id = $1 AND f.status = 'ACCEPTED' AND u.actor_uri IS NOT NULL
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching follower inboxes: %w", err)
+	}
+	defer rows.Close()
+
+	var inboxes []string
+	for rows.Next() {
+		var inbox string
+		if err := rows.Scan(&inbox); err != nil {
+			return nil, fmt.Errorf("error scanning follower inbox: %w", err)
+		}
+		inboxes = append(inboxes, inbox)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating follower inboxes: %w", err)
+	}
+	return inboxes, nil
+}
+
+// EnqueueDeliveryTx queues the activity, signed by the sender, for the inbox
+func EnqueueDeliveryTx(ctx context.Context, tx pgx.Tx, senderID, inbox string, activity []byte) error {
+	_, err := tx.Exec(ctx, `
+        INSERT INTO federation_deliveries (sender_id, inbox_url, activity) VALUES ($1, $2, $3)
+    `, senderID, inbox, string(activity))
+	if err != nil {
+		return fmt.Errorf("error queueing delivery: %w", err)
+	}
+	return nil
+}
+
+// ClaimDeliveries takes up to limit deliveries that are due. They are leased so that other
+// workers skip them until the lease ends, a delivery that is neither completed nor retried
+// in time is tried again.
+func (r *Repository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*Delivery, error) {
+	db, err := r.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query(ctx, `
+        UPDATE federation_deliveries d SET next_attempt_at = $2
+        FROM users u
+        WHERE u.id = d.sender_id AND d.id IN (
+            SELECT id FROM federation_deliveries
+            WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+            ORDER BY next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING d.id, d.sender_id, u.username, d.inbox_url, d.activity::text, d.attempts
+    `, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, fmt.Errorf("error claiming deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*Delivery{}
+	for rows.Next() {
+		var d Delivery
+		var activity string
+		if err := rows.Scan(&d.ID, &d.SenderID, &d.SenderUsername, &d.Inbox, &activity, &d.Attempts); err != nil {
+			return nil, fmt.Errorf("error scanning delivery: %w", err)
+		}
+		d.Activity = []byte(activity)
+		deliveries = append(deliveries, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// CompleteDelivery marks the delivery as accepted by the remote inbox
+func (r *Repository) CompleteDelivery(ctx context.Context, deliveryID string) error {
+	db, err := r.pool()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec(ctx, `
+        UPDATE federation_deliveries SET delivered_at = NOW() WHERE id = $1
+    `, deliveryID)
+	if err != nil {
+		return fmt.Errorf("error completing delivery: %w", err)
+	}
+	return nil
+}
+
+// RetryDelivery records a failed attempt. The delivery is tried again at retryAt, or given
+// up on when retryAt is nil.
+func (r *Repository) RetryDelivery(ctx context.Context, deliveryID string, reason string, retryAt *time.Time) error {
+	db, err := r.pool()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec(ctx, `
+        UPDATE federation_deliveries SET
+            attempts = attempts + 1,
+            last_error = $2,
+            next_attempt_at = COALESCE($3, next_attempt_at),
+            failed_at = CASE WHEN $3::timestamp IS NULL THEN NOW() END
+        WHERE id = $1
+    `, deliveryID, reason, retryAt)
+	if err != nil {
+		return fmt.Errorf("error recording failed delivery: %w", err)
+	}
+	return nil
+}
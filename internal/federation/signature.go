@@ -0,0 +1,230 @@
+package federation
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+)
+
+const (
+	keyBits = 2048
+	// maxClockSkew is how far the Date of a signed request may be from the local clock
+	maxClockSkew = 12 * time.Hour
+)
+
+// GenerateKey creates the keypair a local user signs requests with
+func GenerateKey() (*rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, fmt.Errorf("error generating key: %w", err)
+	}
+	return key, nil
+}
+
+func encodePrivateKey(key *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func encodePublicKey(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", fmt.Errorf("error encoding public key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("private key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %w", err)
+	}
+	return key, nil
+}
+
+// parsePublicKey reads an RSA public key in either of the PEM forms servers publish
+func parsePublicKey(data string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("public key is not PEM encoded")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not an RSA key")
+	}
+	return key, nil
+}
+
+func digestHeader(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// signingString builds the text a signature covers from the listed headers, in order
+func signingString(req *http.Request, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		var value string
+		switch name {
+		case "(request-target)":
+			value = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		default:
+			value = req.Header.Get(name)
+			if value == "" {
+				return "", fmt.Errorf("signed header %s is missing", name)
+			}
+		}
+		lines = append(lines, name+": "+value)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// SignRequest signs the request as the key's owner, following the HTTP Signatures draft
+// servers such as Mastodon use. Date is set when missing, requests with a body also sign
+// a digest of it.
+func SignRequest(req *http.Request, body []byte, keyID string, key *rsa.PrivateKey) error {
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", digestHeader(body))
+		headers = append(headers, "digest")
+	}
+
+	text, err := signingString(req, headers)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(text))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return fmt.Errorf("error signing request: %w", err)
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig)))
+	return nil
+}
+
+// signature is a parsed Signature header
+type signature struct {
+	keyID     string
+	algorithm string
+	headers   []string
+	value     []byte
+}
+
+var signatureParams = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func parseSignature(req *http.Request) (*signature, error) {
+	header := req.Header.Get("Signature")
+	if header == "" {
+		return nil, errorx.New(errorx.ErrCodeUnauthorized, "request is not signed", nil)
+	}
+
+	sig := &signature{headers: []string{"date"}}
+	for _, match := range signatureParams.FindAllStringSubmatch(header, -1) {
+		switch match[1] {
+		case "keyId":
+			sig.keyID = match[2]
+		case "algorithm":
+			sig.algorithm = match[2]
+		case "headers":
+			sig.headers = strings.Fields(strings.ToLower(match[2]))
+		case "signature":
+			value, err := base64.StdEncoding.DecodeString(match[2])
+			if err != nil {
+				return nil, errorx.New(errorx.ErrCodeUnauthorized, "signature is not base64", err)
+			}
+			sig.value = value
+		}
+	}
+	if sig.keyID == "" || sig.value == nil {
+		return nil, errorx.New(errorx.ErrCodeUnauthorized, "signature is missing its key or value", nil)
+	}
+	// hs2019 leaves the algorithm to the key, which is always RSA here
+	if sig.algorithm != "" && sig.algorithm != "rsa-sha256" && sig.algorithm != "hs2019" {
+		return nil, errorx.New(errorx.ErrCodeUnauthorized, fmt.Sprintf("unsupported signature algorithm %s", sig.algorithm), nil)
+	}
+	return sig, nil
+}
+
+func (sig *signature) covers(header string) bool {
+	for _, h := range sig.headers {
+		if h == header {
+			return true
+		}
+	}
+	return false
+}
+
+// verify checks the signature was made by key over a fresh request carrying body. The
+// request target, host and date must be signed, and the digest too when there is a body.
+func (sig *signature) verify(req *http.Request, body []byte, key *rsa.PublicKey, now time.Time) error {
+	for _, required := range []string{"(request-target)", "host", "date"} {
+		if !sig.covers(required) {
+			return errorx.New(errorx.ErrCodeUnauthorized, fmt.Sprintf("signature must cover %s", required), nil)
+		}
+	}
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return errorx.New(errorx.ErrCodeUnauthorized, "request has no valid date", err)
+	}
+	if date.Before(now.Add(-maxClockSkew)) || date.After(now.Add(maxClockSkew)) {
+		return errorx.New(errorx.ErrCodeUnauthorized, "request date is too far from now", nil)
+	}
+	if body != nil {
+		if !sig.covers("digest") {
+			return errorx.New(errorx.ErrCodeUnauthorized, "signature must cover digest", nil)
+		}
+		if req.Header.Get("Digest") != digestHeader(body) {
+			return errorx.New(errorx.ErrCodeUnauthorized, "digest does not match the body", nil)
+		}
+	}
+
+	text, err := signingString(req, sig.headers)
+	if err != nil {
+		return errorx.New(errorx.ErrCodeUnauthorized, err.Error(), nil)
+	}
+	hash := sha256.Sum256([]byte(text))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig.value); err != nil {
+		return errorx.New(errorx.ErrCodeUnauthorized, "signature does not match", err)
+	}
+	return nil
+}
+
+// VerifyRequest checks the request was signed with key, body is nil for requests without one
+func VerifyRequest(req *http.Request, body []byte, key *rsa.PublicKey) error {
+	sig, err := parseSignature(req)
+	if err != nil {
+		return err
+	}
+	return sig.verify(req, body, key, time.Now())
+}
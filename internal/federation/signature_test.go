@@ -0,0 +1,69 @@
+package federation
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignRequest_Verify(t *testing.T) {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	other, err := GenerateKey()
+	require.NoError(t, err)
+	body := []byte(`{"type":"Follow"}`)
+
+	signed := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/users/alice/inbox", bytes.NewReader(body))
+		require.NoError(t, SignRequest(req, body, "https://remote.example/users/bob#main-key", key))
+		return req
+	}
+
+	require.NoError(t, VerifyRequest(signed(), body, &key.PublicKey))
+
+	sig, err := parseSignature(signed())
+	require.NoError(t, err)
+	require.Equal(t, "https://remote.example/users/bob#main-key", sig.keyID)
+	require.Equal(t, []string{"(request-target)", "host", "date", "digest"}, sig.headers)
+
+	t.Run("other key", func(t *testing.T) {
+		require.Error(t, VerifyRequest(signed(), body, &other.PublicKey))
+	})
+	t.Run("changed body", func(t *testing.T) {
+		require.Error(t, VerifyRequest(signed(), []byte(`{"type":"Undo"}`), &key.PublicKey))
+	})
+	t.Run("other inbox", func(t *testing.T) {
+		req := signed()
+		req.URL.Path = "/users/carol/inbox"
+		require.Error(t, VerifyRequest(req, body, &key.PublicKey))
+	})
+	t.Run("stale date", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/users/alice/inbox", bytes.NewReader(body))
+		req.Header.Set("Date", time.Now().Add(-2*maxClockSkew).UTC().Format(http.TimeFormat))
+		require.NoError(t, SignRequest(req, body, "https://remote.example/users/bob#main-key", key))
+		require.Error(t, VerifyRequest(req, body, &key.PublicKey))
+	})
+	t.Run("unsigned", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/users/alice/inbox", bytes.NewReader(body))
+		require.Error(t, VerifyRequest(req, body, &key.PublicKey))
+	})
+}
+
+func TestKeys_RoundTrip(t *testing.T) {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+
+	public, err := encodePublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	parsedPublic, err := parsePublicKey(public)
+	require.NoError(t, err)
+	require.True(t, key.PublicKey.Equal(parsedPublic))
+
+	parsedPrivate, err := parsePrivateKey(encodePrivateKey(key))
+	require.NoError(t, err)
+	require.True(t, key.Equal(parsedPrivate))
+}
@@ -75,7 +75,7 @@ func serveWs(hub *chats.Hub, w http.ResponseWriter, r *http.Request) {
 		Conn:   conn,
 		Send:   make(chan []byte, 256),
 	}
-	fmt.Printf("userID is :", client.User.ID)
+	fmt.Printf("userID is : %s\n", client.User.ID)
 
 	//client := &chats.Client{
 	//	Config: config.AppConfig{},
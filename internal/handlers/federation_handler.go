@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/federation"
+	"github.com/bertoxic/graphqlChat/pkg/config"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxInboxBodySize bounds the activities remote servers post to inboxes
+const maxInboxBodySize = 1 << 20
+
+// FederationService serves local accounts and posts to other ActivityPub servers
+type FederationService interface {
+	WebFinger(ctx context.Context, resource string) (*federation.WebFinger, error)
+	Actor(ctx context.Context, username string) (*federation.Actor, error)
+	Outbox(ctx context.Context, username string) (*federation.OrderedCollection, error)
+	Note(ctx context.Context, postID string) (*federation.Note, error)
+	HandleInbox(ctx context.Context, username string, r *http.Request, body []byte) error
+}
+
+// FederationRepository exposes the ActivityPub endpoints. Without a service, when no
+// public base URL is configured, every endpoint is not found.
+type FederationRepository struct {
+	app        *config.AppConfig
+	federation FederationService
+}
+
+func NewFederationRepository(app *config.AppConfig, federation FederationService) *FederationRepository {
+	return &FederationRepository{app: app, federation: federation}
+}
+
+var FederationRepo *FederationRepository
+
+func NewFederationRepoInit(repo *FederationRepository) {
+	FederationRepo = repo
+}
+
+func (fr *FederationRepository) HandleWebFinger(w http.ResponseWriter, r *http.Request) {
+	if fr.federation == nil {
+		http.NotFound(w, r)
+		return
+	}
+	doc, err := fr.federation.WebFinger(r.Context(), r.URL.Query().Get("resource"))
+	fr.writeDocument(w, r, "application/jrd+json", doc, err)
+}
+
+func (fr *FederationRepository) HandleActor(w http.ResponseWriter, r *http.Request) {
+	if fr.federation == nil {
+		http.NotFound(w, r)
+		return
+	}
+	doc, err := fr.federation.Actor(r.Context(), chi.URLParam(r, "username"))
+	fr.writeDocument(w, r, federation.ContentType, doc, err)
+}
+
+func (fr *FederationRepository) HandleOutbox(w http.ResponseWriter, r *http.Request) {
+	if fr.federation == nil {
+		http.NotFound(w, r)
+		return
+	}
+	doc, err := fr.federation.Outbox(r.Context(), chi.URLParam(r, "username"))
+	fr.writeDocument(w, r, federation.ContentType, doc, err)
+}
+
+func (fr *FederationRepository) HandleNote(w http.ResponseWriter, r *http.Request) {
+	if fr.federation == nil {
+		http.NotFound(w, r)
+		return
+	}
+	doc, err := fr.federation.Note(r.Context(), chi.URLParam(r, "postID"))
+	fr.writeDocument(w, r, federation.ContentType, doc, err)
+}
+
+// HandleInbox takes activities from other servers, they are processed before answering
+// 202 Accepted
+func (fr *FederationRepository) HandleInbox(w http.ResponseWriter, r *http.Request) {
+	if fr.federation == nil {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInboxBodySize))
+	if err != nil {
+		http.Error(w, "Activity is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err = fr.federation.HandleInbox(r.Context(), chi.URLParam(r, "username"), r, body); err != nil {
+		fr.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (fr *FederationRepository) writeDocument(w http.ResponseWriter, r *http.Request, contentType string, doc interface{}, err error) {
+	if err != nil {
+		fr.writeError(w, r, err)
+		return
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		log.Printf("error encoding %s: %v", r.URL.Path, err)
+		http.Error(w, "Could not render document", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	_, _ = w.Write(body)
+}
+
+// writeError answers with the status matching the error, failures of other servers are
+// reported as a bad gateway
+func (fr *FederationRepository) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *errorx.AppError
+	if errors.As(err, &appErr) {
+		switch appErr.Code {
+		case errorx.ErrCodeNotFound:
+			http.NotFound(w, r)
+			return
+		case errorx.ErrCodeUnauthorized:
+			http.Error(w, appErr.Message, http.StatusUnauthorized)
+			return
+		case errorx.ErrCodeBadRequest, errorx.ErrCodeValidation, errorx.ErrCodeInvalidFormat:
+			http.Error(w, appErr.Message, http.StatusBadRequest)
+			return
+		case errorx.ErrCodeThirdPartyService:
+			http.Error(w, appErr.Message, http.StatusBadGateway)
+			return
+		}
+	}
+	log.Printf("error handling %s %s: %v", r.Method, r.URL.Path, err)
+	http.Error(w, "Could not process request", http.StatusInternalServerError)
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/posts"
+	"github.com/bertoxic/graphqlChat/pkg/config"
+	"github.com/go-chi/chi/v5"
+)
+
+// feedTitleLength is how much of the content is used as the title of an untitled post
+const feedTitleLength = 80
+
+// FeedSource loads the public posts of a profile
+type FeedSource interface {
+	GetProfileFeed(ctx context.Context, username string) (*posts.ProfileFeed, error)
+}
+
+// FeedRepository serves public profiles as RSS 2.0, Atom and JSON Feed, so accounts can
+// be followed in feed readers without signing in
+type FeedRepository struct {
+	app   *config.AppConfig
+	feeds FeedSource
+}
+
+func NewFeedRepository(app *config.AppConfig, feeds FeedSource) *FeedRepository {
+	return &FeedRepository{app: app, feeds: feeds}
+}
+
+var FeedRepo *FeedRepository
+
+func NewFeedRepoInit(repo *FeedRepository) {
+	FeedRepo = repo
+}
+
+// feedLinks are the absolute URLs a feed points to
+type feedLinks struct {
+	base    string
+	self    string
+	profile string
+}
+
+func (l feedLinks) post(postID string) string {
+	return l.base + "/posts/" + postID
+}
+
+// absolute turns a stored URL, such as a media path, into one readers can fetch
+func (l feedLinks) absolute(raw string) string {
+	if strings.HasPrefix(raw, "http://") || strings.HasPrefix(raw, "https://") {
+		return raw
+	}
+	return l.base + "/" + strings.TrimPrefix(raw, "/")
+}
+
+func (fr *FeedRepository) HandleRSSFeed(w http.ResponseWriter, r *http.Request) {
+	fr.serveFeed(w, r, "application/rss+xml; charset=utf-8", encodeRSS)
+}
+
+func (fr *FeedRepository) HandleAtomFeed(w http.ResponseWriter, r *http.Request) {
+	fr.serveFeed(w, r, "application/atom+xml; charset=utf-8", encodeAtom)
+}
+
+func (fr *FeedRepository) HandleJSONFeed(w http.ResponseWriter, r *http.Request) {
+	fr.serveFeed(w, r, "application/feed+json; charset=utf-8", encodeJSONFeed)
+}
+
+// serveFeed renders the feed of the profile in the URL, feeds are only served when a public
+// base URL is configured. The ETag is a hash of the body and
+// Last-Modified the last change to the profile, http.ServeContent answers conditional
+// requests with 304 Not Modified.
+func (fr *FeedRepository) serveFeed(w http.ResponseWriter, r *http.Request, contentType string, encode func(*posts.ProfileFeed, feedLinks) ([]byte, error)) {
+	// links in feeds outlive the request, they can't be built from the Host header it was sent with
+	if fr.app.PublicBaseURL == "" {
+		http.NotFound(w, r)
+		return
+	}
+	feed, err := fr.feeds.GetProfileFeed(r.Context(), chi.URLParam(r, "username"))
+	if err != nil {
+		var appErr *errorx.AppError
+		if errors.As(err, &appErr) && appErr.Code == errorx.ErrCodeNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("error loading feed %s: %v", r.URL.Path, err)
+		http.Error(w, "Could not load feed", http.StatusInternalServerError)
+		return
+	}
+
+	base := strings.TrimSuffix(fr.app.PublicBaseURL, "/")
+	links := feedLinks{base: base, self: base + r.URL.Path, profile: base + "/users/" + feed.Username}
+
+	body, err := encode(feed, links)
+	if err != nil {
+		log.Printf("error encoding feed %s: %v", r.URL.Path, err)
+		http.Error(w, "Could not render feed", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	// Last-Modified has a resolution of seconds, a change within the same second still changes the ETag
+	http.ServeContent(w, r, "", feed.UpdatedAt.Truncate(time.Second), bytes.NewReader(body))
+}
+
+// feedTitle is the post's title, or the start of its content for untitled posts. Content
+// warnings come first so readers see them before opening the post.
+func feedTitle(item *posts.FeedItem) string {
+	title := ""
+	if item.Post.Title != nil {
+		title = strings.TrimSpace(*item.Post.Title)
+	}
+	if title == "" {
+		title = strings.Join(strings.Fields(item.Post.Content), " ")
+		if utf8.RuneCountInString(title) > feedTitleLength {
+			title = string([]rune(title)[:feedTitleLength-1]) + "…"
+		}
+	}
+
+	var warnings []string
+	if item.Labels.Category != nil {
+		warning := strings.ToLower(strings.ReplaceAll(string(*item.Labels.Category), "_", " "))
+		if item.Labels.Warning != nil {
+			warning += ": " + *item.Labels.Warning
+		}
+		warnings = append(warnings, warning)
+	}
+	if item.Labels.IsSensitive {
+		warnings = append(warnings, "sensitive media")
+	}
+	if len(warnings) > 0 {
+		title = "[CW " + strings.Join(warnings, ", ") + "] " + title
+	}
+	return title
+}
+
+func feedAuthorName(feed *posts.ProfileFeed) string {
+	if feed.FullName != nil && strings.TrimSpace(*feed.FullName) != "" {
+		return fmt.Sprintf("%s (@%s)", strings.TrimSpace(*feed.FullName), feed.Username)
+	}
+	return "@" + feed.Username
+}
+
+// feedMedia lists the image and audio of a post, with their mime type guessed from the URL,
+// followed by its attachments
+func feedMedia(item *posts.FeedItem, links feedLinks) []feedEnclosure {
+	var media []feedEnclosure
+	for _, raw := range []*string{item.Post.ImageURL, item.Post.AudioURL} {
+		if raw == nil || *raw == "" {
+			continue
+		}
+		mimeType := mime.TypeByExtension(path.Ext(*raw))
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		media = append(media, feedEnclosure{URL: links.absolute(*raw), Type: mimeType, Length: "0"})
+	}
+	for _, attachment := range item.Attachments {
+		media = append(media, feedEnclosure{URL: links.absolute(attachment.URL), Type: attachment.MimeType, Length: "0"})
+	}
+	return media
+}
+
+type feedEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          rssSelf   `xml:"atom:link"`
+	Image         *rssImage `xml:"image,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssImage struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type rssItem struct {
+	Title       string          `xml:"title"`
+	Link        string          `xml:"link"`
+	GUID        rssGUID         `xml:"guid"`
+	PubDate     string          `xml:"pubDate"`
+	Description string          `xml:"description"`
+	Categories  []string        `xml:"category"`
+	Enclosures  []feedEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func encodeRSS(feed *posts.ProfileFeed, links feedLinks) ([]byte, error) {
+	channel := rssChannel{
+		Title:         feedAuthorName(feed),
+		Link:          links.profile,
+		Description:   "Public posts of " + feedAuthorName(feed),
+		LastBuildDate: feed.UpdatedAt.UTC().Format(time.RFC1123Z),
+		Self:          rssSelf{Href: links.self, Rel: "self", Type: "application/rss+xml"},
+		Items:         []rssItem{},
+	}
+	if feed.Bio != nil && *feed.Bio != "" {
+		channel.Description = *feed.Bio
+	}
+	if feed.AvatarURL != nil && *feed.AvatarURL != "" {
+		channel.Image = &rssImage{URL: links.absolute(*feed.AvatarURL), Title: channel.Title, Link: links.profile}
+	}
+	for _, item := range feed.Items {
+		// RSS readers only take one enclosure per item reliably, the image goes first
+		enclosures := feedMedia(item, links)
+		if len(enclosures) > 1 {
+			enclosures = enclosures[:1]
+		}
+		channel.Items = append(channel.Items, rssItem{
+			Title:       feedTitle(item),
+			Link:        links.post(item.Post.ID),
+			GUID:        rssGUID{IsPermaLink: true, Value: links.post(item.Post.ID)},
+			PubDate:     item.Post.CreatedAt.UTC().Format(time.RFC1123Z),
+			Description: item.Post.Content,
+			Categories:  item.Post.Tags,
+			Enclosures:  enclosures,
+		})
+	}
+
+	body, err := xml.MarshalIndent(rssFeed{Version: "2.0", AtomNS: "http://www.w3.org/2005/Atom", Channel: channel}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	NS      string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Icon    string      `xml:"icon,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func encodeAtom(feed *posts.ProfileFeed, links feedLinks) ([]byte, error) {
+	out := atomFeed{
+		NS:      "http://www.w3.org/2005/Atom",
+		ID:      links.profile,
+		Title:   feedAuthorName(feed),
+		Updated: feed.UpdatedAt.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: feedAuthorName(feed), URI: links.profile},
+		Links: []atomLink{
+			{Href: links.self, Rel: "self", Type: "application/atom+xml"},
+			{Href: links.profile, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: []atomEntry{},
+	}
+	if feed.AvatarURL != nil && *feed.AvatarURL != "" {
+		out.Icon = links.absolute(*feed.AvatarURL)
+	}
+	for _, item := range feed.Items {
+		entry := atomEntry{
+			ID:        links.post(item.Post.ID),
+			Title:     feedTitle(item),
+			Published: item.Post.CreatedAt.UTC().Format(time.RFC3339),
+			Updated:   item.Post.UpdatedAt.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Href: links.post(item.Post.ID), Rel: "alternate", Type: "text/html"}},
+			Content:   atomContent{Type: "text", Value: item.Post.Content},
+		}
+		for _, media := range feedMedia(item, links) {
+			entry.Links = append(entry.Links, atomLink{Href: media.URL, Rel: "enclosure", Type: media.Type})
+		}
+		for _, tag := range item.Post.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+
+	body, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description,omitempty"`
+	Icon        string           `json:"icon,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title"`
+	ContentText   string               `json:"content_text"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+}
+
+func encodeJSONFeed(feed *posts.ProfileFeed, links feedLinks) ([]byte, error) {
+	author := jsonFeedAuthor{Name: feedAuthorName(feed), URL: links.profile}
+	out := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feedAuthorName(feed),
+		HomePageURL: links.profile,
+		FeedURL:     links.self,
+		Items:       []jsonFeedItem{},
+	}
+	if feed.Bio != nil {
+		out.Description = *feed.Bio
+	}
+	if feed.AvatarURL != nil && *feed.AvatarURL != "" {
+		out.Icon = links.absolute(*feed.AvatarURL)
+		author.Avatar = out.Icon
+	}
+	out.Authors = []jsonFeedAuthor{author}
+
+	for _, item := range feed.Items {
+		entry := jsonFeedItem{
+			ID:            item.Post.ID,
+			URL:           links.post(item.Post.ID),
+			Title:         feedTitle(item),
+			ContentText:   item.Post.Content,
+			DatePublished: item.Post.CreatedAt.UTC().Format(time.RFC3339),
+			DateModified:  item.Post.UpdatedAt.UTC().Format(time.RFC3339),
+			Tags:          item.Post.Tags,
+		}
+		if item.Post.ImageURL != nil && *item.Post.ImageURL != "" {
+			entry.Image = links.absolute(*item.Post.ImageURL)
+		}
+		for _, media := range feedMedia(item, links) {
+			entry.Attachments = append(entry.Attachments, jsonFeedAttachment{URL: media.URL, MimeType: media.Type})
+		}
+		out.Items = append(out.Items, entry)
+	}
+
+	return json.MarshalIndent(out, "", "  ")
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/posts"
+	"github.com/bertoxic/graphqlChat/pkg/config"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const testPublicBaseURL = "https://chat.example"
+
+var feedUpdatedAt = time.Date(2024, 12, 20, 10, 30, 15, 0, time.UTC)
+
+type fakeFeeds map[string]*posts.ProfileFeed
+
+func (f fakeFeeds) GetProfileFeed(ctx context.Context, username string) (*posts.ProfileFeed, error) {
+	feed, ok := f[username]
+	if !ok {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "user not found", nil)
+	}
+	return feed, nil
+}
+
+func testFeed() *posts.ProfileFeed {
+	title := "Hello feeds"
+	image := "/media/alice/cover.png"
+	fullName := "Alice Liddell"
+	spoiler := posts.WarningSpoiler
+	return &posts.ProfileFeed{
+		UserID:    "user-alice",
+		Username:  "alice",
+		FullName:  &fullName,
+		UpdatedAt: feedUpdatedAt,
+		Items: []*posts.FeedItem{
+			{
+				Post: &posts.Post{ID: "post-1", Title: &title, Content: "first post", ImageURL: &image, Tags: []string{"go"}, CreatedAt: feedUpdatedAt, UpdatedAt: feedUpdatedAt},
+			},
+			{
+				Post:   &posts.Post{ID: "post-2", Content: "the ending", CreatedAt: feedUpdatedAt, UpdatedAt: feedUpdatedAt},
+				Labels: posts.ContentLabels{Category: &spoiler},
+				Attachments: []*posts.Attachment{
+					{MediaID: "media-1", MimeType: "image/jpeg", URL: "/media/alice/photo.jpg"},
+					{MediaID: "media-2", MimeType: "video/mp4", URL: "https://cdn.example/clip.mp4", Position: 1},
+				},
+			},
+		},
+	}
+}
+
+func newFeedRouter(baseURL string) http.Handler {
+	fr := NewFeedRepository(&config.AppConfig{PublicBaseURL: baseURL}, fakeFeeds{"alice": testFeed()})
+	mux := chi.NewRouter()
+	mux.Get("/users/{username}/feed.rss", fr.HandleRSSFeed)
+	mux.Get("/users/{username}/feed.atom", fr.HandleAtomFeed)
+	mux.Get("/users/{username}/feed.json", fr.HandleJSONFeed)
+	return mux
+}
+
+func getFeed(handler http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Host = "attacker.example"
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFeedTitle(t *testing.T) {
+	title := "  A title  "
+	blank := " "
+	spoiler := posts.WarningSpoiler
+	warning := "season finale"
+	long := strings.Repeat("word ", 30)
+
+	tests := []struct {
+		name string
+		item *posts.FeedItem
+		want string
+	}{
+		{name: "title", item: &posts.FeedItem{Post: &posts.Post{Title: &title, Content: "body"}}, want: "A title"},
+		{name: "blank title uses content", item: &posts.FeedItem{Post: &posts.Post{Title: &blank, Content: "line one\n\nline  two"}}, want: "line one line two"},
+		{name: "long content is cut", item: &posts.FeedItem{Post: &posts.Post{Content: long}}, want: string([]rune(strings.TrimSpace(long))[:feedTitleLength-1]) + "…"},
+		{name: "content warning", item: &posts.FeedItem{Post: &posts.Post{Content: "body"}, Labels: posts.ContentLabels{Category: &spoiler, Warning: &warning}}, want: "[CW spoiler: season finale] body"},
+		{name: "sensitive media", item: &posts.FeedItem{Post: &posts.Post{Content: "body"}, Labels: posts.ContentLabels{Category: &spoiler, IsSensitive: true}}, want: "[CW spoiler, sensitive media] body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, feedTitle(tt.item))
+		})
+	}
+}
+
+func TestFeedEncoders(t *testing.T) {
+	handler := newFeedRouter(testPublicBaseURL + "/")
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		contains    []string
+	}{
+		{
+			name:        "rss",
+			target:      "/users/alice/feed.rss",
+			contentType: "application/rss+xml; charset=utf-8",
+			contains: []string{
+				`<atom:link href="https://chat.example/users/alice/feed.rss" rel="self"`,
+				`<title>Alice Liddell (@alice)</title>`,
+				`<guid isPermaLink="true">https://chat.example/posts/post-1</guid>`,
+				`<enclosure url="https://chat.example/media/alice/cover.png" type="image/png" length="0">`,
+				`<enclosure url="https://chat.example/media/alice/photo.jpg" type="image/jpeg" length="0">`,
+				`<title>[CW spoiler] the ending</title>`,
+				`<category>go</category>`,
+			},
+		},
+		{
+			name:        "atom",
+			target:      "/users/alice/feed.atom",
+			contentType: "application/atom+xml; charset=utf-8",
+			contains: []string{
+				`<link href="https://chat.example/users/alice/feed.atom" rel="self" type="application/atom+xml">`,
+				`<id>https://chat.example/posts/post-1</id>`,
+				`<updated>2024-12-20T10:30:15Z</updated>`,
+				`<link href="https://chat.example/media/alice/photo.jpg" rel="enclosure" type="image/jpeg">`,
+				`<link href="https://cdn.example/clip.mp4" rel="enclosure" type="video/mp4">`,
+				`<category term="go">`,
+			},
+		},
+		{
+			name:        "json feed",
+			target:      "/users/alice/feed.json",
+			contentType: "application/feed+json; charset=utf-8",
+			contains: []string{
+				`"feed_url": "https://chat.example/users/alice/feed.json"`,
+				`"image": "https://chat.example/media/alice/cover.png"`,
+				`"url": "https://cdn.example/clip.mp4"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := getFeed(handler, tt.target, nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			require.NotEmpty(t, rec.Header().Get("ETag"))
+			require.Equal(t, feedUpdatedAt.Format(http.TimeFormat), rec.Header().Get("Last-Modified"))
+
+			body := rec.Body.String()
+			require.NotContains(t, body, "attacker.example")
+			for _, want := range tt.contains {
+				require.Contains(t, body, want)
+			}
+		})
+	}
+}
+
+func TestJSONFeed_Attachments(t *testing.T) {
+	rec := getFeed(newFeedRouter(testPublicBaseURL), "/users/alice/feed.json", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var feed jsonFeed
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &feed))
+	require.Len(t, feed.Items, 2)
+	require.Equal(t, []jsonFeedAttachment{
+		{URL: "https://chat.example/media/alice/photo.jpg", MimeType: "image/jpeg"},
+		{URL: "https://cdn.example/clip.mp4", MimeType: "video/mp4"},
+	}, feed.Items[1].Attachments)
+}
+
+func TestServeFeed_ConditionalRequests(t *testing.T) {
+	handler := newFeedRouter(testPublicBaseURL)
+	etag := getFeed(handler, "/users/alice/feed.atom", nil).Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{name: "unconditional", want: http.StatusOK},
+		{name: "matching etag", header: http.Header{"If-None-Match": {etag}}, want: http.StatusNotModified},
+		{name: "one of several etags", header: http.Header{"If-None-Match": {`"stale", ` + etag}}, want: http.StatusNotModified},
+		{name: "other etag", header: http.Header{"If-None-Match": {`"stale"`}}, want: http.StatusOK},
+		{name: "not modified since", header: http.Header{"If-Modified-Since": {feedUpdatedAt.Format(http.TimeFormat)}}, want: http.StatusNotModified},
+		{name: "modified since", header: http.Header{"If-Modified-Since": {feedUpdatedAt.Add(-time.Hour).Format(http.TimeFormat)}}, want: http.StatusOK},
+		// If-None-Match wins over If-Modified-Since
+		{name: "other etag, not modified since", header: http.Header{"If-None-Match": {`"stale"`}, "If-Modified-Since": {feedUpdatedAt.Format(http.TimeFormat)}}, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := getFeed(handler, "/users/alice/feed.atom", tt.header)
+			require.Equal(t, tt.want, rec.Code)
+			if tt.want == http.StatusNotModified {
+				require.Empty(t, rec.Body.String())
+				require.Equal(t, etag, rec.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestServeFeed_NotFound(t *testing.T) {
+	require.Equal(t, http.StatusNotFound, getFeed(newFeedRouter(testPublicBaseURL), "/users/bob/feed.rss", nil).Code)
+	// without a public base URL there is nothing to build links from
+	require.Equal(t, http.StatusNotFound, getFeed(newFeedRouter(""), "/users/alice/feed.rss", nil).Code)
+}
@@ -0,0 +1,225 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // registers the gif decoder with image.Decode
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	// maxImagePixels guards against small files that decode into huge images
+	maxImagePixels = 24_000_000
+	thumbnailSize  = 320
+	blurhashSize   = 32
+	jpegQuality    = 90
+	thumbQuality   = 80
+)
+
+type processedImage struct {
+	data      []byte
+	thumbnail []byte
+	width     int
+	height    int
+	blurhash  string
+}
+
+// processImage validates an uploaded image and re-encodes it, which drops EXIF and every
+// other metadata block. The EXIF orientation is applied to the pixels first so photos keep
+// their rotation. GIFs are kept as they are to preserve animation, they carry no EXIF.
+func processImage(data []byte, mimeType string) (*processedImage, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeInvalidFormat, "image could not be read", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("images can have at most %d pixels", maxImagePixels), nil)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeInvalidFormat, "image could not be read", err)
+	}
+
+	var out bytes.Buffer
+	switch mimeType {
+	case "image/jpeg":
+		img = applyOrientation(img, jpegOrientation(data))
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: jpegQuality})
+	case "image/png":
+		err = png.Encode(&out, img)
+	case "image/gif":
+		_, err = out.Write(data)
+	default:
+		return nil, errorx.New(errorx.ErrCodeUnsupportedMedia, fmt.Sprintf("unsupported image type %s", mimeType), nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error encoding image: %w", err)
+	}
+
+	thumb := resize(img, thumbnailSize)
+	var thumbOut bytes.Buffer
+	if err := jpeg.Encode(&thumbOut, thumb, &jpeg.Options{Quality: thumbQuality}); err != nil {
+		return nil, fmt.Errorf("error encoding thumbnail: %w", err)
+	}
+
+	bounds := img.Bounds()
+	return &processedImage{
+		data:      out.Bytes(),
+		thumbnail: thumbOut.Bytes(),
+		width:     bounds.Dx(),
+		height:    bounds.Dy(),
+		blurhash:  encodeBlurhash(resize(thumb, blurhashSize), blurhashComponentsX, blurhashComponentsY),
+	}, nil
+}
+
+// resize scales the image down so that its longer side is at most maxSide, averaging a
+// few samples per output pixel. Smaller images are only copied.
+func resize(img image.Image, maxSide int) *image.NRGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			tw, th = maxSide, max(1, h*maxSide/w)
+		} else {
+			tw, th = max(1, w*maxSide/h), maxSide
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	if tw == w && th == h {
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+		return dst
+	}
+
+	const samples = 4
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			var r, g, b, a uint32
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					px := bounds.Min.X + (x*samples+sx)*w/(tw*samples)
+					py := bounds.Min.Y + (y*samples+sy)*h/(th*samples)
+					c := color.NRGBAModel.Convert(img.At(px, py)).(color.NRGBA)
+					r += uint32(c.R)
+					g += uint32(c.G)
+					b += uint32(c.B)
+					a += uint32(c.A)
+				}
+			}
+			n := uint32(samples * samples)
+			dst.SetNRGBA(x, y, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+// jpegOrientation reads the EXIF orientation tag of a jpeg, 1 means upright
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// the image data starts at SOS, metadata segments all come before it
+		if marker == 0xDA {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// applyOrientation turns the pixels so that the image is upright for the given EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation == 1 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	src := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	// orientations 5 to 8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.SetNRGBA(dx, dy, src.NRGBAAt(x, y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,82 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// jpegWithOrientation encodes a w x h jpeg and inserts an EXIF segment carrying the orientation
+func jpegWithOrientation(t *testing.T, w, h int, orientation uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / w), G: 80, B: uint8(y * 255 / h), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+
+	// little endian tiff header with a single IFD entry for the orientation
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry[0:], 0x0112)
+	binary.LittleEndian.PutUint16(entry[2:], 3)
+	binary.LittleEndian.PutUint32(entry[4:], 1)
+	binary.LittleEndian.PutUint16(entry[8:], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	data := buf.Bytes()
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessImage_StripsExifAndAppliesOrientation(t *testing.T) {
+	data := jpegWithOrientation(t, 64, 32, 6)
+	require.Equal(t, 6, jpegOrientation(data))
+
+	img, err := processImage(data, "image/jpeg")
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(img.data, []byte("Exif")))
+	require.Equal(t, 1, jpegOrientation(img.data))
+
+	// orientation 6 is a quarter turn, so width and height swap
+	require.Equal(t, 32, img.width)
+	require.Equal(t, 64, img.height)
+
+	thumb, err := jpeg.Decode(bytes.NewReader(img.thumbnail))
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 32, 64), thumb.Bounds())
+
+	// size flag, maximum AC, 4 characters of DC and 2 per AC component
+	require.Len(t, img.blurhash, 2+4+2*(blurhashComponentsX*blurhashComponentsY-1))
+}
+
+func TestProcessImage_RejectsInvalidImages(t *testing.T) {
+	_, err := processImage([]byte("not an image"), "image/png")
+	require.Error(t, err)
+}
+
+func TestEncodeBlurhash_SolidColour(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	hash := encodeBlurhash(img, 4, 3)
+	// 4x3 components and pure red as the average colour
+	require.Equal(t, "L", hash[:1])
+	require.Equal(t, encodeBase83(255<<16, 4), hash[2:6])
+}
@@ -0,0 +1,214 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindImage Kind = "IMAGE"
+	KindAudio Kind = "AUDIO"
+	KindVideo Kind = "VIDEO"
+)
+
+// allowed upload types, detected from the file content rather than trusted from the client
+var mimeTypes = map[string]struct {
+	kind Kind
+	ext  string
+}{
+	"image/jpeg":      {KindImage, ".jpg"},
+	"image/png":       {KindImage, ".png"},
+	"image/gif":       {KindImage, ".gif"},
+	"audio/mpeg":      {KindAudio, ".mp3"},
+	"audio/wave":      {KindAudio, ".wav"},
+	"application/ogg": {KindAudio, ".ogg"},
+	"video/mp4":       {KindVideo, ".mp4"},
+	"video/webm":      {KindVideo, ".webm"},
+}
+
+// maximum size of a single upload per kind
+var maxSizes = map[Kind]int64{
+	KindImage: 10 << 20,
+	KindAudio: 20 << 20,
+	KindVideo: 50 << 20,
+}
+
+const (
+	// MaxFilesPerUpload is the number of files accepted by one upload request
+	MaxFilesPerUpload = 4
+	// MaxRequestSize bounds a multipart request carrying MaxFilesPerUpload of the largest files
+	MaxRequestSize = MaxFilesPerUpload*(50<<20) + 1<<20
+)
+
+type Media struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	Kind         Kind      `json:"kind"`
+	MimeType     string    `json:"mime_type"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	ThumbnailURL *string   `json:"thumbnail_url,omitempty"`
+	Width        *int      `json:"width,omitempty"`
+	Height       *int      `json:"height,omitempty"`
+	Blurhash     *string   `json:"blurhash,omitempty"` // Placeholder shown while an image loads
+	StorageKey   string    `json:"-"`
+	ThumbnailKey *string   `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UploadFile is a file received from a client
+type UploadFile struct {
+	File     io.Reader
+	Filename string
+	Size     int64
+}
+
+type MediaService interface {
+	Upload(ctx context.Context, userID string, files []UploadFile) ([]*Media, error)
+}
+
+type MediaServiceImpl struct {
+	DB      database.DatabaseRepo
+	Storage Storage
+}
+
+func NewMediaServiceImpl(db database.DatabaseRepo, storage Storage) *MediaServiceImpl {
+	return &MediaServiceImpl{DB: db, Storage: storage}
+}
+
+// Upload validates and stores the files, returning them in the same order. Images are
+// stripped of metadata and get a thumbnail and blurhash. Files stored before a later
+// file fails are removed again so a failed upload leaves nothing behind.
+func (ms *MediaServiceImpl) Upload(ctx context.Context, userID string, files []UploadFile) ([]*Media, error) {
+	if len(files) == 0 {
+		return nil, errorx.New(errorx.ErrCodeMissingField, "no files were uploaded", nil)
+	}
+	if len(files) > MaxFilesPerUpload {
+		return nil, errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("at most %d files can be uploaded at once", MaxFilesPerUpload), nil)
+	}
+
+	var stored []string
+	uploaded := make([]*Media, 0, len(files))
+	for _, file := range files {
+		media, keys, err := ms.store(ctx, userID, file)
+		stored = append(stored, keys...)
+		if err != nil {
+			ms.cleanup(stored)
+			return nil, err
+		}
+		uploaded = append(uploaded, media)
+	}
+
+	if err := ms.insertMedia(ctx, uploaded); err != nil {
+		ms.cleanup(stored)
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to save media", err)
+	}
+	return uploaded, nil
+}
+
+// store checks a single file and writes it, with its thumbnail, to the storage
+func (ms *MediaServiceImpl) store(ctx context.Context, userID string, file UploadFile) (*Media, []string, error) {
+	// the declared size is only a hint, the limit is enforced on what is actually read
+	data, err := io.ReadAll(io.LimitReader(file.File, maxSizes[KindVideo]+1))
+	if err != nil {
+		return nil, nil, errorx.New(errorx.ErrCodeBadRequest, "failed to read upload", err)
+	}
+
+	mimeType := http.DetectContentType(data)
+	info, ok := mimeTypes[mimeType]
+	if !ok {
+		return nil, nil, errorx.New(errorx.ErrCodeUnsupportedMedia, fmt.Sprintf("%s has an unsupported file type %s", file.Filename, mimeType), nil)
+	}
+	if mimeType == "application/ogg" {
+		mimeType = "audio/ogg"
+	}
+	if int64(len(data)) > maxSizes[info.kind] {
+		return nil, nil, errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("%s is larger than %d MB", file.Filename, maxSizes[info.kind]>>20), nil)
+	}
+
+	id := uuid.NewString()
+	media := &Media{
+		ID:        id,
+		UserID:    userID,
+		Kind:      info.kind,
+		MimeType:  mimeType,
+		CreatedAt: time.Now(),
+	}
+	key := fmt.Sprintf("%s/%s%s", userID, id, info.ext)
+
+	var keys []string
+	if info.kind == KindImage {
+		img, err := processImage(data, mimeType)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = img.data
+		media.Width, media.Height, media.Blurhash = &img.width, &img.height, &img.blurhash
+
+		thumbKey := fmt.Sprintf("%s/%s_thumb.jpg", userID, id)
+		if err := ms.Storage.Save(ctx, thumbKey, bytes.NewReader(img.thumbnail)); err != nil {
+			return nil, nil, fmt.Errorf("error storing thumbnail: %w", err)
+		}
+		keys = append(keys, thumbKey)
+		thumbURL := ms.Storage.URL(thumbKey)
+		media.ThumbnailKey, media.ThumbnailURL = &thumbKey, &thumbURL
+	}
+
+	if err := ms.Storage.Save(ctx, key, bytes.NewReader(data)); err != nil {
+		return nil, keys, fmt.Errorf("error storing media: %w", err)
+	}
+	keys = append(keys, key)
+	media.Size = int64(len(data))
+	media.StorageKey, media.URL = key, ms.Storage.URL(key)
+
+	return media, keys, nil
+}
+
+func (ms *MediaServiceImpl) cleanup(keys []string) {
+	for _, key := range keys {
+		// the request context may be cancelled already, the files still need to go
+		_ = ms.Storage.Delete(context.Background(), key)
+	}
+}
+
+func (ms *MediaServiceImpl) insertMedia(ctx context.Context, uploaded []*Media) error {
+	db, ok := ms.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("ms.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+        INSERT INTO media (id, user_id, kind, mime_type, size_bytes, storage_key, url, thumbnail_key, thumbnail_url, width, height, blurhash, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+    `
+	for _, m := range uploaded {
+		_, err = tx.Exec(ctx, query,
+			m.ID, m.UserID, m.Kind, m.MimeType, m.Size, m.StorageKey, m.URL, m.ThumbnailKey, m.ThumbnailURL,
+			m.Width, m.Height, m.Blurhash, m.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("error inserting media: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
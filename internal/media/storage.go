@@ -0,0 +1,129 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage keeps uploaded files. Keys are slash separated paths chosen by the media service.
+type Storage interface {
+	Save(ctx context.Context, key string, r io.Reader) error
+	Delete(ctx context.Context, key string) error
+	// URL returns the address clients use to fetch the file stored under key
+	URL(key string) string
+}
+
+// LocalStorage stores files on the local disk under Root and serves them below BaseURL
+type LocalStorage struct {
+	Root    string
+	BaseURL string
+}
+
+func NewLocalStorage(root string, baseURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating media directory: %w", err)
+	}
+	return &LocalStorage{Root: root, BaseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+// path maps a key to a file below Root, refusing keys that would escape it
+func (ls *LocalStorage) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(ls.Root, filepath.FromSlash(clean)), nil
+}
+
+// Save writes the file to a temporary name first so readers never see a partial upload
+func (ls *LocalStorage) Save(ctx context.Context, key string, r io.Reader) error {
+	dest, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("error creating media directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("error creating media file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing media file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing media file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("error writing media file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("error storing media file: %w", err)
+	}
+	return nil
+}
+
+func (ls *LocalStorage) Delete(ctx context.Context, key string) error {
+	dest, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error deleting media file: %w", err)
+	}
+	return nil
+}
+
+func (ls *LocalStorage) URL(key string) string {
+	return ls.BaseURL + "/" + strings.TrimLeft(key, "/")
+}
+
+// ServePath returns the path on this server that the files under baseURL are requested
+// from, without the trailing slash. It is empty when baseURL has no path of its own, files
+// are not served from the root of the site.
+func ServePath(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(path.Clean("/"+u.Path), "/")
+}
+
+// FileServer serves the files stored below root. Directories are answered with 404 rather
+// than listed.
+func FileServer(root string) http.Handler {
+	return http.FileServer(filesOnly{http.Dir(root)})
+}
+
+// filesOnly hides the directories of a file system
+type filesOnly struct {
+	fs http.FileSystem
+}
+
+func (f filesOnly) Open(name string) (http.File, error) {
+	file, err := f.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, os.ErrNotExist
+	}
+	return file, nil
+}
@@ -0,0 +1,40 @@
+package media
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServePath(t *testing.T) {
+	require.Equal(t, "/media", ServePath("/media"))
+	require.Equal(t, "/media", ServePath("/media/"))
+	require.Equal(t, "/static/uploads", ServePath("https://chat.example/static/uploads/"))
+	require.Equal(t, "", ServePath("https://cdn.example"))
+	require.Equal(t, "", ServePath("/"))
+}
+
+func TestFileServer_RefusesDirectories(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "user-1"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "user-1", "photo.jpg"), []byte("jpeg"), 0o644))
+	server := http.StripPrefix("/media/", FileServer(root))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/media/user-1/photo.jpg")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "jpeg", rec.Body.String())
+
+	for _, target := range []string{"/media/", "/media/user-1", "/media/user-1/", "/media/missing.jpg", "/media/../storage.go"} {
+		require.Equal(t, http.StatusNotFound, get(target).Code, target)
+	}
+}
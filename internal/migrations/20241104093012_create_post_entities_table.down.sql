@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_username_lower;
+DROP INDEX IF EXISTS idx_post_entities_post_id;
+DROP INDEX IF EXISTS idx_post_mentions_user_id;
+DROP TABLE IF EXISTS post_entities;
+DROP TABLE IF EXISTS post_mentions;
@@ -0,0 +1,23 @@
+-- Create post_mentions table
+CREATE TABLE IF NOT EXISTS post_mentions (
+                                             post_id UUID NOT NULL REFERENCES posts(id),
+                                             user_id UUID NOT NULL REFERENCES users(id),
+                                             created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                             PRIMARY KEY (post_id, user_id)
+);
+
+-- Create post_entities table, offsets are rune positions inside posts.content
+CREATE TABLE IF NOT EXISTS post_entities (
+                                             id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                             post_id UUID NOT NULL REFERENCES posts(id),
+                                             type VARCHAR(20) NOT NULL,
+                                             text TEXT NOT NULL,
+                                             start_offset INT NOT NULL,
+                                             end_offset INT NOT NULL,
+                                             user_id UUID REFERENCES users(id),
+                                             created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_post_mentions_user_id ON post_mentions(user_id);
+CREATE INDEX idx_post_entities_post_id ON post_entities(post_id);
+CREATE INDEX idx_users_username_lower ON users(lower(username));
@@ -0,0 +1,13 @@
+-- Create post_revisions table, every saved version of a post including the original
+CREATE TABLE IF NOT EXISTS post_revisions (
+                                              id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                              post_id UUID NOT NULL REFERENCES posts(id),
+                                              editor_id UUID NOT NULL REFERENCES users(id),
+                                              title VARCHAR(255),
+                                              content TEXT NOT NULL,
+                                              image_url TEXT,
+                                              audio_url TEXT,
+                                              created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_post_revisions_post_id ON post_revisions(post_id, created_at);
@@ -0,0 +1,4 @@
+-- Scheduled drafts are published by the draft scheduler once publish_at has passed
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP;
+
+CREATE INDEX idx_posts_scheduled_drafts ON posts(publish_at) WHERE is_draft = TRUE AND publish_at IS NOT NULL;
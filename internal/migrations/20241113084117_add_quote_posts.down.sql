@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_blocked_users_blocked_id;
+DROP TABLE IF EXISTS blocked_users;
+DROP INDEX IF EXISTS idx_posts_quoted_post_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS quote_count;
+ALTER TABLE posts DROP COLUMN IF EXISTS quoted_post_id;
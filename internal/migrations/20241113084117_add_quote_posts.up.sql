@@ -0,0 +1,15 @@
+-- Quote posts reference the post they quote, the original keeps a count of its quotes
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS quoted_post_id UUID REFERENCES posts(id);
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS quote_count INT NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_posts_quoted_post_id ON posts(quoted_post_id);
+
+-- Create blocked_users table
+CREATE TABLE IF NOT EXISTS blocked_users (
+                                             blocker_id UUID NOT NULL REFERENCES users(id),
+                                             blocked_id UUID NOT NULL REFERENCES users(id),
+                                             created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                             PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX idx_blocked_users_blocked_id ON blocked_users(blocked_id);
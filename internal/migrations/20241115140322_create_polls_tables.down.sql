@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_polls_pending_close;
+DROP TABLE IF EXISTS poll_votes;
+DROP TABLE IF EXISTS poll_options;
+DROP TABLE IF EXISTS polls;
@@ -0,0 +1,31 @@
+-- Create polls table, at most one poll per post
+CREATE TABLE IF NOT EXISTS polls (
+                                     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                     post_id UUID NOT NULL UNIQUE REFERENCES posts(id),
+                                     multiple_choice BOOLEAN NOT NULL DEFAULT FALSE,
+                                     closes_at TIMESTAMP NOT NULL,
+                                     close_notified BOOLEAN NOT NULL DEFAULT FALSE,
+                                     created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create poll_options table
+CREATE TABLE IF NOT EXISTS poll_options (
+                                            id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                            poll_id UUID NOT NULL REFERENCES polls(id),
+                                            position INT NOT NULL,
+                                            text VARCHAR(100) NOT NULL,
+                                            vote_count INT NOT NULL DEFAULT 0,
+                                            UNIQUE (poll_id, position)
+);
+
+-- Create poll_votes table, one ballot per user holding every option they chose
+CREATE TABLE IF NOT EXISTS poll_votes (
+                                          poll_id UUID NOT NULL REFERENCES polls(id),
+                                          user_id UUID NOT NULL REFERENCES users(id),
+                                          option_ids UUID[] NOT NULL,
+                                          created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                          updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                          PRIMARY KEY (poll_id, user_id)
+);
+
+CREATE INDEX idx_polls_pending_close ON polls(closes_at) WHERE close_notified = FALSE;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_parent_thread;
+ALTER TABLE posts ALTER COLUMN comment_count DROP NOT NULL;
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
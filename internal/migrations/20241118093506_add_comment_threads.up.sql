@@ -0,0 +1,9 @@
+-- Deleted posts keep their row so replies below them stay attached, they are shown as placeholders
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- comment_count holds the number of live direct replies
+UPDATE posts p
+SET comment_count = (SELECT COUNT(*) FROM posts c WHERE c.parent_id = p.id AND c.is_draft = FALSE);
+ALTER TABLE posts ALTER COLUMN comment_count SET NOT NULL;
+
+CREATE INDEX idx_posts_parent_thread ON posts(parent_id, created_at, id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_user_pinned;
+ALTER TABLE posts ALTER COLUMN is_pinned DROP NOT NULL;
+ALTER TABLE posts DROP COLUMN IF EXISTS pinned_at;
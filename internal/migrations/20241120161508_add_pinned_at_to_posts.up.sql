@@ -0,0 +1,6 @@
+-- pinned_at orders the pinned posts of a user, the most recently pinned first
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMP;
+UPDATE posts SET is_pinned = FALSE WHERE is_pinned IS NULL;
+ALTER TABLE posts ALTER COLUMN is_pinned SET NOT NULL;
+
+CREATE INDEX idx_posts_user_pinned ON posts(user_id, pinned_at DESC) WHERE is_pinned = TRUE;
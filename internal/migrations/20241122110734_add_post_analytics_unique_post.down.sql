@@ -0,0 +1,3 @@
+ALTER TABLE posts ALTER COLUMN view_count DROP NOT NULL;
+DROP INDEX IF EXISTS idx_post_analytics_post_id;
+CREATE INDEX idx_post_analytics_post_id ON post_analytics(post_id);
@@ -0,0 +1,10 @@
+-- post_analytics holds one row per post, kept up to date by the view flusher
+DELETE FROM post_analytics a
+USING post_analytics b
+WHERE a.post_id = b.post_id AND a.updated_at < b.updated_at;
+
+DROP INDEX IF EXISTS idx_post_analytics_post_id;
+CREATE UNIQUE INDEX idx_post_analytics_post_id ON post_analytics(post_id);
+
+UPDATE posts SET view_count = 0 WHERE view_count IS NULL;
+ALTER TABLE posts ALTER COLUMN view_count SET NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_daily_stats;
+DROP TABLE IF EXISTS post_daily_stats;
@@ -0,0 +1,32 @@
+-- Create post_daily_stats table, views are added by the view flusher and likes by the creator stats job
+CREATE TABLE IF NOT EXISTS post_daily_stats (
+                                                post_id UUID NOT NULL REFERENCES posts(id),
+                                                user_id UUID NOT NULL REFERENCES users(id),
+                                                day DATE NOT NULL,
+                                                views INT NOT NULL DEFAULT 0,
+                                                unique_views INT NOT NULL DEFAULT 0,
+                                                likes INT NOT NULL DEFAULT 0,
+                                                PRIMARY KEY (post_id, day)
+);
+
+-- Create user_daily_stats table, filled by the creator stats job
+CREATE TABLE IF NOT EXISTS user_daily_stats (
+                                                user_id UUID NOT NULL REFERENCES users(id),
+                                                day DATE NOT NULL,
+                                                new_followers INT NOT NULL DEFAULT 0,
+                                                PRIMARY KEY (user_id, day)
+);
+
+CREATE INDEX idx_post_daily_stats_user_day ON post_daily_stats(user_id, day);
+
+-- likes and follows carry their own timestamps so past days can be filled in, views before this point are lost
+INSERT INTO post_daily_stats (post_id, user_id, day, likes)
+SELECT pl.post_id, p.user_id, pl.created_at::date, COUNT(*)
+FROM post_likes pl
+JOIN posts p ON p.id = pl.post_id
+GROUP BY pl.post_id, p.user_id, pl.created_at::date;
+
+INSERT INTO user_daily_stats (user_id, day, new_followers)
+SELECT followed_id, created_at::date, COUNT(*)
+FROM follows
+GROUP BY followed_id, created_at::date;
@@ -0,0 +1,29 @@
+-- Create media table, files uploaded by users before they are attached to posts
+CREATE TABLE IF NOT EXISTS media (
+                                     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                     user_id UUID NOT NULL REFERENCES users(id),
+                                     kind VARCHAR(10) NOT NULL,
+                                     mime_type VARCHAR(50) NOT NULL,
+                                     size_bytes BIGINT NOT NULL,
+                                     storage_key TEXT NOT NULL,
+                                     url TEXT NOT NULL,
+                                     thumbnail_key TEXT,
+                                     thumbnail_url TEXT,
+                                     width INT,
+                                     height INT,
+                                     blurhash VARCHAR(64),
+                                     created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create post_attachments table, the media shown with a post in order
+CREATE TABLE IF NOT EXISTS post_attachments (
+                                                post_id UUID NOT NULL REFERENCES posts(id),
+                                                media_id UUID NOT NULL REFERENCES media(id),
+                                                position INT NOT NULL,
+                                                alt_text VARCHAR(1000),
+                                                PRIMARY KEY (post_id, media_id),
+                                                UNIQUE (post_id, position)
+);
+
+CREATE INDEX idx_media_user_id ON media(user_id);
+CREATE INDEX idx_post_attachments_media_id ON post_attachments(media_id);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_follows_pending;
+DROP INDEX IF EXISTS idx_close_friends_friend_id;
+DROP INDEX IF EXISTS idx_posts_audience_post_id;
+
+DELETE FROM follows WHERE status <> 'ACCEPTED';
+ALTER TABLE follows DROP COLUMN IF EXISTS status;
+
+DROP TABLE IF EXISTS close_friends;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS audience_post_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS audience;
@@ -0,0 +1,28 @@
+-- audience limits who can read a post, replies and reposts follow the audience of audience_post_id
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS audience VARCHAR(20) NOT NULL DEFAULT 'PUBLIC';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS audience_post_id UUID REFERENCES posts(id);
+
+-- replies and reposts are governed by the top of their thread
+WITH RECURSIVE roots AS (
+    SELECT id, id AS root_id FROM posts WHERE parent_id IS NULL
+    UNION ALL
+    SELECT p.id, r.root_id FROM posts p JOIN roots r ON p.parent_id = r.id
+)
+UPDATE posts SET audience_post_id = roots.root_id
+FROM roots
+WHERE posts.id = roots.id AND posts.parent_id IS NOT NULL;
+
+-- Create close_friends table, the list is private to its owner
+CREATE TABLE IF NOT EXISTS close_friends (
+                                             user_id UUID NOT NULL REFERENCES users(id),
+                                             friend_id UUID NOT NULL REFERENCES users(id),
+                                             created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                             PRIMARY KEY (user_id, friend_id)
+);
+
+-- follows of private accounts wait for approval, existing follows stay accepted
+ALTER TABLE follows ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'ACCEPTED';
+
+CREATE INDEX idx_posts_audience_post_id ON posts(audience_post_id);
+CREATE INDEX idx_close_friends_friend_id ON close_friends(friend_id);
+CREATE INDEX idx_follows_pending ON follows(followed_id, created_at DESC) WHERE status = 'PENDING';
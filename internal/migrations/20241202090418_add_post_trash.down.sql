@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+DROP INDEX IF EXISTS idx_posts_user_deleted;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS purged_at;
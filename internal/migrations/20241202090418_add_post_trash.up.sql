@@ -0,0 +1,6 @@
+-- purged_at marks deleted posts whose content was removed after the trash window,
+-- they stay as placeholders until nothing references them
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS purged_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_posts_user_deleted ON posts(user_id, deleted_at DESC) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_bookmarks_collection_page;
+
+ALTER TABLE bookmarks DROP COLUMN IF EXISTS note;
+ALTER TABLE bookmarks DROP COLUMN IF EXISTS collection_id;
+
+DROP TABLE IF EXISTS bookmark_collections;
@@ -0,0 +1,29 @@
+-- Create bookmark_collections table, every user with bookmarks has exactly one default collection
+CREATE TABLE IF NOT EXISTS bookmark_collections (
+                                                    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                                    user_id UUID NOT NULL REFERENCES users(id),
+                                                    name VARCHAR(100) NOT NULL,
+                                                    position INT NOT NULL,
+                                                    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+                                                    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                                    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_bookmark_collections_default ON bookmark_collections(user_id) WHERE is_default = TRUE;
+CREATE INDEX idx_bookmark_collections_user_position ON bookmark_collections(user_id, position);
+
+-- a post is bookmarked at most once per user, so a bookmark lives in a single collection
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS collection_id UUID REFERENCES bookmark_collections(id);
+ALTER TABLE bookmarks ADD COLUMN IF NOT EXISTS note TEXT;
+
+-- existing bookmarks move to their owner's default collection
+INSERT INTO bookmark_collections (user_id, name, position, is_default)
+SELECT DISTINCT user_id, 'Saved', 0, TRUE FROM bookmarks;
+
+UPDATE bookmarks b SET collection_id = c.id
+FROM bookmark_collections c
+WHERE c.user_id = b.user_id AND c.is_default = TRUE;
+
+ALTER TABLE bookmarks ALTER COLUMN collection_id SET NOT NULL;
+
+CREATE INDEX idx_bookmarks_collection_page ON bookmarks(collection_id, created_at DESC, id DESC);
@@ -0,0 +1,8 @@
+-- search_vector is recomputed by postgres on every write, titles weigh more than content
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(content, '')), 'B')
+    ) STORED;
+
+CREATE INDEX idx_posts_search_vector ON posts USING GIN (search_vector);
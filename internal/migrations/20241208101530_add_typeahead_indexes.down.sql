@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tags_name_prefix;
+DROP INDEX IF EXISTS idx_users_full_name_prefix;
+DROP INDEX IF EXISTS idx_users_username_prefix;
@@ -0,0 +1,4 @@
+-- type-ahead search matches with LIKE prefix%, text_pattern_ops lets it use an index under any collation
+CREATE INDEX IF NOT EXISTS idx_users_username_prefix ON users (lower(username) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_users_full_name_prefix ON users (lower(full_name) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_tags_name_prefix ON tags (name text_pattern_ops);
@@ -0,0 +1,5 @@
+-- fuzzy user search ranks by trigram similarity, these indexes serve the % and <% operators
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING GIN (lower(username) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_full_name_trgm ON users USING GIN (lower(coalesce(full_name, '')) gin_trgm_ops);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_user_created;
+
+DROP TABLE IF EXISTS user_list_followers;
+DROP TABLE IF EXISTS user_list_members;
+DROP TABLE IF EXISTS user_lists;
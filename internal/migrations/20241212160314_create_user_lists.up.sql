@@ -0,0 +1,34 @@
+-- Create user_lists table, curated sets of accounts with their own timeline
+CREATE TABLE IF NOT EXISTS user_lists (
+                                          id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                          owner_id UUID NOT NULL REFERENCES users(id),
+                                          name VARCHAR(100) NOT NULL,
+                                          description VARCHAR(280),
+                                          is_private BOOLEAN NOT NULL DEFAULT FALSE,
+                                          created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                          updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_user_lists_owner ON user_lists(owner_id, created_at DESC);
+
+-- membership is kept apart from follows, listing someone doesn't follow them
+CREATE TABLE IF NOT EXISTS user_list_members (
+                                                 list_id UUID NOT NULL REFERENCES user_lists(id),
+                                                 user_id UUID NOT NULL REFERENCES users(id),
+                                                 created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                                 PRIMARY KEY (list_id, user_id)
+);
+
+CREATE INDEX idx_user_list_members_user_id ON user_list_members(user_id);
+
+CREATE TABLE IF NOT EXISTS user_list_followers (
+                                                   list_id UUID NOT NULL REFERENCES user_lists(id),
+                                                   user_id UUID NOT NULL REFERENCES users(id),
+                                                   created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                                   PRIMARY KEY (list_id, user_id)
+);
+
+CREATE INDEX idx_user_list_followers_user ON user_list_followers(user_id, created_at DESC);
+
+-- list timelines read the newest posts of each member
+CREATE INDEX IF NOT EXISTS idx_posts_user_created ON posts(user_id, created_at DESC) WHERE deleted_at IS NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_repost_of_created;
+DROP INDEX IF EXISTS idx_posts_one_repost_per_user;
+
+UPDATE posts SET parent_id = repost_of_id WHERE repost_of_id IS NOT NULL;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS repost_of_id;
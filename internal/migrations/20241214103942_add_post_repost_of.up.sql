@@ -0,0 +1,39 @@
+-- reposts point at the original with repost_of_id, parent_id is left to replies
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS repost_of_id UUID REFERENCES posts(id);
+
+-- reposts used to share parent_id with replies, they copied the original so the content tells them apart
+UPDATE posts r SET repost_of_id = r.parent_id, parent_id = NULL
+FROM posts o
+WHERE r.parent_id = o.id AND r.quoted_post_id IS NULL
+  AND r.content = o.content AND r.title IS NOT DISTINCT FROM o.title
+  AND r.image_url IS NOT DISTINCT FROM o.image_url AND r.audio_url IS NOT DISTINCT FROM o.audio_url;
+
+-- reposts of reposts now point at the original
+UPDATE posts r SET repost_of_id = o.repost_of_id
+FROM posts o
+WHERE r.repost_of_id = o.id AND o.repost_of_id IS NOT NULL;
+
+-- a user keeps only their first repost of a post, later ones go to the trash
+UPDATE posts SET deleted_at = NOW()
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, repost_of_id ORDER BY created_at, id) AS rn
+        FROM posts
+        WHERE repost_of_id IS NOT NULL AND deleted_at IS NULL
+    ) d
+    WHERE rn > 1
+);
+
+CREATE UNIQUE INDEX idx_posts_one_repost_per_user ON posts(repost_of_id, user_id) WHERE repost_of_id IS NOT NULL AND deleted_at IS NULL;
+CREATE INDEX idx_posts_repost_of_created ON posts(repost_of_id, created_at DESC, id DESC) WHERE repost_of_id IS NOT NULL;
+
+-- counters of originals and of the posts reposts were wrongly counted as replies of
+UPDATE posts p SET reposts = (
+    SELECT COUNT(*) FROM posts r WHERE r.repost_of_id = p.id AND r.deleted_at IS NULL
+)
+WHERE p.reposts <> 0 OR EXISTS (SELECT 1 FROM posts r WHERE r.repost_of_id = p.id);
+
+UPDATE posts p SET comment_count = (
+    SELECT COUNT(*) FROM posts c WHERE c.parent_id = p.id AND c.is_draft = FALSE AND c.deleted_at IS NULL
+)
+WHERE EXISTS (SELECT 1 FROM posts r WHERE r.repost_of_id = p.id);
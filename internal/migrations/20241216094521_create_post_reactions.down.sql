@@ -0,0 +1,12 @@
+ALTER TABLE posts DROP COLUMN IF EXISTS reaction_counts;
+
+DROP INDEX IF EXISTS idx_post_reactions_post_kind_created;
+DROP INDEX IF EXISTS idx_post_reactions_post_created;
+ALTER INDEX IF EXISTS idx_post_reactions_user_id RENAME TO idx_post_likes_user_id;
+
+-- only likes fit the old table
+DELETE FROM post_reactions WHERE kind <> 'LIKE';
+ALTER TABLE post_reactions DROP COLUMN IF EXISTS kind;
+ALTER TABLE post_reactions RENAME CONSTRAINT post_reactions_pkey TO post_likes_pkey;
+ALTER TABLE post_reactions RENAME TO post_likes;
+CREATE INDEX idx_post_likes_post_id ON post_likes(post_id);
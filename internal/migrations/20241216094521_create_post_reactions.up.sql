@@ -0,0 +1,22 @@
+-- likes become the LIKE reaction, a user keeps at most one reaction per post
+ALTER TABLE post_likes RENAME TO post_reactions;
+ALTER TABLE post_reactions RENAME CONSTRAINT post_likes_pkey TO post_reactions_pkey;
+ALTER TABLE post_reactions ADD COLUMN kind VARCHAR(16) NOT NULL DEFAULT 'LIKE'
+    CHECK (kind IN ('LIKE', 'LOVE', 'LAUGH', 'WOW', 'SAD', 'ANGRY'));
+ALTER TABLE post_reactions ALTER COLUMN kind DROP DEFAULT;
+
+DROP INDEX IF EXISTS idx_post_likes_post_id;
+ALTER INDEX IF EXISTS idx_post_likes_user_id RENAME TO idx_post_reactions_user_id;
+CREATE INDEX idx_post_reactions_post_created ON post_reactions(post_id, created_at DESC, user_id DESC);
+CREATE INDEX idx_post_reactions_post_kind_created ON post_reactions(post_id, kind, created_at DESC, user_id DESC);
+
+-- per kind counters, likes keeps counting the LIKE reactions
+ALTER TABLE posts ADD COLUMN reaction_counts JSONB NOT NULL DEFAULT '{}';
+
+UPDATE posts p SET reaction_counts = c.counts
+FROM (
+    SELECT post_id, jsonb_object_agg(kind, n) AS counts
+    FROM (SELECT post_id, kind, COUNT(*) AS n FROM post_reactions GROUP BY post_id, kind) k
+    GROUP BY post_id
+) c
+WHERE c.post_id = p.id;
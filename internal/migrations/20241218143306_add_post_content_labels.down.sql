@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS post_label_changes;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_moderator;
+ALTER TABLE users DROP COLUMN IF EXISTS content_filter;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS labeled_by_moderator;
+ALTER TABLE posts DROP COLUMN IF EXISTS is_sensitive;
+ALTER TABLE posts DROP COLUMN IF EXISTS content_warning;
+ALTER TABLE posts DROP COLUMN IF EXISTS content_warning_category;
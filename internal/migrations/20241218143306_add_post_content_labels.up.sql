@@ -0,0 +1,28 @@
+-- content warnings set by the author or a moderator, once a moderator labels a post only moderators can change its labels
+ALTER TABLE posts ADD COLUMN content_warning_category VARCHAR(16)
+    CHECK (content_warning_category IN ('SPOILER', 'VIOLENCE', 'NUDITY', 'SELF_HARM', 'DISTURBING', 'OTHER'));
+ALTER TABLE posts ADD COLUMN content_warning TEXT;
+ALTER TABLE posts ADD COLUMN is_sensitive BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE posts ADD COLUMN labeled_by_moderator BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- what the user wants done with labeled posts of others
+ALTER TABLE users ADD COLUMN content_filter VARCHAR(8) NOT NULL DEFAULT 'BLUR'
+    CHECK (content_filter IN ('HIDE', 'BLUR', 'SHOW'));
+ALTER TABLE users ADD COLUMN is_moderator BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Create post_label_changes table, every change to the labels of a post with the values before and after
+CREATE TABLE IF NOT EXISTS post_label_changes (
+                                                  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                                  post_id UUID NOT NULL REFERENCES posts(id),
+                                                  actor_id UUID NOT NULL REFERENCES users(id),
+                                                  by_moderator BOOLEAN NOT NULL,
+                                                  old_category VARCHAR(16),
+                                                  old_warning TEXT,
+                                                  old_sensitive BOOLEAN NOT NULL,
+                                                  new_category VARCHAR(16),
+                                                  new_warning TEXT,
+                                                  new_sensitive BOOLEAN NOT NULL,
+                                                  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_post_label_changes_post_created ON post_label_changes(post_id, created_at DESC);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS federation_deliveries;
+
+DROP INDEX IF EXISTS idx_posts_federation_pending;
+ALTER TABLE posts DROP COLUMN IF EXISTS federated_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS ap_id;
+
+DROP TABLE IF EXISTS actor_keys;
+
+ALTER TABLE users DROP COLUMN IF EXISTS shared_inbox_url;
+ALTER TABLE users DROP COLUMN IF EXISTS inbox_url;
+ALTER TABLE users DROP COLUMN IF EXISTS actor_uri;
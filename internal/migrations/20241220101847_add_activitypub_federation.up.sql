@@ -0,0 +1,40 @@
+-- remote accounts are users known by the URI of their ActivityPub actor, they have no
+-- password and can't sign in
+ALTER TABLE users ADD COLUMN actor_uri TEXT UNIQUE;
+ALTER TABLE users ADD COLUMN inbox_url TEXT;
+ALTER TABLE users ADD COLUMN shared_inbox_url TEXT;
+
+-- Create actor_keys table, the keypair local users sign requests with and the public keys
+-- of remote actors, found by the key id their requests are signed with
+CREATE TABLE IF NOT EXISTS actor_keys (
+                                          user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+                                          key_id TEXT UNIQUE,
+                                          public_key_pem TEXT NOT NULL,
+                                          private_key_pem TEXT,
+                                          updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- remote posts keep the id of their ActivityPub object
+ALTER TABLE posts ADD COLUMN ap_id TEXT UNIQUE;
+-- set once a local post was handed to the delivery queue, posts published before
+-- federation are not delivered
+ALTER TABLE posts ADD COLUMN federated_at TIMESTAMP;
+UPDATE posts SET federated_at = NOW() WHERE is_draft = FALSE;
+CREATE INDEX idx_posts_federation_pending ON posts(created_at) WHERE federated_at IS NULL AND is_draft = FALSE;
+
+-- Create federation_deliveries table, activities waiting to be posted to remote inboxes
+CREATE TABLE IF NOT EXISTS federation_deliveries (
+                                                     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                                     sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                                     inbox_url TEXT NOT NULL,
+                                                     activity JSONB NOT NULL,
+                                                     attempts INT NOT NULL DEFAULT 0,
+                                                     next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                                     last_error TEXT,
+                                                     delivered_at TIMESTAMP,
+                                                     failed_at TIMESTAMP,
+                                                     created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_federation_deliveries_due ON federation_deliveries(next_attempt_at)
+    WHERE delivered_at IS NULL AND failed_at IS NULL;
@@ -0,0 +1,35 @@
+-- Create webhook_subscriptions table, URLs users have events of their account posted to.
+-- The secret signs every payload so receivers can check it came from us.
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+                                                     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                                     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                                     url TEXT NOT NULL,
+                                                     event_types TEXT[] NOT NULL,
+                                                     secret TEXT NOT NULL,
+                                                     is_active BOOLEAN NOT NULL DEFAULT TRUE,
+                                                     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                                     updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_subscriptions_user ON webhook_subscriptions(user_id);
+
+-- Create webhook_deliveries table, one row per event and subscription. It is the delivery
+-- log, deliveries given up on after the last attempt are the dead letters.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+                                                  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                                  subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+                                                  event_type VARCHAR(50) NOT NULL,
+                                                  payload JSONB NOT NULL,
+                                                  attempts INT NOT NULL DEFAULT 0,
+                                                  next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                                  last_status_code INT,
+                                                  last_error TEXT,
+                                                  last_attempt_at TIMESTAMP,
+                                                  delivered_at TIMESTAMP,
+                                                  dead_at TIMESTAMP,
+                                                  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at)
+    WHERE delivered_at IS NULL AND dead_at IS NULL;
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created_at DESC);
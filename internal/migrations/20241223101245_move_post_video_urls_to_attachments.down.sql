@@ -0,0 +1,10 @@
+UPDATE posts p SET video_url = m.url
+FROM post_attachments a
+JOIN media m ON m.id = a.media_id
+WHERE a.post_id = p.id AND m.kind = 'VIDEO' AND m.storage_key = '';
+
+DELETE FROM post_attachments a
+USING media m
+WHERE m.id = a.media_id AND m.kind = 'VIDEO' AND m.storage_key = '';
+
+DELETE FROM media WHERE kind = 'VIDEO' AND storage_key = '';
@@ -0,0 +1,22 @@
+-- Move the video_url of posts into post_attachments, posts show their videos as attachments.
+-- The files live elsewhere, so the media rows have no storage key and nothing is deleted with them.
+WITH videos AS (
+    SELECT p.id AS post_id, p.user_id, p.video_url, p.created_at, uuid_generate_v4() AS media_id,
+           COALESCE((SELECT MAX(a.position) + 1 FROM post_attachments a WHERE a.post_id = p.id), 0) AS position
+    FROM posts p
+    WHERE p.video_url IS NOT NULL AND p.video_url <> ''
+), moved AS (
+    INSERT INTO media (id, user_id, kind, mime_type, size_bytes, storage_key, url, created_at)
+    SELECT media_id, user_id, 'VIDEO',
+           CASE
+               WHEN lower(video_url) LIKE '%.webm%' THEN 'video/webm'
+               WHEN lower(video_url) LIKE '%.mov%' THEN 'video/quicktime'
+               ELSE 'video/mp4'
+           END,
+           0, '', video_url, created_at
+    FROM videos
+)
+INSERT INTO post_attachments (post_id, media_id, position)
+SELECT post_id, media_id, position FROM videos;
+
+UPDATE posts SET video_url = NULL WHERE video_url IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE post_mentions DROP COLUMN IF EXISTS source;
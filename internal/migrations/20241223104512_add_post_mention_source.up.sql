@@ -0,0 +1,10 @@
+-- Record where a mention came from, TEXT for @username in the content and TAG for users tagged
+-- with tagUserInPost. Edits only remove TEXT mentions.
+ALTER TABLE post_mentions ADD COLUMN IF NOT EXISTS source VARCHAR(10) NOT NULL DEFAULT 'TEXT';
+
+-- mentions without a matching entity were tagged by hand
+UPDATE post_mentions m SET source = 'TAG'
+WHERE NOT EXISTS (
+    SELECT 1 FROM post_entities e
+    WHERE e.post_id = m.post_id AND e.type = 'MENTION' AND e.user_id = m.user_id
+);
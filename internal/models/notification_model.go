@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
 
 type Notification struct {
 	ID        string
@@ -22,4 +26,55 @@ const (
 	Follow
 	Mention
 	Retweet
+	Quote
+	PollClosed
+	Reaction
 )
+
+var notificationTypeNames = map[NotificationType]string{
+	Like:       "LIKE",
+	Comment:    "COMMENT",
+	Follow:     "FOLLOW",
+	Mention:    "MENTION",
+	Retweet:    "RETWEET",
+	Quote:      "QUOTE",
+	PollClosed: "POLL_CLOSED",
+	Reaction:   "REACTION",
+}
+
+// String returns the name the type is stored and exposed under
+func (t NotificationType) String() string {
+	if name, ok := notificationTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("NotificationType(%d)", int(t))
+}
+
+// Value stores the type by name in the notifications.type column
+func (t NotificationType) Value() (driver.Value, error) {
+	name, ok := notificationTypeNames[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown notification type %d", int(t))
+	}
+	return name, nil
+}
+
+// Scan reads a type stored by name
+func (t *NotificationType) Scan(src interface{}) error {
+	var name string
+	switch v := src.(type) {
+	case string:
+		name = v
+	case []byte:
+		name = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into NotificationType", src)
+	}
+	for nt, n := range notificationTypeNames {
+		if n == name {
+			*t = nt
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown notification type %q", name)
+}
@@ -42,7 +42,6 @@ type Post struct {
 	Title     *string        `json:"title,omitempty"`     // Optional title for posts
 	Content   string         `json:"content"`             // The main body content
 	ImageURL  *string        `json:"image_url,omitempty"` // Optional image (URL)
-	VideoURL  *string        `json:"video_url,omitempty"` // Optional video URL
 	AudioURL  *string        `json:"audio_url,omitempty"` // Optional audio (URL)
 	IsEdited  *bool          `json:"is_edited"`           // Indicates if the post was edited
 	IsDraft   *bool          `json:"is_draft"`            // Indicates if the post was edited
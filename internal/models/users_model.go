@@ -62,6 +62,19 @@ type UpdateUserInput struct {
 	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
 }
 
+// UserMatch is a user found by search with how well they matched, from 0 to 1
+type UserMatch struct {
+	User  User
+	Score float64
+}
+
+// UserSearchPage is one page of user search results, best matches first
+type UserSearchPage struct {
+	Users      []*User `json:"users"`
+	HasMore    bool    `json:"has_more"`
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
+
 type UserStats struct {
 	TotalPosts     int
 	TotalFollowers int
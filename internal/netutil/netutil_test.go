@@ -0,0 +1,42 @@
+package netutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicClient_RefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	_, err := NewPublicClient(time.Second).Post(server.URL, "application/json", nil)
+	require.ErrorIs(t, err, ErrPrivateAddress)
+	require.False(t, called)
+}
+
+func TestIsPublicHost(t *testing.T) {
+	hosts := map[string]bool{
+		"https://example.com/inbox":     true,
+		"https://93.184.216.34/inbox":   true,
+		"https://localhost/inbox":       false,
+		"https://chat.localhost/inbox":  false,
+		"https://127.0.0.1:8080/inbox":  false,
+		"https://10.0.0.7/inbox":        false,
+		"http://169.254.169.254/latest": false,
+		"https://[::1]/inbox":           false,
+		"https:///inbox":                false,
+	}
+	for raw, public := range hosts {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		require.Equal(t, public, IsPublicHost(u), raw)
+	}
+}
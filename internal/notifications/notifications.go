@@ -1,6 +1,13 @@
 package notifications
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
 
 type NotificationService interface {
 	MarkNotificationAsRead(ctx context.Context, notificationID string) error
@@ -8,3 +15,27 @@ type NotificationService interface {
 
 type NotificationImpl struct {
 }
+
+// CreateNotificationTx stores a notification as part of the caller's transaction so
+// that it is only sent when the action causing it is committed
+func CreateNotificationTx(ctx context.Context, tx pgx.Tx, notification *models.Notification) error {
+	if notification.ID == "" {
+		notification.ID = uuid.NewString()
+	}
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+
+	query := `
+        INSERT INTO notifications (id, user_id, type, title, content, is_read, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `
+	_, err := tx.Exec(ctx, query,
+		notification.ID, notification.UserID, notification.Type, notification.Title, notification.Content,
+		notification.IsRead, notification.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"strings"
+)
+
+const (
+	maxAttachments   = 4
+	maxAltTextLength = 1000
+)
+
+// AttachmentInput attaches media uploaded earlier by the author to a new post
+type AttachmentInput struct {
+	MediaID string  `json:"media_id"`
+	AltText *string `json:"alt_text,omitempty"` // Description read out by screen readers
+}
+
+// Attachment is a media file shown with a post, ordered by Position
+type Attachment struct {
+	MediaID      string  `json:"media_id"`
+	Kind         string  `json:"kind"`
+	MimeType     string  `json:"mime_type"`
+	URL          string  `json:"url"`
+	ThumbnailURL *string `json:"thumbnail_url,omitempty"`
+	Width        *int    `json:"width,omitempty"`
+	Height       *int    `json:"height,omitempty"`
+	Blurhash     *string `json:"blurhash,omitempty"`
+	AltText      *string `json:"alt_text,omitempty"`
+	Position     int     `json:"position"`
+}
+
+func validateAttachments(attachments []AttachmentInput) error {
+	if len(attachments) > maxAttachments {
+		return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("a post can have at most %d attachments", maxAttachments), nil)
+	}
+	seen := make(map[string]bool, len(attachments))
+	for _, a := range attachments {
+		if seen[a.MediaID] {
+			return errorx.New(errorx.ErrCodeValidation, "the same media is attached twice", nil)
+		}
+		seen[a.MediaID] = true
+		if a.AltText != nil && len([]rune(strings.TrimSpace(*a.AltText))) > maxAltTextLength {
+			return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("alt text can be at most %d characters", maxAltTextLength), nil)
+		}
+	}
+	return nil
+}
+
+// attachMediaTx links the media to the post in the given order. Only media uploaded by
+// the post's author can be attached.
+func (pr *PostRepo) attachMediaTx(ctx context.Context, tx pgx.Tx, postID string, userID string, attachments []AttachmentInput) error {
+	query := `
+        INSERT INTO post_attachments (post_id, media_id, position, alt_text)
+        SELECT $1, id, $4, $5 FROM media WHERE id = $2 AND user_id = $3
+    `
+	for i, a := range attachments {
+		var altText *string
+		if a.AltText != nil {
+			trimmed := strings.TrimSpace(*a.AltText)
+			if trimmed != "" {
+				altText = &trimmed
+			}
+		}
+		tag, err := tx.Exec(ctx, query, postID, a.MediaID, userID, i, altText)
+		if err != nil {
+			return fmt.Errorf("error attaching media: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return errorx.New(errorx.ErrCodeNotFound, fmt.Sprintf("media %s not found", a.MediaID), nil)
+		}
+	}
+	return nil
+}
+
+func (pr *PostRepo) GetPostAttachments(ctx context.Context, postID string) ([]*Attachment, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT m.id, m.kind, m.mime_type, m.url, m.thumbnail_url, m.width, m.height, m.blurhash, pa.alt_text, pa.position
+        FROM post_attachments pa
+        JOIN media m ON m.id = pa.media_id
+        WHERE pa.post_id = $1
+        ORDER BY pa.position
+    `
+
+	rows, err := db.DB.Query(ctx, query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []*Attachment{}
+	for rows.Next() {
+		var a Attachment
+		err := rows.Scan(&a.MediaID, &a.Kind, &a.MimeType, &a.URL, &a.ThumbnailURL, &a.Width, &a.Height, &a.Blurhash, &a.AltText, &a.Position)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning attachment: %w", err)
+		}
+		attachments = append(attachments, &a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachments: %w", err)
+	}
+
+	return attachments, nil
+}
+
+// getAttachmentsByPost returns the attachments of the posts in order, keyed by post id
+func getAttachmentsByPost(ctx context.Context, q rowsQuerier, postIDs []string) (map[string][]*Attachment, error) {
+	byPost := make(map[string][]*Attachment, len(postIDs))
+	if len(postIDs) == 0 {
+		return byPost, nil
+	}
+
+	rows, err := q.Query(ctx, `
+        SELECT pa.post_id, m.id, m.kind, m.mime_type, m.url, m.thumbnail_url, m.width, m.height, m.blurhash, pa.alt_text, pa.position
+        FROM post_attachments pa
+        JOIN media m ON m.id = pa.media_id
+        WHERE pa.post_id = ANY($1::uuid[])
+        ORDER BY pa.post_id, pa.position
+    `, postIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID string
+		var a Attachment
+		err := rows.Scan(&postID, &a.MediaID, &a.Kind, &a.MimeType, &a.URL, &a.ThumbnailURL, &a.Width, &a.Height, &a.Blurhash, &a.AltText, &a.Position)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning attachment: %w", err)
+		}
+		byPost[postID] = append(byPost[postID], &a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachments: %w", err)
+	}
+	return byPost, nil
+}
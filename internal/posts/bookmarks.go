@@ -0,0 +1,471 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCollectionName    = "Saved"
+	maxBookmarkCollections   = 100
+	collectionNameMaxLength  = 100
+	bookmarkNoteMaxLength    = 500
+	bookmarkPageDefaultFirst = 20
+	bookmarkPageMaxFirst     = 100
+)
+
+// BookmarkCollection is a named folder of bookmarks. Bookmarks made without picking a
+// collection go to the user's default collection, which can't be deleted.
+type BookmarkCollection struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id"`
+	Name          string    `json:"name"`
+	Position      int       `json:"position"`
+	IsDefault     bool      `json:"is_default"`
+	BookmarkCount int       `json:"bookmark_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Bookmark is a saved post with the private note its owner left on it
+type Bookmark struct {
+	ID           string    `json:"id"`
+	Post         *Post     `json:"post"`
+	CollectionID string    `json:"collection_id"`
+	Note         *string   `json:"note,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// BookmarkPage is one page of a collection, newest bookmarks first
+type BookmarkPage struct {
+	Bookmarks  []*Bookmark `json:"bookmarks"`
+	HasMore    bool        `json:"has_more"`
+	NextCursor *string     `json:"next_cursor,omitempty"`
+}
+
+func normalizeCollectionName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > collectionNameMaxLength {
+		return "", errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("collection names must be 1 to %d characters", collectionNameMaxLength), nil)
+	}
+	return name, nil
+}
+
+// normalizeBookmarkNote trims the note, a blank note clears it
+func normalizeBookmarkNote(note *string) (*string, error) {
+	if note == nil {
+		return nil, nil
+	}
+	trimmed := strings.TrimSpace(*note)
+	if trimmed == "" {
+		return nil, nil
+	}
+	if len(trimmed) > bookmarkNoteMaxLength {
+		return nil, errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("notes can be at most %d characters", bookmarkNoteMaxLength), nil)
+	}
+	return &trimmed, nil
+}
+
+// defaultCollectionID returns the user's default collection, creating it on first use
+func defaultCollectionID(ctx context.Context, q rowQuerier, userID string) (string, error) {
+	var id string
+	err := q.QueryRow(ctx, `
+        INSERT INTO bookmark_collections (user_id, name, position, is_default)
+        VALUES ($1, $2, 0, TRUE)
+        ON CONFLICT (user_id) WHERE is_default = TRUE DO UPDATE SET is_default = TRUE
+        RETURNING id
+    `, userID, defaultCollectionName).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("error fetching default bookmark collection: %w", err)
+	}
+	return id, nil
+}
+
+// requireNameAvailable fails when the user already has a collection with the name, ignoring case
+func requireNameAvailable(ctx context.Context, q rowQuerier, userID string, name string, exceptID string) error {
+	var taken bool
+	err := q.QueryRow(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM bookmark_collections
+            WHERE user_id = $1 AND lower(name) = lower($2) AND id::text <> $3
+        )
+    `, userID, name, exceptID).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("error checking collection name: %w", err)
+	}
+	if taken {
+		return errorx.New(errorx.ErrCodeAlreadyExists, "a collection with this name already exists", nil)
+	}
+	return nil
+}
+
+// GetBookmarkCollections returns the user's collections in their chosen order
+func (pr *PostRepo) GetBookmarkCollections(ctx context.Context, userID string) ([]*BookmarkCollection, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	if _, err := defaultCollectionID(ctx, db.DB, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query(ctx, `
+        SELECT c.id, c.user_id, c.name, c.position, c.is_default, c.created_at, c.updated_at,
+               (SELECT COUNT(*) FROM bookmarks b JOIN posts p ON p.id = b.post_id
+                WHERE b.collection_id = c.id AND p.deleted_at IS NULL)
+        FROM bookmark_collections c
+        WHERE c.user_id = $1
+        ORDER BY c.position, c.created_at
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching bookmark collections: %w", err)
+	}
+	defer rows.Close()
+
+	collections := []*BookmarkCollection{}
+	for rows.Next() {
+		var c BookmarkCollection
+		err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Position, &c.IsDefault, &c.CreatedAt, &c.UpdatedAt, &c.BookmarkCount)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning bookmark collection: %w", err)
+		}
+		collections = append(collections, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bookmark collections: %w", err)
+	}
+
+	return collections, nil
+}
+
+// CreateBookmarkCollection adds an empty collection after the user's existing ones
+func (pr *PostRepo) CreateBookmarkCollection(ctx context.Context, userID string, name string) (*BookmarkCollection, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// lock the owner so concurrent creates can't go past the limit or reuse a name
+	_, err = tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error locking user: %w", err)
+	}
+
+	// the default collection always comes first
+	if _, err = defaultCollectionID(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	var count, nextPosition int
+	err = tx.QueryRow(ctx, `
+        SELECT COUNT(*), COALESCE(MAX(position), 0) + 1 FROM bookmark_collections WHERE user_id = $1
+    `, userID).Scan(&count, &nextPosition)
+	if err != nil {
+		return nil, fmt.Errorf("error counting bookmark collections: %w", err)
+	}
+	if count >= maxBookmarkCollections {
+		return nil, errorx.New(errorx.ErrCodeQuotaExceeded, fmt.Sprintf("at most %d collections can be created", maxBookmarkCollections), nil)
+	}
+	if err = requireNameAvailable(ctx, tx, userID, name, ""); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	collection := BookmarkCollection{UserID: userID, Name: name, Position: nextPosition, CreatedAt: now, UpdatedAt: now}
+	err = tx.QueryRow(ctx, `
+        INSERT INTO bookmark_collections (user_id, name, position, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $4)
+        RETURNING id
+    `, userID, name, nextPosition, now).Scan(&collection.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error creating bookmark collection: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &collection, nil
+}
+
+// RenameBookmarkCollection renames one of the user's collections, the default one included
+func (pr *PostRepo) RenameBookmarkCollection(ctx context.Context, collectionID string, userID string, name string) (*BookmarkCollection, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error locking user: %w", err)
+	}
+	if err = requireNameAvailable(ctx, tx, userID, name, collectionID); err != nil {
+		return nil, err
+	}
+
+	var c BookmarkCollection
+	err = tx.QueryRow(ctx, `
+        UPDATE bookmark_collections SET name = $1, updated_at = $2
+        WHERE id = $3 AND user_id = $4
+        RETURNING id, user_id, name, position, is_default, created_at, updated_at,
+                  (SELECT COUNT(*) FROM bookmarks b JOIN posts p ON p.id = b.post_id
+                   WHERE b.collection_id = $3 AND p.deleted_at IS NULL)
+    `, name, time.Now(), collectionID, userID).Scan(
+		&c.ID, &c.UserID, &c.Name, &c.Position, &c.IsDefault, &c.CreatedAt, &c.UpdatedAt, &c.BookmarkCount,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "collection not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error renaming bookmark collection: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &c, nil
+}
+
+// DeleteBookmarkCollection removes a collection, its bookmarks move to the default collection
+func (pr *PostRepo) DeleteBookmarkCollection(ctx context.Context, collectionID string, userID string) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var isDefault bool
+	err = tx.QueryRow(ctx, `
+        SELECT is_default FROM bookmark_collections
+        WHERE id = $1 AND user_id = $2
+        FOR UPDATE
+    `, collectionID, userID).Scan(&isDefault)
+	if err == pgx.ErrNoRows {
+		return errorx.New(errorx.ErrCodeNotFound, "collection not found", nil)
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching bookmark collection: %w", err)
+	}
+	if isDefault {
+		return errorx.New(errorx.ErrCodeValidation, "the default collection can't be deleted", nil)
+	}
+
+	defaultID, err := defaultCollectionID(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE bookmarks SET collection_id = $1 WHERE collection_id = $2`, defaultID, collectionID)
+	if err != nil {
+		return fmt.Errorf("error moving bookmarks: %w", err)
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM bookmark_collections WHERE id = $1`, collectionID)
+	if err != nil {
+		return fmt.Errorf("error deleting bookmark collection: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ReorderBookmarkCollections saves a new order, collectionIDs must list every collection of the user once
+func (pr *PostRepo) ReorderBookmarkCollections(ctx context.Context, userID string, collectionIDs []string) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return fmt.Errorf("error locking user: %w", err)
+	}
+
+	var owned, matched int
+	err = tx.QueryRow(ctx, `
+        SELECT COUNT(*), COUNT(*) FILTER (WHERE id::text = ANY($2))
+        FROM bookmark_collections WHERE user_id = $1
+    `, userID, collectionIDs).Scan(&owned, &matched)
+	if err != nil {
+		return fmt.Errorf("error checking bookmark collections: %w", err)
+	}
+	if owned != len(collectionIDs) || matched != owned {
+		return errorx.New(errorx.ErrCodeValidation, "the new order must list each of your collections once", nil)
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE bookmark_collections c SET position = o.position, updated_at = $3
+        FROM unnest($2::uuid[]) WITH ORDINALITY AS o(id, position)
+        WHERE c.id = o.id AND c.user_id = $1
+    `, userID, collectionIDs, time.Now())
+	if err != nil {
+		return fmt.Errorf("error reordering bookmark collections: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// MoveBookmark moves the user's bookmark of the post into another of their collections
+func (pr *PostRepo) MoveBookmark(ctx context.Context, postID string, userID string, collectionID string) (*Bookmark, error) {
+	return pr.updateBookmark(ctx, postID, userID, `
+        UPDATE bookmarks b SET collection_id = c.id
+        FROM bookmark_collections c
+        WHERE b.post_id = $1 AND b.user_id = $2 AND c.id = $3 AND c.user_id = $2
+        RETURNING b.id, b.collection_id, b.note, b.created_at
+    `, collectionID)
+}
+
+// SetBookmarkNote replaces the private note on the user's bookmark, a nil note removes it
+func (pr *PostRepo) SetBookmarkNote(ctx context.Context, postID string, userID string, note *string) (*Bookmark, error) {
+	return pr.updateBookmark(ctx, postID, userID, `
+        UPDATE bookmarks b SET note = $3
+        WHERE b.post_id = $1 AND b.user_id = $2
+        RETURNING b.id, b.collection_id, b.note, b.created_at
+    `, note)
+}
+
+// updateBookmark runs an update on the bookmark of postID owned by userID and returns it with its post
+func (pr *PostRepo) updateBookmark(ctx context.Context, postID string, userID string, query string, arg interface{}) (*Bookmark, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = requireVisible(ctx, tx, postID, userID); err != nil {
+		return nil, err
+	}
+
+	var bookmark Bookmark
+	err = tx.QueryRow(ctx, query, postID, userID, arg).Scan(
+		&bookmark.ID, &bookmark.CollectionID, &bookmark.Note, &bookmark.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "bookmark or collection not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error updating bookmark: %w", err)
+	}
+
+	bookmark.Post, err = pr.getPostTx(ctx, tx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &bookmark, nil
+}
+
+// GetBookmarkCollectionPage returns up to first bookmarks of the collection the user can still
+// read, continuing after the cursor of an earlier page
+func (pr *PostRepo) GetBookmarkCollectionPage(ctx context.Context, collectionID string, userID string, first int, after *string) (*BookmarkPage, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var afterCreatedAt, afterID interface{}
+	if after != nil && *after != "" {
+		createdAt, id, err := decodeCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		afterCreatedAt, afterID = createdAt, id
+	}
+
+	var owned bool
+	err := db.DB.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM bookmark_collections WHERE id = $1 AND user_id = $2)
+    `, collectionID, userID).Scan(&owned)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching bookmark collection: %w", err)
+	}
+	if !owned {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "collection not found", nil)
+	}
+
+	query := `
+        SELECT b.id, b.collection_id, b.note, b.created_at,
+               p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts, p.audience
+        FROM bookmarks b
+        JOIN posts p ON p.id = b.post_id
+        WHERE b.collection_id = $1 AND b.user_id = $2
+          AND p.is_draft = FALSE AND p.deleted_at IS NULL
+          AND ` + postVisibleSQL("p", 2) + `
+          AND ($3::timestamp IS NULL OR (b.created_at, b.id) < ($3::timestamp, $4::uuid))
+        ORDER BY b.created_at DESC, b.id DESC
+        LIMIT $5
+    `
+	rows, err := db.DB.Query(ctx, query, collectionID, userID, afterCreatedAt, afterID, first+1)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching bookmarks: %w", err)
+	}
+	defer rows.Close()
+
+	page := &BookmarkPage{Bookmarks: []*Bookmark{}}
+	for rows.Next() {
+		var b Bookmark
+		var post Post
+		err := rows.Scan(
+			&b.ID, &b.CollectionID, &b.Note, &b.CreatedAt,
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning bookmark: %w", err)
+		}
+		b.Post = &post
+		page.Bookmarks = append(page.Bookmarks, &b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bookmarks: %w", err)
+	}
+
+	if len(page.Bookmarks) > first {
+		page.Bookmarks = page.Bookmarks[:first]
+		page.HasMore = true
+		last := page.Bookmarks[first-1]
+		cursor := encodeCursor(last.CreatedAt, last.ID)
+		page.NextCursor = &cursor
+	}
+
+	return page, nil
+}
@@ -0,0 +1,40 @@
+package posts
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeCollectionName(t *testing.T) {
+	name, err := normalizeCollectionName("  Recipes ")
+	require.NoError(t, err)
+	require.Equal(t, "Recipes", name)
+
+	_, err = normalizeCollectionName("   ")
+	require.Error(t, err)
+
+	_, err = normalizeCollectionName(strings.Repeat("a", collectionNameMaxLength+1))
+	require.Error(t, err)
+}
+
+func TestNormalizeBookmarkNote(t *testing.T) {
+	blank := "  "
+	note := " read later "
+	long := strings.Repeat("a", bookmarkNoteMaxLength+1)
+
+	got, err := normalizeBookmarkNote(nil)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	got, err = normalizeBookmarkNote(&blank)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	got, err = normalizeBookmarkNote(&note)
+	require.NoError(t, err)
+	require.Equal(t, "read later", *got)
+
+	_, err = normalizeBookmarkNote(&long)
+	require.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"time"
+)
+
+// maxCloseFriends caps the size of a close friends list
+const maxCloseFriends = 500
+
+// AddCloseFriend adds friendID to the user's close friends, adding someone twice is a no-op
+func (pr *PostRepo) AddCloseFriend(ctx context.Context, userID string, friendID string) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// lock the owner so concurrent adds can't go past the limit
+	_, err = tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return fmt.Errorf("error locking user: %w", err)
+	}
+
+	var exists, listed bool
+	var count int
+	err = tx.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM users WHERE id = $2),
+               EXISTS (SELECT 1 FROM close_friends WHERE user_id = $1 AND friend_id = $2),
+               (SELECT COUNT(*) FROM close_friends WHERE user_id = $1)
+    `, userID, friendID).Scan(&exists, &listed, &count)
+	if err != nil {
+		return fmt.Errorf("error checking close friends: %w", err)
+	}
+	if !exists {
+		return errorx.New(errorx.ErrCodeNotFound, "user not found", nil)
+	}
+	if listed {
+		return nil
+	}
+	if count >= maxCloseFriends {
+		return errorx.New(errorx.ErrCodeQuotaExceeded, fmt.Sprintf("at most %d close friends can be added", maxCloseFriends), nil)
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO close_friends (user_id, friend_id, created_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id, friend_id) DO NOTHING
+    `, userID, friendID, time.Now())
+	if err != nil {
+		return fmt.Errorf("error adding close friend: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (pr *PostRepo) RemoveCloseFriend(ctx context.Context, userID string, friendID string) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	_, err := db.DB.Exec(ctx, `DELETE FROM close_friends WHERE user_id = $1 AND friend_id = $2`, userID, friendID)
+	if err != nil {
+		return fmt.Errorf("error removing close friend: %w", err)
+	}
+	return nil
+}
+
+// GetCloseFriends returns the ids on the user's close friends list, the most recently added first
+func (pr *PostRepo) GetCloseFriends(ctx context.Context, userID string) ([]string, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	rows, err := db.DB.Query(ctx, `
+        SELECT friend_id FROM close_friends
+        WHERE user_id = $1
+        ORDER BY created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching close friends: %w", err)
+	}
+	defer rows.Close()
+
+	friendIDs := []string{}
+	for rows.Next() {
+		var friendID string
+		if err := rows.Scan(&friendID); err != nil {
+			return nil, fmt.Errorf("error scanning close friend: %w", err)
+		}
+		friendIDs = append(friendIDs, friendID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating close friends: %w", err)
+	}
+
+	return friendIDs, nil
+}
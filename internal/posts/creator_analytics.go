@@ -0,0 +1,306 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"log"
+	"time"
+)
+
+// AnalyticsGranularity is the size of the buckets a creator analytics series is grouped into
+type AnalyticsGranularity string
+
+const (
+	AnalyticsGranularityDay   AnalyticsGranularity = "DAY"
+	AnalyticsGranularityWeek  AnalyticsGranularity = "WEEK"
+	AnalyticsGranularityMonth AnalyticsGranularity = "MONTH"
+)
+
+// postgres date_trunc field for each granularity
+var analyticsTruncFields = map[AnalyticsGranularity]string{
+	AnalyticsGranularityDay:   "day",
+	AnalyticsGranularityWeek:  "week",
+	AnalyticsGranularityMonth: "month",
+}
+
+const (
+	creatorAnalyticsMaxRange  = 366 * 24 * time.Hour
+	creatorAnalyticsPostLimit = 100
+	creatorAnalyticsTopPosts  = 5
+	// creatorStatsLookback is how many past days the creator stats job recomputes on every run
+	creatorStatsLookback = 2
+)
+
+func (g AnalyticsGranularity) IsValid() bool {
+	_, ok := analyticsTruncFields[g]
+	return ok
+}
+
+// AnalyticsPoint holds the stats of one bucket. Reach adds up the unique viewers of each
+// post per day, so a viewer who saw several posts is counted once per post.
+type AnalyticsPoint struct {
+	Start        time.Time `json:"start"`
+	Views        int       `json:"views"`
+	Reach        int       `json:"reach"`
+	Likes        int       `json:"likes"`
+	NewFollowers int       `json:"new_followers"`
+}
+
+// PostPeriodStats are the stats of a single post over the requested period
+type PostPeriodStats struct {
+	Post  *Post `json:"post"`
+	Views int   `json:"views"`
+	Reach int   `json:"reach"`
+	Likes int   `json:"likes"`
+}
+
+type CreatorAnalytics struct {
+	From        time.Time            `json:"from"`
+	To          time.Time            `json:"to"`
+	Granularity AnalyticsGranularity `json:"granularity"`
+	Series      []*AnalyticsPoint    `json:"series"`
+	Totals      *AnalyticsPoint      `json:"totals"`
+	Posts       []*PostPeriodStats   `json:"posts"`     // posts with activity in the period, most viewed first
+	TopPosts    []*PostPeriodStats   `json:"top_posts"` // the first few of Posts
+}
+
+func validateAnalyticsRange(from, to time.Time, granularity AnalyticsGranularity) error {
+	if !granularity.IsValid() {
+		return errorx.New(errorx.ErrCodeInvalidEnum, fmt.Sprintf("unknown granularity %q", granularity), nil)
+	}
+	if !to.After(from) {
+		return errorx.New(errorx.ErrCodeInvalidRange, "from must be before to", nil)
+	}
+	if to.Sub(from) > creatorAnalyticsMaxRange {
+		return errorx.New(errorx.ErrCodeInvalidRange, "the range can span at most one year", nil)
+	}
+	return nil
+}
+
+// GetCreatorAnalytics returns the daily rollups of the user's posts and followers between
+// from and to, grouped by granularity. Buckets without activity are returned as zeros.
+func (pr *PostRepo) GetCreatorAnalytics(ctx context.Context, userID string, from, to time.Time, granularity AnalyticsGranularity) (*CreatorAnalytics, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+	field := analyticsTruncFields[granularity]
+
+	seriesQuery := `
+        WITH buckets AS (
+            SELECT generate_series(date_trunc($3, $1::date::timestamp), $2::date::timestamp, ('1 ' || $3)::interval) AS start
+        ), p AS (
+            SELECT date_trunc($3, day::timestamp) AS start,
+                   SUM(views) AS views, SUM(unique_views) AS reach, SUM(likes) AS likes
+            FROM post_daily_stats
+            WHERE user_id = $4 AND day BETWEEN $1::date AND $2::date
+            GROUP BY 1
+        ), f AS (
+            SELECT date_trunc($3, day::timestamp) AS start, SUM(new_followers) AS new_followers
+            FROM user_daily_stats
+            WHERE user_id = $4 AND day BETWEEN $1::date AND $2::date
+            GROUP BY 1
+        )
+        SELECT b.start, COALESCE(p.views, 0), COALESCE(p.reach, 0), COALESCE(p.likes, 0), COALESCE(f.new_followers, 0)
+        FROM buckets b
+        LEFT JOIN p ON p.start = b.start
+        LEFT JOIN f ON f.start = b.start
+        ORDER BY b.start
+    `
+
+	rows, err := db.DB.Query(ctx, seriesQuery, from, to, field, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching creator analytics: %w", err)
+	}
+	defer rows.Close()
+
+	analytics := &CreatorAnalytics{
+		From:        from,
+		To:          to,
+		Granularity: granularity,
+		Series:      []*AnalyticsPoint{},
+		Totals:      &AnalyticsPoint{Start: from},
+	}
+	for rows.Next() {
+		var point AnalyticsPoint
+		if err := rows.Scan(&point.Start, &point.Views, &point.Reach, &point.Likes, &point.NewFollowers); err != nil {
+			return nil, fmt.Errorf("error scanning analytics point: %w", err)
+		}
+		analytics.Series = append(analytics.Series, &point)
+		analytics.Totals.Views += point.Views
+		analytics.Totals.Reach += point.Reach
+		analytics.Totals.Likes += point.Likes
+		analytics.Totals.NewFollowers += point.NewFollowers
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating analytics points: %w", err)
+	}
+
+	analytics.Posts, err = pr.getPostPeriodStats(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	analytics.TopPosts = analytics.Posts
+	if len(analytics.TopPosts) > creatorAnalyticsTopPosts {
+		analytics.TopPosts = analytics.TopPosts[:creatorAnalyticsTopPosts]
+	}
+
+	return analytics, nil
+}
+
+func (pr *PostRepo) getPostPeriodStats(ctx context.Context, userID string, from, to time.Time) ([]*PostPeriodStats, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts, p.audience,
+               s.views, s.reach, s.likes
+        FROM (
+            SELECT post_id, SUM(views) AS views, SUM(unique_views) AS reach, SUM(likes) AS likes
+            FROM post_daily_stats
+            WHERE user_id = $1 AND day BETWEEN $2::date AND $3::date
+            GROUP BY post_id
+        ) s
+        JOIN posts p ON p.id = s.post_id
+        WHERE p.deleted_at IS NULL
+        ORDER BY s.views DESC, s.likes DESC, p.created_at DESC
+        LIMIT $4
+    `
+
+	rows, err := db.DB.Query(ctx, query, userID, from, to, creatorAnalyticsPostLimit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching post breakdown: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []*PostPeriodStats{}
+	for rows.Next() {
+		var post Post
+		var s PostPeriodStats
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience,
+			&s.Views, &s.Reach, &s.Likes,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning post breakdown: %w", err)
+		}
+		s.Post = &post
+		stats = append(stats, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating post breakdown: %w", err)
+	}
+
+	return stats, nil
+}
+
+// RollupCreatorStats recomputes the likes and new followers of every day since the given
+// day from LIKE reactions and follows. Removed likes and follows drop out of their day again.
+func (pr *PostRepo) RollupCreatorStats(ctx context.Context, since time.Time) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	likesQuery := `
+        WITH counts AS (
+            SELECT pl.post_id, p.user_id, pl.created_at::date AS day, COUNT(*) AS likes
+            FROM post_reactions pl
+            JOIN posts p ON p.id = pl.post_id
+            WHERE pl.created_at >= $1::date AND pl.kind = 'LIKE'
+            GROUP BY pl.post_id, p.user_id, pl.created_at::date
+        ), cleared AS (
+            UPDATE post_daily_stats s SET likes = 0
+            WHERE s.day >= $1::date AND s.likes > 0
+              AND NOT EXISTS (SELECT 1 FROM counts c WHERE c.post_id = s.post_id AND c.day = s.day)
+        )
+        INSERT INTO post_daily_stats (post_id, user_id, day, likes)
+        SELECT post_id, user_id, day, likes FROM counts
+        ON CONFLICT (post_id, day) DO UPDATE SET likes = EXCLUDED.likes
+    `
+	if _, err = tx.Exec(ctx, likesQuery, since); err != nil {
+		return fmt.Errorf("error rolling up likes: %w", err)
+	}
+
+	followersQuery := `
+        WITH counts AS (
+            SELECT followed_id AS user_id, created_at::date AS day, COUNT(*) AS new_followers
+            FROM follows
+            WHERE created_at >= $1::date AND status = 'ACCEPTED'
+            GROUP BY followed_id, created_at::date
+        ), cleared AS (
+            UPDATE user_daily_stats s SET new_followers = 0
+            WHERE s.day >= $1::date AND s.new_followers > 0
+              AND NOT EXISTS (SELECT 1 FROM counts c WHERE c.user_id = s.user_id AND c.day = s.day)
+        )
+        INSERT INTO user_daily_stats (user_id, day, new_followers)
+        SELECT user_id, day, new_followers FROM counts
+        ON CONFLICT (user_id, day) DO UPDATE SET new_followers = EXCLUDED.new_followers
+    `
+	if _, err = tx.Exec(ctx, followersQuery, since); err != nil {
+		return fmt.Errorf("error rolling up followers: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CreatorStatsAggregator periodically refreshes the daily creator stats of the last few days
+type CreatorStatsAggregator struct {
+	Repo     *PostRepo
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewCreatorStatsAggregator(repo *PostRepo, interval time.Duration) *CreatorStatsAggregator {
+	ctx, cancel := context.WithCancel(context.Background())
+	csa := &CreatorStatsAggregator{
+		Repo:     repo,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	go csa.run()
+	return csa
+}
+
+func (csa *CreatorStatsAggregator) Stop() {
+	csa.cancel()
+}
+
+func (csa *CreatorStatsAggregator) run() {
+	ticker := time.NewTicker(csa.interval)
+	defer ticker.Stop()
+
+	csa.rollup()
+	for {
+		select {
+		case <-csa.ctx.Done():
+			return
+		case <-ticker.C:
+			csa.rollup()
+		}
+	}
+}
+
+func (csa *CreatorStatsAggregator) rollup() {
+	since := time.Now().AddDate(0, 0, -creatorStatsLookback)
+	if err := csa.Repo.RollupCreatorStats(csa.ctx, since); err != nil {
+		log.Printf("failed to roll up creator stats: %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"log"
+	"time"
+)
+
+// scheduledPublishBatch caps how many due drafts are published per tick
+const scheduledPublishBatch = 100
+
+// dueDraftsQuery locks the scheduled drafts whose publish time has passed. Drafts moved to
+// the trash are not published. SKIP LOCKED lets several instances run the scheduler
+// without publishing twice.
+const dueDraftsQuery = `
+        SELECT id, user_id, publish_at
+        FROM posts
+        WHERE is_draft = TRUE AND deleted_at IS NULL AND publish_at <= $1
+        ORDER BY publish_at
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED
+    `
+
+// isDraft reports whether the input should be stored as a draft. Scheduled posts
+// stay drafts until their publish time.
+func (in *CreatePostInput) isDraft() bool {
+	return (in.IsDraft != nil && *in.IsDraft) || in.PublishAt != nil
+}
+
+func validatePublishAt(publishAt *time.Time) error {
+	if publishAt != nil && !publishAt.After(time.Now()) {
+		return errorx.New(errorx.ErrCodeValidation, "publishAt must be in the future", nil)
+	}
+	return nil
+}
+
+// AutosaveDraft overwrites the content and schedule of a draft. Drafts keep no
+// revisions, history starts once they are published.
+func (pr *PostRepo) AutosaveDraft(ctx context.Context, postID string, userID string, input CreatePostInput) (*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+        UPDATE posts
+        SET title = $1, content = $2, image_url = $3, audio_url = $4, publish_at = $5, updated_at = $6, audience = COALESCE($9, audience)
+        WHERE id = $7 AND user_id = $8 AND is_draft = TRUE AND deleted_at IS NULL
+        RETURNING id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, is_draft, publish_at
+    `
+
+	var draft Post
+	err = tx.QueryRow(ctx, query,
+		input.Title, input.Content, input.ImageURL, input.AudioURL, input.PublishAt, time.Now(), postID, userID, input.Audience,
+	).Scan(
+		&draft.ID, &draft.UserID, &draft.Title, &draft.Content, &draft.ImageURL, &draft.AudioURL,
+		&draft.ParentID, &draft.CreatedAt, &draft.UpdatedAt, &draft.Likes, &draft.Reposts, &draft.Audience, &draft.IsDraft, &draft.PublishAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "draft not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error saving draft: %w", err)
+	}
+
+	draft.Entities, draft.Tags, err = pr.syncEntitiesTx(ctx, tx, draft.ID, draft.Content)
+	if err != nil {
+		return nil, fmt.Errorf("error extracting post entities: %w", err)
+	}
+
+	if input.Labels != nil {
+		if err = setLabelsTx(ctx, tx, draft.ID, userID, *input.Labels, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &draft, nil
+}
+
+// publishDraftTx turns a draft into a public post. The creation time is moved to the
+// publish time so the post shows up at the right place in feeds.
+func (pr *PostRepo) publishDraftTx(ctx context.Context, tx pgx.Tx, postID string, userID string, publishedAt time.Time) (*Post, error) {
+	query := `
+        UPDATE posts
+        SET is_draft = FALSE, publish_at = NULL, created_at = $1, updated_at = $1
+        WHERE id = $2 AND user_id = $3 AND is_draft = TRUE AND deleted_at IS NULL
+        RETURNING id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, is_draft
+    `
+
+	var post Post
+	err := tx.QueryRow(ctx, query, publishedAt, postID, userID).Scan(
+		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+		&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience, &post.IsDraft,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "draft not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error publishing draft: %w", err)
+	}
+
+	if err = pr.insertRevisionTx(ctx, tx, &post, userID, publishedAt); err != nil {
+		return nil, err
+	}
+	if err = recordPostCreatedTx(ctx, tx, &post); err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
+
+// PublishDueDrafts publishes scheduled drafts whose publish time has passed and
+// returns how many were published
+func (pr *PostRepo) PublishDueDrafts(ctx context.Context, now time.Time) (int, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return 0, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, dueDraftsQuery, now, scheduledPublishBatch)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching scheduled drafts: %w", err)
+	}
+
+	type dueDraft struct {
+		id, userID string
+		publishAt  time.Time
+	}
+	var due []dueDraft
+	for rows.Next() {
+		var d dueDraft
+		if err := rows.Scan(&d.id, &d.userID, &d.publishAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning scheduled draft: %w", err)
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating scheduled drafts: %w", err)
+	}
+
+	for _, d := range due {
+		if _, err := pr.publishDraftTx(ctx, tx, d.id, d.userID, d.publishAt); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(due), nil
+}
+
+// DraftScheduler periodically publishes drafts whose publishAt has passed
+type DraftScheduler struct {
+	Repo     *PostRepo
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewDraftScheduler(repo *PostRepo, interval time.Duration) *DraftScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler := &DraftScheduler{
+		Repo:     repo,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	go scheduler.run()
+	return scheduler
+}
+
+func (ds *DraftScheduler) Stop() {
+	ds.cancel()
+}
+
+func (ds *DraftScheduler) run() {
+	ticker := time.NewTicker(ds.interval)
+	defer ticker.Stop()
+
+	ds.publishDue()
+	for {
+		select {
+		case <-ds.ctx.Done():
+			return
+		case <-ticker.C:
+			ds.publishDue()
+		}
+	}
+}
+
+func (ds *DraftScheduler) publishDue() {
+	// keep going while full batches come back so a backlog clears in one tick
+	for {
+		published, err := ds.Repo.PublishDueDrafts(ds.ctx, time.Now())
+		if err != nil {
+			log.Printf("failed to publish scheduled drafts: %v", err)
+			return
+		}
+		if published < scheduledPublishBatch {
+			return
+		}
+	}
+}
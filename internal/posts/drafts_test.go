@@ -0,0 +1,16 @@
+package posts
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDueDraftsQuery_SkipsTrashedDrafts guards against the scheduler publishing a draft
+// that was moved to the trash before its publish time
+func TestDueDraftsQuery_SkipsTrashedDrafts(t *testing.T) {
+	where := strings.Join(strings.Fields(dueDraftsQuery), " ")
+	require.Contains(t, where, "is_draft = TRUE AND deleted_at IS NULL AND publish_at <= $1")
+	require.Contains(t, where, "FOR UPDATE SKIP LOCKED")
+}
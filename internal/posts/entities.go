@@ -0,0 +1,300 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	"github.com/jackc/pgx/v4"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EntityType is the kind of entity found inside post content
+type EntityType string
+
+const (
+	EntityHashtag EntityType = "HASHTAG"
+	EntityMention EntityType = "MENTION"
+	EntityURL     EntityType = "URL"
+)
+
+// Sources of post_mentions rows. Mentions parsed from the content follow its edits, users
+// tagged with TagUserInPost stay tagged.
+const (
+	mentionSourceText = "TEXT"
+	mentionSourceTag  = "TAG"
+)
+
+// PostEntity is a hashtag, mention or link found in a post. Start and End are
+// rune offsets into the content, End is exclusive.
+type PostEntity struct {
+	Type   EntityType `json:"type"`
+	Text   string     `json:"text"`
+	Start  int        `json:"start"`
+	End    int        `json:"end"`
+	UserID *string    `json:"user_id,omitempty"` // resolved user for mentions
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+const urlTrailingPunctuation = ".,!?;:)]}'\""
+
+// ExtractEntities parses hashtags, mentions and URLs from the content in the order they appear
+func ExtractEntities(content string) []PostEntity {
+	var entities []PostEntity
+
+	// byte ranges already claimed by urls, so that "#" and "@" inside links are ignored
+	var urlRanges [][2]int
+	for _, loc := range urlPattern.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		end = start + len(strings.TrimRight(content[start:end], urlTrailingPunctuation))
+		urlRanges = append(urlRanges, [2]int{start, end})
+	}
+
+	inURL := func(i int) (int, bool) {
+		for _, r := range urlRanges {
+			if i >= r[0] && i < r[1] {
+				return r[1], true
+			}
+		}
+		return 0, false
+	}
+
+	var prev rune
+	for i := 0; i < len(content); {
+		if end, ok := inURL(i); ok {
+			entities = append(entities, newEntity(content, EntityURL, i, end))
+			prev = 0
+			i = end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(content[i:])
+		if (r == '#' || r == '@') && !isEntityRune(prev, r == '@') {
+			end := i + size
+			for end < len(content) {
+				next, nextSize := utf8.DecodeRuneInString(content[end:])
+				if !isEntityRune(next, r == '@') {
+					break
+				}
+				end += nextSize
+			}
+			if body := content[i+size : end]; r == '#' && isValidHashtag(body) {
+				entities = append(entities, newEntity(content, EntityHashtag, i, end))
+				i, prev = end, 0
+				continue
+			} else if r == '@' && body != "" {
+				entities = append(entities, newEntity(content, EntityMention, i, end))
+				i, prev = end, 0
+				continue
+			}
+		}
+
+		prev = r
+		i += size
+	}
+
+	return entities
+}
+
+func newEntity(content string, entityType EntityType, start, end int) PostEntity {
+	runeStart := utf8.RuneCountInString(content[:start])
+	text := content[start:end]
+	return PostEntity{
+		Type:  entityType,
+		Text:  text,
+		Start: runeStart,
+		End:   runeStart + utf8.RuneCountInString(text),
+	}
+}
+
+// isEntityRune reports whether r can be part of a hashtag, or of a username when mention is set
+func isEntityRune(r rune, mention bool) bool {
+	if mention {
+		return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-')
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// hashtagMaxLength caps the runes of a tag, longer ones are not treated as hashtags
+const hashtagMaxLength = 100
+
+// isValidHashtag rejects empty, overlong and purely numeric tags such as "#1"
+func isValidHashtag(body string) bool {
+	if utf8.RuneCountInString(body) > hashtagMaxLength {
+		return false
+	}
+	for _, r := range body {
+		if !unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns the normalized tag name or username the entity refers to
+func (e PostEntity) Value() string {
+	switch e.Type {
+	case EntityHashtag:
+		return strings.ToLower(strings.TrimPrefix(e.Text, "#"))
+	case EntityMention:
+		return strings.TrimPrefix(e.Text, "@")
+	default:
+		return e.Text
+	}
+}
+
+// syncEntitiesTx stores the entities found in content and brings post_tags and
+// post_mentions in line with them, removing tags and mentions that are no longer present.
+// Users tagged by hand are left alone.
+func (pr *PostRepo) syncEntitiesTx(ctx context.Context, tx pgx.Tx, postID string, content string) ([]PostEntity, []string, error) {
+	entities := ExtractEntities(content)
+
+	// kept non-nil so that an empty list is sent as an empty array rather than NULL
+	tagNames, usernames := []string{}, []string{}
+	for _, entity := range entities {
+		switch entity.Type {
+		case EntityHashtag:
+			tagNames = appendUnique(tagNames, entity.Value())
+		case EntityMention:
+			usernames = appendUnique(usernames, strings.ToLower(entity.Value()))
+		}
+	}
+
+	userIDs, err := resolveUsernamesTx(ctx, tx, usernames)
+	if err != nil {
+		return nil, nil, err
+	}
+	mentionedIDs := []string{}
+	for i := range entities {
+		if entities[i].Type != EntityMention {
+			continue
+		}
+		if id, ok := userIDs[strings.ToLower(entities[i].Value())]; ok {
+			entities[i].UserID = &id
+			mentionedIDs = appendUnique(mentionedIDs, id)
+		}
+	}
+
+	// tags that are no longer in the content
+	_, err = tx.Exec(ctx, `
+        DELETE FROM post_tags pt
+        USING tags t
+        WHERE pt.tag_id = t.id AND pt.post_id = $1 AND NOT (t.name = ANY($2))
+    `, postID, tagNames)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error removing stale tags: %w", err)
+	}
+	for _, tag := range tagNames {
+		if _, err = pr.addTag(ctx, tx, postID, tag); err != nil {
+			return nil, nil, fmt.Errorf("error adding tag: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+        DELETE FROM post_mentions
+        WHERE post_id = $1 AND source = $2 AND NOT (user_id::text = ANY($3))
+    `, postID, mentionSourceText, mentionedIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error removing stale mentions: %w", err)
+	}
+	for _, userID := range mentionedIDs {
+		// a user tagged by hand keeps the tag when the mention is edited out again
+		_, err = tx.Exec(ctx, `
+            INSERT INTO post_mentions (post_id, user_id, source)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (post_id, user_id) DO NOTHING
+        `, postID, userID, mentionSourceText)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error adding mention: %w", err)
+		}
+	}
+
+	// offsets shift on every edit so the entity rows are always rewritten
+	_, err = tx.Exec(ctx, `DELETE FROM post_entities WHERE post_id = $1`, postID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error clearing post entities: %w", err)
+	}
+	for _, entity := range entities {
+		_, err = tx.Exec(ctx, `
+            INSERT INTO post_entities (post_id, type, text, start_offset, end_offset, user_id)
+            VALUES ($1, $2, $3, $4, $5, $6)
+        `, postID, entity.Type, entity.Text, entity.Start, entity.End, entity.UserID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error storing post entity: %w", err)
+		}
+	}
+
+	return entities, tagNames, nil
+}
+
+func resolveUsernamesTx(ctx context.Context, tx pgx.Tx, usernames []string) (map[string]string, error) {
+	userIDs := make(map[string]string, len(usernames))
+	if len(usernames) == 0 {
+		return userIDs, nil
+	}
+
+	rows, err := tx.Query(ctx, `SELECT id, lower(username) FROM users WHERE lower(username) = ANY($1)`, usernames)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving mentions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, username string
+		if err := rows.Scan(&id, &username); err != nil {
+			return nil, fmt.Errorf("error scanning mentioned user: %w", err)
+		}
+		userIDs[username] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating mentioned users: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+func (pr *PostRepo) GetPostEntities(ctx context.Context, postID string) ([]PostEntity, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT e.type, e.text, e.start_offset, e.end_offset, e.user_id
+        FROM post_entities e
+        JOIN posts p ON p.id = e.post_id
+        WHERE e.post_id = $1 AND p.deleted_at IS NULL
+        ORDER BY e.start_offset
+    `
+	rows, err := db.DB.Query(ctx, query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying post entities: %w", err)
+	}
+	defer rows.Close()
+
+	entities := []PostEntity{}
+	for rows.Next() {
+		var entity PostEntity
+		if err := rows.Scan(&entity.Type, &entity.Text, &entity.Start, &entity.End, &entity.UserID); err != nil {
+			return nil, fmt.Errorf("error scanning post entity: %w", err)
+		}
+		entities = append(entities, entity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating post entities: %w", err)
+	}
+
+	return entities, nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
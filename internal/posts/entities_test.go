@@ -0,0 +1,58 @@
+package posts
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestExtractEntities(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []PostEntity
+	}{
+		{
+			name:    "hashtags and mentions",
+			content: "hello @bert_x loving #GoLang!",
+			want: []PostEntity{
+				{Type: EntityMention, Text: "@bert_x", Start: 6, End: 13},
+				{Type: EntityHashtag, Text: "#GoLang", Start: 21, End: 28},
+			},
+		},
+		{
+			name:    "ignores sigils inside words and urls",
+			content: "mail me@example.com or see https://example.com/a#b?x=@y.",
+			want: []PostEntity{
+				{Type: EntityURL, Text: "https://example.com/a#b?x=@y", Start: 27, End: 55},
+			},
+		},
+		{
+			name:    "numeric hashtags are skipped",
+			content: "#1 #2024goals",
+			want: []PostEntity{
+				{Type: EntityHashtag, Text: "#2024goals", Start: 3, End: 13},
+			},
+		},
+		{
+			name:    "overlong hashtags are skipped",
+			content: "#" + strings.Repeat("é", hashtagMaxLength+1) + " #" + strings.Repeat("a", hashtagMaxLength),
+			want: []PostEntity{
+				{Type: EntityHashtag, Text: "#" + strings.Repeat("a", hashtagMaxLength), Start: hashtagMaxLength + 3, End: 2*hashtagMaxLength + 4},
+			},
+		},
+		{
+			name:    "offsets count characters not bytes",
+			content: "héllo #café",
+			want: []PostEntity{
+				{Type: EntityHashtag, Text: "#café", Start: 6, End: 11},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, ExtractEntities(tt.content))
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// FederatedPost is a public local post as it is shared with other servers over ActivityPub
+type FederatedPost struct {
+	Post     *Post
+	Username string
+	Labels   ContentLabels
+	// ParentObjectURI is the ActivityPub id of the parent when the post replies to a remote post
+	ParentObjectURI *string
+	// ParentInbox is where the author of that remote parent receives activities
+	ParentInbox *string
+}
+
+// RemotePostInput is a public post received from another server
+type RemotePostInput struct {
+	ObjectURI   string
+	UserID      string // local account of the remote author
+	Content     string
+	ParentID    *string
+	Labels      ContentLabels
+	PublishedAt time.Time
+}
+
+// federatedPostSQL selects the posts other servers may see: published, public posts of
+// local accounts that aren't reposts. Visibility is checked for an anonymous viewer bound
+// to $1, which leaves out private accounts and posts limited to an audience.
+var federatedPostSQL = `
+    SELECT p.id, p.user_id, u.username, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.audience,
+           p.content_warning_category, p.content_warning, p.is_sensitive,
+           pp.ap_id, COALESCE(pu.shared_inbox_url, pu.inbox_url),
+           ARRAY(SELECT t.name FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = p.id ORDER BY t.name)
+    FROM posts p
+    JOIN users u ON u.id = p.user_id
+    LEFT JOIN posts pp ON pp.id = p.parent_id
+    LEFT JOIN users pu ON pu.id = pp.user_id AND pu.actor_uri IS NOT NULL
+    WHERE u.actor_uri IS NULL AND p.ap_id IS NULL AND p.repost_of_id IS NULL
+      AND p.is_draft = FALSE AND p.deleted_at IS NULL
+      AND ` + postVisibleSQL("p", 1)
+
+func scanFederatedPost(row pgx.Row) (*FederatedPost, error) {
+	var post Post
+	fp := &FederatedPost{Post: &post}
+	err := row.Scan(
+		&post.ID, &post.UserID, &fp.Username, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL, &post.ParentID,
+		&post.CreatedAt, &post.UpdatedAt, &post.Audience,
+		&fp.Labels.Category, &fp.Labels.Warning, &fp.Labels.IsSensitive,
+		&fp.ParentObjectURI, &fp.ParentInbox, &post.Tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return fp, nil
+}
+
+// rowsQuerier is satisfied by both the connection pool and a transaction
+type rowsQuerier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func queryFederatedPosts(ctx context.Context, q rowsQuerier, query string, args ...interface{}) ([]*FederatedPost, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching federated posts: %w", err)
+	}
+	defer rows.Close()
+
+	posts := []*FederatedPost{}
+	for rows.Next() {
+		fp, err := scanFederatedPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning federated post: %w", err)
+		}
+		posts = append(posts, fp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating federated posts: %w", err)
+	}
+	return posts, nil
+}
+
+// GetFederatedPost returns the post when other servers may see it, anything else is not found
+func (pr *PostRepo) GetFederatedPost(ctx context.Context, postID string) (*FederatedPost, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	fp, err := scanFederatedPost(db.DB.QueryRow(ctx, federatedPostSQL+` AND p.id = $2`, nil, postID))
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching federated post: %w", err)
+	}
+	return fp, nil
+}
+
+// GetFederatedPosts returns the latest posts of the user other servers may see, replies included
+func (pr *PostRepo) GetFederatedPosts(ctx context.Context, userID string, limit int) ([]*FederatedPost, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := federatedPostSQL + `
+      AND p.user_id = $2
+    ORDER BY p.created_at DESC, p.id DESC
+    LIMIT $3`
+	return queryFederatedPosts(ctx, db.DB, query, nil, userID, limit)
+}
+
+// ClaimPostsToFederate takes up to limit published local posts that weren't handed to
+// federation yet and marks them. Those other servers may see are passed to deliver within
+// the same transaction, so a post is either queued for delivery or stays unclaimed.
+// Scheduled drafts are picked up once they are published. It returns how many posts were
+// claimed, delivered or not.
+func (pr *PostRepo) ClaimPostsToFederate(ctx context.Context, limit int, deliver func(ctx context.Context, tx pgx.Tx, post *FederatedPost) error) (int, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return 0, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+        SELECT id FROM posts
+        WHERE federated_at IS NULL AND is_draft = FALSE
+        ORDER BY created_at
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED
+    `, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching posts to federate: %w", err)
+	}
+	var postIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning post to federate: %w", err)
+		}
+		postIDs = append(postIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating posts to federate: %w", err)
+	}
+	if len(postIDs) == 0 {
+		return 0, nil
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE posts SET federated_at = NOW() WHERE id = ANY($1)`, postIDs); err != nil {
+		return 0, fmt.Errorf("error marking posts as federated: %w", err)
+	}
+
+	federated, err := queryFederatedPosts(ctx, tx, federatedPostSQL+` AND p.id = ANY($2) ORDER BY p.created_at`, nil, postIDs)
+	if err != nil {
+		return 0, err
+	}
+	for _, fp := range federated {
+		if err = deliver(ctx, tx, fp); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(postIDs), nil
+}
+
+// GetPostIDByObjectURI returns the local id of a post received from another server
+func (pr *PostRepo) GetPostIDByObjectURI(ctx context.Context, objectURI string) (string, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return "", fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var postID string
+	err := db.DB.QueryRow(ctx, `SELECT id FROM posts WHERE ap_id = $1 AND deleted_at IS NULL`, objectURI).Scan(&postID)
+	if err == pgx.ErrNoRows {
+		return "", errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching post by object: %w", err)
+	}
+	return postID, nil
+}
+
+// SaveRemotePost stores a public post received from another server and returns its id.
+// An object that was received before keeps the post it was stored as.
+func (pr *PostRepo) SaveRemotePost(ctx context.Context, input RemotePostInput) (string, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return "", fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// serializes deliveries of the same object, servers often send it to several inboxes
+	if _, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, input.ObjectURI); err != nil {
+		return "", fmt.Errorf("error locking remote post: %w", err)
+	}
+	var postID string
+	err = tx.QueryRow(ctx, `SELECT id FROM posts WHERE ap_id = $1`, input.ObjectURI).Scan(&postID)
+	if err == nil {
+		return postID, nil
+	}
+	if err != pgx.ErrNoRows {
+		return "", fmt.Errorf("error fetching remote post: %w", err)
+	}
+
+	create := CreatePostInput{Content: input.Content}
+	if input.Labels.IsLabeled() {
+		create.Labels = &input.Labels
+	}
+	post, err := pr.createPostTx(ctx, tx, create, input.UserID, input.ParentID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE posts SET ap_id = $2, federated_at = NOW(), created_at = $3, updated_at = $3 WHERE id = $1
+    `, post.ID, input.ObjectURI, input.PublishedAt)
+	if err != nil {
+		return "", fmt.Errorf("error saving remote post: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return post.ID, nil
+}
@@ -0,0 +1,109 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// profileFeedSize is how many of the latest posts the feed of a profile carries
+const profileFeedSize = 50
+
+// ProfileFeed is what the RSS, Atom and JSON feeds of a public profile are built from
+type ProfileFeed struct {
+	UserID    string
+	Username  string
+	FullName  *string
+	Bio       *string
+	AvatarURL *string
+	Items     []*FeedItem
+	// UpdatedAt is when the profile or any of its posts last changed, deletions included
+	UpdatedAt time.Time
+}
+
+// FeedItem is a post in a profile feed with the content warnings readers should see first
+type FeedItem struct {
+	Post        *Post
+	Labels      ContentLabels
+	Attachments []*Attachment
+}
+
+// GetProfileFeed returns the latest public posts of the user for feed readers. Replies,
+// reposts, drafts and posts limited to an audience are left out. Private and unknown
+// accounts are reported as not found.
+func (pr *PostRepo) GetProfileFeed(ctx context.Context, username string) (*ProfileFeed, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	feed := &ProfileFeed{Items: []*FeedItem{}}
+	var isPrivate bool
+	err := db.DB.QueryRow(ctx, `
+        SELECT u.id, u.username, u.full_name, u.bio, u.profile_picture_url, u.is_private,
+               GREATEST(u.updated_at, COALESCE((
+                   SELECT MAX(GREATEST(p.updated_at, COALESCE(p.deleted_at, p.updated_at)))
+                   FROM posts p WHERE p.user_id = u.id
+               ), u.updated_at))
+        FROM users u
+        WHERE u.username = $1
+    `, username).Scan(&feed.UserID, &feed.Username, &feed.FullName, &feed.Bio, &feed.AvatarURL, &isPrivate, &feed.UpdatedAt)
+	if err == pgx.ErrNoRows || (err == nil && isPrivate) {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "user not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching feed owner: %w", err)
+	}
+
+	// visibility is checked for an anonymous viewer, which only leaves public posts
+	query := `
+        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.created_at, p.updated_at, p.audience,
+               p.content_warning_category, p.content_warning, p.is_sensitive,
+               ARRAY(SELECT t.name FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = p.id ORDER BY t.name)
+        FROM posts p
+        WHERE p.user_id = $1 AND p.parent_id IS NULL AND p.repost_of_id IS NULL
+          AND p.is_draft = FALSE AND p.deleted_at IS NULL
+          AND ` + postVisibleSQL("p", 2) + `
+        ORDER BY p.created_at DESC, p.id DESC
+        LIMIT $3
+    `
+	rows, err := db.DB.Query(ctx, query, feed.UserID, nil, profileFeedSize)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching feed posts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post Post
+		item := &FeedItem{Post: &post}
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL, &post.CreatedAt, &post.UpdatedAt, &post.Audience,
+			&item.Labels.Category, &item.Labels.Warning, &item.Labels.IsSensitive, &post.Tags,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning feed post: %w", err)
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating feed posts: %w", err)
+	}
+	rows.Close()
+
+	postIDs := make([]string, len(feed.Items))
+	for i, item := range feed.Items {
+		postIDs[i] = item.Post.ID
+	}
+	attachments, err := getAttachmentsByPost(ctx, db.DB, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range feed.Items {
+		item.Attachments = attachments[item.Post.ID]
+	}
+
+	return feed, nil
+}
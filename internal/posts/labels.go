@@ -0,0 +1,338 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const contentWarningMaxLength = 200
+
+// WarningCategory says what a content warning is about
+type WarningCategory string
+
+const (
+	WarningSpoiler    WarningCategory = "SPOILER"
+	WarningViolence   WarningCategory = "VIOLENCE"
+	WarningNudity     WarningCategory = "NUDITY"
+	WarningSelfHarm   WarningCategory = "SELF_HARM"
+	WarningDisturbing WarningCategory = "DISTURBING"
+	WarningOther      WarningCategory = "OTHER"
+)
+
+func (c WarningCategory) IsValid() bool {
+	switch c {
+	case WarningSpoiler, WarningViolence, WarningNudity, WarningSelfHarm, WarningDisturbing, WarningOther:
+		return true
+	}
+	return false
+}
+
+// ContentFilter is what a user wants done with labeled posts of others
+type ContentFilter string
+
+const (
+	ContentFilterHide ContentFilter = "HIDE"
+	ContentFilterBlur ContentFilter = "BLUR"
+	ContentFilterShow ContentFilter = "SHOW"
+)
+
+func (f ContentFilter) IsValid() bool {
+	switch f {
+	case ContentFilterHide, ContentFilterBlur, ContentFilterShow:
+		return true
+	}
+	return false
+}
+
+// ContentLabels are the warnings shown before a post's content. A warning is a category
+// with optional free text, sensitive media is flagged separately.
+type ContentLabels struct {
+	Category    *WarningCategory `json:"category,omitempty"`
+	Warning     *string          `json:"warning,omitempty"`
+	IsSensitive bool             `json:"is_sensitive"`
+}
+
+// Sanitize trims the warning text, a blank warning clears it
+func (l *ContentLabels) Sanitize() {
+	if l.Warning != nil {
+		warning := strings.TrimSpace(*l.Warning)
+		l.Warning = &warning
+		if warning == "" {
+			l.Warning = nil
+		}
+	}
+}
+
+func (l *ContentLabels) Validate() error {
+	if l.Category != nil && !l.Category.IsValid() {
+		return errorx.New(errorx.ErrCodeInvalidEnum, "invalid content warning category", nil)
+	}
+	if l.Warning != nil && l.Category == nil {
+		return errorx.New(errorx.ErrCodeValidation, "a content warning needs a category", nil)
+	}
+	if l.Warning != nil && utf8.RuneCountInString(*l.Warning) > contentWarningMaxLength {
+		return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("content warnings can be at most %d characters", contentWarningMaxLength), nil)
+	}
+	return nil
+}
+
+// IsLabeled reports whether the labels flag the post at all
+func (l ContentLabels) IsLabeled() bool {
+	return l.Category != nil || l.IsSensitive
+}
+
+func (l ContentLabels) equal(other ContentLabels) bool {
+	sameCategory := (l.Category == nil) == (other.Category == nil) && (l.Category == nil || *l.Category == *other.Category)
+	sameWarning := (l.Warning == nil) == (other.Warning == nil) && (l.Warning == nil || *l.Warning == *other.Warning)
+	return sameCategory && sameWarning && l.IsSensitive == other.IsSensitive
+}
+
+// PostLabels are the labels of a post as a viewer sees them, Filter is what the viewer's
+// preference does with the post
+type PostLabels struct {
+	ContentLabels
+	ByModerator bool          `json:"by_moderator"`
+	Filter      ContentFilter `json:"filter"`
+}
+
+// LabelChange is an audit log entry of a change to the labels of a post
+type LabelChange struct {
+	ID          string        `json:"id"`
+	PostID      string        `json:"post_id"`
+	ActorID     string        `json:"actor_id"`
+	ByModerator bool          `json:"by_moderator"`
+	Before      ContentLabels `json:"before"`
+	After       ContentLabels `json:"after"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// labelFilterSQL returns a condition that drops labeled posts for a viewer bound to the
+// numbered query parameter who hides them. Reposts carry the labels of their original.
+// Authors always see their own posts, anonymous viewers get the default filter.
+func labelFilterSQL(alias string, viewerParam int) string {
+	viewer := "$" + strconv.Itoa(viewerParam) + "::uuid"
+	return fmt.Sprintf(`NOT EXISTS (
+    SELECT 1 FROM posts lp
+    WHERE lp.id = COALESCE(%[1]s.repost_of_id, %[1]s.id)
+      AND (lp.content_warning_category IS NOT NULL OR lp.is_sensitive)
+      AND lp.user_id IS DISTINCT FROM %[2]s
+      AND COALESCE((SELECT content_filter FROM users WHERE id = %[2]s), '%[3]s') = '%[4]s'
+)`, alias, viewer, ContentFilterBlur, ContentFilterHide)
+}
+
+// isModerator reports whether the user can label any post
+func isModerator(ctx context.Context, q rowQuerier, userID string) (bool, error) {
+	var moderator bool
+	err := q.QueryRow(ctx, `SELECT is_moderator FROM users WHERE id = $1`, userID).Scan(&moderator)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking moderator: %w", err)
+	}
+	return moderator, nil
+}
+
+// setLabelsTx replaces the labels of the post and logs the change, labeling a repost labels
+// its original. Once a moderator has labeled a post only moderators can change its labels.
+// Setting the labels it already has changes and logs nothing.
+func setLabelsTx(ctx context.Context, tx pgx.Tx, postID string, actorID string, labels ContentLabels, byModerator bool) error {
+	var current ContentLabels
+	var authorID string
+	var labeledByModerator bool
+	err := tx.QueryRow(ctx, `
+        SELECT o.id, o.user_id, o.content_warning_category, o.content_warning, o.is_sensitive, o.labeled_by_moderator
+        FROM posts p JOIN posts o ON o.id = COALESCE(p.repost_of_id, p.id)
+        WHERE p.id = $1 AND o.deleted_at IS NULL
+        FOR UPDATE OF o
+    `, postID).Scan(&postID, &authorID, &current.Category, &current.Warning, &current.IsSensitive, &labeledByModerator)
+	if err == pgx.ErrNoRows {
+		return errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching post labels: %w", err)
+	}
+
+	if current.equal(labels) {
+		return nil
+	}
+	if !byModerator && authorID != actorID {
+		return errorx.New(errorx.ErrCodeForbidden, "only the author can label this post", nil)
+	}
+	if labeledByModerator && !byModerator {
+		return errorx.New(errorx.ErrCodeForbidden, "labels set by a moderator can only be changed by a moderator", nil)
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE posts
+        SET content_warning_category = $2, content_warning = $3, is_sensitive = $4, labeled_by_moderator = labeled_by_moderator OR $5
+        WHERE id = $1
+    `, postID, labels.Category, labels.Warning, labels.IsSensitive, byModerator)
+	if err != nil {
+		return fmt.Errorf("error updating post labels: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO post_label_changes (post_id, actor_id, by_moderator, old_category, old_warning, old_sensitive, new_category, new_warning, new_sensitive)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    `, postID, actorID, byModerator, current.Category, current.Warning, current.IsSensitive, labels.Category, labels.Warning, labels.IsSensitive)
+	if err != nil {
+		return fmt.Errorf("error logging label change: %w", err)
+	}
+
+	return nil
+}
+
+// LabelPost sets the labels of any post as a moderator, the author can't change them after
+func (pr *PostRepo) LabelPost(ctx context.Context, postID string, moderatorID string, labels ContentLabels) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	moderator, err := isModerator(ctx, tx, moderatorID)
+	if err != nil {
+		return err
+	}
+	if !moderator {
+		return errorx.New(errorx.ErrCodeForbidden, "only moderators can label posts", nil)
+	}
+
+	if err = setLabelsTx(ctx, tx, postID, moderatorID, labels, true); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetPostLabels returns the labels of the post, those of the original for a repost, with
+// what the viewer's filter does with it. It returns nil when the post is not labeled.
+func (pr *PostRepo) GetPostLabels(ctx context.Context, postID string, viewerID string) (*PostLabels, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var labels PostLabels
+	var authorID string
+	err := db.DB.QueryRow(ctx, `
+        SELECT p.content_warning_category, p.content_warning, p.is_sensitive, p.labeled_by_moderator, p.user_id,
+               COALESCE((SELECT content_filter FROM users WHERE id = $2::uuid), $3)
+        FROM posts r
+        JOIN posts p ON p.id = COALESCE(r.repost_of_id, r.id)
+        WHERE r.id = $1
+    `, postID, viewerArg(viewerID), ContentFilterBlur).Scan(
+		&labels.Category, &labels.Warning, &labels.IsSensitive, &labels.ByModerator, &authorID, &labels.Filter,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching post labels: %w", err)
+	}
+
+	if !labels.IsLabeled() {
+		return nil, nil
+	}
+	if authorID == viewerID {
+		labels.Filter = ContentFilterShow
+	}
+	return &labels, nil
+}
+
+// GetLabelHistory returns every change to the labels of the post, the latest first.
+// Only moderators can read it.
+func (pr *PostRepo) GetLabelHistory(ctx context.Context, postID string, moderatorID string) ([]*LabelChange, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	moderator, err := isModerator(ctx, db.DB, moderatorID)
+	if err != nil {
+		return nil, err
+	}
+	if !moderator {
+		return nil, errorx.New(errorx.ErrCodeForbidden, "only moderators can read the label history", nil)
+	}
+
+	rows, err := db.DB.Query(ctx, `
+        SELECT id, post_id, actor_id, by_moderator, old_category, old_warning, old_sensitive,
+               new_category, new_warning, new_sensitive, created_at
+        FROM post_label_changes
+        WHERE post_id = $1
+        ORDER BY created_at DESC, id DESC
+    `, postID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching label history: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []*LabelChange{}
+	for rows.Next() {
+		var c LabelChange
+		err := rows.Scan(
+			&c.ID, &c.PostID, &c.ActorID, &c.ByModerator, &c.Before.Category, &c.Before.Warning, &c.Before.IsSensitive,
+			&c.After.Category, &c.After.Warning, &c.After.IsSensitive, &c.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning label change: %w", err)
+		}
+		changes = append(changes, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating label history: %w", err)
+	}
+
+	return changes, nil
+}
+
+func (pr *PostRepo) GetContentFilter(ctx context.Context, userID string) (ContentFilter, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return "", fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var filter ContentFilter
+	err := db.DB.QueryRow(ctx, `SELECT content_filter FROM users WHERE id = $1`, userID).Scan(&filter)
+	if err == pgx.ErrNoRows {
+		return "", errorx.New(errorx.ErrCodeNotFound, "user not found", nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching content filter: %w", err)
+	}
+	return filter, nil
+}
+
+func (pr *PostRepo) SetContentFilter(ctx context.Context, userID string, filter ContentFilter) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tag, err := db.DB.Exec(ctx, `UPDATE users SET content_filter = $2 WHERE id = $1`, userID, filter)
+	if err != nil {
+		return fmt.Errorf("error updating content filter: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.New(errorx.ErrCodeNotFound, "user not found", nil)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package posts
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestContentLabels_Validate(t *testing.T) {
+	spoiler := WarningSpoiler
+	unknown := WarningCategory("GROSS")
+	warning := "season finale"
+	longWarning := strings.Repeat("a", contentWarningMaxLength+1)
+	tests := []struct {
+		name    string
+		labels  ContentLabels
+		wantErr bool
+	}{
+		{name: "category only", labels: ContentLabels{Category: &spoiler}},
+		{name: "category with text", labels: ContentLabels{Category: &spoiler, Warning: &warning}},
+		{name: "sensitive only", labels: ContentLabels{IsSensitive: true}},
+		{name: "text without category", labels: ContentLabels{Warning: &warning}, wantErr: true},
+		{name: "unknown category", labels: ContentLabels{Category: &unknown}, wantErr: true},
+		{name: "long text", labels: ContentLabels{Category: &spoiler, Warning: &longWarning}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.labels.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestContentLabels_SanitizeAndEqual(t *testing.T) {
+	spoiler := WarningSpoiler
+	violence := WarningViolence
+	blank := "   "
+	labels := ContentLabels{Category: &spoiler, Warning: &blank}
+	labels.Sanitize()
+	require.Nil(t, labels.Warning)
+	require.True(t, labels.IsLabeled())
+	require.False(t, ContentLabels{}.IsLabeled())
+
+	other := WarningSpoiler
+	require.True(t, labels.equal(ContentLabels{Category: &other}))
+	require.False(t, labels.equal(ContentLabels{Category: &violence}))
+	require.False(t, labels.equal(ContentLabels{Category: &spoiler, IsSensitive: true}))
+}
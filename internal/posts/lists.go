@@ -0,0 +1,590 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"strings"
+	"time"
+)
+
+const (
+	maxListsPerUser          = 1000
+	maxListMembers           = 5000
+	listNameMaxLength        = 100
+	listDescriptionMaxLength = 280
+	listTimelineDefaultFirst = 20
+	listTimelineMaxFirst     = 100
+)
+
+// UserList is a curated set of accounts whose posts make up the list's timeline. Being on a
+// list is independent of following. Private lists are only visible to their owner, public
+// ones can be followed by anyone.
+type UserList struct {
+	ID            string    `json:"id"`
+	OwnerID       string    `json:"owner_id"`
+	Name          string    `json:"name"`
+	Description   *string   `json:"description,omitempty"`
+	IsPrivate     bool      `json:"is_private"`
+	MemberCount   int       `json:"member_count"`
+	FollowerCount int       `json:"follower_count"`
+	IsFollowing   bool      `json:"is_following"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// UserListInput holds the editable fields of a list
+type UserListInput struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	IsPrivate   bool    `json:"is_private"`
+}
+
+// PostPage is one page of a timeline, newest posts first
+type PostPage struct {
+	Posts      []*Post `json:"posts"`
+	HasMore    bool    `json:"has_more"`
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
+
+// Sanitize trims the name and description, a blank description clears it
+func (in *UserListInput) Sanitize() {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Description != nil {
+		description := strings.TrimSpace(*in.Description)
+		in.Description = &description
+		if description == "" {
+			in.Description = nil
+		}
+	}
+}
+
+func (in *UserListInput) Validate() error {
+	if in.Name == "" || len(in.Name) > listNameMaxLength {
+		return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("list names must be 1 to %d characters", listNameMaxLength), nil)
+	}
+	if in.Description != nil && len(*in.Description) > listDescriptionMaxLength {
+		return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("list descriptions can be at most %d characters", listDescriptionMaxLength), nil)
+	}
+	return nil
+}
+
+// userListColumns selects a list with its counts and whether the viewer bound to $2 follows it
+const userListColumns = `
+    l.id, l.owner_id, l.name, l.description, l.is_private, l.created_at, l.updated_at,
+    (SELECT COUNT(*) FROM user_list_members m WHERE m.list_id = l.id),
+    (SELECT COUNT(*) FROM user_list_followers f WHERE f.list_id = l.id),
+    EXISTS (SELECT 1 FROM user_list_followers f WHERE f.list_id = l.id AND f.user_id = $2::uuid)`
+
+// listVisibleSQL holds when the list under alias l can be seen by the viewer bound to $2,
+// private lists only by their owner and no list by someone blocked in either direction
+const listVisibleSQL = `
+    (l.is_private = FALSE OR l.owner_id = $2::uuid)
+    AND ($2::uuid IS NULL OR NOT EXISTS (
+        SELECT 1 FROM blocked_users
+        WHERE (blocker_id = l.owner_id AND blocked_id = $2::uuid) OR (blocker_id = $2::uuid AND blocked_id = l.owner_id)
+    ))`
+
+func scanUserList(row pgx.Row) (*UserList, error) {
+	var l UserList
+	err := row.Scan(
+		&l.ID, &l.OwnerID, &l.Name, &l.Description, &l.IsPrivate, &l.CreatedAt, &l.UpdatedAt,
+		&l.MemberCount, &l.FollowerCount, &l.IsFollowing,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// getListTx returns the list if the viewer can see it, private lists of other users are
+// reported as missing so their existence isn't revealed
+func getListTx(ctx context.Context, q rowQuerier, listID string, viewerID string) (*UserList, error) {
+	list, err := scanUserList(q.QueryRow(ctx, `
+        SELECT `+userListColumns+`
+        FROM user_lists l
+        WHERE l.id = $1 AND `+listVisibleSQL+`
+    `, listID, viewerArg(viewerID)))
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "list not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching list: %w", err)
+	}
+	return list, nil
+}
+
+// requireListOwner locks the list for the rest of the transaction and fails unless the user owns it
+func requireListOwner(ctx context.Context, tx pgx.Tx, listID string, userID string) error {
+	var ownerID string
+	var isPrivate bool
+	err := tx.QueryRow(ctx, `SELECT owner_id, is_private FROM user_lists WHERE id = $1 FOR UPDATE`, listID).Scan(&ownerID, &isPrivate)
+	if err == pgx.ErrNoRows || (err == nil && isPrivate && ownerID != userID) {
+		return errorx.New(errorx.ErrCodeNotFound, "list not found", nil)
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching list: %w", err)
+	}
+	if ownerID != userID {
+		return errorx.New(errorx.ErrCodeForbidden, "only the owner can change this list", nil)
+	}
+	return nil
+}
+
+// GetList returns a list the viewer can see
+func (pr *PostRepo) GetList(ctx context.Context, listID string, viewerID string) (*UserList, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+	return getListTx(ctx, db.DB, listID, viewerID)
+}
+
+// GetUserLists returns the lists owned by ownerID that the viewer can see, newest first
+func (pr *PostRepo) GetUserLists(ctx context.Context, ownerID string, viewerID string) ([]*UserList, error) {
+	return pr.queryLists(ctx, `
+        SELECT `+userListColumns+`
+        FROM user_lists l
+        WHERE l.owner_id = $1 AND `+listVisibleSQL+`
+        ORDER BY l.created_at DESC
+    `, ownerID, viewerArg(viewerID))
+}
+
+// GetFollowedLists returns the lists the user follows, the most recently followed first
+func (pr *PostRepo) GetFollowedLists(ctx context.Context, userID string) ([]*UserList, error) {
+	return pr.queryLists(ctx, `
+        SELECT `+userListColumns+`
+        FROM user_list_followers lf
+        JOIN user_lists l ON l.id = lf.list_id
+        WHERE lf.user_id = $1 AND `+listVisibleSQL+`
+        ORDER BY lf.created_at DESC
+    `, userID, userID)
+}
+
+func (pr *PostRepo) queryLists(ctx context.Context, query string, args ...interface{}) ([]*UserList, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	rows, err := db.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching lists: %w", err)
+	}
+	defer rows.Close()
+
+	lists := []*UserList{}
+	for rows.Next() {
+		list, err := scanUserList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning list: %w", err)
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lists: %w", err)
+	}
+
+	return lists, nil
+}
+
+// CreateList adds an empty list owned by the user
+func (pr *PostRepo) CreateList(ctx context.Context, ownerID string, input UserListInput) (*UserList, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// lock the owner so concurrent creates can't go past the limit
+	_, err = tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("error locking user: %w", err)
+	}
+
+	var count int
+	err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM user_lists WHERE owner_id = $1`, ownerID).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("error counting lists: %w", err)
+	}
+	if count >= maxListsPerUser {
+		return nil, errorx.New(errorx.ErrCodeQuotaExceeded, fmt.Sprintf("at most %d lists can be created", maxListsPerUser), nil)
+	}
+
+	now := time.Now()
+	list := UserList{OwnerID: ownerID, Name: input.Name, Description: input.Description, IsPrivate: input.IsPrivate, CreatedAt: now, UpdatedAt: now}
+	err = tx.QueryRow(ctx, `
+        INSERT INTO user_lists (owner_id, name, description, is_private, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $5)
+        RETURNING id
+    `, ownerID, input.Name, input.Description, input.IsPrivate, now).Scan(&list.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error creating list: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &list, nil
+}
+
+// UpdateList replaces the list's name, description and privacy. Making a list private
+// drops its followers.
+func (pr *PostRepo) UpdateList(ctx context.Context, listID string, ownerID string, input UserListInput) (*UserList, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = requireListOwner(ctx, tx, listID, ownerID); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE user_lists SET name = $1, description = $2, is_private = $3, updated_at = $4
+        WHERE id = $5
+    `, input.Name, input.Description, input.IsPrivate, time.Now(), listID)
+	if err != nil {
+		return nil, fmt.Errorf("error updating list: %w", err)
+	}
+	if input.IsPrivate {
+		if _, err = tx.Exec(ctx, `DELETE FROM user_list_followers WHERE list_id = $1`, listID); err != nil {
+			return nil, fmt.Errorf("error removing list followers: %w", err)
+		}
+	}
+
+	list, err := getListTx(ctx, tx, listID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return list, nil
+}
+
+// DeleteList removes the list with its members and followers
+func (pr *PostRepo) DeleteList(ctx context.Context, listID string, ownerID string) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = requireListOwner(ctx, tx, listID, ownerID); err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM user_list_members WHERE list_id = $1`,
+		`DELETE FROM user_list_followers WHERE list_id = $1`,
+		`DELETE FROM user_lists WHERE id = $1`,
+	} {
+		if _, err = tx.Exec(ctx, query, listID); err != nil {
+			return fmt.Errorf("error deleting list: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// AddListMember puts memberID on the list, adding someone twice is a no-op. Users blocked
+// in either direction can't be added.
+func (pr *PostRepo) AddListMember(ctx context.Context, listID string, ownerID string, memberID string) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// the list row lock also keeps concurrent adds from going past the limit
+	if err = requireListOwner(ctx, tx, listID, ownerID); err != nil {
+		return err
+	}
+
+	var exists, blocked, listed bool
+	var count int
+	err = tx.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM users WHERE id = $2),
+               EXISTS (
+                   SELECT 1 FROM blocked_users
+                   WHERE (blocker_id = $2 AND blocked_id = $3) OR (blocker_id = $3 AND blocked_id = $2)
+               ),
+               EXISTS (SELECT 1 FROM user_list_members WHERE list_id = $1 AND user_id = $2),
+               (SELECT COUNT(*) FROM user_list_members WHERE list_id = $1)
+    `, listID, memberID, ownerID).Scan(&exists, &blocked, &listed, &count)
+	if err != nil {
+		return fmt.Errorf("error checking list members: %w", err)
+	}
+	if !exists {
+		return errorx.New(errorx.ErrCodeNotFound, "user not found", nil)
+	}
+	if blocked {
+		return errorx.New(errorx.ErrCodeForbidden, "this user can't be added to your lists", nil)
+	}
+	if listed {
+		return nil
+	}
+	if count >= maxListMembers {
+		return errorx.New(errorx.ErrCodeQuotaExceeded, fmt.Sprintf("lists can have at most %d members", maxListMembers), nil)
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(ctx, `
+        INSERT INTO user_list_members (list_id, user_id, created_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (list_id, user_id) DO NOTHING
+    `, listID, memberID, now)
+	if err != nil {
+		return fmt.Errorf("error adding list member: %w", err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE user_lists SET updated_at = $1 WHERE id = $2`, now, listID)
+	if err != nil {
+		return fmt.Errorf("error updating list: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (pr *PostRepo) RemoveListMember(ctx context.Context, listID string, ownerID string, memberID string) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = requireListOwner(ctx, tx, listID, ownerID); err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM user_list_members WHERE list_id = $1 AND user_id = $2`, listID, memberID)
+	if err != nil {
+		return fmt.Errorf("error removing list member: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		_, err = tx.Exec(ctx, `UPDATE user_lists SET updated_at = $1 WHERE id = $2`, time.Now(), listID)
+		if err != nil {
+			return fmt.Errorf("error updating list: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetListMembers returns the ids of the list's members the viewer isn't blocked from, the
+// most recently added first
+func (pr *PostRepo) GetListMembers(ctx context.Context, listID string, viewerID string) ([]string, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	if _, err := getListTx(ctx, db.DB, listID, viewerID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query(ctx, `
+        SELECT m.user_id FROM user_list_members m
+        WHERE m.list_id = $1
+          AND ($2::uuid IS NULL OR NOT EXISTS (
+              SELECT 1 FROM blocked_users
+              WHERE (blocker_id = m.user_id AND blocked_id = $2::uuid) OR (blocker_id = $2::uuid AND blocked_id = m.user_id)
+          ))
+        ORDER BY m.created_at DESC
+    `, listID, viewerArg(viewerID))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching list members: %w", err)
+	}
+	defer rows.Close()
+
+	memberIDs := []string{}
+	for rows.Next() {
+		var memberID string
+		if err := rows.Scan(&memberID); err != nil {
+			return nil, fmt.Errorf("error scanning list member: %w", err)
+		}
+		memberIDs = append(memberIDs, memberID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating list members: %w", err)
+	}
+
+	return memberIDs, nil
+}
+
+// FollowList subscribes the user to a public list of someone else, following twice is a no-op
+func (pr *PostRepo) FollowList(ctx context.Context, listID string, userID string) (*UserList, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// lock the list so it can't turn private while it is being followed
+	var ownerID string
+	var isPrivate bool
+	err = tx.QueryRow(ctx, `SELECT owner_id, is_private FROM user_lists WHERE id = $1 FOR SHARE`, listID).Scan(&ownerID, &isPrivate)
+	if err == pgx.ErrNoRows || (err == nil && isPrivate && ownerID != userID) {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "list not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching list: %w", err)
+	}
+	if ownerID == userID {
+		return nil, errorx.New(errorx.ErrCodeValidation, "you can't follow your own list", nil)
+	}
+
+	var blocked bool
+	err = tx.QueryRow(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM blocked_users
+            WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+        )
+    `, ownerID, userID).Scan(&blocked)
+	if err != nil {
+		return nil, fmt.Errorf("error checking blocks: %w", err)
+	}
+	if blocked {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "list not found", nil)
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO user_list_followers (list_id, user_id, created_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (list_id, user_id) DO NOTHING
+    `, listID, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error following list: %w", err)
+	}
+
+	list, err := getListTx(ctx, tx, listID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return list, nil
+}
+
+func (pr *PostRepo) UnfollowList(ctx context.Context, listID string, userID string) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	_, err := db.DB.Exec(ctx, `DELETE FROM user_list_followers WHERE list_id = $1 AND user_id = $2`, listID, userID)
+	if err != nil {
+		return fmt.Errorf("error unfollowing list: %w", err)
+	}
+	return nil
+}
+
+// GetListTimeline returns up to first posts of the list's members that the viewer can read,
+// newest first, continuing after the cursor of an earlier page
+func (pr *PostRepo) GetListTimeline(ctx context.Context, listID string, viewerID string, first int, after *string) (*PostPage, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var afterCreatedAt, afterID interface{}
+	if after != nil && *after != "" {
+		createdAt, id, err := decodeCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		afterCreatedAt, afterID = createdAt, id
+	}
+
+	if _, err := getListTx(ctx, db.DB, listID, viewerID); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts, p.audience
+        FROM user_list_members m
+        JOIN posts p ON p.user_id = m.user_id
+        WHERE m.list_id = $1 AND p.is_draft = FALSE AND p.deleted_at IS NULL
+          AND ` + postVisibleSQL("p", 2) + `
+          AND ` + labelFilterSQL("p", 2) + `
+          AND ($3::timestamp IS NULL OR (p.created_at, p.id) < ($3::timestamp, $4::uuid))
+        ORDER BY p.created_at DESC, p.id DESC
+        LIMIT $5
+    `
+	rows, err := db.DB.Query(ctx, query, listID, viewerArg(viewerID), afterCreatedAt, afterID, first+1)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching list timeline: %w", err)
+	}
+	defer rows.Close()
+
+	page := &PostPage{Posts: []*Post{}}
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning list timeline post: %w", err)
+		}
+		page.Posts = append(page.Posts, &post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating list timeline: %w", err)
+	}
+
+	if len(page.Posts) > first {
+		page.Posts = page.Posts[:first]
+		page.HasMore = true
+		last := page.Posts[first-1]
+		cursor := encodeCursor(last.CreatedAt, last.ID)
+		page.NextCursor = &cursor
+	}
+
+	return page, nil
+}
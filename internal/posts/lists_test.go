@@ -0,0 +1,45 @@
+package posts
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestUserListInput_Sanitize(t *testing.T) {
+	blank := "   "
+	description := " people I read "
+	input := UserListInput{Name: " Go devs ", Description: &description}
+	input.Sanitize()
+	require.Equal(t, "Go devs", input.Name)
+	require.Equal(t, "people I read", *input.Description)
+
+	input = UserListInput{Name: "Go devs", Description: &blank}
+	input.Sanitize()
+	require.Nil(t, input.Description)
+}
+
+func TestUserListInput_Validate(t *testing.T) {
+	longDescription := strings.Repeat("a", listDescriptionMaxLength+1)
+	tests := []struct {
+		name    string
+		input   UserListInput
+		wantErr bool
+	}{
+		{name: "valid list", input: UserListInput{Name: "Go devs", IsPrivate: true}},
+		{name: "empty name", input: UserListInput{Name: ""}, wantErr: true},
+		{name: "long name", input: UserListInput{Name: strings.Repeat("a", listNameMaxLength+1)}, wantErr: true},
+		{name: "long description", input: UserListInput{Name: "Go devs", Description: &longDescription}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// maxPinnedPosts is the number of posts a user can keep pinned to their profile
+const maxPinnedPosts = 3
+
+// PinPost pins the post to its author's profile. Pinning an already pinned post is a no-op.
+func (pr *PostRepo) PinPost(ctx context.Context, postID string, userID string) (*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// lock the author so concurrent pins can't go past the limit
+	_, err = tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error locking user: %w", err)
+	}
+
+	var authorID string
+	var pinned bool
+	err = tx.QueryRow(ctx, `
+        SELECT user_id, is_pinned FROM posts
+        WHERE id = $1 AND is_draft = FALSE AND deleted_at IS NULL
+        FOR UPDATE
+    `, postID).Scan(&authorID, &pinned)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching post: %w", err)
+	}
+	if authorID != userID {
+		return nil, errorx.New(errorx.ErrCodeForbidden, "only the author can pin a post", nil)
+	}
+
+	if !pinned {
+		var count int
+		err = tx.QueryRow(ctx, `
+            SELECT COUNT(*) FROM posts
+            WHERE user_id = $1 AND is_pinned = TRUE AND deleted_at IS NULL
+        `, userID).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("error counting pinned posts: %w", err)
+		}
+		if count >= maxPinnedPosts {
+			return nil, errorx.New(errorx.ErrCodeQuotaExceeded, fmt.Sprintf("at most %d posts can be pinned", maxPinnedPosts), nil)
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE posts SET is_pinned = TRUE, pinned_at = $1 WHERE id = $2`, time.Now(), postID)
+		if err != nil {
+			return nil, fmt.Errorf("error pinning post: %w", err)
+		}
+	}
+
+	post, err := pr.getPostTx(ctx, tx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return post, nil
+}
+
+// UnpinPost removes the post from its author's pinned posts
+func (pr *PostRepo) UnpinPost(ctx context.Context, postID string, userID string) (*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var authorID string
+	err = tx.QueryRow(ctx, `
+        SELECT user_id FROM posts
+        WHERE id = $1 AND is_draft = FALSE AND deleted_at IS NULL
+        FOR UPDATE
+    `, postID).Scan(&authorID)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching post: %w", err)
+	}
+	if authorID != userID {
+		return nil, errorx.New(errorx.ErrCodeForbidden, "only the author can unpin a post", nil)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE posts SET is_pinned = FALSE, pinned_at = NULL WHERE id = $1`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("error unpinning post: %w", err)
+	}
+
+	post, err := pr.getPostTx(ctx, tx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return post, nil
+}
+
+// GetPinnedPosts returns the posts pinned by the user that the viewer can read, the most recently pinned first
+func (pr *PostRepo) GetPinnedPosts(ctx context.Context, userID string, viewerID string) ([]*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, is_pinned
+        FROM posts
+        WHERE user_id = $1 AND is_pinned = TRUE AND is_draft = FALSE AND deleted_at IS NULL
+          AND ` + postVisibleSQL("posts", 2) + `
+        ORDER BY pinned_at DESC
+    `
+
+	rows, err := db.DB.Query(ctx, query, userID, viewerArg(viewerID))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pinned posts: %w", err)
+	}
+	defer rows.Close()
+
+	var pinned []*Post
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience, &post.IsPinned,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning pinned post: %w", err)
+		}
+		pinned = append(pinned, &post)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pinned posts: %w", err)
+	}
+
+	return pinned, nil
+}
@@ -0,0 +1,388 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/models"
+	"github.com/bertoxic/graphqlChat/internal/notifications"
+	"github.com/jackc/pgx/v4"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	pollMinOptions      = 2
+	pollMaxOptions      = 4
+	pollOptionMaxLength = 100
+	pollCloseBatch      = 100
+)
+
+// CreatePollInput attaches a poll to a new post
+type CreatePollInput struct {
+	Options        []string  `json:"options"`
+	ClosesAt       time.Time `json:"closes_at"`
+	MultipleChoice bool      `json:"multiple_choice"`
+}
+
+func (in *CreatePollInput) Validate() error {
+	if len(in.Options) < pollMinOptions || len(in.Options) > pollMaxOptions {
+		return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("a poll needs between %d and %d options", pollMinOptions, pollMaxOptions), nil)
+	}
+	seen := make(map[string]bool, len(in.Options))
+	for i, option := range in.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || len(option) > pollOptionMaxLength {
+			return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("poll options must be 1 to %d characters", pollOptionMaxLength), nil)
+		}
+		if seen[strings.ToLower(option)] {
+			return errorx.New(errorx.ErrCodeValidation, "poll options must be unique", nil)
+		}
+		seen[strings.ToLower(option)] = true
+		in.Options[i] = option
+	}
+	if !in.ClosesAt.After(time.Now()) {
+		return errorx.New(errorx.ErrCodeValidation, "poll closing time must be in the future", nil)
+	}
+	return nil
+}
+
+// Poll as seen by one viewer. Vote counts are nil until the viewer has voted or the poll is closed.
+type Poll struct {
+	ID              string       `json:"id"`
+	PostID          string       `json:"post_id"`
+	MultipleChoice  bool         `json:"multiple_choice"`
+	ClosesAt        time.Time    `json:"closes_at"`
+	Options         []PollOption `json:"options"`
+	TotalVotes      *int         `json:"total_votes,omitempty"`
+	ViewerOptionIDs []string     `json:"viewer_option_ids"`
+	ResultsVisible  bool         `json:"results_visible"`
+}
+
+type PollOption struct {
+	ID       string `json:"id"`
+	Position int    `json:"position"`
+	Text     string `json:"text"`
+	Votes    *int   `json:"votes,omitempty"`
+}
+
+func (p *Poll) IsClosed() bool {
+	return !time.Now().Before(p.ClosesAt)
+}
+
+func (pr *PostRepo) createPollTx(ctx context.Context, tx pgx.Tx, postID string, input *CreatePollInput) error {
+	var pollID string
+	err := tx.QueryRow(ctx, `
+        INSERT INTO polls (post_id, multiple_choice, closes_at)
+        VALUES ($1, $2, $3)
+        RETURNING id
+    `, postID, input.MultipleChoice, input.ClosesAt).Scan(&pollID)
+	if err != nil {
+		return fmt.Errorf("error creating poll: %w", err)
+	}
+
+	for i, option := range input.Options {
+		_, err = tx.Exec(ctx, `INSERT INTO poll_options (poll_id, position, text) VALUES ($1, $2, $3)`, pollID, i, option)
+		if err != nil {
+			return fmt.Errorf("error creating poll option: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetPoll returns the poll attached to the post as seen by the viewer, or nil when the post has none
+func (pr *PostRepo) GetPoll(ctx context.Context, postID string, viewerID string) (*Poll, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	visible, err := canViewPost(ctx, db.DB, postID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, nil
+	}
+
+	var poll Poll
+	var totalVotes int
+	err = db.DB.QueryRow(ctx, `
+        SELECT p.id, p.post_id, p.multiple_choice, p.closes_at,
+               (SELECT COUNT(*) FROM poll_votes v WHERE v.poll_id = p.id AND cardinality(v.option_ids) > 0),
+               COALESCE((SELECT v.option_ids::text[] FROM poll_votes v WHERE v.poll_id = p.id AND v.user_id = $2), '{}')
+        FROM polls p
+        WHERE p.post_id = $1
+    `, postID, viewerArg(viewerID)).Scan(
+		&poll.ID, &poll.PostID, &poll.MultipleChoice, &poll.ClosesAt, &totalVotes, &poll.ViewerOptionIDs,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching poll: %w", err)
+	}
+
+	rows, err := db.DB.Query(ctx, `
+        SELECT id, position, text, vote_count
+        FROM poll_options
+        WHERE poll_id = $1
+        ORDER BY position
+    `, poll.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching poll options: %w", err)
+	}
+	defer rows.Close()
+
+	poll.ResultsVisible = poll.IsClosed() || len(poll.ViewerOptionIDs) > 0
+	for rows.Next() {
+		var option PollOption
+		var votes int
+		if err := rows.Scan(&option.ID, &option.Position, &option.Text, &votes); err != nil {
+			return nil, fmt.Errorf("error scanning poll option: %w", err)
+		}
+		if poll.ResultsVisible {
+			option.Votes = &votes
+		}
+		poll.Options = append(poll.Options, option)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating poll options: %w", err)
+	}
+
+	if poll.ResultsVisible {
+		poll.TotalVotes = &totalVotes
+	}
+
+	return &poll, nil
+}
+
+// VotePoll records the user's choice on the post's poll. When changing is false the user
+// must not have voted yet, when it is true an earlier vote is replaced.
+func (pr *PostRepo) VotePoll(ctx context.Context, postID string, userID string, optionIDs []string, changing bool) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var pollID string
+	var multipleChoice bool
+	var closesAt time.Time
+	err = tx.QueryRow(ctx, `
+        SELECT p.id, p.multiple_choice, p.closes_at
+        FROM polls p
+        JOIN posts ON posts.id = p.post_id
+        WHERE p.post_id = $1 AND posts.is_draft = FALSE AND posts.deleted_at IS NULL
+        FOR SHARE OF p
+    `, postID).Scan(&pollID, &multipleChoice, &closesAt)
+	if err == pgx.ErrNoRows {
+		return errorx.New(errorx.ErrCodeNotFound, "poll not found", nil)
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching poll: %w", err)
+	}
+
+	if !time.Now().Before(closesAt) {
+		return errorx.New(errorx.ErrCodeResourceLocked, "poll is closed", nil)
+	}
+	visible, err := canViewPost(ctx, tx, postID, userID)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return errorx.New(errorx.ErrCodeNotFound, "poll not found", nil)
+	}
+
+	choices := []string{}
+	for _, id := range optionIDs {
+		choices = appendUnique(choices, id)
+	}
+	if len(choices) == 0 {
+		return errorx.New(errorx.ErrCodeValidation, "choose at least one option", nil)
+	}
+	if !multipleChoice && len(choices) > 1 {
+		return errorx.New(errorx.ErrCodeValidation, "this poll allows a single choice", nil)
+	}
+
+	var matched int
+	err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM poll_options WHERE poll_id = $1 AND id::text = ANY($2)`, pollID, choices).Scan(&matched)
+	if err != nil {
+		return fmt.Errorf("error checking poll options: %w", err)
+	}
+	if matched != len(choices) {
+		return errorx.New(errorx.ErrCodeValidation, "unknown poll option", nil)
+	}
+
+	// The empty ballot is inserted first so that its row lock serialises concurrent
+	// votes by the same user, the ballot is the one vote a user gets.
+	tag, err := tx.Exec(ctx, `
+        INSERT INTO poll_votes (poll_id, user_id, option_ids)
+        VALUES ($1, $2, '{}')
+        ON CONFLICT (poll_id, user_id) DO NOTHING
+    `, pollID, userID)
+	if err != nil {
+		return fmt.Errorf("error creating ballot: %w", err)
+	}
+	if !changing && tag.RowsAffected() == 0 {
+		return errorx.New(errorx.ErrCodeConflict, "user has already voted in this poll", nil)
+	}
+
+	var previous []string
+	err = tx.QueryRow(ctx, `
+        SELECT option_ids::text[] FROM poll_votes
+        WHERE poll_id = $1 AND user_id = $2
+        FOR UPDATE
+    `, pollID, userID).Scan(&previous)
+	if err != nil {
+		return fmt.Errorf("error fetching ballot: %w", err)
+	}
+	if changing && len(previous) == 0 {
+		return errorx.New(errorx.ErrCodeNotFound, "user has not voted in this poll", nil)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE poll_options SET vote_count = vote_count - 1 WHERE poll_id = $1 AND id::text = ANY($2)`, pollID, previous)
+	if err != nil {
+		return fmt.Errorf("error removing previous vote: %w", err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE poll_options SET vote_count = vote_count + 1 WHERE poll_id = $1 AND id::text = ANY($2)`, pollID, choices)
+	if err != nil {
+		return fmt.Errorf("error counting vote: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+        UPDATE poll_votes SET option_ids = $3::uuid[], updated_at = $4
+        WHERE poll_id = $1 AND user_id = $2
+    `, pollID, userID, choices, time.Now())
+	if err != nil {
+		return fmt.Errorf("error saving vote: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// NotifyClosedPolls tells authors that their polls have closed and returns how many were handled
+func (pr *PostRepo) NotifyClosedPolls(ctx context.Context, now time.Time) (int, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return 0, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+        SELECT p.id, posts.user_id, posts.content
+        FROM polls p
+        JOIN posts ON posts.id = p.post_id
+        WHERE p.close_notified = FALSE AND p.closes_at <= $1 AND posts.is_draft = FALSE AND posts.deleted_at IS NULL
+        ORDER BY p.closes_at
+        LIMIT $2
+        FOR UPDATE OF p SKIP LOCKED
+    `, now, pollCloseBatch)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching closed polls: %w", err)
+	}
+
+	type closedPoll struct {
+		id, authorID, content string
+	}
+	var closed []closedPoll
+	for rows.Next() {
+		var c closedPoll
+		if err := rows.Scan(&c.id, &c.authorID, &c.content); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning closed poll: %w", err)
+		}
+		closed = append(closed, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating closed polls: %w", err)
+	}
+
+	for _, c := range closed {
+		err = notifications.CreateNotificationTx(ctx, tx, &models.Notification{
+			UserID:  c.authorID,
+			Type:    models.PollClosed,
+			Title:   "Your poll has closed",
+			Content: c.content,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if _, err = tx.Exec(ctx, `UPDATE polls SET close_notified = TRUE WHERE id = $1`, c.id); err != nil {
+			return 0, fmt.Errorf("error marking poll as notified: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(closed), nil
+}
+
+// PollCloser periodically notifies authors about polls that have closed
+type PollCloser struct {
+	Repo     *PostRepo
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewPollCloser(repo *PostRepo, interval time.Duration) *PollCloser {
+	ctx, cancel := context.WithCancel(context.Background())
+	closer := &PollCloser{
+		Repo:     repo,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	go closer.run()
+	return closer
+}
+
+func (pc *PollCloser) Stop() {
+	pc.cancel()
+}
+
+func (pc *PollCloser) run() {
+	ticker := time.NewTicker(pc.interval)
+	defer ticker.Stop()
+
+	pc.notifyClosed()
+	for {
+		select {
+		case <-pc.ctx.Done():
+			return
+		case <-ticker.C:
+			pc.notifyClosed()
+		}
+	}
+}
+
+func (pc *PollCloser) notifyClosed() {
+	for {
+		handled, err := pc.Repo.NotifyClosedPolls(pc.ctx, time.Now())
+		if err != nil {
+			log.Printf("failed to notify closed polls: %v", err)
+			return
+		}
+		if handled < pollCloseBatch {
+			return
+		}
+	}
+}
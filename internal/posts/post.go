@@ -2,9 +2,10 @@ package posts
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	errorx "github.com/bertoxic/graphqlChat/internal/error"
-	"log"
+	"github.com/google/uuid"
 	"strings"
 	"time"
 )
@@ -16,10 +17,16 @@ var (
 
 // Input struct for creating/updating posts
 type CreatePostInput struct {
-	Title    *string `json:"title,omitempty"`
-	Content  string  `json:"content"`
-	ImageURL *string `json:"image_url,omitempty"`
-	AudioURL *string `json:"audio_url,omitempty"`
+	Title       *string           `json:"title,omitempty"`
+	Content     string            `json:"content"`
+	ImageURL    *string           `json:"image_url,omitempty"`
+	AudioURL    *string           `json:"audio_url,omitempty"`
+	IsDraft     *bool             `json:"is_draft,omitempty"`
+	PublishAt   *time.Time        `json:"publish_at,omitempty"` // Publishes the draft automatically at this time
+	Poll        *CreatePollInput  `json:"poll,omitempty"`
+	Attachments []AttachmentInput `json:"attachments,omitempty"`
+	Audience    *Audience         `json:"audience,omitempty"` // Defaults to public, replies use the audience of their thread
+	Labels      *ContentLabels    `json:"labels,omitempty"`   // Content warnings, kept as they are on update when nil
 }
 type PostResponse struct {
 	Success bool   `json:"success,omitempty"`
@@ -32,39 +39,64 @@ func (in *CreatePostInput) Sanitize() {
 		trimmedTitle := strings.TrimSpace(*in.Title)
 		in.Title = &trimmedTitle
 	}
+	if in.Labels != nil {
+		in.Labels.Sanitize()
+	}
 }
 func (in *CreatePostInput) Validate() error {
 	if len(in.Content) < bodyMinLength {
 		return errorx.New(errorx.ErrCodeBadRequest, "length of post body is too short", nil)
 	}
+	if in.Audience != nil && !in.Audience.IsValid() {
+		return errorx.New(errorx.ErrCodeInvalidEnum, "invalid audience", nil)
+	}
+	if err := validateAttachments(in.Attachments); err != nil {
+		return err
+	}
+	if in.Labels != nil {
+		if err := in.Labels.Validate(); err != nil {
+			return err
+		}
+	}
+	if in.Poll != nil {
+		return in.Poll.Validate()
+	}
 	return nil
 }
 
 type Post struct {
-	ID        string         `json:"id"`
-	UserID    string         `json:"user_id"`
-	Title     *string        `json:"title,omitempty"`     // Optional title for posts
-	Content   string         `json:"content"`             // The main body content
-	ImageURL  *string        `json:"image_url,omitempty"` // Optional image (URL)
-	VideoURL  *string        `json:"video_url,omitempty"` // Optional video URL
-	AudioURL  *string        `json:"audio_url,omitempty"` // Optional audio (URL)
-	IsEdited  *bool          `json:"is_edited"`           // Indicates if the post was edited
-	IsDraft   *bool          `json:"is_draft"`            // Indicates if the post was edited
-	ParentID  *string        `json:"parent_id,omitempty"` // Parent post ID for comments or reposts
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	Likes     int            `json:"likes"`
-	Reposts   int            `json:"reposts"`
-	Tags      []string       `json:"tags"`
-	Children  []*Post        `json:"children"`            // Comments or reposts (children posts)
-	Analytics *PostAnalytics `json:"analytics,omitempty"` //  field for analytics
+	ID           string         `json:"id"`
+	UserID       string         `json:"user_id"`
+	Title        *string        `json:"title,omitempty"`          // Optional title for posts
+	Content      string         `json:"content"`                  // The main body content
+	ImageURL     *string        `json:"image_url,omitempty"`      // Optional image (URL)
+	AudioURL     *string        `json:"audio_url,omitempty"`      // Optional audio (URL)
+	IsEdited     *bool          `json:"is_edited"`                // Indicates if the post was edited
+	IsDraft      *bool          `json:"is_draft"`                 // Indicates if the post is an unpublished draft
+	PublishAt    *time.Time     `json:"publish_at,omitempty"`     // Scheduled publish time of a draft
+	ParentID     *string        `json:"parent_id,omitempty"`      // Parent post ID for comments
+	RepostOfID   *string        `json:"repost_of_id,omitempty"`   // Original post shared by a repost
+	QuotedPostID *string        `json:"quoted_post_id,omitempty"` // Post embedded by a quote post
+	QuoteCount   int            `json:"quote_count"`
+	DeletedAt    *time.Time     `json:"deleted_at,omitempty"` // Set on deleted posts kept as placeholders in threads
+	IsPinned     bool           `json:"is_pinned"`            // Pinned to the top of the author's profile
+	Audience     Audience       `json:"audience"`             // Who besides the author can read the post
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	Likes        int            `json:"likes"`
+	Reposts      int            `json:"reposts"`
+	Tags         []string       `json:"tags"`
+	Entities     []PostEntity   `json:"entities"`            // Hashtags, mentions and links found in the content
+	Children     []*Post        `json:"children"`            // Comments (children posts)
+	Analytics    *PostAnalytics `json:"analytics,omitempty"` //  field for analytics
 
 }
 type PostAnalytics struct {
-	Views         int `json:"views"`
-	Reach         int `json:"reach"`
-	CommentsCount int `json:"comments"`
-	Shares        int `json:"shares"`
+	Views          int     `json:"views"`
+	Reach          int     `json:"reach"` // unique viewers
+	CommentsCount  int     `json:"comments"`
+	Shares         int     `json:"shares"`
+	EngagementRate float64 `json:"engagement_rate"` // likes, reposts and comments per view
 }
 
 type UserPostStats struct {
@@ -77,36 +109,113 @@ type UserPostStats struct {
 type PostService interface {
 	// Post management
 	CreatePost(ctx context.Context, input CreatePostInput, userID string, parentID *string) (*Post, error)
-	GetPost(ctx context.Context, postID string) (*Post, error)
-	UpdatePost(ctx context.Context, postID string, input CreatePostInput) (*Post, error)
-	DeletePost(ctx context.Context, postID string) (PostResponse, error)
-	GetAllUserPosts(ctx context.Context, userID string) ([]*Post, error)
+	GetPost(ctx context.Context, postID string, viewerID string) (*Post, error)
+	UpdatePost(ctx context.Context, postID string, input CreatePostInput, userID string) (*Post, error)
+	GetPostRevisions(ctx context.Context, postID string) ([]*PostRevision, error)
+	DeletePost(ctx context.Context, postID string, userID string) (PostResponse, error)
+	GetDeletedPosts(ctx context.Context, userID string) ([]*Post, error)
+	RestorePost(ctx context.Context, postID string, userID string) (*Post, error)
+	GetAllUserPosts(ctx context.Context, userID string, viewerID string) ([]*Post, error)
+	PinPost(ctx context.Context, postID string, userID string) (*Post, error)
+	UnpinPost(ctx context.Context, postID string, userID string) (*Post, error)
+	GetPinnedPosts(ctx context.Context, userID string, viewerID string) ([]*Post, error)
 
 	// Repost / Comment functionality
 	Repost(ctx context.Context, postID string, userID string) (*Post, error)
+	UndoRepost(ctx context.Context, postID string, userID string) error
+	GetRepostedPost(ctx context.Context, postID string, viewerID string) (*Post, error)
+	GetReposters(ctx context.Context, postID string, viewerID string, first int, after *string) (*ReposterPage, error)
 	AddComment(ctx context.Context, postID string, input CreatePostInput, userID string) (*Post, error)
-	GetPostComments(ctx context.Context, postID string) ([]*Post, error)
+	QuotePost(ctx context.Context, postID string, userID string, input CreatePostInput) (*Post, error)
+	GetQuotedPost(ctx context.Context, postID string, viewerID string) (*Post, error)
+	GetQuoteCount(ctx context.Context, postID string) (int, error)
+
+	// Polls
+	GetPoll(ctx context.Context, postID string, viewerID string) (*Poll, error)
+	VotePoll(ctx context.Context, postID string, userID string, optionIDs []string) (*Poll, error)
+	ChangePollVote(ctx context.Context, postID string, userID string, optionIDs []string) (*Poll, error)
+	GetPostComments(ctx context.Context, postID string, viewerID string) ([]*Post, error)
+	GetThread(ctx context.Context, postID string, viewerID string, depth int, first int, after *string) (*ThreadNode, error)
 
 	// Like/Unlike a post
 	LikePost(ctx context.Context, postID string, userID string) (PostResponse, error)
 	UnlikePost(ctx context.Context, postID string, userID string) (PostResponse, error)
-	GetUsersWhoLikedPost(ctx context.Context, postID string) ([]string, error)
+	GetUsersWhoLikedPost(ctx context.Context, postID string, viewerID string) ([]string, error)
+
+	// Reactions, a like is the LIKE reaction and a user has one reaction per post
+	React(ctx context.Context, postID string, userID string, kind ReactionKind) (*Post, error)
+	Unreact(ctx context.Context, postID string, userID string) error
+	GetReactionCounts(ctx context.Context, postID string) ([]*ReactionCount, error)
+	GetViewerReaction(ctx context.Context, postID string, viewerID string) (*ReactionKind, error)
+	GetReactions(ctx context.Context, postID string, viewerID string, kind *ReactionKind, first int, after *string) (*ReactionPage, error)
+
+	// Content warnings, feeds and search drop labeled posts for users who hide them
+	GetPostLabels(ctx context.Context, postID string, viewerID string) (*PostLabels, error)
+	LabelPost(ctx context.Context, postID string, moderatorID string, labels ContentLabels) (*PostLabels, error)
+	GetLabelHistory(ctx context.Context, postID string, moderatorID string) ([]*LabelChange, error)
+	GetContentFilter(ctx context.Context, userID string) (ContentFilter, error)
+	SetContentFilter(ctx context.Context, userID string, filter ContentFilter) (ContentFilter, error)
+
 	// Feed and tagging
 	GetUserFeed(ctx context.Context, userID string) ([]*Post, error)
-	TagUserInPost(ctx context.Context, postID string, taggedUserID string) (PostResponse, error)
+	GetProfileFeed(ctx context.Context, username string) (*ProfileFeed, error)
+	TagUserInPost(ctx context.Context, postID string, userID string, taggedUserID string) (PostResponse, error)
+	GetPostEntities(ctx context.Context, postID string) ([]PostEntity, error)
+	GetPostAttachments(ctx context.Context, postID string) ([]*Attachment, error)
+
+	// Federation, public posts shared with and received from other servers over ActivityPub
+	GetFederatedPost(ctx context.Context, postID string) (*FederatedPost, error)
+	GetFederatedPosts(ctx context.Context, userID string, limit int) ([]*FederatedPost, error)
+	GetPostIDByObjectURI(ctx context.Context, objectURI string) (string, error)
+	SaveRemotePost(ctx context.Context, input RemotePostInput) (string, error)
 
 	// search posts
-	SearchPosts(ctx context.Context, query string) ([]*Post, error)
-	GetTrendingPosts(ctx context.Context, limit int) ([]*Post, error)
-	GetPostsByTag(ctx context.Context, tag string) ([]*Post, error)
+	SearchPosts(ctx context.Context, query string, filter PostSearchFilter, viewerID string, first int, after *string) (*PostSearchPage, error)
+	SearchTags(ctx context.Context, query string, viewerID string, limit int) ([]*TagMatch, error)
+	GetTrendingPosts(ctx context.Context, window TrendingWindow, limit int, viewerID string) ([]*Post, error)
+	GetTrendingTags(ctx context.Context, window TrendingWindow, limit int) ([]*TrendingTag, error)
+	GetPostsByTag(ctx context.Context, tag string, viewerID string) ([]*Post, error)
+
+	// Close friends, the audience of CLOSE_FRIENDS posts
+	AddCloseFriend(ctx context.Context, userID string, friendID string) error
+	RemoveCloseFriend(ctx context.Context, userID string, friendID string) error
+	GetCloseFriends(ctx context.Context, userID string) ([]string, error)
 
 	BookmarkPost(ctx context.Context, postID string, userID string) (PostResponse, error)
 	RemoveBookmark(ctx context.Context, postID string, userID string) (PostResponse, error)
 	GetUserBookmarkedPosts(ctx context.Context, userID string) ([]*Post, error)
 
+	// Bookmark collections, private to their owner
+	GetBookmarkCollections(ctx context.Context, userID string) ([]*BookmarkCollection, error)
+	GetBookmarkCollectionPage(ctx context.Context, collectionID string, userID string, first int, after *string) (*BookmarkPage, error)
+	CreateBookmarkCollection(ctx context.Context, userID string, name string) (*BookmarkCollection, error)
+	RenameBookmarkCollection(ctx context.Context, collectionID string, userID string, name string) (*BookmarkCollection, error)
+	DeleteBookmarkCollection(ctx context.Context, collectionID string, userID string) error
+	ReorderBookmarkCollections(ctx context.Context, userID string, collectionIDs []string) ([]*BookmarkCollection, error)
+	MoveBookmark(ctx context.Context, postID string, userID string, collectionID string) (*Bookmark, error)
+	SetBookmarkNote(ctx context.Context, postID string, userID string, note *string) (*Bookmark, error)
+
+	// Lists, curated sets of accounts with their own timeline
+	GetList(ctx context.Context, listID string, viewerID string) (*UserList, error)
+	GetUserLists(ctx context.Context, ownerID string, viewerID string) ([]*UserList, error)
+	GetFollowedLists(ctx context.Context, userID string) ([]*UserList, error)
+	GetListMembers(ctx context.Context, listID string, viewerID string) ([]string, error)
+	GetListTimeline(ctx context.Context, listID string, viewerID string, first int, after *string) (*PostPage, error)
+	CreateList(ctx context.Context, ownerID string, input UserListInput) (*UserList, error)
+	UpdateList(ctx context.Context, listID string, ownerID string, input UserListInput) (*UserList, error)
+	DeleteList(ctx context.Context, listID string, ownerID string) error
+	AddListMember(ctx context.Context, listID string, ownerID string, memberID string) (*UserList, error)
+	RemoveListMember(ctx context.Context, listID string, ownerID string, memberID string) (*UserList, error)
+	FollowList(ctx context.Context, listID string, userID string) (*UserList, error)
+	UnfollowList(ctx context.Context, listID string, userID string) error
+
 	//SaveDraft(ctx context.Context, userID string, draftInput CreatePostInput) (*Post, error)
 	GetDrafts(ctx context.Context, userID string) ([]*Post, error)
-	GetPostAnalytics(ctx context.Context, postID string) (*PostAnalytics, error)
+	AutosaveDraft(ctx context.Context, postID string, userID string, input CreatePostInput) (*Post, error)
+	PublishDraft(ctx context.Context, postID string, userID string) (*Post, error)
+	GetPostAnalytics(ctx context.Context, postID string, userID string) (*PostAnalytics, error)
+	RecordViews(ctx context.Context, postIDs []string, viewerID string) error
+	GetCreatorAnalytics(ctx context.Context, userID string, from, to time.Time, granularity AnalyticsGranularity) (*CreatorAnalytics, error)
 	GetUserPostStats(ctx context.Context, userID string) (*UserPostStats, error)
 	HandleNullablePostFields(post *Post)
 }
@@ -114,6 +223,8 @@ type PostService interface {
 // PostServiceImpl implements the PostService interface
 type PostServiceImpl struct {
 	Repo *PostRepo
+	// EditWindow is how long after creation a post can still be edited, zero means forever
+	EditWindow time.Duration
 }
 
 func NewPostServiceImpl(repo *PostRepo) *PostServiceImpl {
@@ -121,6 +232,28 @@ func NewPostServiceImpl(repo *PostRepo) *PostServiceImpl {
 	return &PostServiceImpl{Repo: repo}
 }
 
+// serviceError passes through errors the repository already classified and
+// reports anything else as a database failure
+func serviceError(message string, err error) error {
+	var appErr *errorx.AppError
+	if errors.As(err, &appErr) && appErr.Code != errorx.ErrCodeDatabase {
+		return err
+	}
+	return errorx.New(errorx.ErrCodeDatabase, message, err)
+}
+
+// requireAuthor returns a forbidden error unless the user wrote the post
+func (pr *PostServiceImpl) requireAuthor(ctx context.Context, postID string, userID string, action string) error {
+	authorID, err := pr.Repo.GetPostAuthorID(ctx, postID)
+	if err != nil {
+		return serviceError("failed to get post", err)
+	}
+	if authorID != userID {
+		return errorx.New(errorx.ErrCodeForbidden, fmt.Sprintf("only the author can %s this post", action), nil)
+	}
+	return nil
+}
+
 func (pr *PostServiceImpl) HandleNullablePostFields(post *Post) {
 	if post == nil {
 		return
@@ -133,10 +266,6 @@ func (pr *PostServiceImpl) HandleNullablePostFields(post *Post) {
 		defaultImageURL := ""
 		post.ImageURL = &defaultImageURL
 	}
-	if post.VideoURL == nil {
-		defaultVideoURL := ""
-		post.VideoURL = &defaultVideoURL
-	}
 	if post.AudioURL == nil {
 		defaultAudioURL := ""
 		post.AudioURL = &defaultAudioURL
@@ -151,43 +280,110 @@ func (pr *PostServiceImpl) HandleNullablePostFields(post *Post) {
 		post.IsEdited = &defaultIsEdited
 	}
 	if post.IsDraft == nil {
-		defaultIsDraft := true
+		defaultIsDraft := false
 		post.IsDraft = &defaultIsDraft
 	}
 
 }
 
-func (pr *PostServiceImpl) SearchPosts(ctx context.Context, query string) ([]*Post, error) {
-	//pr.lowercaseStringsZ()
-	log.Printf("xxxxxxxxxxxxxxxxxxxxxxxxxxxx%s", query)
-	posts, err := pr.Repo.SearchPosts(ctx, query)
+func (pr *PostServiceImpl) SearchPosts(ctx context.Context, query string, filter PostSearchFilter, viewerID string, first int, after *string) (*PostSearchPage, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	if first < 1 {
+		first = searchDefaultFirst
+	}
+	if first > searchMaxFirst {
+		first = searchMaxFirst
+	}
+	page, err := pr.Repo.SearchPosts(ctx, query, filter, viewerID, first, after)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search posts: %w", err)
+		return nil, serviceError("failed to search posts", err)
 	}
-	return posts, nil
+	return page, nil
+}
+
+// SearchTags matches the start of tag names, a leading # is ignored
+func (pr *PostServiceImpl) SearchTags(ctx context.Context, query string, viewerID string, limit int) ([]*TagMatch, error) {
+	prefix := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "#"))
+	if prefix == "" {
+		return []*TagMatch{}, nil
+	}
+	tags, err := pr.Repo.SearchTags(ctx, prefix, viewerID, limit)
+	if err != nil {
+		return nil, serviceError("failed to search tags", err)
+	}
+	return tags, nil
 }
 
-func (pr *PostServiceImpl) GetTrendingPosts(ctx context.Context, limit int) ([]*Post, error) {
-	trendingPosts, err := pr.Repo.GetTrendingPosts(ctx, limit)
+func (pr *PostServiceImpl) GetTrendingPosts(ctx context.Context, window TrendingWindow, limit int, viewerID string) ([]*Post, error) {
+	window, err := normalizeTrendingWindow(window)
+	if err != nil {
+		return nil, err
+	}
+	trendingPosts, err := pr.Repo.GetTrendingPosts(ctx, window, limit, viewerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get trending posts: %w", err)
 	}
 	return trendingPosts, nil
 }
 
-func (pr *PostServiceImpl) GetPostsByTag(ctx context.Context, tag string) ([]*Post, error) {
+func (pr *PostServiceImpl) GetTrendingTags(ctx context.Context, window TrendingWindow, limit int) ([]*TrendingTag, error) {
+	window, err := normalizeTrendingWindow(window)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = trendingTagsDefaultLimit
+	}
+	if limit > trendingTagsLimit {
+		limit = trendingTagsLimit
+	}
+	trendingTags, err := pr.Repo.GetTrendingTags(ctx, window, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trending tags: %w", err)
+	}
+	return trendingTags, nil
+}
+
+func (pr *PostServiceImpl) GetPostsByTag(ctx context.Context, tag string, viewerID string) ([]*Post, error) {
 
-	posts, err := pr.Repo.GetPostsByTag(ctx, tag)
+	posts, err := pr.Repo.GetPostsByTag(ctx, tag, viewerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get posts by tag: %w", err)
 	}
 	return posts, nil
 }
 
+func (pr *PostServiceImpl) AddCloseFriend(ctx context.Context, userID string, friendID string) error {
+	if userID == friendID {
+		return errorx.New(errorx.ErrCodeValidation, "users cannot add themselves as a close friend", nil)
+	}
+	if err := pr.Repo.AddCloseFriend(ctx, userID, friendID); err != nil {
+		return serviceError("failed to add close friend", err)
+	}
+	return nil
+}
+
+func (pr *PostServiceImpl) RemoveCloseFriend(ctx context.Context, userID string, friendID string) error {
+	if err := pr.Repo.RemoveCloseFriend(ctx, userID, friendID); err != nil {
+		return errorx.New(errorx.ErrCodeDatabase, "failed to remove close friend", err)
+	}
+	return nil
+}
+
+func (pr *PostServiceImpl) GetCloseFriends(ctx context.Context, userID string) ([]string, error) {
+	friendIDs, err := pr.Repo.GetCloseFriends(ctx, userID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get close friends", err)
+	}
+	return friendIDs, nil
+}
+
 func (pr *PostServiceImpl) BookmarkPost(ctx context.Context, postID string, userID string) (PostResponse, error) {
 	postresp, err := pr.Repo.BookmarkPost(ctx, postID, userID)
 	if err != nil {
-		return postresp, fmt.Errorf("failed to bookmark post: %w", err)
+		return postresp, serviceError("failed to bookmark post", err)
 	}
 	return postresp, nil
 }
@@ -208,6 +404,192 @@ func (pr *PostServiceImpl) GetUserBookmarkedPosts(ctx context.Context, userID st
 	return bookmarkedPosts, nil
 }
 
+func (pr *PostServiceImpl) GetBookmarkCollections(ctx context.Context, userID string) ([]*BookmarkCollection, error) {
+	collections, err := pr.Repo.GetBookmarkCollections(ctx, userID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get bookmark collections", err)
+	}
+	return collections, nil
+}
+
+func (pr *PostServiceImpl) GetBookmarkCollectionPage(ctx context.Context, collectionID string, userID string, first int, after *string) (*BookmarkPage, error) {
+	if first < 1 {
+		first = bookmarkPageDefaultFirst
+	}
+	if first > bookmarkPageMaxFirst {
+		first = bookmarkPageMaxFirst
+	}
+	page, err := pr.Repo.GetBookmarkCollectionPage(ctx, collectionID, userID, first, after)
+	if err != nil {
+		return nil, serviceError("failed to get bookmarks", err)
+	}
+	return page, nil
+}
+
+func (pr *PostServiceImpl) CreateBookmarkCollection(ctx context.Context, userID string, name string) (*BookmarkCollection, error) {
+	name, err := normalizeCollectionName(name)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := pr.Repo.CreateBookmarkCollection(ctx, userID, name)
+	if err != nil {
+		return nil, serviceError("failed to create bookmark collection", err)
+	}
+	return collection, nil
+}
+
+func (pr *PostServiceImpl) RenameBookmarkCollection(ctx context.Context, collectionID string, userID string, name string) (*BookmarkCollection, error) {
+	name, err := normalizeCollectionName(name)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := pr.Repo.RenameBookmarkCollection(ctx, collectionID, userID, name)
+	if err != nil {
+		return nil, serviceError("failed to rename bookmark collection", err)
+	}
+	return collection, nil
+}
+
+func (pr *PostServiceImpl) DeleteBookmarkCollection(ctx context.Context, collectionID string, userID string) error {
+	if err := pr.Repo.DeleteBookmarkCollection(ctx, collectionID, userID); err != nil {
+		return serviceError("failed to delete bookmark collection", err)
+	}
+	return nil
+}
+
+func (pr *PostServiceImpl) ReorderBookmarkCollections(ctx context.Context, userID string, collectionIDs []string) ([]*BookmarkCollection, error) {
+	if err := pr.Repo.ReorderBookmarkCollections(ctx, userID, collectionIDs); err != nil {
+		return nil, serviceError("failed to reorder bookmark collections", err)
+	}
+	return pr.GetBookmarkCollections(ctx, userID)
+}
+
+func (pr *PostServiceImpl) MoveBookmark(ctx context.Context, postID string, userID string, collectionID string) (*Bookmark, error) {
+	bookmark, err := pr.Repo.MoveBookmark(ctx, postID, userID, collectionID)
+	if err != nil {
+		return nil, serviceError("failed to move bookmark", err)
+	}
+	return bookmark, nil
+}
+
+func (pr *PostServiceImpl) SetBookmarkNote(ctx context.Context, postID string, userID string, note *string) (*Bookmark, error) {
+	note, err := normalizeBookmarkNote(note)
+	if err != nil {
+		return nil, err
+	}
+	bookmark, err := pr.Repo.SetBookmarkNote(ctx, postID, userID, note)
+	if err != nil {
+		return nil, serviceError("failed to update bookmark note", err)
+	}
+	return bookmark, nil
+}
+
+func (pr *PostServiceImpl) GetList(ctx context.Context, listID string, viewerID string) (*UserList, error) {
+	list, err := pr.Repo.GetList(ctx, listID, viewerID)
+	if err != nil {
+		return nil, serviceError("failed to get list", err)
+	}
+	return list, nil
+}
+
+func (pr *PostServiceImpl) GetUserLists(ctx context.Context, ownerID string, viewerID string) ([]*UserList, error) {
+	lists, err := pr.Repo.GetUserLists(ctx, ownerID, viewerID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get lists", err)
+	}
+	return lists, nil
+}
+
+func (pr *PostServiceImpl) GetFollowedLists(ctx context.Context, userID string) ([]*UserList, error) {
+	lists, err := pr.Repo.GetFollowedLists(ctx, userID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get followed lists", err)
+	}
+	return lists, nil
+}
+
+func (pr *PostServiceImpl) GetListMembers(ctx context.Context, listID string, viewerID string) ([]string, error) {
+	memberIDs, err := pr.Repo.GetListMembers(ctx, listID, viewerID)
+	if err != nil {
+		return nil, serviceError("failed to get list members", err)
+	}
+	return memberIDs, nil
+}
+
+func (pr *PostServiceImpl) GetListTimeline(ctx context.Context, listID string, viewerID string, first int, after *string) (*PostPage, error) {
+	if first < 1 {
+		first = listTimelineDefaultFirst
+	}
+	if first > listTimelineMaxFirst {
+		first = listTimelineMaxFirst
+	}
+	page, err := pr.Repo.GetListTimeline(ctx, listID, viewerID, first, after)
+	if err != nil {
+		return nil, serviceError("failed to get list timeline", err)
+	}
+	return page, nil
+}
+
+func (pr *PostServiceImpl) CreateList(ctx context.Context, ownerID string, input UserListInput) (*UserList, error) {
+	input.Sanitize()
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	list, err := pr.Repo.CreateList(ctx, ownerID, input)
+	if err != nil {
+		return nil, serviceError("failed to create list", err)
+	}
+	return list, nil
+}
+
+func (pr *PostServiceImpl) UpdateList(ctx context.Context, listID string, ownerID string, input UserListInput) (*UserList, error) {
+	input.Sanitize()
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	list, err := pr.Repo.UpdateList(ctx, listID, ownerID, input)
+	if err != nil {
+		return nil, serviceError("failed to update list", err)
+	}
+	return list, nil
+}
+
+func (pr *PostServiceImpl) DeleteList(ctx context.Context, listID string, ownerID string) error {
+	if err := pr.Repo.DeleteList(ctx, listID, ownerID); err != nil {
+		return serviceError("failed to delete list", err)
+	}
+	return nil
+}
+
+func (pr *PostServiceImpl) AddListMember(ctx context.Context, listID string, ownerID string, memberID string) (*UserList, error) {
+	if err := pr.Repo.AddListMember(ctx, listID, ownerID, memberID); err != nil {
+		return nil, serviceError("failed to add list member", err)
+	}
+	return pr.GetList(ctx, listID, ownerID)
+}
+
+func (pr *PostServiceImpl) RemoveListMember(ctx context.Context, listID string, ownerID string, memberID string) (*UserList, error) {
+	if err := pr.Repo.RemoveListMember(ctx, listID, ownerID, memberID); err != nil {
+		return nil, serviceError("failed to remove list member", err)
+	}
+	return pr.GetList(ctx, listID, ownerID)
+}
+
+func (pr *PostServiceImpl) FollowList(ctx context.Context, listID string, userID string) (*UserList, error) {
+	list, err := pr.Repo.FollowList(ctx, listID, userID)
+	if err != nil {
+		return nil, serviceError("failed to follow list", err)
+	}
+	return list, nil
+}
+
+func (pr *PostServiceImpl) UnfollowList(ctx context.Context, listID string, userID string) error {
+	if err := pr.Repo.UnfollowList(ctx, listID, userID); err != nil {
+		return serviceError("failed to unfollow list", err)
+	}
+	return nil
+}
+
 //func (pr *PostServiceImpl) SaveDraft(ctx context.Context, userID string, draftInput CreatePostInput) (*Post, error) {
 //	draft, err := pr.Repo.SaveDraft(ctx, userID, draftInput)
 //	if err != nil {
@@ -223,10 +605,74 @@ func (pr *PostServiceImpl) GetDrafts(ctx context.Context, userID string) ([]*Pos
 	}
 	return drafts, nil
 }
-func (pr *PostServiceImpl) GetPostAnalytics(ctx context.Context, postID string) (*PostAnalytics, error) {
-	analytics, err := pr.Repo.GetPostAnalytics(ctx, postID)
+
+func (pr *PostServiceImpl) AutosaveDraft(ctx context.Context, postID string, userID string, input CreatePostInput) (*Post, error) {
+	if err := validatePublishAt(input.PublishAt); err != nil {
+		return nil, err
+	}
+	if input.Labels != nil {
+		input.Labels.Sanitize()
+		if err := input.Labels.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	draft, err := pr.Repo.AutosaveDraft(ctx, postID, userID, input)
+	if err != nil {
+		return nil, serviceError("failed to save draft", err)
+	}
+	return draft, nil
+}
+
+func (pr *PostServiceImpl) PublishDraft(ctx context.Context, postID string, userID string) (*Post, error) {
+	post, err := pr.Repo.PublishDraft(ctx, postID, userID)
+	if err != nil {
+		return nil, serviceError("failed to publish draft", err)
+	}
+	return post, nil
+}
+
+// GetPostAnalytics returns the analytics of a published post, only its author may read them
+func (pr *PostServiceImpl) GetPostAnalytics(ctx context.Context, postID string, userID string) (*PostAnalytics, error) {
+	analytics, err := pr.Repo.GetPostAnalytics(ctx, postID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get post analytics: %w", err)
+		return nil, serviceError("failed to get post analytics", err)
+	}
+	return analytics, nil
+}
+
+// RecordViews counts impressions of the given posts, viewerID is empty for anonymous viewers
+func (pr *PostServiceImpl) RecordViews(ctx context.Context, postIDs []string, viewerID string) error {
+	if len(postIDs) > maxViewsPerRequest {
+		return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("at most %d views can be recorded at once", maxViewsPerRequest), nil)
+	}
+	for _, id := range postIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("invalid post id %q", id), nil)
+		}
+	}
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	// views of posts the viewer can't see, or that don't exist, are not counted
+	visible, err := pr.Repo.visiblePostIDs(ctx, postIDs, viewerID)
+	if err != nil {
+		return serviceError("failed to record views", err)
+	}
+	pr.Repo.RecordViews(ctx, visible, viewerID)
+	return nil
+}
+
+func (pr *PostServiceImpl) GetCreatorAnalytics(ctx context.Context, userID string, from, to time.Time, granularity AnalyticsGranularity) (*CreatorAnalytics, error) {
+	if granularity == "" {
+		granularity = AnalyticsGranularityDay
+	}
+	if err := validateAnalyticsRange(from, to, granularity); err != nil {
+		return nil, err
+	}
+	analytics, err := pr.Repo.GetCreatorAnalytics(ctx, userID, from, to, granularity)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get creator analytics", err)
 	}
 	return analytics, nil
 }
@@ -240,70 +686,254 @@ func (pr *PostServiceImpl) GetUserPostStats(ctx context.Context, userID string)
 }
 
 func (pr *PostServiceImpl) CreatePost(ctx context.Context, input CreatePostInput, userID string, parentID *string) (*Post, error) {
+	if parentID != nil && input.isDraft() {
+		return nil, errorx.New(errorx.ErrCodeValidation, "comments cannot be saved as drafts", nil)
+	}
+	if parentID != nil && input.Audience != nil {
+		return nil, errorx.New(errorx.ErrCodeValidation, "replies share the audience of their thread", nil)
+	}
+	input.Sanitize()
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	if err := validatePublishAt(input.PublishAt); err != nil {
+		return nil, err
+	}
+	if input.Poll != nil && input.PublishAt != nil && !input.Poll.ClosesAt.After(*input.PublishAt) {
+		return nil, errorx.New(errorx.ErrCodeValidation, "poll must close after the post is published", nil)
+	}
 	post, err := pr.Repo.CreatePost(ctx, input, userID, parentID)
 	if err != nil {
-		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to create post", err)
+		return nil, serviceError("failed to create post", err)
 	}
 	return post, nil
 }
-func (pr *PostServiceImpl) GetUsersWhoLikedPost(ctx context.Context, postID string) ([]string, error) {
-	userIdList, err := pr.Repo.GetUsersWhoLikedPost(ctx, postID)
+func (pr *PostServiceImpl) GetUsersWhoLikedPost(ctx context.Context, postID string, viewerID string) ([]string, error) {
+	userIdList, err := pr.Repo.GetUsersWhoLikedPost(ctx, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
 	return userIdList, nil
 }
 
-func (pr *PostServiceImpl) GetPost(ctx context.Context, postID string) (*Post, error) {
-	post, err := pr.Repo.GetPost(ctx, postID)
+func (pr *PostServiceImpl) GetPost(ctx context.Context, postID string, viewerID string) (*Post, error) {
+	post, err := pr.Repo.GetPost(ctx, postID, viewerID)
 	if err != nil {
-		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get post", err)
+		return nil, serviceError("failed to get post", err)
 	}
 	return post, nil
 }
 
-func (pr *PostServiceImpl) UpdatePost(ctx context.Context, postID string, input CreatePostInput) (*Post, error) {
-	post, err := pr.Repo.UpdatePost(ctx, postID, input)
+func (pr *PostServiceImpl) UpdatePost(ctx context.Context, postID string, input CreatePostInput, userID string) (*Post, error) {
+	if err := pr.requireAuthor(ctx, postID, userID, "edit"); err != nil {
+		return nil, err
+	}
+	input.Sanitize()
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	post, err := pr.Repo.UpdatePost(ctx, postID, input, userID, pr.EditWindow)
 	if err != nil {
-		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to update post", err)
+		return nil, serviceError("failed to update post", err)
 	}
 	return post, nil
 }
 
-func (pr *PostServiceImpl) DeletePost(ctx context.Context, postID string) (PostResponse, error) {
+func (pr *PostServiceImpl) GetPostRevisions(ctx context.Context, postID string) ([]*PostRevision, error) {
+	revisions, err := pr.Repo.GetPostRevisions(ctx, postID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get post revisions", err)
+	}
+	return revisions, nil
+}
+
+func (pr *PostServiceImpl) DeletePost(ctx context.Context, postID string, userID string) (PostResponse, error) {
+	if err := pr.requireAuthor(ctx, postID, userID, "delete"); err != nil {
+		return PostResponse{Message: "unable to delete post"}, err
+	}
 	postresp, err := pr.Repo.DeletePost(ctx, postID)
 	if err != nil {
-		return postresp, errorx.New(errorx.ErrCodeDatabase, "failed to delete post", err)
+		return postresp, serviceError("failed to delete post", err)
 	}
 	return postresp, nil
 }
 
-func (pr *PostServiceImpl) GetAllUserPosts(ctx context.Context, userID string) ([]*Post, error) {
-	posts, err := pr.Repo.GetAllUserPosts(ctx, userID)
+func (pr *PostServiceImpl) GetDeletedPosts(ctx context.Context, userID string) ([]*Post, error) {
+	deleted, err := pr.Repo.GetDeletedPosts(ctx, userID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get deleted posts", err)
+	}
+	return deleted, nil
+}
+
+func (pr *PostServiceImpl) RestorePost(ctx context.Context, postID string, userID string) (*Post, error) {
+	post, err := pr.Repo.RestorePost(ctx, postID, userID)
+	if err != nil {
+		return nil, serviceError("failed to restore post", err)
+	}
+	return post, nil
+}
+
+func (pr *PostServiceImpl) GetAllUserPosts(ctx context.Context, userID string, viewerID string) ([]*Post, error) {
+	posts, err := pr.Repo.GetAllUserPosts(ctx, userID, viewerID)
 	if err != nil {
 		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get user posts", err)
 	}
 	return posts, nil
 }
 
+func (pr *PostServiceImpl) PinPost(ctx context.Context, postID string, userID string) (*Post, error) {
+	post, err := pr.Repo.PinPost(ctx, postID, userID)
+	if err != nil {
+		return nil, serviceError("failed to pin post", err)
+	}
+	return post, nil
+}
+
+func (pr *PostServiceImpl) UnpinPost(ctx context.Context, postID string, userID string) (*Post, error) {
+	post, err := pr.Repo.UnpinPost(ctx, postID, userID)
+	if err != nil {
+		return nil, serviceError("failed to unpin post", err)
+	}
+	return post, nil
+}
+
+func (pr *PostServiceImpl) GetPinnedPosts(ctx context.Context, userID string, viewerID string) ([]*Post, error) {
+	pinned, err := pr.Repo.GetPinnedPosts(ctx, userID, viewerID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get pinned posts", err)
+	}
+	return pinned, nil
+}
+
 func (pr *PostServiceImpl) Repost(ctx context.Context, postID string, userID string) (*Post, error) {
 	repost, err := pr.Repo.Repost(ctx, postID, userID)
 	if err != nil {
-		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to repost", err)
+		return nil, serviceError("failed to repost", err)
 	}
 	return repost, nil
 }
 
+func (pr *PostServiceImpl) UndoRepost(ctx context.Context, postID string, userID string) error {
+	if err := pr.Repo.UndoRepost(ctx, postID, userID); err != nil {
+		return serviceError("failed to undo repost", err)
+	}
+	return nil
+}
+
+func (pr *PostServiceImpl) GetRepostedPost(ctx context.Context, postID string, viewerID string) (*Post, error) {
+	post, err := pr.Repo.GetRepostedPost(ctx, postID, viewerID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get reposted post", err)
+	}
+	return post, nil
+}
+
+func (pr *PostServiceImpl) GetReposters(ctx context.Context, postID string, viewerID string, first int, after *string) (*ReposterPage, error) {
+	if first < 1 {
+		first = repostersDefaultFirst
+	}
+	if first > repostersMaxFirst {
+		first = repostersMaxFirst
+	}
+	page, err := pr.Repo.GetReposters(ctx, postID, viewerID, first, after)
+	if err != nil {
+		return nil, serviceError("failed to get reposters", err)
+	}
+	return page, nil
+}
+
 func (pr *PostServiceImpl) AddComment(ctx context.Context, postID string, input CreatePostInput, userID string) (*Post, error) {
+	if input.Audience != nil {
+		return nil, errorx.New(errorx.ErrCodeValidation, "replies share the audience of their thread", nil)
+	}
+	input.Sanitize()
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
 	comment, err := pr.Repo.AddComment(ctx, postID, input, userID)
 	if err != nil {
-		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to add comment", err)
+		return nil, serviceError("failed to add comment", err)
 	}
 	return comment, nil
 }
 
-func (pr *PostServiceImpl) GetPostComments(ctx context.Context, postID string) ([]*Post, error) {
-	comments, err := pr.Repo.GetPostComments(ctx, postID)
+func (pr *PostServiceImpl) QuotePost(ctx context.Context, postID string, userID string, input CreatePostInput) (*Post, error) {
+	if input.isDraft() {
+		return nil, errorx.New(errorx.ErrCodeValidation, "quotes cannot be saved as drafts", nil)
+	}
+	input.Sanitize()
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	quote, err := pr.Repo.QuotePost(ctx, postID, userID, input)
+	if err != nil {
+		return nil, serviceError("failed to quote post", err)
+	}
+	return quote, nil
+}
+
+func (pr *PostServiceImpl) GetQuotedPost(ctx context.Context, postID string, viewerID string) (*Post, error) {
+	post, err := pr.Repo.GetQuotedPost(ctx, postID, viewerID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get quoted post", err)
+	}
+	return post, nil
+}
+
+func (pr *PostServiceImpl) GetQuoteCount(ctx context.Context, postID string) (int, error) {
+	count, err := pr.Repo.GetQuoteCount(ctx, postID)
+	if err != nil {
+		return 0, serviceError("failed to get quote count", err)
+	}
+	return count, nil
+}
+
+func (pr *PostServiceImpl) GetPoll(ctx context.Context, postID string, viewerID string) (*Poll, error) {
+	poll, err := pr.Repo.GetPoll(ctx, postID, viewerID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get poll", err)
+	}
+	return poll, nil
+}
+
+func (pr *PostServiceImpl) VotePoll(ctx context.Context, postID string, userID string, optionIDs []string) (*Poll, error) {
+	if err := pr.Repo.VotePoll(ctx, postID, userID, optionIDs, false); err != nil {
+		return nil, serviceError("failed to vote in poll", err)
+	}
+	return pr.GetPoll(ctx, postID, userID)
+}
+
+func (pr *PostServiceImpl) ChangePollVote(ctx context.Context, postID string, userID string, optionIDs []string) (*Poll, error) {
+	if err := pr.Repo.VotePoll(ctx, postID, userID, optionIDs, true); err != nil {
+		return nil, serviceError("failed to change poll vote", err)
+	}
+	return pr.GetPoll(ctx, postID, userID)
+}
+
+// GetThread loads a post with its replies. depth and first are clamped to the supported range.
+func (pr *PostServiceImpl) GetThread(ctx context.Context, postID string, viewerID string, depth int, first int, after *string) (*ThreadNode, error) {
+	if depth < 0 {
+		depth = threadDefaultDepth
+	}
+	if depth > threadMaxDepth {
+		depth = threadMaxDepth
+	}
+	if first < 1 {
+		first = threadDefaultFirst
+	}
+	if first > threadMaxFirst {
+		first = threadMaxFirst
+	}
+	thread, err := pr.Repo.GetThread(ctx, postID, viewerID, depth, first, after)
+	if err != nil {
+		return nil, serviceError("failed to get thread", err)
+	}
+	return thread, nil
+}
+
+func (pr *PostServiceImpl) GetPostComments(ctx context.Context, postID string, viewerID string) ([]*Post, error) {
+	comments, err := pr.Repo.GetPostComments(ctx, postID, viewerID)
 	if err != nil {
 		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get post comments", err)
 	}
@@ -313,7 +943,7 @@ func (pr *PostServiceImpl) GetPostComments(ctx context.Context, postID string) (
 func (pr *PostServiceImpl) LikePost(ctx context.Context, postID string, userID string) (PostResponse, error) {
 	postresp, err := pr.Repo.LikePost(ctx, postID, userID)
 	if err != nil {
-		return postresp, errorx.New(errorx.ErrCodeDatabase, "failed to like post", err)
+		return postresp, serviceError("failed to like post", err)
 	}
 	return postresp, nil
 }
@@ -321,11 +951,106 @@ func (pr *PostServiceImpl) LikePost(ctx context.Context, postID string, userID s
 func (pr *PostServiceImpl) UnlikePost(ctx context.Context, postID string, userID string) (PostResponse, error) {
 	postresp, err := pr.Repo.UnlikePost(ctx, postID, userID)
 	if err != nil {
-		return postresp, errorx.New(errorx.ErrCodeDatabase, "failed to unlike post", err)
+		return postresp, serviceError("failed to unlike post", err)
 	}
 	return postresp, nil
 }
 
+func (pr *PostServiceImpl) React(ctx context.Context, postID string, userID string, kind ReactionKind) (*Post, error) {
+	if !kind.IsValid() {
+		return nil, errorx.New(errorx.ErrCodeValidation, "invalid reaction", nil)
+	}
+	if err := pr.Repo.React(ctx, postID, userID, kind); err != nil {
+		return nil, serviceError("failed to react to post", err)
+	}
+	return pr.GetPost(ctx, postID, userID)
+}
+
+func (pr *PostServiceImpl) Unreact(ctx context.Context, postID string, userID string) error {
+	if err := pr.Repo.Unreact(ctx, postID, userID); err != nil {
+		return serviceError("failed to remove reaction", err)
+	}
+	return nil
+}
+
+func (pr *PostServiceImpl) GetReactionCounts(ctx context.Context, postID string) ([]*ReactionCount, error) {
+	counts, err := pr.Repo.GetReactionCounts(ctx, postID)
+	if err != nil {
+		return nil, serviceError("failed to get reaction counts", err)
+	}
+	return counts, nil
+}
+
+func (pr *PostServiceImpl) GetViewerReaction(ctx context.Context, postID string, viewerID string) (*ReactionKind, error) {
+	kind, err := pr.Repo.GetViewerReaction(ctx, postID, viewerID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get reaction", err)
+	}
+	return kind, nil
+}
+
+func (pr *PostServiceImpl) GetReactions(ctx context.Context, postID string, viewerID string, kind *ReactionKind, first int, after *string) (*ReactionPage, error) {
+	if kind != nil && !kind.IsValid() {
+		return nil, errorx.New(errorx.ErrCodeValidation, "invalid reaction", nil)
+	}
+	if first < 1 {
+		first = reactionsDefaultFirst
+	}
+	if first > reactionsMaxFirst {
+		first = reactionsMaxFirst
+	}
+	page, err := pr.Repo.GetReactions(ctx, postID, viewerID, kind, first, after)
+	if err != nil {
+		return nil, serviceError("failed to get reactions", err)
+	}
+	return page, nil
+}
+
+func (pr *PostServiceImpl) GetPostLabels(ctx context.Context, postID string, viewerID string) (*PostLabels, error) {
+	labels, err := pr.Repo.GetPostLabels(ctx, postID, viewerID)
+	if err != nil {
+		return nil, serviceError("failed to get post labels", err)
+	}
+	return labels, nil
+}
+
+func (pr *PostServiceImpl) LabelPost(ctx context.Context, postID string, moderatorID string, labels ContentLabels) (*PostLabels, error) {
+	labels.Sanitize()
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
+	if err := pr.Repo.LabelPost(ctx, postID, moderatorID, labels); err != nil {
+		return nil, serviceError("failed to label post", err)
+	}
+	return pr.GetPostLabels(ctx, postID, moderatorID)
+}
+
+func (pr *PostServiceImpl) GetLabelHistory(ctx context.Context, postID string, moderatorID string) ([]*LabelChange, error) {
+	changes, err := pr.Repo.GetLabelHistory(ctx, postID, moderatorID)
+	if err != nil {
+		return nil, serviceError("failed to get label history", err)
+	}
+	return changes, nil
+}
+
+func (pr *PostServiceImpl) GetContentFilter(ctx context.Context, userID string) (ContentFilter, error) {
+	filter, err := pr.Repo.GetContentFilter(ctx, userID)
+	if err != nil {
+		return "", serviceError("failed to get content filter", err)
+	}
+	return filter, nil
+}
+
+func (pr *PostServiceImpl) SetContentFilter(ctx context.Context, userID string, filter ContentFilter) (ContentFilter, error) {
+	if !filter.IsValid() {
+		return "", errorx.New(errorx.ErrCodeInvalidEnum, "invalid content filter", nil)
+	}
+	if err := pr.Repo.SetContentFilter(ctx, userID, filter); err != nil {
+		return "", serviceError("failed to set content filter", err)
+	}
+	return filter, nil
+}
+
 func (pr *PostServiceImpl) GetUserFeed(ctx context.Context, userID string) ([]*Post, error) {
 	feed, err := pr.Repo.GetUserFeed(ctx, userID)
 	if err != nil {
@@ -334,10 +1059,79 @@ func (pr *PostServiceImpl) GetUserFeed(ctx context.Context, userID string) ([]*P
 	return feed, nil
 }
 
-func (pr *PostServiceImpl) TagUserInPost(ctx context.Context, postID string, taggedUserID string) (PostResponse, error) {
+func (pr *PostServiceImpl) GetProfileFeed(ctx context.Context, username string) (*ProfileFeed, error) {
+	feed, err := pr.Repo.GetProfileFeed(ctx, strings.TrimPrefix(username, "@"))
+	if err != nil {
+		return nil, serviceError("failed to get profile feed", err)
+	}
+	return feed, nil
+}
+
+func (pr *PostServiceImpl) GetFederatedPost(ctx context.Context, postID string) (*FederatedPost, error) {
+	post, err := pr.Repo.GetFederatedPost(ctx, postID)
+	if err != nil {
+		return nil, serviceError("failed to get post", err)
+	}
+	return post, nil
+}
+
+func (pr *PostServiceImpl) GetFederatedPosts(ctx context.Context, userID string, limit int) ([]*FederatedPost, error) {
+	posts, err := pr.Repo.GetFederatedPosts(ctx, userID, limit)
+	if err != nil {
+		return nil, serviceError("failed to get posts", err)
+	}
+	return posts, nil
+}
+
+func (pr *PostServiceImpl) GetPostIDByObjectURI(ctx context.Context, objectURI string) (string, error) {
+	postID, err := pr.Repo.GetPostIDByObjectURI(ctx, objectURI)
+	if err != nil {
+		return "", serviceError("failed to get post", err)
+	}
+	return postID, nil
+}
+
+// SaveRemotePost stores a post received from another server, replies need a parent
+// the remote author can see
+func (pr *PostServiceImpl) SaveRemotePost(ctx context.Context, input RemotePostInput) (string, error) {
+	input.Content = strings.TrimSpace(input.Content)
+	input.Labels.Sanitize()
+	if input.Content == "" {
+		return "", errorx.New(errorx.ErrCodeBadRequest, "length of post body is too short", nil)
+	}
+	if err := input.Labels.Validate(); err != nil {
+		return "", err
+	}
+	postID, err := pr.Repo.SaveRemotePost(ctx, input)
+	if err != nil {
+		return "", serviceError("failed to save remote post", err)
+	}
+	return postID, nil
+}
+
+func (pr *PostServiceImpl) TagUserInPost(ctx context.Context, postID string, userID string, taggedUserID string) (PostResponse, error) {
+	if err := pr.requireAuthor(ctx, postID, userID, "tag users in"); err != nil {
+		return PostResponse{Message: "unable to tag user"}, err
+	}
 	postResp, err := pr.Repo.TagUserInPost(ctx, postID, taggedUserID)
 	if err != nil {
 		return postResp, errorx.New(errorx.ErrCodeDatabase, "failed to tag user in post", err)
 	}
 	return postResp, nil
 }
+
+func (pr *PostServiceImpl) GetPostAttachments(ctx context.Context, postID string) ([]*Attachment, error) {
+	attachments, err := pr.Repo.GetPostAttachments(ctx, postID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get post attachments", err)
+	}
+	return attachments, nil
+}
+
+func (pr *PostServiceImpl) GetPostEntities(ctx context.Context, postID string) ([]PostEntity, error) {
+	entities, err := pr.Repo.GetPostEntities(ctx, postID)
+	if err != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "failed to get post entities", err)
+	}
+	return entities, nil
+}
@@ -3,22 +3,85 @@ package posts
 import (
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestCreatePostInput_Sanitize(t *testing.T) {
+	title := "title "
 	postData :=
 		CreatePostInput{
-			Title:   "title ",
+			Title:   &title,
 			Content: "body   ",
 		}
 	postData.Sanitize()
+	wantTitle := "title"
 	wantData :=
 		CreatePostInput{
-			Title:   "title",
+			Title:   &wantTitle,
 			Content: "body",
 		}
 	require.Equal(t, wantData, postData)
 }
 func TestCreatePostInput_Validate(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	closeFriends := AudienceCloseFriends
+	unknownAudience := Audience("EVERYONE")
+	spoiler := WarningSpoiler
+	unknownCategory := WarningCategory("GORE")
+	warning := "ending of the film"
+	tests := []struct {
+		name    string
+		input   CreatePostInput
+		wantErr bool
+	}{
+		{name: "plain post", input: CreatePostInput{Content: "hello"}},
+		{name: "short body", input: CreatePostInput{Content: "h"}, wantErr: true},
+		{name: "close friends audience", input: CreatePostInput{Content: "hello", Audience: &closeFriends}},
+		{name: "unknown audience", input: CreatePostInput{Content: "hello", Audience: &unknownAudience}, wantErr: true},
+		{
+			name:  "valid poll",
+			input: CreatePostInput{Content: "pick one", Poll: &CreatePollInput{Options: []string{"yes", "no"}, ClosesAt: future}},
+		},
+		{
+			name:    "poll with one option",
+			input:   CreatePostInput{Content: "pick one", Poll: &CreatePollInput{Options: []string{"yes"}, ClosesAt: future}},
+			wantErr: true,
+		},
+		{
+			name:    "poll with five options",
+			input:   CreatePostInput{Content: "pick one", Poll: &CreatePollInput{Options: []string{"a", "b", "c", "d", "e"}, ClosesAt: future}},
+			wantErr: true,
+		},
+		{
+			name:    "poll with duplicate options",
+			input:   CreatePostInput{Content: "pick one", Poll: &CreatePollInput{Options: []string{"Yes", "yes "}, ClosesAt: future}},
+			wantErr: true,
+		},
+		{
+			name:    "poll already closed",
+			input:   CreatePostInput{Content: "pick one", Poll: &CreatePollInput{Options: []string{"yes", "no"}, ClosesAt: time.Now().Add(-time.Minute)}},
+			wantErr: true,
+		},
+		{name: "labeled post", input: CreatePostInput{Content: "hello", Labels: &ContentLabels{Category: &spoiler, Warning: &warning}}},
+		{name: "warning without category", input: CreatePostInput{Content: "hello", Labels: &ContentLabels{Warning: &warning}}, wantErr: true},
+		{name: "unknown warning category", input: CreatePostInput{Content: "hello", Labels: &ContentLabels{Category: &unknownCategory}}, wantErr: true},
+	}
 
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCreatePostInput_SanitizeLabels(t *testing.T) {
+	blank := "   "
+	postData := CreatePostInput{Content: "hello", Labels: &ContentLabels{Warning: &blank}}
+	postData.Sanitize()
+	require.Nil(t, postData.Labels.Warning)
 }
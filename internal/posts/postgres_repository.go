@@ -6,17 +6,20 @@ import (
 	"github.com/bertoxic/graphqlChat/internal/database"
 	"github.com/bertoxic/graphqlChat/internal/database/postgres"
 	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/webhooks"
 	"github.com/jackc/pgx/v4"
 	"time"
 )
 
 type PostRepo struct {
-	DB database.DatabaseRepo
+	DB    database.DatabaseRepo
+	Redis *database.RedisClient
 }
 
-func NewPostRepo(db database.DatabaseRepo) *PostRepo {
+func NewPostRepo(db database.DatabaseRepo, rdb *database.RedisClient) *PostRepo {
 	return &PostRepo{
-		DB: db,
+		DB:    db,
+		Redis: rdb,
 	}
 }
 
@@ -54,34 +57,110 @@ func (pr *PostRepo) createPostTx(ctx context.Context, tx pgx.Tx, input CreatePos
 		parentIDValue = nil
 	}
 
+	if parentID != nil {
+		if err := requireVisible(ctx, tx, *parentID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// replies are readable by whoever can read the top of their thread
 	query := `
-    INSERT INTO posts (user_id, title, content, image_url, audio_url, parent_id, is_edited, created_at, updated_at)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8,$9)
-    RETURNING id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts
+    INSERT INTO posts (user_id, title, content, image_url, audio_url, parent_id, is_edited, is_draft, publish_at, created_at, updated_at, audience, audience_post_id)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, (SELECT COALESCE(audience_post_id, id) FROM posts WHERE id = $6))
+    RETURNING id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, is_draft, publish_at
 `
 	var post Post
 	err := tx.QueryRow(ctx, query,
 		userID, input.Title, input.Content, input.ImageURL, input.AudioURL, parentIDValue,
-		false, time.Now(), time.Now(),
+		false, input.isDraft(), input.PublishAt, time.Now(), time.Now(), input.audience(),
 	).Scan(
 		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
-		&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
+		&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience, &post.IsDraft, &post.PublishAt,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("error executing query: %w", err)
 	}
-	for _, tag := range post.Tags {
-		_, err = pr.addTag(ctx, tx, post.ID, tag)
+	post.Entities, post.Tags, err = pr.syncEntitiesTx(ctx, tx, post.ID, post.Content)
+	if err != nil {
+		return nil, fmt.Errorf("error extracting post entities: %w", err)
+	}
+	if input.Poll != nil {
+		if err = pr.createPollTx(ctx, tx, post.ID, input.Poll); err != nil {
+			return nil, err
+		}
+	}
+	if err = pr.attachMediaTx(ctx, tx, post.ID, userID, input.Attachments); err != nil {
+		return nil, err
+	}
+	if input.Labels != nil && input.Labels.IsLabeled() {
+		if err = setLabelsTx(ctx, tx, post.ID, userID, *input.Labels, false); err != nil {
+			return nil, err
+		}
+	}
+	if parentID != nil {
+		tag, err := tx.Exec(ctx, `
+            UPDATE posts SET comment_count = comment_count + 1
+            WHERE id = $1 AND is_draft = FALSE AND deleted_at IS NULL
+        `, *parentID)
 		if err != nil {
-			return nil, fmt.Errorf("error adding tag: %w", err)
+			return nil, fmt.Errorf("error updating comment count: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return nil, errorx.New(errorx.ErrCodeNotFound, "parent post not found", nil)
+		}
+	}
+	// the revision history of a draft starts when it is published
+	if !input.isDraft() {
+		if err = pr.insertRevisionTx(ctx, tx, &post, userID, post.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err = recordPostCreatedTx(ctx, tx, &post); err != nil {
+			return nil, err
 		}
 	}
 
 	return &post, nil
 }
 
-func (pr *PostRepo) GetPost(ctx context.Context, postID string) (*Post, error) {
+// recordPostCreatedTx tells the webhooks of the author, and for replies of the parent's
+// author, about a published post. Replies report the audience of their thread, which is
+// who can read them.
+func recordPostCreatedTx(ctx context.Context, tx pgx.Tx, post *Post) error {
+	var audience string
+	var parentAuthorID *string
+	err := tx.QueryRow(ctx, `
+        SELECT COALESCE(t.audience, p.audience), parent.user_id
+        FROM posts p
+        LEFT JOIN posts t ON t.id = p.audience_post_id
+        LEFT JOIN posts parent ON parent.id = p.parent_id
+        WHERE p.id = $1
+    `, post.ID).Scan(&audience, &parentAuthorID)
+	if err != nil {
+		return fmt.Errorf("error fetching post audience: %w", err)
+	}
+
+	userIDs := []string{post.UserID}
+	if parentAuthorID != nil {
+		userIDs = append(userIDs, *parentAuthorID)
+	}
+
+	return webhooks.RecordEventTx(ctx, tx, webhooks.Event{
+		Type:    webhooks.EventPostCreated,
+		UserIDs: userIDs,
+		Data: webhooks.PostCreated{
+			PostID:    post.ID,
+			UserID:    post.UserID,
+			ParentID:  post.ParentID,
+			Content:   post.Content,
+			Audience:  audience,
+			CreatedAt: post.CreatedAt,
+		},
+	})
+}
+
+// GetPost returns the post with the replies the viewer can read
+func (pr *PostRepo) GetPost(ctx context.Context, postID string, viewerID string) (*Post, error) {
 	db, ok := pr.DB.(*postgres.PostgresDBRepo)
 	if !ok {
 		return nil, fmt.Errorf("pr.Repo does not implement database.Database")
@@ -91,22 +170,25 @@ func (pr *PostRepo) GetPost(ctx context.Context, postID string) (*Post, error) {
 
 	query := `
         WITH RECURSIVE post_tree AS (
-            SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, 0 AS depth
+            SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, deleted_at, 0 AS depth
             FROM posts
-            WHERE id = $1
+            WHERE id = $1 AND is_draft = FALSE AND ` + keptInThreadSQL("posts") + `
+              AND ` + postVisibleSQL("posts", 2) + `
             
             UNION ALL
             
-            SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts, pt.depth + 1
+            SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts, p.audience, p.deleted_at, pt.depth + 1
             FROM posts p
             JOIN post_tree pt ON p.parent_id = pt.id
+            WHERE p.is_draft = FALSE AND ` + keptInThreadSQL("p") + `
+              AND ` + postVisibleSQL("p", 2) + `
         )
-        SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, depth
+        SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, deleted_at, depth
         FROM post_tree
         ORDER BY depth, created_at DESC
     `
 
-	rows, err := pgDB.Query(ctx, query, postID)
+	rows, err := pgDB.Query(ctx, query, postID, viewerArg(viewerID))
 	if err != nil {
 		return nil, fmt.Errorf("error fetching post and descendants: %w", err)
 	}
@@ -120,11 +202,12 @@ func (pr *PostRepo) GetPost(ctx context.Context, postID string) (*Post, error) {
 		var depth int
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
-			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &depth,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience, &post.DeletedAt, &depth,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning post: %w", err)
 		}
+		post.scrubDeleted()
 
 		postMap[post.ID] = &post
 
@@ -147,7 +230,9 @@ func (pr *PostRepo) GetPost(ctx context.Context, postID string) (*Post, error) {
 	return rootPost, nil
 }
 
-func (pr *PostRepo) UpdatePost(ctx context.Context, postID string, input CreatePostInput) (*Post, error) {
+// UpdatePost saves the new content as a revision of the post. When editWindow is
+// positive, posts older than the window can no longer be changed.
+func (pr *PostRepo) UpdatePost(ctx context.Context, postID string, input CreatePostInput, editorID string, editWindow time.Duration) (*Post, error) {
 	db, ok := pr.DB.(*postgres.PostgresDBRepo)
 	if !ok {
 		return nil, fmt.Errorf("pr.Repo does not implement database.Database")
@@ -161,25 +246,72 @@ func (pr *PostRepo) UpdatePost(ctx context.Context, postID string, input CreateP
 	}
 	defer tx.Rollback(ctx)
 
+	var current Post
+	err = tx.QueryRow(ctx, `
+		SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, is_draft
+		FROM posts
+		WHERE id = $1
+		FOR UPDATE
+	`, postID).Scan(
+		&current.ID, &current.UserID, &current.Title, &current.Content, &current.ImageURL, &current.AudioURL, &current.ParentID, &current.CreatedAt, &current.IsDraft,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching post: %w", err)
+	}
+
+	if current.IsDraft != nil && *current.IsDraft {
+		return nil, errorx.New(errorx.ErrCodeBadRequest, "drafts are saved with autosaveDraft", nil)
+	}
+
+	if current.ParentID != nil && input.Audience != nil {
+		return nil, errorx.New(errorx.ErrCodeValidation, "replies share the audience of their thread", nil)
+	}
+
+	if editWindow > 0 && time.Since(current.CreatedAt) > editWindow {
+		return nil, errorx.New(errorx.ErrCodeResourceLocked, "post can no longer be edited", nil)
+	}
+
+	if err = pr.ensureOriginalRevisionTx(ctx, tx, &current); err != nil {
+		return nil, err
+	}
+
 	query := `
 		UPDATE posts
-		SET title = $1, content = $2, image_url = $3, audio_url = $4, updated_at = $5
+		SET title = $1, content = $2, image_url = $3, audio_url = $4, is_edited = TRUE, updated_at = $5, audience = COALESCE($7, audience)
 		WHERE id = $6
-		RETURNING id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts
+		RETURNING id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, is_edited
 	`
 
 	var post Post
 	err = tx.QueryRow(ctx, query,
-		input.Title, input.Content, input.ImageURL, input.AudioURL, time.Now(), postID,
+		input.Title, input.Content, input.ImageURL, input.AudioURL, time.Now(), postID, input.Audience,
 	).Scan(
 		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
-		&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
+		&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience, &post.IsEdited,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("error updating post: %w", err)
 	}
 
+	post.Entities, post.Tags, err = pr.syncEntitiesTx(ctx, tx, post.ID, post.Content)
+	if err != nil {
+		return nil, fmt.Errorf("error extracting post entities: %w", err)
+	}
+
+	if input.Labels != nil {
+		if err = setLabelsTx(ctx, tx, post.ID, editorID, *input.Labels, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = pr.insertRevisionTx(ctx, tx, &post, editorID, post.UpdatedAt); err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -187,6 +319,8 @@ func (pr *PostRepo) UpdatePost(ctx context.Context, postID string, input CreateP
 	return &post, nil
 }
 
+// DeletePost marks the post as deleted. The row is kept so replies stay attached to the
+// thread, where the post shows up as a placeholder while it still has replies.
 func (pr *PostRepo) DeletePost(ctx context.Context, postID string) (PostResponse, error) {
 	db, ok := pr.DB.(*postgres.PostgresDBRepo)
 	if !ok {
@@ -198,10 +332,25 @@ func (pr *PostRepo) DeletePost(ctx context.Context, postID string) (PostResponse
 
 	pgDB := db.DB
 
-	// SQL query to delete a post by its ID
-	query := `DELETE FROM posts WHERE id = $1`
+	tx, err := pgDB.Begin(ctx)
+	if err != nil {
+		return PostResponse{
+			Message: "failed to begin transaction",
+			Success: false,
+		}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE posts SET deleted_at = $1, is_pinned = FALSE, pinned_at = NULL WHERE id = $2 AND deleted_at IS NULL RETURNING parent_id, repost_of_id, quoted_post_id`
 
-	_, err := pgDB.Exec(ctx, query, postID)
+	var parentID, repostOfID, quotedPostID *string
+	err = tx.QueryRow(ctx, query, time.Now(), postID).Scan(&parentID, &repostOfID, &quotedPostID)
+	if err == pgx.ErrNoRows {
+		return PostResponse{
+			Message: "post not found",
+			Success: false,
+		}, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
 	if err != nil {
 		return PostResponse{
 			Message: "error deleting post",
@@ -209,6 +358,42 @@ func (pr *PostRepo) DeletePost(ctx context.Context, postID string) (PostResponse
 		}, fmt.Errorf("error deleting post: %w", err)
 	}
 
+	if parentID != nil {
+		_, err = tx.Exec(ctx, `UPDATE posts SET comment_count = GREATEST(comment_count - 1, 0) WHERE id = $1`, *parentID)
+		if err != nil {
+			return PostResponse{
+				Message: "error deleting post",
+				Success: false,
+			}, fmt.Errorf("error updating comment count: %w", err)
+		}
+	}
+	// deleting a repost undoes it
+	if repostOfID != nil {
+		_, err = tx.Exec(ctx, `UPDATE posts SET reposts = GREATEST(reposts - 1, 0) WHERE id = $1`, *repostOfID)
+		if err != nil {
+			return PostResponse{
+				Message: "error deleting post",
+				Success: false,
+			}, fmt.Errorf("error updating repost count: %w", err)
+		}
+	}
+	if quotedPostID != nil {
+		_, err = tx.Exec(ctx, `UPDATE posts SET quote_count = GREATEST(quote_count - 1, 0) WHERE id = $1`, *quotedPostID)
+		if err != nil {
+			return PostResponse{
+				Message: "error deleting post",
+				Success: false,
+			}, fmt.Errorf("error updating quote count: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return PostResponse{
+			Message: "failed to commit transaction",
+			Success: false,
+		}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return PostResponse{
 		Message: "post successfully deleted",
 		Success: true,
@@ -235,41 +420,27 @@ func (pr *PostRepo) LikePost(ctx context.Context, postID string, userID string)
 	}
 	defer tx.Rollback(ctx)
 
-	// Check if the user has already liked the post
-	checkQuery := `SELECT EXISTS(SELECT 1 FROM post_likes WHERE post_id = $1 AND user_id = $2)`
-	var exists bool
-	err = tx.QueryRow(ctx, checkQuery, postID, userID).Scan(&exists)
-	if err != nil {
-		return PostResponse{
-			Message: "error checking existing like",
-			Success: false,
-		}, fmt.Errorf("error checking existing like: %w", err)
-	}
-
-	if exists {
+	if err = requireVisible(ctx, tx, postID, userID); err != nil {
 		return PostResponse{
-			Message: "user has already liked this post",
+			Message: "post not found",
 			Success: false,
-		}, errorx.New(errorx.ErrCodeConflict, "user has already liked this post", nil)
+		}, err
 	}
 
-	// If not, add the like
-	insertQuery := `INSERT INTO post_likes (post_id, user_id) VALUES ($1, $2)`
-	_, err = tx.Exec(ctx, insertQuery, postID, userID)
+	// a like is the LIKE reaction, another reaction of the user is switched to it
+	previous, err := setReaction(ctx, tx, postID, userID, ReactionLike)
 	if err != nil {
 		return PostResponse{
 			Message: "error adding like",
 			Success: false,
-		}, fmt.Errorf("error adding like: %w", err)
+		}, err
 	}
 
-	updateQuery := `UPDATE posts SET likes = likes + 1 WHERE id = $1`
-	_, err = tx.Exec(ctx, updateQuery, postID)
-	if err != nil {
+	if previous != nil && *previous == ReactionLike {
 		return PostResponse{
-			Message: "error updating post likes count",
+			Message: "user has already liked this post",
 			Success: false,
-		}, fmt.Errorf("error updating post likes count: %w", err)
+		}, errorx.New(errorx.ErrCodeConflict, "user has already liked this post", nil)
 	}
 
 	// Commit the transaction if all is successful...htis
@@ -280,6 +451,10 @@ func (pr *PostRepo) LikePost(ctx context.Context, postID string, userID string)
 		}, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	if previous == nil {
+		pr.recordEngagement(ctx, postID, engagementLike, 1)
+	}
+
 	return PostResponse{
 		Message: "post successfully liked",
 		Success: true,
@@ -306,41 +481,22 @@ func (pr *PostRepo) UnlikePost(ctx context.Context, postID string, userID string
 	}
 	defer tx.Rollback(ctx)
 
-	checkQuery := `SELECT EXISTS(SELECT 1 FROM post_likes WHERE post_id = $1 AND user_id = $2)`
-	var exists bool
-	err = tx.QueryRow(ctx, checkQuery, postID, userID).Scan(&exists)
+	like := ReactionLike
+	removed, err := removeReaction(ctx, tx, postID, userID, &like)
 	if err != nil {
 		return PostResponse{
-			Message: "error checking existing like",
+			Message: "error removing like",
 			Success: false,
-		}, fmt.Errorf("error checking existing like: %w", err)
+		}, err
 	}
 
-	if !exists {
+	if removed == nil {
 		return PostResponse{
 			Message: "user has not liked this post",
 			Success: false,
 		}, errorx.New(errorx.ErrCodeNotFound, "user has not liked this post", nil)
 	}
 
-	deleteQuery := `DELETE FROM post_likes WHERE post_id = $1 AND user_id = $2`
-	_, err = tx.Exec(ctx, deleteQuery, postID, userID)
-	if err != nil {
-		return PostResponse{
-			Message: "error removing like",
-			Success: false,
-		}, fmt.Errorf("error removing like: %w", err)
-	}
-
-	updateQuery := `UPDATE posts SET likes = likes - 1 WHERE id = $1`
-	_, err = tx.Exec(ctx, updateQuery, postID)
-	if err != nil {
-		return PostResponse{
-			Message: "error updating post likes count",
-			Success: false,
-		}, fmt.Errorf("error updating post likes count: %w", err)
-	}
-
 	if err = tx.Commit(ctx); err != nil {
 		return PostResponse{
 			Message: "failed to commit transaction",
@@ -348,6 +504,8 @@ func (pr *PostRepo) UnlikePost(ctx context.Context, postID string, userID string
 		}, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	pr.recordEngagement(ctx, postID, engagementLike, -1)
+
 	return PostResponse{
 		Message: "successfully unliked post",
 		Success: true,
@@ -381,7 +539,7 @@ func (pr *PostRepo) addTag(ctx context.Context, tx pgx.Tx, postID string, tagNam
 	}, nil
 }
 
-func (pr *PostRepo) GetUsersWhoLikedPost(ctx context.Context, postID string) ([]string, error) {
+func (pr *PostRepo) GetUsersWhoLikedPost(ctx context.Context, postID string, viewerID string) ([]string, error) {
 	db, ok := pr.DB.(*postgres.PostgresDBRepo)
 	if !ok {
 		return nil, fmt.Errorf("pr.Repo does not implement database.Database")
@@ -389,7 +547,11 @@ func (pr *PostRepo) GetUsersWhoLikedPost(ctx context.Context, postID string) ([]
 
 	pgDB := db.DB
 
-	query := `SELECT user_id FROM post_likes WHERE post_id = $1`
+	if err := requireVisible(ctx, pgDB, postID, viewerID); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT user_id FROM post_reactions WHERE post_id = $1 AND kind = 'LIKE'`
 	rows, err := pgDB.Query(ctx, query, postID)
 	if err != nil {
 		return nil, fmt.Errorf("error querying users who liked post: %w", err)
@@ -412,20 +574,22 @@ func (pr *PostRepo) GetUsersWhoLikedPost(ctx context.Context, postID string) ([]
 	return userIDs, nil
 }
 
-func (pr *PostRepo) GetAllUserPosts(ctx context.Context, userID string) ([]*Post, error) {
+// GetAllUserPosts returns the posts of the user that the viewer can read, pinned posts first
+func (pr *PostRepo) GetAllUserPosts(ctx context.Context, userID string, viewerID string) ([]*Post, error) {
 	db, ok := pr.DB.(*postgres.PostgresDBRepo)
 	if !ok {
 		return nil, fmt.Errorf("pr.Repo does not implement database.Database")
 	}
 	pgDB := db.DB
 	query := `
-		SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts
+		SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, is_pinned
 		FROM posts
-		WHERE user_id = $1
-		ORDER BY created_at DESC
+		WHERE user_id = $1 AND is_draft = FALSE AND deleted_at IS NULL
+		  AND ` + postVisibleSQL("posts", 2) + `
+		ORDER BY is_pinned DESC, pinned_at DESC NULLS LAST, created_at DESC
 	`
 
-	rows, err := pgDB.Query(ctx, query, userID)
+	rows, err := pgDB.Query(ctx, query, userID, viewerArg(viewerID))
 	if err != nil {
 		return nil, fmt.Errorf("error fetching user posts: %w", err)
 	}
@@ -436,7 +600,7 @@ func (pr *PostRepo) GetAllUserPosts(ctx context.Context, userID string) ([]*Post
 		var post Post
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
-			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience, &post.IsPinned,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning post row: %w", err)
@@ -465,15 +629,54 @@ func (pr *PostRepo) Repost(ctx context.Context, postID string, userID string) (*
 	}
 	defer tx.Rollback(ctx)
 
+	// reposting a repost shares the original, the lock keeps the reposts counter in step
+	var originalID string
+	err = tx.QueryRow(ctx, `
+        SELECT o.id FROM posts p JOIN posts o ON o.id = COALESCE(p.repost_of_id, p.id)
+        WHERE p.id = $1
+        FOR UPDATE OF o
+    `, postID).Scan(&originalID)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reposted post: %w", err)
+	}
+	postID = originalID
+
+	if err = requireVisible(ctx, tx, postID, userID); err != nil {
+		return nil, err
+	}
+	// a repost copies the content, so only posts anyone can read are shared this way
+	public, err := canViewPost(ctx, tx, postID, "")
+	if err != nil {
+		return nil, err
+	}
+	if !public {
+		return nil, errorx.New(errorx.ErrCodeForbidden, "only public posts can be reposted", nil)
+	}
+
+	var reposted bool
+	err = tx.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM posts WHERE repost_of_id = $1 AND user_id = $2 AND deleted_at IS NULL)
+    `, postID, userID).Scan(&reposted)
+	if err != nil {
+		return nil, fmt.Errorf("error checking reposts: %w", err)
+	}
+	if reposted {
+		return nil, errorx.New(errorx.ErrCodeAlreadyExists, "you already reposted this post", nil)
+	}
+
 	originalPost, err := pr.getPostTx(ctx, tx, postID)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching Original post: %w", err)
 	}
 
+	// the repost stays hidden from anyone who loses access to the original thread
 	repostQuery := `
-		INSERT INTO posts (user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts
+		INSERT INTO posts (user_id, title, content, image_url, audio_url, repost_of_id, created_at, updated_at, audience_post_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, (SELECT COALESCE(audience_post_id, id) FROM posts WHERE id = $6))
+		RETURNING id, user_id, title, content, image_url, audio_url, parent_id, repost_of_id, created_at, updated_at, likes, reposts, audience
 	`
 
 	var repost Post
@@ -482,7 +685,7 @@ func (pr *PostRepo) Repost(ctx context.Context, postID string, userID string) (*
 		time.Now(), time.Now(),
 	).Scan(
 		&repost.ID, &repost.UserID, &repost.Title, &repost.Content, &repost.ImageURL, &repost.AudioURL,
-		&repost.ParentID, &repost.CreatedAt, &repost.UpdatedAt, &repost.Likes, &repost.Reposts,
+		&repost.ParentID, &repost.RepostOfID, &repost.CreatedAt, &repost.UpdatedAt, &repost.Likes, &repost.Reposts, &repost.Audience,
 	)
 
 	if err != nil {
@@ -499,14 +702,23 @@ func (pr *PostRepo) Repost(ctx context.Context, postID string, userID string) (*
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	pr.recordEngagement(ctx, postID, engagementRepost, 1)
+
 	return &repost, nil
 }
 
 func (pr *PostRepo) AddComment(ctx context.Context, postID string, input CreatePostInput, userID string) (*Post, error) {
-	return pr.CreatePost(ctx, input, userID, &postID)
+	comment, err := pr.CreatePost(ctx, input, userID, &postID)
+	if err != nil {
+		return nil, err
+	}
+
+	pr.recordEngagement(ctx, postID, engagementComment, 1)
+
+	return comment, nil
 }
 
-func (pr *PostRepo) GetPostComments(ctx context.Context, postID string) ([]*Post, error) {
+func (pr *PostRepo) GetPostComments(ctx context.Context, postID string, viewerID string) ([]*Post, error) {
 	db, ok := pr.DB.(*postgres.PostgresDBRepo)
 	if !ok {
 		return nil, fmt.Errorf("pr.Repo does not implement database.Database")
@@ -514,13 +726,14 @@ func (pr *PostRepo) GetPostComments(ctx context.Context, postID string) ([]*Post
 	pgDB := db.DB
 
 	query := `
-		SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts
+		SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, deleted_at
 		FROM posts
-		WHERE parent_id = $1
+		WHERE parent_id = $1 AND is_draft = FALSE AND ` + keptInThreadSQL("posts") + `
+		  AND ` + postVisibleSQL("posts", 2) + `
 		ORDER BY created_at ASC
 	`
 
-	rows, err := pgDB.Query(ctx, query, postID)
+	rows, err := pgDB.Query(ctx, query, postID, viewerArg(viewerID))
 	if err != nil {
 		return nil, fmt.Errorf("error fetching post comments: %w", err)
 	}
@@ -531,11 +744,12 @@ func (pr *PostRepo) GetPostComments(ctx context.Context, postID string) ([]*Post
 		var comment Post
 		err := rows.Scan(
 			&comment.ID, &comment.UserID, &comment.Title, &comment.Content, &comment.ImageURL, &comment.AudioURL,
-			&comment.ParentID, &comment.CreatedAt, &comment.UpdatedAt, &comment.Likes, &comment.Reposts,
+			&comment.ParentID, &comment.CreatedAt, &comment.UpdatedAt, &comment.Likes, &comment.Reposts, &comment.Audience, &comment.DeletedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning comment row: %w", err)
 		}
+		comment.scrubDeleted()
 		comments = append(comments, &comment)
 	}
 
@@ -554,10 +768,12 @@ func (pr *PostRepo) GetUserFeed(ctx context.Context, userID string) ([]*Post, er
 
 	pgDB := db.DB
 	query := `
-		SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts
+		SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts, p.audience
 		FROM posts p
 		JOIN follows f ON p.user_id = f.followed_id
-		WHERE f.follower_id = $1
+		WHERE f.follower_id = $1 AND f.status = 'ACCEPTED' AND p.is_draft = FALSE AND p.deleted_at IS NULL
+		  AND ` + postVisibleSQL("p", 1) + `
+		  AND ` + labelFilterSQL("p", 1) + `
 		ORDER BY p.created_at DESC
 		LIMIT 50
 	`
@@ -573,7 +789,7 @@ func (pr *PostRepo) GetUserFeed(ctx context.Context, userID string) ([]*Post, er
 		var post Post
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
-			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning feed post row: %w", err)
@@ -599,13 +815,14 @@ func (pr *PostRepo) TagUserInPost(ctx context.Context, postID string, taggedUser
 
 	pgDB := db.DB
 
+	// tagging a user who is already mentioned in the text keeps them when the text changes
 	query := `
-        INSERT INTO post_tags (post_id, tag_id)
-        VALUES ($1, $2)
-        ON CONFLICT (post_id, tag_id) DO NOTHING
+        INSERT INTO post_mentions (post_id, user_id, source)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (post_id, user_id) DO UPDATE SET source = EXCLUDED.source
     `
 
-	_, err := pgDB.Exec(ctx, query, postID, taggedUserID)
+	_, err := pgDB.Exec(ctx, query, postID, taggedUserID, mentionSourceTag)
 	if err != nil {
 		return PostResponse{
 			Success: false,
@@ -619,16 +836,34 @@ func (pr *PostRepo) TagUserInPost(ctx context.Context, postID string, taggedUser
 	}, nil
 }
 
+// GetPostAuthorID returns the author of a post that has not been deleted, drafts included
+func (pr *PostRepo) GetPostAuthorID(ctx context.Context, postID string) (string, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return "", fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var authorID string
+	err := db.DB.QueryRow(ctx, `SELECT user_id FROM posts WHERE id = $1 AND deleted_at IS NULL`, postID).Scan(&authorID)
+	if err == pgx.ErrNoRows {
+		return "", errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching post author: %w", err)
+	}
+	return authorID, nil
+}
+
 func (pr *PostRepo) getPostTx(ctx context.Context, tx pgx.Tx, postID string) (*Post, error) {
 	query := `
-		SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts
+		SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, is_pinned
 		FROM posts
-		WHERE id = $1
+		WHERE id = $1 AND is_draft = FALSE AND deleted_at IS NULL
 	`
 	var post Post
 	err := tx.QueryRow(ctx, query, postID).Scan(
 		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
-		&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
+		&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience, &post.IsPinned,
 	)
 
 	if err != nil {
@@ -643,97 +878,22 @@ func (pr *PostRepo) getPostTx(ctx context.Context, tx pgx.Tx, postID string) (*P
 
 //////////////////////----------------------NEW important funcs that might break the code ________________________________________//////////////////////////////
 
-func (pr *PostRepo) SearchPosts(ctx context.Context, query string) ([]*Post, error) {
+func (pr *PostRepo) GetPostsByTag(ctx context.Context, tagName string, viewerID string) ([]*Post, error) {
 	db, ok := pr.DB.(*postgres.PostgresDBRepo)
 	if !ok {
 		return nil, fmt.Errorf("pr.DB does not implement database.Database")
 	}
-
-	sqlQuery := `
-        SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts
-        FROM posts
-        WHERE to_tsvector('english', coalesce(title, '') || ' ' || content) @@ plainto_tsquery('english', $1)
-        ORDER BY created_at DESC
-    `
-
-	rows, err := db.DB.Query(ctx, sqlQuery, query)
-	if err != nil {
-		return nil, fmt.Errorf("error executing search query: %w", err)
-	}
-	defer rows.Close()
-
-	var posts []*Post
-	for rows.Next() {
-		var post Post
-		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
-			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning post: %w", err)
-		}
-		posts = append(posts, &post)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating posts: %w", err)
-	}
-
-	return posts, nil
-}
-func (pr *PostRepo) GetTrendingPosts(ctx context.Context, limit int) ([]*Post, error) {
-	db, ok := pr.DB.(*postgres.PostgresDBRepo)
-	if !ok {
-		return nil, fmt.Errorf("pr.DB does not implement database.Database")
-	}
-
 	query := `
-        SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts
-        FROM posts
-        ORDER BY (likes + reposts) DESC, created_at DESC
-        LIMIT $1
-    `
-
-	rows, err := db.DB.Query(ctx, query, limit)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching trending posts: %w", err)
-	}
-	defer rows.Close()
-
-	var posts []*Post
-	for rows.Next() {
-		var post Post
-		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
-			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning post: %w", err)
-		}
-		posts = append(posts, &post)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating posts: %w", err)
-	}
-
-	return posts, nil
-}
-
-func (pr *PostRepo) GetPostsByTag(ctx context.Context, tagName string) ([]*Post, error) {
-	db, ok := pr.DB.(*postgres.PostgresDBRepo)
-	if !ok {
-		return nil, fmt.Errorf("pr.DB does not implement database.Database")
-	}
-	query := `
-        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at
+        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts, p.audience
         FROM posts p
         JOIN post_tags pt ON p.id = pt.post_id
         JOIN tags t ON pt.tag_id = t.id
-        WHERE t.name = $1 AND p.is_draft = FALSE
+        WHERE t.name = $1 AND p.is_draft = FALSE AND p.deleted_at IS NULL
+          AND ` + postVisibleSQL("p", 2) + `
+          AND ` + labelFilterSQL("p", 2) + `
         ORDER BY p.created_at DESC
     `
-	rows, err := db.DB.Query(ctx, query, tagName)
+	rows, err := db.DB.Query(ctx, query, tagName, viewerArg(viewerID))
 	if err != nil {
 		return nil, fmt.Errorf("error querying posts by tag: %w", err)
 	}
@@ -744,7 +904,7 @@ func (pr *PostRepo) GetPostsByTag(ctx context.Context, tagName string) ([]*Post,
 		post := &Post{}
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
-			&post.ParentID, &post.CreatedAt, &post.UpdatedAt,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning post: %w", err)
@@ -798,9 +958,9 @@ func (pr *PostRepo) GetDrafts(ctx context.Context, userID string) ([]*Post, erro
 		return nil, fmt.Errorf("pr.DB does not implement database.Database")
 	}
 	query := `
-        SELECT id, title, content, image_url, audio_url, parent_id, created_at, updated_at
+        SELECT id, title, content, image_url, audio_url, parent_id, created_at, updated_at, publish_at, audience
         FROM posts
-        WHERE user_id = $1 AND is_draft = TRUE
+        WHERE user_id = $1 AND is_draft = TRUE AND deleted_at IS NULL
         ORDER BY updated_at DESC
     `
 	rows, err := db.DB.Query(ctx, query, userID)
@@ -814,7 +974,7 @@ func (pr *PostRepo) GetDrafts(ctx context.Context, userID string) ([]*Post, erro
 		draft := &Post{}
 		err := rows.Scan(
 			&draft.ID, &draft.Title, &draft.Content, &draft.ImageURL, &draft.AudioURL,
-			&draft.ParentID, &draft.CreatedAt, &draft.UpdatedAt,
+			&draft.ParentID, &draft.CreatedAt, &draft.UpdatedAt, &draft.PublishAt, &draft.Audience,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning draft: %w", err)
@@ -836,17 +996,29 @@ func (pr *PostRepo) GetDrafts(ctx context.Context, userID string) ([]*Post, erro
 	return drafts, nil
 }
 
-func (pr *PostRepo) PublishDraft(ctx context.Context, postID string) (PostResponse, error) {
+// PublishDraft makes one of the user's drafts public right away
+func (pr *PostRepo) PublishDraft(ctx context.Context, postID string, userID string) (*Post, error) {
 	db, ok := pr.DB.(*postgres.PostgresDBRepo)
 	if !ok {
-		return PostResponse{Message: "unable to publish draft", Success: ok}, fmt.Errorf("pr.DB does not implement database.Database")
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	query := `UPDATE posts SET is_draft = FALSE WHERE id = $1`
-	_, err := db.DB.Exec(ctx, query, postID)
+	defer tx.Rollback(ctx)
+
+	post, err := pr.publishDraftTx(ctx, tx, postID, userID, time.Now())
 	if err != nil {
-		return PostResponse{Message: "unable to publish draft", Success: false}, fmt.Errorf("error publishing draft: %w", err)
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
-	return PostResponse{Message: "successfully published draft", Success: true}, nil
+
+	return post, nil
 }
 
 func (pr *PostRepo) BookmarkPost(ctx context.Context, postID string, userID string) (PostResponse, error) {
@@ -858,13 +1030,29 @@ func (pr *PostRepo) BookmarkPost(ctx context.Context, postID string, userID stri
 		}, fmt.Errorf("pr.DB does not implement the expected database interface")
 	}
 
+	if err := requireVisible(ctx, db.DB, postID, userID); err != nil {
+		return PostResponse{
+			Message: "post not found",
+			Success: false,
+		}, err
+	}
+
+	collectionID, err := defaultCollectionID(ctx, db.DB, userID)
+	if err != nil {
+		return PostResponse{
+			Message: "unable to bookmark post",
+			Success: false,
+		}, err
+	}
+
+	// bookmarking a post again leaves it in the collection it is in
 	query := `
-        INSERT INTO bookmarks (user_id, post_id, created_at)
-        VALUES ($1, $2, $3)
+        INSERT INTO bookmarks (user_id, post_id, collection_id, created_at)
+        VALUES ($1, $2, $3, $4)
         ON CONFLICT (user_id, post_id) DO NOTHING
     `
 
-	_, err := db.DB.Exec(ctx, query, userID, postID, time.Now())
+	_, err = db.DB.Exec(ctx, query, userID, postID, collectionID, time.Now())
 	if err != nil {
 		return PostResponse{
 			Message: "unable to bookmark post",
@@ -914,10 +1102,11 @@ func (pr *PostRepo) GetUserBookmarkedPosts(ctx context.Context, userID string) (
 	}
 
 	query := `
-        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts
+        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts, p.audience
         FROM posts p
         JOIN bookmarks b ON p.id = b.post_id
-        WHERE b.user_id = $1
+        WHERE b.user_id = $1 AND p.is_draft = FALSE AND p.deleted_at IS NULL
+          AND ` + postVisibleSQL("p", 1) + `
         ORDER BY b.created_at DESC
     `
 
@@ -932,7 +1121,7 @@ func (pr *PostRepo) GetUserBookmarkedPosts(ctx context.Context, userID string) (
 		var post Post
 		err := rows.Scan(
 			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
-			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning post: %w", err)
@@ -975,26 +1164,30 @@ func (pr *PostRepo) GetUserBookmarkedPosts(ctx context.Context, userID string) (
 //	return &draft, nil
 //}
 
-func (pr *PostRepo) GetPostAnalytics(ctx context.Context, postID string) (*PostAnalytics, error) {
+// GetPostAnalytics reports a post that is a draft or belongs to someone else as not found
+func (pr *PostRepo) GetPostAnalytics(ctx context.Context, postID string, userID string) (*PostAnalytics, error) {
 	db, ok := pr.DB.(*postgres.PostgresDBRepo)
 	if !ok {
 		return nil, fmt.Errorf("pr.DB does not implement database.Database")
 	}
 
+	// posts whose views have not been flushed yet have no analytics row and report zero views
 	query := `
-        SELECT views, engagement_rate, unique_views, shares
-        FROM post_analytics
-        WHERE post_id = $1
+        SELECT p.view_count, COALESCE(pa.unique_views, 0), p.comment_count,
+               COALESCE(p.reposts, 0) + p.quote_count, COALESCE(pa.engagement_rate, 0)
+        FROM posts p
+        LEFT JOIN post_analytics pa ON pa.post_id = p.id
+        WHERE p.id = $1 AND p.user_id = $2 AND p.is_draft = FALSE AND p.deleted_at IS NULL
     `
 
 	var analytics PostAnalytics
-	err := db.DB.QueryRow(ctx, query, postID).Scan(
-		&analytics.Views, &analytics.Reach, &analytics.CommentsCount, &analytics.Shares,
+	err := db.DB.QueryRow(ctx, query, postID, userID).Scan(
+		&analytics.Views, &analytics.Reach, &analytics.CommentsCount, &analytics.Shares, &analytics.EngagementRate,
 	)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, errorx.New(errorx.ErrCodeNotFound, "post analytics not found", nil)
+			return nil, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
 		}
 		return nil, fmt.Errorf("error fetching post analytics: %w", err)
 	}
@@ -1014,7 +1207,7 @@ func (pr *PostRepo) GetUserPostStats(ctx context.Context, userID string) (*UserP
             COALESCE(SUM(likes), 0) as total_likes,
             COALESCE(SUM(reposts), 0) as total_reposts
         FROM posts
-        WHERE user_id = $1
+        WHERE user_id = $1 AND is_draft = FALSE AND deleted_at IS NULL
     `
 
 	var stats UserPostStats
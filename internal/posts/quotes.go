@@ -0,0 +1,122 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/models"
+	"github.com/bertoxic/graphqlChat/internal/notifications"
+	"github.com/jackc/pgx/v4"
+)
+
+// QuotePost creates a new post by the user that embeds the quoted post, and notifies the quoted author
+func (pr *PostRepo) QuotePost(ctx context.Context, postID string, userID string, input CreatePostInput) (*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var authorID string
+	err = tx.QueryRow(ctx, `SELECT user_id FROM posts WHERE id = $1 AND is_draft = FALSE AND deleted_at IS NULL`, postID).Scan(&authorID)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching quoted post: %w", err)
+	}
+
+	if err = requireVisible(ctx, tx, postID, userID); err != nil {
+		return nil, err
+	}
+
+	quote, err := pr.createPostTx(ctx, tx, input, userID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating quote: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE posts SET quoted_post_id = $1 WHERE id = $2`, postID, quote.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error linking quote: %w", err)
+	}
+	quote.QuotedPostID = &postID
+
+	_, err = tx.Exec(ctx, `UPDATE posts SET quote_count = quote_count + 1 WHERE id = $1`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("error updating quote count: %w", err)
+	}
+
+	if authorID != userID {
+		err = notifications.CreateNotificationTx(ctx, tx, &models.Notification{
+			UserID:  authorID,
+			Type:    models.Quote,
+			Title:   "Your post was quoted",
+			Content: quote.Content,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	pr.recordEngagement(ctx, postID, engagementQuote, 1)
+
+	return quote, nil
+}
+
+// GetQuotedPost returns the post quoted by postID. It returns nil when the post is not
+// a quote, or when the quoted post is hidden from the viewer by its audience, privacy or blocks.
+func (pr *PostRepo) GetQuotedPost(ctx context.Context, postID string, viewerID string) (*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT q.id, q.user_id, q.title, q.content, q.image_url, q.audio_url, q.parent_id, q.created_at, q.updated_at, q.likes, q.reposts, q.audience, q.quoted_post_id, q.quote_count
+        FROM posts p
+        JOIN posts q ON q.id = p.quoted_post_id
+        WHERE p.id = $1 AND q.is_draft = FALSE AND q.deleted_at IS NULL
+          AND ` + postVisibleSQL("q", 2) + `
+    `
+
+	var post Post
+	err := db.DB.QueryRow(ctx, query, postID, viewerArg(viewerID)).Scan(
+		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+		&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience, &post.QuotedPostID, &post.QuoteCount,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching quoted post: %w", err)
+	}
+
+	return &post, nil
+}
+
+func (pr *PostRepo) GetQuoteCount(ctx context.Context, postID string) (int, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return 0, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var count int
+	err := db.DB.QueryRow(ctx, `SELECT quote_count FROM posts WHERE id = $1`, postID).Scan(&count)
+	if err == pgx.ErrNoRows {
+		return 0, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error fetching quote count: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,368 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/models"
+	"github.com/bertoxic/graphqlChat/internal/notifications"
+	"github.com/bertoxic/graphqlChat/internal/webhooks"
+	"github.com/jackc/pgx/v4"
+	"strings"
+	"time"
+)
+
+const (
+	reactionsDefaultFirst = 20
+	reactionsMaxFirst     = 100
+)
+
+// ReactionKind is one of the fixed reactions a user can leave on a post, LIKE is what
+// likePost and unlikePost work with
+type ReactionKind string
+
+const (
+	ReactionLike  ReactionKind = "LIKE"
+	ReactionLove  ReactionKind = "LOVE"
+	ReactionLaugh ReactionKind = "LAUGH"
+	ReactionWow   ReactionKind = "WOW"
+	ReactionSad   ReactionKind = "SAD"
+	ReactionAngry ReactionKind = "ANGRY"
+)
+
+// ReactionKinds lists every kind in the order counts are shown
+var ReactionKinds = []ReactionKind{ReactionLike, ReactionLove, ReactionLaugh, ReactionWow, ReactionSad, ReactionAngry}
+
+func (k ReactionKind) IsValid() bool {
+	for _, kind := range ReactionKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Reaction is a user's reaction to a post, CreatedAt moves when they switch kinds
+type Reaction struct {
+	UserID    string       `json:"user_id"`
+	Kind      ReactionKind `json:"kind"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// ReactionPage is one page of a post's reactions, the latest first
+type ReactionPage struct {
+	Reactions  []*Reaction `json:"reactions"`
+	HasMore    bool        `json:"has_more"`
+	NextCursor *string     `json:"next_cursor,omitempty"`
+}
+
+// ReactionCount is how many users left one kind of reaction on a post
+type ReactionCount struct {
+	Kind  ReactionKind `json:"kind"`
+	Count int          `json:"count"`
+}
+
+// adjustReactionCount moves the post's counter for the kind by delta, LIKE reactions are
+// also counted in likes. It returns the author of the post.
+func adjustReactionCount(ctx context.Context, tx pgx.Tx, postID string, kind ReactionKind, delta int) (string, error) {
+	var authorID string
+	err := tx.QueryRow(ctx, `
+        UPDATE posts SET
+            reaction_counts = reaction_counts || jsonb_build_object($2::text, GREATEST(COALESCE((reaction_counts->>$2::text)::int, 0) + $3, 0)),
+            likes = CASE WHEN $2::text = 'LIKE' THEN GREATEST(likes + $3, 0) ELSE likes END
+        WHERE id = $1
+        RETURNING user_id
+    `, postID, string(kind), delta).Scan(&authorID)
+	if err != nil {
+		return "", fmt.Errorf("error updating reaction counts: %w", err)
+	}
+	return authorID, nil
+}
+
+// setReaction stores the user's reaction to the post, replacing a reaction of another kind.
+// It returns the kind the user had before, nil when the reaction is new. Only new
+// reactions notify the author, switching kinds doesn't. A reaction becoming a like, new or
+// switched, is a POST_LIKED webhook event. Callers check the post is visible.
+func setReaction(ctx context.Context, tx pgx.Tx, postID string, userID string, kind ReactionKind) (*ReactionKind, error) {
+	tag, err := tx.Exec(ctx, `
+        INSERT INTO post_reactions (post_id, user_id, kind) VALUES ($1, $2, $3)
+        ON CONFLICT (post_id, user_id) DO NOTHING
+    `, postID, userID, string(kind))
+	if err != nil {
+		return nil, fmt.Errorf("error adding reaction: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		var previous ReactionKind
+		err = tx.QueryRow(ctx, `
+            SELECT kind FROM post_reactions WHERE post_id = $1 AND user_id = $2 FOR UPDATE
+        `, postID, userID).Scan(&previous)
+		if err == pgx.ErrNoRows {
+			// removed by a concurrent request between the insert and the lock
+			return nil, errorx.New(errorx.ErrCodeConflict, "reaction changed while reacting, try again", nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error fetching reaction: %w", err)
+		}
+		if previous == kind {
+			return &previous, nil
+		}
+
+		_, err = tx.Exec(ctx, `
+            UPDATE post_reactions SET kind = $3, created_at = NOW() WHERE post_id = $1 AND user_id = $2
+        `, postID, userID, string(kind))
+		if err != nil {
+			return nil, fmt.Errorf("error changing reaction: %w", err)
+		}
+		if _, err = adjustReactionCount(ctx, tx, postID, previous, -1); err != nil {
+			return nil, err
+		}
+		authorID, err := adjustReactionCount(ctx, tx, postID, kind, 1)
+		if err != nil {
+			return nil, err
+		}
+		if kind == ReactionLike {
+			if err = recordPostLikedTx(ctx, tx, postID, userID, authorID); err != nil {
+				return nil, err
+			}
+		}
+		return &previous, nil
+	}
+
+	authorID, err := adjustReactionCount(ctx, tx, postID, kind, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if authorID != userID {
+		notification := &models.Notification{
+			UserID:  authorID,
+			Type:    models.Reaction,
+			Title:   "Someone reacted to your post",
+			Content: string(kind),
+		}
+		if kind == ReactionLike {
+			notification.Type = models.Like
+			notification.Title = "Your post was liked"
+		}
+		if err = notifications.CreateNotificationTx(ctx, tx, notification); err != nil {
+			return nil, err
+		}
+	}
+	if kind == ReactionLike {
+		if err = recordPostLikedTx(ctx, tx, postID, userID, authorID); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// recordPostLikedTx tells the webhooks of the user and of the post's author about the like
+func recordPostLikedTx(ctx context.Context, tx pgx.Tx, postID string, userID string, authorID string) error {
+	return webhooks.RecordEventTx(ctx, tx, webhooks.Event{
+		Type:    webhooks.EventPostLiked,
+		UserIDs: []string{userID, authorID},
+		Data:    webhooks.PostLiked{PostID: postID, UserID: userID, AuthorID: authorID},
+	})
+}
+
+// removeReaction deletes the user's reaction to the post, only when it is of the given
+// kind if one is passed. It returns the removed kind, nil when there was nothing to remove.
+func removeReaction(ctx context.Context, tx pgx.Tx, postID string, userID string, only *ReactionKind) (*ReactionKind, error) {
+	var kind ReactionKind
+	err := tx.QueryRow(ctx, `
+        DELETE FROM post_reactions
+        WHERE post_id = $1 AND user_id = $2 AND ($3::text IS NULL OR kind = $3::text)
+        RETURNING kind
+    `, postID, userID, only).Scan(&kind)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error removing reaction: %w", err)
+	}
+
+	if _, err = adjustReactionCount(ctx, tx, postID, kind, -1); err != nil {
+		return nil, err
+	}
+	return &kind, nil
+}
+
+// React sets the user's reaction to the post, replacing any reaction of another kind.
+// Reacting again with the same kind changes nothing.
+func (pr *PostRepo) React(ctx context.Context, postID string, userID string, kind ReactionKind) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = requireVisible(ctx, tx, postID, userID); err != nil {
+		return err
+	}
+
+	previous, err := setReaction(ctx, tx, postID, userID, kind)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// every kind weighs like a like in trending, so switching kinds changes nothing
+	if previous == nil {
+		pr.recordEngagement(ctx, postID, engagementLike, 1)
+	}
+
+	return nil
+}
+
+// Unreact removes the user's reaction to the post, whatever its kind
+func (pr *PostRepo) Unreact(ctx context.Context, postID string, userID string) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	removed, err := removeReaction(ctx, tx, postID, userID, nil)
+	if err != nil {
+		return err
+	}
+	if removed == nil {
+		return errorx.New(errorx.ErrCodeNotFound, "you haven't reacted to this post", nil)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	pr.recordEngagement(ctx, postID, engagementLike, -1)
+
+	return nil
+}
+
+// GetReactionCounts returns the number of reactions of each kind left on the post, kinds
+// nobody used are left out
+func (pr *PostRepo) GetReactionCounts(ctx context.Context, postID string) ([]*ReactionCount, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var counts map[string]int
+	err := db.DB.QueryRow(ctx, `SELECT reaction_counts FROM posts WHERE id = $1`, postID).Scan(&counts)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reaction counts: %w", err)
+	}
+
+	result := []*ReactionCount{}
+	for _, kind := range ReactionKinds {
+		if count := counts[string(kind)]; count > 0 {
+			result = append(result, &ReactionCount{Kind: kind, Count: count})
+		}
+	}
+	return result, nil
+}
+
+// GetViewerReaction returns the kind of the viewer's reaction to the post, nil when they
+// haven't reacted or are anonymous
+func (pr *PostRepo) GetViewerReaction(ctx context.Context, postID string, viewerID string) (*ReactionKind, error) {
+	if viewerID == "" {
+		return nil, nil
+	}
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var kind ReactionKind
+	err := db.DB.QueryRow(ctx, `
+        SELECT kind FROM post_reactions WHERE post_id = $1 AND user_id = $2
+    `, postID, viewerID).Scan(&kind)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reaction: %w", err)
+	}
+	return &kind, nil
+}
+
+// GetReactions returns up to first reactions to the post, only those of kind when it is
+// given, continuing after the cursor of an earlier page. Reactions of users blocked in
+// either direction or of private accounts the viewer doesn't follow are left out.
+func (pr *PostRepo) GetReactions(ctx context.Context, postID string, viewerID string, kind *ReactionKind, first int, after *string) (*ReactionPage, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var afterCreatedAt, afterID interface{}
+	if after != nil && *after != "" {
+		createdAt, id, err := decodeCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		afterCreatedAt, afterID = createdAt, id
+	}
+
+	if err := requireVisible(ctx, db.DB, postID, viewerID); err != nil {
+		return nil, err
+	}
+
+	reactorVisible := strings.NewReplacer("{author}", "r.user_id", "{viewer}", "$2::uuid").Replace(authorVisibleSQL)
+	query := `
+        SELECT r.user_id, r.kind, r.created_at
+        FROM post_reactions r
+        WHERE r.post_id = $1
+          AND (r.user_id = $2::uuid OR (` + reactorVisible + `))
+          AND ($3::text IS NULL OR r.kind = $3::text)
+          AND ($4::timestamp IS NULL OR (r.created_at, r.user_id) < ($4::timestamp, $5::uuid))
+        ORDER BY r.created_at DESC, r.user_id DESC
+        LIMIT $6
+    `
+	rows, err := db.DB.Query(ctx, query, postID, viewerArg(viewerID), kind, afterCreatedAt, afterID, first+1)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reactions: %w", err)
+	}
+	defer rows.Close()
+
+	page := &ReactionPage{Reactions: []*Reaction{}}
+	for rows.Next() {
+		var r Reaction
+		if err := rows.Scan(&r.UserID, &r.Kind, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning reaction: %w", err)
+		}
+		page.Reactions = append(page.Reactions, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reactions: %w", err)
+	}
+
+	if len(page.Reactions) > first {
+		page.Reactions = page.Reactions[:first]
+		page.HasMore = true
+		last := page.Reactions[first-1]
+		cursor := encodeCursor(last.CreatedAt, last.UserID)
+		page.NextCursor = &cursor
+	}
+
+	return page, nil
+}
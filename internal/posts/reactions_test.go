@@ -0,0 +1,30 @@
+package posts
+
+import (
+	"context"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestReactionKind_IsValid(t *testing.T) {
+	for _, kind := range ReactionKinds {
+		require.True(t, kind.IsValid(), kind)
+	}
+	require.False(t, ReactionKind("like").IsValid())
+	require.False(t, ReactionKind("").IsValid())
+}
+
+func TestReact_InvalidKind(t *testing.T) {
+	service := NewPostServiceImpl(&PostRepo{})
+
+	_, err := service.React(context.Background(), "post", "user", ReactionKind("CLAP"))
+	var appErr *errorx.AppError
+	require.ErrorAs(t, err, &appErr)
+	require.Equal(t, errorx.ErrCodeValidation, appErr.Code)
+
+	kind := ReactionKind("CLAP")
+	_, err = service.GetReactions(context.Background(), "post", "", &kind, 20, nil)
+	require.ErrorAs(t, err, &appErr)
+	require.Equal(t, errorx.ErrCodeValidation, appErr.Code)
+}
@@ -0,0 +1,173 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+const (
+	repostersDefaultFirst = 20
+	repostersMaxFirst     = 100
+)
+
+// Reposter is a user who reposted a post, RepostID is their repost
+type Reposter struct {
+	UserID     string    `json:"user_id"`
+	RepostID   string    `json:"repost_id"`
+	RepostedAt time.Time `json:"reposted_at"`
+}
+
+// ReposterPage is one page of the users who reposted a post, the latest reposts first
+type ReposterPage struct {
+	Reposters  []*Reposter `json:"reposters"`
+	HasMore    bool        `json:"has_more"`
+	NextCursor *string     `json:"next_cursor,omitempty"`
+}
+
+// UndoRepost removes the user's repost of the post, postID can be the original or any repost
+// of it. Reposts of deleted posts can still be undone.
+func (pr *PostRepo) UndoRepost(ctx context.Context, postID string, userID string) error {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// the same lock as Repost, so the counter can't miss a concurrent repost
+	var originalID string
+	err = tx.QueryRow(ctx, `
+        SELECT o.id FROM posts p JOIN posts o ON o.id = COALESCE(p.repost_of_id, p.id)
+        WHERE p.id = $1
+        FOR UPDATE OF o
+    `, postID).Scan(&originalID)
+	if err == pgx.ErrNoRows {
+		return errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching reposted post: %w", err)
+	}
+
+	// the repost is soft deleted like any post, the trash purger removes it later
+	tag, err := tx.Exec(ctx, `
+        UPDATE posts SET deleted_at = $1, is_pinned = FALSE, pinned_at = NULL
+        WHERE repost_of_id = $2 AND user_id = $3 AND deleted_at IS NULL
+    `, time.Now(), originalID, userID)
+	if err != nil {
+		return fmt.Errorf("error undoing repost: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.New(errorx.ErrCodeNotFound, "you haven't reposted this post", nil)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE posts SET reposts = GREATEST(reposts - 1, 0) WHERE id = $1`, originalID)
+	if err != nil {
+		return fmt.Errorf("error updating repost count: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	pr.recordEngagement(ctx, originalID, engagementRepost, -1)
+
+	return nil
+}
+
+// GetRepostedPost returns the original shared by a repost, nil when the post is not a repost
+// or the original is hidden from the viewer
+func (pr *PostRepo) GetRepostedPost(ctx context.Context, postID string, viewerID string) (*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT o.id, o.user_id, o.title, o.content, o.image_url, o.audio_url, o.parent_id, o.created_at, o.updated_at, o.likes, o.reposts, o.audience, o.quoted_post_id, o.quote_count
+        FROM posts p
+        JOIN posts o ON o.id = p.repost_of_id
+        WHERE p.id = $1 AND o.is_draft = FALSE AND o.deleted_at IS NULL
+          AND ` + postVisibleSQL("o", 2) + `
+    `
+
+	var post Post
+	err := db.DB.QueryRow(ctx, query, postID, viewerArg(viewerID)).Scan(
+		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+		&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience, &post.QuotedPostID, &post.QuoteCount,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reposted post: %w", err)
+	}
+
+	return &post, nil
+}
+
+// GetReposters returns up to first users who reposted the post, continuing after the cursor
+// of an earlier page. Reposts the viewer can't read are left out.
+func (pr *PostRepo) GetReposters(ctx context.Context, postID string, viewerID string, first int, after *string) (*ReposterPage, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var afterCreatedAt, afterID interface{}
+	if after != nil && *after != "" {
+		createdAt, id, err := decodeCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		afterCreatedAt, afterID = createdAt, id
+	}
+
+	if err := requireVisible(ctx, db.DB, postID, viewerID); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT r.user_id, r.id, r.created_at
+        FROM posts r
+        WHERE r.repost_of_id = $1 AND r.deleted_at IS NULL
+          AND ` + postVisibleSQL("r", 2) + `
+          AND ($3::timestamp IS NULL OR (r.created_at, r.id) < ($3::timestamp, $4::uuid))
+        ORDER BY r.created_at DESC, r.id DESC
+        LIMIT $5
+    `
+	rows, err := db.DB.Query(ctx, query, postID, viewerArg(viewerID), afterCreatedAt, afterID, first+1)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reposters: %w", err)
+	}
+	defer rows.Close()
+
+	page := &ReposterPage{Reposters: []*Reposter{}}
+	for rows.Next() {
+		var r Reposter
+		if err := rows.Scan(&r.UserID, &r.RepostID, &r.RepostedAt); err != nil {
+			return nil, fmt.Errorf("error scanning reposter: %w", err)
+		}
+		page.Reposters = append(page.Reposters, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reposters: %w", err)
+	}
+
+	if len(page.Reposters) > first {
+		page.Reposters = page.Reposters[:first]
+		page.HasMore = true
+		last := page.Reposters[first-1]
+		cursor := encodeCursor(last.RepostedAt, last.RepostID)
+		page.NextCursor = &cursor
+	}
+
+	return page, nil
+}
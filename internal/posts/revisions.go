@@ -0,0 +1,88 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// PostRevision is one saved version of a post's content
+type PostRevision struct {
+	ID        string    `json:"id"`
+	PostID    string    `json:"post_id"`
+	EditorID  string    `json:"editor_id"`
+	Title     *string   `json:"title,omitempty"`
+	Content   string    `json:"content"`
+	ImageURL  *string   `json:"image_url,omitempty"`
+	AudioURL  *string   `json:"audio_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (pr *PostRepo) insertRevisionTx(ctx context.Context, tx pgx.Tx, post *Post, editorID string, createdAt time.Time) error {
+	query := `
+        INSERT INTO post_revisions (post_id, editor_id, title, content, image_url, audio_url, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `
+	_, err := tx.Exec(ctx, query,
+		post.ID, editorID, post.Title, post.Content, post.ImageURL, post.AudioURL, createdAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving post revision: %w", err)
+	}
+	return nil
+}
+
+// ensureOriginalRevisionTx records the current content of a post as its first revision
+// when the post was written before revisions were tracked
+func (pr *PostRepo) ensureOriginalRevisionTx(ctx context.Context, tx pgx.Tx, post *Post) error {
+	var exists bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM post_revisions WHERE post_id = $1)`, post.ID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking post revisions: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	return pr.insertRevisionTx(ctx, tx, post, post.UserID, post.CreatedAt)
+}
+
+func (pr *PostRepo) GetPostRevisions(ctx context.Context, postID string) ([]*PostRevision, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT r.id, r.post_id, r.editor_id, r.title, r.content, r.image_url, r.audio_url, r.created_at
+        FROM post_revisions r
+        JOIN posts p ON p.id = r.post_id
+        WHERE r.post_id = $1 AND p.deleted_at IS NULL
+        ORDER BY r.created_at ASC
+    `
+	rows, err := db.DB.Query(ctx, query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying post revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []*PostRevision{}
+	for rows.Next() {
+		var revision PostRevision
+		err := rows.Scan(
+			&revision.ID, &revision.PostID, &revision.EditorID, &revision.Title, &revision.Content,
+			&revision.ImageURL, &revision.AudioURL, &revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning post revision: %w", err)
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating post revisions: %w", err)
+	}
+
+	return revisions, nil
+}
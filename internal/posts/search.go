@@ -0,0 +1,305 @@
+package posts
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/utils"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	searchQueryMaxLength = 256
+	searchMaxTerms       = 16
+	searchDefaultFirst   = 20
+	searchMaxFirst       = 50
+
+	// ts_headline marks matches with these private use characters, stripped from the text
+	// first, so the raw text can be highlighted and escaped afterwards
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// highlightHTML escapes a headline and turns its markers into <mark> tags
+func highlightHTML(headline string) string {
+	escaped := html.EscapeString(headline)
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(escaped)
+}
+
+// PostSearchFilter narrows a search, nil fields are ignored. From is inclusive, To exclusive.
+type PostSearchFilter struct {
+	AuthorID *string    `json:"author_id,omitempty"`
+	Tag      *string    `json:"tag,omitempty"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	HasMedia *bool      `json:"has_media,omitempty"`
+}
+
+func (f *PostSearchFilter) Validate() error {
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return errorx.New(errorx.ErrCodeValidation, "from must be before to", nil)
+	}
+	if f.Tag != nil {
+		tag := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(*f.Tag), "#"))
+		f.Tag = &tag
+	}
+	return nil
+}
+
+// PostSearchResult is a matching post with the matched words highlighted. The highlights
+// are HTML escaped with every match wrapped in <mark>.
+type PostSearchResult struct {
+	Post           *Post   `json:"post"`
+	Rank           float64 `json:"rank"`
+	TitleHighlight *string `json:"title_highlight,omitempty"`
+	Snippet        string  `json:"snippet"`
+}
+
+// PostSearchPage is one page of search results, best matches first
+type PostSearchPage struct {
+	Results    []*PostSearchResult `json:"results"`
+	HasMore    bool                `json:"has_more"`
+	NextCursor *string             `json:"next_cursor,omitempty"`
+}
+
+// TagMatch is a tag found by search with how well it matched, from 0 to 1
+type TagMatch struct {
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+// parseSearchQuery turns user input into a to_tsquery expression. Words must all match,
+// "quoted words" must match as a phrase and a trailing * matches any word with that prefix.
+// Punctuation is dropped so the result is always valid tsquery syntax.
+func parseSearchQuery(raw string) (string, error) {
+	if len(raw) > searchQueryMaxLength {
+		return "", errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("search queries can be at most %d characters", searchQueryMaxLength), nil)
+	}
+
+	var terms []string
+	addTerm := func(text string, phrase bool) {
+		prefix := !phrase && strings.HasSuffix(text, "*")
+		words := strings.FieldsFunc(text, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			return
+		}
+		if prefix {
+			words[len(words)-1] += ":*"
+		}
+		term := strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			term = "(" + term + ")"
+		}
+		terms = append(terms, term)
+	}
+
+	rest := raw
+	for {
+		start := strings.IndexByte(rest, '"')
+		if start < 0 {
+			break
+		}
+		for _, word := range strings.Fields(rest[:start]) {
+			addTerm(word, false)
+		}
+		end := strings.IndexByte(rest[start+1:], '"')
+		if end < 0 {
+			// an unclosed quote runs to the end of the query
+			addTerm(rest[start+1:], true)
+			rest = ""
+			break
+		}
+		addTerm(rest[start+1:start+1+end], true)
+		rest = rest[start+2+end:]
+	}
+	for _, word := range strings.Fields(rest) {
+		addTerm(word, false)
+	}
+
+	if len(terms) == 0 {
+		return "", errorx.New(errorx.ErrCodeValidation, "search query is empty", nil)
+	}
+	if len(terms) > searchMaxTerms {
+		return "", errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("search queries can have at most %d terms", searchMaxTerms), nil)
+	}
+	return strings.Join(terms, " & "), nil
+}
+
+func encodeRankCursor(rank float32, id string) string {
+	raw := strconv.FormatFloat(float64(rank), 'g', -1, 32) + "|" + id
+	return base64.URLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRankCursor(cursor string) (float32, string, error) {
+	raw, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, "", errorx.New(errorx.ErrCodeValidation, "invalid cursor", err)
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return 0, "", errorx.New(errorx.ErrCodeValidation, "invalid cursor", nil)
+	}
+	rank, err := strconv.ParseFloat(parts[0], 32)
+	if err != nil {
+		return 0, "", errorx.New(errorx.ErrCodeValidation, "invalid cursor", err)
+	}
+	return float32(rank), parts[1], nil
+}
+
+// SearchPosts ranks the posts the viewer can read against the query with ts_rank_cd and
+// returns up to first results, continuing after the cursor of an earlier page
+func (pr *PostRepo) SearchPosts(ctx context.Context, query string, filter PostSearchFilter, viewerID string, first int, after *string) (*PostSearchPage, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tsQuery, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var afterRank, afterID interface{}
+	if after != nil && *after != "" {
+		rank, id, err := decodeRankCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		afterRank, afterID = rank, id
+	}
+
+	// headlines are only built for the page. They are built from the raw text, escaping it
+	// first would let searches match inside entities such as &amp;, see highlightHTML.
+	sqlQuery := `
+        WITH q AS (SELECT to_tsquery('english', $1) AS query),
+        hits AS (
+            SELECT p.id, ts_rank_cd(p.search_vector, q.query) AS rank
+            FROM posts p, q
+            WHERE p.search_vector @@ q.query
+              AND p.is_draft = FALSE AND p.deleted_at IS NULL
+              AND ` + postVisibleSQL("p", 2) + `
+              AND ` + labelFilterSQL("p", 2) + `
+              AND ($3::uuid IS NULL OR p.user_id = $3::uuid)
+              AND ($4::text IS NULL OR EXISTS (
+                  SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+                  WHERE pt.post_id = p.id AND t.name = $4::text
+              ))
+              AND ($5::timestamp IS NULL OR p.created_at >= $5::timestamp)
+              AND ($6::timestamp IS NULL OR p.created_at < $6::timestamp)
+              AND ($7::boolean IS NULL OR $7::boolean = (
+                  p.image_url IS NOT NULL OR p.audio_url IS NOT NULL
+                  OR EXISTS (SELECT 1 FROM post_attachments a WHERE a.post_id = p.id)
+              ))
+              AND ($8::real IS NULL OR (ts_rank_cd(p.search_vector, q.query), p.id) < ($8::real, $9::uuid))
+            ORDER BY rank DESC, p.id DESC
+            LIMIT $10
+        )
+        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, p.parent_id, p.created_at, p.updated_at,
+               p.likes, p.reposts, p.audience, hits.rank,
+               ts_headline('english', translate(p.title, '` + highlightStart + highlightStop + `', ''), q.query,
+                           'HighlightAll=TRUE, StartSel=` + highlightStart + `, StopSel=` + highlightStop + `'),
+               ts_headline('english', translate(p.content, '` + highlightStart + highlightStop + `', ''), q.query,
+                           'StartSel=` + highlightStart + `, StopSel=` + highlightStop + `, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" … "')
+        FROM hits
+        JOIN posts p ON p.id = hits.id
+        CROSS JOIN q
+        ORDER BY hits.rank DESC, p.id DESC
+    `
+	rows, err := db.DB.Query(ctx, sqlQuery,
+		tsQuery, viewerArg(viewerID), filter.AuthorID, filter.Tag, filter.From, filter.To, filter.HasMedia,
+		afterRank, afterID, first+1,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+	defer rows.Close()
+
+	page := &PostSearchPage{Results: []*PostSearchResult{}}
+	var ranks []float32
+	for rows.Next() {
+		var post Post
+		var rank float32
+		var snippet *string
+		result := &PostSearchResult{Post: &post}
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience,
+			&rank, &result.TitleHighlight, &snippet,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning search result: %w", err)
+		}
+		result.Rank = float64(rank)
+		if result.TitleHighlight != nil {
+			highlighted := highlightHTML(*result.TitleHighlight)
+			result.TitleHighlight = &highlighted
+		}
+		if snippet != nil {
+			result.Snippet = highlightHTML(*snippet)
+		}
+		page.Results = append(page.Results, result)
+		ranks = append(ranks, rank)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+
+	if len(page.Results) > first {
+		page.Results = page.Results[:first]
+		page.HasMore = true
+		cursor := encodeRankCursor(ranks[first-1], page.Results[first-1].Post.ID)
+		page.NextCursor = &cursor
+	}
+
+	return page, nil
+}
+
+// SearchTags returns tags starting with prefix that are used on at least one post the
+// viewer can read, an exact match first and shorter tags before longer ones
+func (pr *PostRepo) SearchTags(ctx context.Context, prefix string, viewerID string, limit int) ([]*TagMatch, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT t.name, CASE WHEN t.name = $1 THEN 1.0 ELSE 0.7 END::float8 AS score
+        FROM tags t
+        WHERE t.name LIKE $2 || '%'
+          AND EXISTS (
+              SELECT 1 FROM post_tags pt
+              JOIN posts p ON p.id = pt.post_id
+              WHERE pt.tag_id = t.id AND p.is_draft = FALSE AND p.deleted_at IS NULL
+                AND ` + postVisibleSQL("p", 3) + `
+          )
+        ORDER BY score DESC, length(t.name), t.name
+        LIMIT $4
+    `
+	rows, err := db.DB.Query(ctx, query, prefix, utils.EscapeLike(prefix), viewerArg(viewerID), limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching tags: %w", err)
+	}
+	defer rows.Close()
+
+	matches := []*TagMatch{}
+	for rows.Next() {
+		var match TagMatch
+		if err := rows.Scan(&match.Name, &match.Score); err != nil {
+			return nil, fmt.Errorf("error scanning tag: %w", err)
+		}
+		matches = append(matches, &match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+
+	return matches, nil
+}
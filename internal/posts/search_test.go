@@ -0,0 +1,54 @@
+package posts
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{name: "words", query: "go  generics", want: "go & generics"},
+		{name: "phrase", query: `"type parameters" go`, want: "(type <-> parameters) & go"},
+		{name: "prefix", query: "gener*", want: "gener:*"},
+		{name: "punctuation is dropped", query: `e-mail's & | !`, want: "(e <-> mail <-> s)"},
+		{name: "unclosed quote", query: `release "go 1`, want: "release & (go <-> 1)"},
+		{name: "empty", query: `  "" * `, wantErr: true},
+		{name: "too long", query: strings.Repeat("a", searchQueryMaxLength+1), wantErr: true},
+		{name: "too many terms", query: strings.Repeat("a ", searchMaxTerms+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSearchQuery(tt.query)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRankCursorRoundTrip(t *testing.T) {
+	cursor := encodeRankCursor(0.123456, "post-id")
+	rank, id, err := decodeRankCursor(cursor)
+	require.NoError(t, err)
+	require.Equal(t, float32(0.123456), rank)
+	require.Equal(t, "post-id", id)
+
+	_, _, err = decodeRankCursor("not a cursor")
+	require.Error(t, err)
+}
+
+func TestHighlightHTML(t *testing.T) {
+	require.Equal(t, "Tom &amp; <mark>Jerry</mark> &lt;3", highlightHTML("Tom & "+highlightStart+"Jerry"+highlightStop+" <3"))
+	require.Equal(t, "&lt;mark&gt;not a match&lt;/mark&gt;", highlightHTML("<mark>not a match</mark>"))
+	require.Equal(t, "<mark>a</mark> … <mark>b</mark>", highlightHTML(highlightStart+"a"+highlightStop+" … "+highlightStart+"b"+highlightStop))
+}
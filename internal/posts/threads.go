@@ -0,0 +1,196 @@
+package posts
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"strings"
+	"time"
+)
+
+const (
+	threadDefaultDepth = 3
+	threadMaxDepth     = 10
+	threadDefaultFirst = 10
+	threadMaxFirst     = 50
+)
+
+// ThreadNode is a post with one page of its replies. HasMoreReplies is set when replies
+// were left out, either past the page size or past the requested depth. MoreRepliesCursor
+// continues the page, when it is nil the rest is loaded by requesting the node's own thread.
+type ThreadNode struct {
+	Post              *Post         `json:"post"`
+	ReplyCount        int           `json:"reply_count"`
+	Replies           []*ThreadNode `json:"replies"`
+	HasMoreReplies    bool          `json:"has_more_replies"`
+	MoreRepliesCursor *string       `json:"more_replies_cursor,omitempty"`
+}
+
+// scrubDeleted hides the content of a deleted post that is kept as a placeholder for its replies
+func (p *Post) scrubDeleted() {
+	if p.DeletedAt == nil {
+		return
+	}
+	p.Title = nil
+	p.Content = ""
+	p.ImageURL = nil
+	p.AudioURL = nil
+	p.Tags = nil
+	p.Entities = nil
+}
+
+// keptInThreadSQL returns a condition that holds when the post under alias is live, or is
+// deleted but still has a live reply anywhere below it and stays as a placeholder so the
+// thread holds together
+func keptInThreadSQL(alias string) string {
+	return `(` + alias + `.deleted_at IS NULL OR EXISTS (
+            WITH RECURSIVE below AS (
+                SELECT d.id, d.deleted_at, d.is_draft FROM posts d WHERE d.parent_id = ` + alias + `.id
+                UNION
+                SELECT d.id, d.deleted_at, d.is_draft FROM posts d JOIN below b ON d.parent_id = b.id
+            )
+            SELECT 1 FROM below WHERE below.deleted_at IS NULL AND below.is_draft = FALSE
+        ))`
+}
+
+// encodeCursor builds an opaque keyset cursor from the sort time and id of the last item on a page
+func encodeCursor(createdAt time.Time, id string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id
+	return base64.URLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", errorx.New(errorx.ErrCodeValidation, "invalid cursor", err)
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", errorx.New(errorx.ErrCodeValidation, "invalid cursor", nil)
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", errorx.New(errorx.ErrCodeValidation, "invalid cursor", err)
+	}
+	return createdAt, parts[1], nil
+}
+
+// GetThread loads the post and the replies the viewer can read down to depth levels, with at
+// most first replies per post. after continues the replies of the root post from an earlier page.
+func (pr *PostRepo) GetThread(ctx context.Context, postID string, viewerID string, depth int, first int, after *string) (*ThreadNode, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	var afterCreatedAt, afterID interface{}
+	if after != nil && *after != "" {
+		createdAt, id, err := decodeCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		afterCreatedAt, afterID = createdAt, id
+	}
+
+	rootQuery := `
+        SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, comment_count, deleted_at
+        FROM posts
+        WHERE id = $1 AND is_draft = FALSE AND ` + keptInThreadSQL("posts") + `
+          AND ` + postVisibleSQL("posts", 2) + `
+    `
+	var root Post
+	var rootReplies int
+	err := db.DB.QueryRow(ctx, rootQuery, postID, viewerArg(viewerID)).Scan(
+		&root.ID, &root.UserID, &root.Title, &root.Content, &root.ImageURL, &root.AudioURL,
+		&root.ParentID, &root.CreatedAt, &root.UpdatedAt, &root.Likes, &root.Reposts, &root.Audience, &rootReplies, &root.DeletedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching thread root: %w", err)
+	}
+	root.scrubDeleted()
+
+	rootNode := &ThreadNode{Post: &root, ReplyCount: rootReplies, Replies: []*ThreadNode{}}
+	level := []*ThreadNode{rootNode}
+
+	// one query per level, each post gets up to first+1 replies so we know if there are more
+	repliesQuery := `
+        SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience, comment_count, deleted_at, rn
+        FROM (
+            SELECT p.*, ROW_NUMBER() OVER (PARTITION BY p.parent_id ORDER BY p.created_at, p.id) AS rn
+            FROM posts p
+            WHERE p.parent_id = ANY($1) AND p.is_draft = FALSE AND ` + keptInThreadSQL("p") + `
+              AND ($2::timestamp IS NULL OR (p.created_at, p.id) > ($2::timestamp, $3::uuid))
+              AND ` + postVisibleSQL("p", 5) + `
+        ) replies
+        WHERE rn <= $4
+        ORDER BY parent_id, created_at, id
+    `
+	for d := 0; d < depth && len(level) > 0; d++ {
+		nodes := make(map[string]*ThreadNode, len(level))
+		parentIDs := make([]string, 0, len(level))
+		for _, node := range level {
+			nodes[node.Post.ID] = node
+			parentIDs = append(parentIDs, node.Post.ID)
+		}
+
+		// the cursor only applies to the replies of the root
+		var cursorCreatedAt, cursorID interface{}
+		if d == 0 {
+			cursorCreatedAt, cursorID = afterCreatedAt, afterID
+		}
+
+		rows, err := db.DB.Query(ctx, repliesQuery, parentIDs, cursorCreatedAt, cursorID, first+1, viewerArg(viewerID))
+		if err != nil {
+			return nil, fmt.Errorf("error fetching thread replies: %w", err)
+		}
+
+		var next []*ThreadNode
+		for rows.Next() {
+			var reply Post
+			var replyCount, rn int
+			err := rows.Scan(
+				&reply.ID, &reply.UserID, &reply.Title, &reply.Content, &reply.ImageURL, &reply.AudioURL,
+				&reply.ParentID, &reply.CreatedAt, &reply.UpdatedAt, &reply.Likes, &reply.Reposts, &reply.Audience, &replyCount, &reply.DeletedAt, &rn,
+			)
+			if err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("error scanning thread reply: %w", err)
+			}
+
+			parent := nodes[*reply.ParentID]
+			if rn > first {
+				parent.HasMoreReplies = true
+				last := parent.Replies[len(parent.Replies)-1].Post
+				cursor := encodeCursor(last.CreatedAt, last.ID)
+				parent.MoreRepliesCursor = &cursor
+				continue
+			}
+
+			reply.scrubDeleted()
+			node := &ThreadNode{Post: &reply, ReplyCount: replyCount, Replies: []*ThreadNode{}}
+			parent.Replies = append(parent.Replies, node)
+			next = append(next, node)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating thread replies: %w", err)
+		}
+
+		level = next
+	}
+
+	// posts on the last loaded level have their replies left for a follow-up request
+	for _, node := range level {
+		if node.ReplyCount > 0 {
+			node.HasMoreReplies = true
+		}
+	}
+
+	return rootNode, nil
+}
@@ -0,0 +1,287 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"log"
+	"time"
+)
+
+// trashRetention is how long deleted posts stay in their author's trash before they are purged
+const trashRetention = 30 * 24 * time.Hour
+
+// trashPurgeBatch caps how many expired posts are purged per transaction
+const trashPurgeBatch = 100
+
+// GetDeletedPosts returns the user's posts that are still in the trash, the most recently deleted first
+func (pr *PostRepo) GetDeletedPosts(ctx context.Context, userID string) ([]*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at,
+               likes, reposts, audience, is_draft, deleted_at
+        FROM posts
+        WHERE user_id = $1 AND deleted_at > $2 AND purged_at IS NULL AND repost_of_id IS NULL
+        ORDER BY deleted_at DESC
+    `
+	rows, err := db.DB.Query(ctx, query, userID, time.Now().Add(-trashRetention))
+	if err != nil {
+		return nil, fmt.Errorf("error querying deleted posts: %w", err)
+	}
+	defer rows.Close()
+
+	deleted := []*Post{}
+	for rows.Next() {
+		post := &Post{}
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
+			&post.Audience, &post.IsDraft, &post.DeletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning deleted post: %w", err)
+		}
+		deleted = append(deleted, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted posts: %w", err)
+	}
+
+	for _, post := range deleted {
+		if post.Tags, err = pr.getPostTags(ctx, post.ID); err != nil {
+			return nil, fmt.Errorf("error fetching tags for deleted post: %w", err)
+		}
+	}
+
+	return deleted, nil
+}
+
+// RestorePost takes a post out of its author's trash. The post comes back unpinned.
+// Undone reposts don't go to the trash, the post can be reposted again instead.
+func (pr *PostRepo) RestorePost(ctx context.Context, postID string, userID string) (*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var authorID string
+	err = tx.QueryRow(ctx, `
+        SELECT user_id FROM posts
+        WHERE id = $1 AND deleted_at > $2 AND purged_at IS NULL AND repost_of_id IS NULL
+        FOR UPDATE
+    `, postID, time.Now().Add(-trashRetention)).Scan(&authorID)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "post not found in trash", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching deleted post: %w", err)
+	}
+	if authorID != userID {
+		return nil, errorx.New(errorx.ErrCodeForbidden, "only the author can restore a post", nil)
+	}
+
+	var post Post
+	err = tx.QueryRow(ctx, `
+        UPDATE posts SET deleted_at = NULL
+        WHERE id = $1
+        RETURNING id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at,
+                  likes, reposts, audience, is_draft, publish_at, quoted_post_id, quote_count
+    `, postID).Scan(
+		&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+		&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
+		&post.Audience, &post.IsDraft, &post.PublishAt, &post.QuotedPostID, &post.QuoteCount,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error restoring post: %w", err)
+	}
+
+	// DeletePost took the post off its parent's reply count and the quoted post's quote count
+	if post.ParentID != nil {
+		_, err = tx.Exec(ctx, `UPDATE posts SET comment_count = comment_count + 1 WHERE id = $1`, *post.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("error updating comment count: %w", err)
+		}
+	}
+	if post.QuotedPostID != nil {
+		_, err = tx.Exec(ctx, `UPDATE posts SET quote_count = quote_count + 1 WHERE id = $1`, *post.QuotedPostID)
+		if err != nil {
+			return nil, fmt.Errorf("error updating quote count: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if post.Tags, err = pr.getPostTags(ctx, post.ID); err != nil {
+		return nil, fmt.Errorf("error fetching tags for restored post: %w", err)
+	}
+	return &post, nil
+}
+
+// purgeDependents removes the rows that reference purged posts, every table with a foreign
+// key to posts other than posts itself must be listed
+var purgeDependents = []struct{ name, query string }{
+	{"reactions", `DELETE FROM post_reactions WHERE post_id = ANY($1)`},
+	{"tags", `DELETE FROM post_tags WHERE post_id = ANY($1)`},
+	{"mentions", `DELETE FROM post_mentions WHERE post_id = ANY($1)`},
+	{"entities", `DELETE FROM post_entities WHERE post_id = ANY($1)`},
+	{"bookmarks", `DELETE FROM bookmarks WHERE post_id = ANY($1)`},
+	{"analytics", `DELETE FROM post_analytics WHERE post_id = ANY($1)`},
+	{"daily stats", `DELETE FROM post_daily_stats WHERE post_id = ANY($1)`},
+	{"revisions", `DELETE FROM post_revisions WHERE post_id = ANY($1)`},
+	{"poll votes", `DELETE FROM poll_votes WHERE poll_id IN (SELECT id FROM polls WHERE post_id = ANY($1))`},
+	{"poll options", `DELETE FROM poll_options WHERE poll_id IN (SELECT id FROM polls WHERE post_id = ANY($1))`},
+	{"polls", `DELETE FROM polls WHERE post_id = ANY($1)`},
+	{"attachments", `DELETE FROM post_attachments WHERE post_id = ANY($1)`},
+	{"label changes", `DELETE FROM post_label_changes WHERE post_id = ANY($1)`},
+}
+
+// PurgeDeletedPosts permanently removes posts deleted before the cutoff and returns how many
+// were handled. Likes, tags, bookmarks, analytics and the other rows hanging off a post go
+// first. Posts that replies or reposts still point at can't be dropped, they keep an empty
+// placeholder row until the last of them is purged.
+func (pr *PostRepo) PurgeDeletedPosts(ctx context.Context, before time.Time) (int, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return 0, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// placeholders are picked up again once nothing points at them
+	rows, err := tx.Query(ctx, `
+        SELECT p.id FROM posts p
+        WHERE p.deleted_at < $1
+          AND (
+              p.purged_at IS NULL
+              OR NOT EXISTS (SELECT 1 FROM posts c WHERE c.parent_id = p.id OR c.repost_of_id = p.id OR c.audience_post_id = p.id)
+          )
+        ORDER BY p.deleted_at
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED
+    `, before, trashPurgeBatch)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching expired posts: %w", err)
+	}
+	var postIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning expired post: %w", err)
+		}
+		postIDs = append(postIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating expired posts: %w", err)
+	}
+	if len(postIDs) == 0 {
+		return 0, nil
+	}
+
+	for _, d := range purgeDependents {
+		if _, err = tx.Exec(ctx, d.query, postIDs); err != nil {
+			return 0, fmt.Errorf("error purging %s: %w", d.name, err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE posts SET quoted_post_id = NULL WHERE quoted_post_id = ANY($1)`, postIDs)
+	if err != nil {
+		return 0, fmt.Errorf("error detaching quotes: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        UPDATE posts
+        SET title = NULL, content = '', image_url = NULL, audio_url = NULL, video_url = NULL,
+            quoted_post_id = NULL, purged_at = $2
+        WHERE id = ANY($1) AND purged_at IS NULL
+    `, postIDs, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("error clearing purged posts: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        DELETE FROM posts p
+        WHERE p.id = ANY($1)
+          AND NOT EXISTS (SELECT 1 FROM posts c WHERE c.parent_id = p.id OR c.repost_of_id = p.id OR c.audience_post_id = p.id)
+    `, postIDs)
+	if err != nil {
+		return 0, fmt.Errorf("error purging posts: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(postIDs), nil
+}
+
+// TrashPurger periodically purges posts that have been in the trash for longer than trashRetention
+type TrashPurger struct {
+	Repo     *PostRepo
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewTrashPurger(repo *PostRepo, interval time.Duration) *TrashPurger {
+	ctx, cancel := context.WithCancel(context.Background())
+	purger := &TrashPurger{
+		Repo:     repo,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	go purger.run()
+	return purger
+}
+
+func (tp *TrashPurger) Stop() {
+	tp.cancel()
+}
+
+func (tp *TrashPurger) run() {
+	ticker := time.NewTicker(tp.interval)
+	defer ticker.Stop()
+
+	tp.purge()
+	for {
+		select {
+		case <-tp.ctx.Done():
+			return
+		case <-ticker.C:
+			tp.purge()
+		}
+	}
+}
+
+func (tp *TrashPurger) purge() {
+	for {
+		purged, err := tp.Repo.PurgeDeletedPosts(tp.ctx, time.Now().Add(-trashRetention))
+		if err != nil {
+			log.Printf("failed to purge deleted posts: %v", err)
+			return
+		}
+		if purged < trashPurgeBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package posts
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	createTableRe = regexp.MustCompile(`(?is)CREATE TABLE (?:IF NOT EXISTS )?(\w+)\s*\((.*?)\n\s*\);`)
+	renameTableRe = regexp.MustCompile(`(?i)ALTER TABLE (\w+) RENAME TO (\w+)`)
+	postsFKRe     = regexp.MustCompile(`(?i)REFERENCES posts\s*\(id\)([^,\n]*)`)
+	purgeTableRe  = regexp.MustCompile(`DELETE FROM (\w+)`)
+)
+
+// TestPurgeDependents_CoverPostReferences keeps the purge in step with the migrations, a
+// table referencing posts without being purged would make purging a post that has rows in
+// it fail on the foreign key, as post_label_changes did for labeled posts
+func TestPurgeDependents_CoverPostReferences(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "migrations", "*.up.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	referencing := map[string]bool{}
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		require.NoError(t, err)
+		sql := stripSQLComments(string(raw))
+
+		for _, m := range createTableRe.FindAllStringSubmatch(sql, -1) {
+			table := strings.ToLower(m[1])
+			if table == "posts" {
+				continue
+			}
+			for _, fk := range postsFKRe.FindAllStringSubmatch(m[2], -1) {
+				if !strings.Contains(strings.ToUpper(fk[1]), "ON DELETE CASCADE") {
+					referencing[table] = true
+				}
+			}
+		}
+		for _, m := range renameTableRe.FindAllStringSubmatch(sql, -1) {
+			from, to := strings.ToLower(m[1]), strings.ToLower(m[2])
+			if referencing[from] {
+				delete(referencing, from)
+				referencing[to] = true
+			}
+		}
+	}
+	require.Contains(t, referencing, "post_label_changes")
+
+	purged := map[string]bool{}
+	for _, d := range purgeDependents {
+		for _, m := range purgeTableRe.FindAllStringSubmatch(d.query, -1) {
+			purged[m[1]] = true
+		}
+	}
+	for table := range referencing {
+		require.True(t, purged[table], "purging posts leaves rows in %s behind", table)
+	}
+}
+
+func stripSQLComments(sql string) string {
+	lines := strings.Split(sql, "\n")
+	for i, line := range lines {
+		if idx := strings.Index(line, "--"); idx >= 0 {
+			lines[i] = line[:idx]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
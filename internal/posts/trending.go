@@ -0,0 +1,408 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// TrendingWindow is the period over which trending posts and tags are ranked
+type TrendingWindow string
+
+const (
+	TrendingWindowHour TrendingWindow = "HOUR"
+	TrendingWindowDay  TrendingWindow = "DAY"
+	TrendingWindowWeek TrendingWindow = "WEEK"
+)
+
+type TrendingTag struct {
+	Name      string  `json:"name"`
+	Score     float64 `json:"score"`
+	PostCount int     `json:"post_count"`
+}
+
+type engagementKind int
+
+const (
+	engagementLike engagementKind = iota
+	engagementComment
+	engagementRepost
+	engagementQuote
+)
+
+// weights given to each engagement when it is counted towards a post's trending score
+var engagementWeights = map[engagementKind]float64{
+	engagementLike:    1,
+	engagementComment: 2,
+	engagementRepost:  3,
+	engagementQuote:   3,
+}
+
+const (
+	trendingPostsKey     = "trending:posts:%s"
+	trendingTagsKey      = "trending:tags:%s"
+	trendingTagCountsKey = "trending:tags:%s:counts"
+	trendingBucketKey    = "trending:events:%s:%d"
+	trendingTagsLimit    = 100
+	// trendingTagsDefaultLimit is how many tags are returned when no limit is asked for,
+	// at most trendingTagsLimit are kept
+	trendingTagsDefaultLimit = 10
+	trendingDefaultWindow    = TrendingWindowDay
+)
+
+// trendingBucket describes how raw engagement events are grouped in redis
+type trendingBucket struct {
+	name string
+	size time.Duration
+	ttl  time.Duration
+}
+
+var (
+	fineBucket   = trendingBucket{name: "5m", size: 5 * time.Minute, ttl: 2 * time.Hour}
+	coarseBucket = trendingBucket{name: "1h", size: time.Hour, ttl: 8 * 24 * time.Hour}
+)
+
+// trendingWindowConfig holds the span, decay half-life and bucket granularity for a window
+type trendingWindowConfig struct {
+	span     time.Duration
+	halfLife time.Duration
+	bucket   trendingBucket
+}
+
+var trendingWindows = map[TrendingWindow]trendingWindowConfig{
+	TrendingWindowHour: {span: time.Hour, halfLife: 20 * time.Minute, bucket: fineBucket},
+	TrendingWindowDay:  {span: 24 * time.Hour, halfLife: 6 * time.Hour, bucket: coarseBucket},
+	TrendingWindowWeek: {span: 7 * 24 * time.Hour, halfLife: 36 * time.Hour, bucket: coarseBucket},
+}
+
+func (w TrendingWindow) IsValid() bool {
+	_, ok := trendingWindows[w]
+	return ok
+}
+
+func normalizeTrendingWindow(window TrendingWindow) (TrendingWindow, error) {
+	if window == "" {
+		return trendingDefaultWindow, nil
+	}
+	if !window.IsValid() {
+		return "", errorx.New(errorx.ErrCodeInvalidEnum, fmt.Sprintf("unknown trending window %q", window), nil)
+	}
+	return window, nil
+}
+
+// decayWeight returns the weight of an event of the given age, halving every halfLife
+func decayWeight(age, halfLife time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, age.Seconds()/halfLife.Seconds())
+}
+
+// recordEngagement adds an engagement event for a post to the current redis buckets.
+// Failures are only logged, trending is best effort and must never fail the write itself.
+func (pr *PostRepo) recordEngagement(ctx context.Context, postID string, kind engagementKind, sign float64) {
+	if pr.Redis == nil || pr.Redis.Client == nil {
+		return
+	}
+	now := time.Now()
+	weight := engagementWeights[kind] * sign
+
+	pipe := pr.Redis.Client.TxPipeline()
+	for _, bucket := range []trendingBucket{fineBucket, coarseBucket} {
+		key := fmt.Sprintf(trendingBucketKey, bucket.name, now.Truncate(bucket.size).Unix())
+		pipe.ZIncrBy(ctx, key, weight, postID)
+		pipe.Expire(ctx, key, bucket.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("failed to record engagement for post %s: %v", postID, err)
+	}
+}
+
+// GetTrendingPosts returns the top posts of the window, leaving out the ones the viewer can't read
+func (pr *PostRepo) GetTrendingPosts(ctx context.Context, window TrendingWindow, limit int, viewerID string) ([]*Post, error) {
+	cfg := trendingWindows[window]
+
+	var ids []string
+	if pr.Redis != nil && pr.Redis.Client != nil {
+		var err error
+		ids, err = pr.Redis.Client.ZRevRangeByScore(ctx, fmt.Sprintf(trendingPostsKey, window), &redis.ZRangeBy{
+			Min:   "(0",
+			Max:   "+inf",
+			Count: int64(limit),
+		}).Result()
+		if err != nil && err != redis.Nil {
+			log.Printf("failed to read trending posts from redis, falling back to postgres: %v", err)
+			ids = nil
+		}
+	}
+	if len(ids) == 0 {
+		return pr.getRecentPopularPosts(ctx, time.Now().Add(-cfg.span), limit, viewerID)
+	}
+
+	return pr.getPostsByIDs(ctx, ids, viewerID)
+}
+
+// getRecentPopularPosts ranks posts created since the given time by raw engagement.
+// It is used until the aggregator has populated redis.
+func (pr *PostRepo) getRecentPopularPosts(ctx context.Context, since time.Time, limit int, viewerID string) ([]*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience
+        FROM posts
+        WHERE created_at > $1 AND is_draft = FALSE AND deleted_at IS NULL
+          AND ` + postVisibleSQL("posts", 3) + `
+          AND ` + labelFilterSQL("posts", 3) + `
+        ORDER BY (likes + reposts) DESC, created_at DESC
+        LIMIT $2
+    `
+
+	rows, err := db.DB.Query(ctx, query, since, limit, viewerArg(viewerID))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching trending posts: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []*Post
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning post: %w", err)
+		}
+		posts = append(posts, &post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating posts: %w", err)
+	}
+
+	return posts, nil
+}
+
+// getPostsByIDs loads the given posts the viewer can read and returns them in the same order as ids
+func (pr *PostRepo) getPostsByIDs(ctx context.Context, ids []string, viewerID string) ([]*Post, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT id, user_id, title, content, image_url, audio_url, parent_id, created_at, updated_at, likes, reposts, audience
+        FROM posts
+        WHERE id = ANY($1) AND is_draft = FALSE AND deleted_at IS NULL
+          AND ` + postVisibleSQL("posts", 2) + `
+          AND ` + labelFilterSQL("posts", 2) + `
+    `
+
+	rows, err := db.DB.Query(ctx, query, ids, viewerArg(viewerID))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching posts: %w", err)
+	}
+	defer rows.Close()
+
+	postMap := make(map[string]*Post, len(ids))
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
+			&post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts, &post.Audience,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning post: %w", err)
+		}
+		postMap[post.ID] = &post
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating posts: %w", err)
+	}
+
+	posts := make([]*Post, 0, len(postMap))
+	for _, id := range ids {
+		if post, ok := postMap[id]; ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+func (pr *PostRepo) GetTrendingTags(ctx context.Context, window TrendingWindow, limit int) ([]*TrendingTag, error) {
+	if pr.Redis == nil || pr.Redis.Client == nil {
+		return nil, errorx.New(errorx.ErrCodeServiceUnavailable, "trending tags are unavailable", nil)
+	}
+
+	entries, err := pr.Redis.Client.ZRevRangeWithScores(ctx, fmt.Sprintf(trendingTagsKey, window), 0, int64(limit-1)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("error reading trending tags: %w", err)
+	}
+	if len(entries) == 0 {
+		return []*TrendingTag{}, nil
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = fmt.Sprint(entry.Member)
+	}
+	counts, err := pr.Redis.Client.HMGet(ctx, fmt.Sprintf(trendingTagCountsKey, window), names...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error reading trending tag counts: %w", err)
+	}
+
+	tags := make([]*TrendingTag, len(entries))
+	for i, entry := range entries {
+		tag := &TrendingTag{Name: names[i], Score: entry.Score}
+		if s, ok := counts[i].(string); ok {
+			tag.PostCount, _ = strconv.Atoi(s)
+		}
+		tags[i] = tag
+	}
+	return tags, nil
+}
+
+// TrendingAggregator periodically folds the raw engagement buckets into decayed
+// trending scores per window and keeps them in redis
+type TrendingAggregator struct {
+	Repo     *PostRepo
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewTrendingAggregator(repo *PostRepo, interval time.Duration) *TrendingAggregator {
+	ctx, cancel := context.WithCancel(context.Background())
+	agg := &TrendingAggregator{
+		Repo:     repo,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	go agg.run()
+	return agg
+}
+
+func (ta *TrendingAggregator) Stop() {
+	ta.cancel()
+}
+
+func (ta *TrendingAggregator) run() {
+	ticker := time.NewTicker(ta.interval)
+	defer ticker.Stop()
+
+	ta.aggregateAll()
+	for {
+		select {
+		case <-ta.ctx.Done():
+			return
+		case <-ticker.C:
+			ta.aggregateAll()
+		}
+	}
+}
+
+func (ta *TrendingAggregator) aggregateAll() {
+	if ta.Repo.Redis == nil || ta.Repo.Redis.Client == nil {
+		return
+	}
+	now := time.Now()
+	for window, cfg := range trendingWindows {
+		if err := ta.aggregatePosts(ta.ctx, window, cfg, now); err != nil {
+			log.Printf("failed to aggregate trending posts for %s: %v", window, err)
+		}
+		if err := ta.aggregateTags(ta.ctx, window, cfg, now); err != nil {
+			log.Printf("failed to aggregate trending tags for %s: %v", window, err)
+		}
+	}
+}
+
+// aggregatePosts unions every bucket inside the window, weighting each one by its decayed age
+func (ta *TrendingAggregator) aggregatePosts(ctx context.Context, window TrendingWindow, cfg trendingWindowConfig, now time.Time) error {
+	current := now.Truncate(cfg.bucket.size)
+	var store redis.ZStore
+	for start := current; now.Sub(start) < cfg.span; start = start.Add(-cfg.bucket.size) {
+		store.Keys = append(store.Keys, fmt.Sprintf(trendingBucketKey, cfg.bucket.name, start.Unix()))
+		// events are spread across the bucket so its midpoint is used as their age
+		age := now.Sub(start.Add(cfg.bucket.size / 2))
+		store.Weights = append(store.Weights, decayWeight(age, cfg.halfLife))
+	}
+	store.Aggregate = "SUM"
+
+	return ta.Repo.Redis.Client.ZUnionStore(ctx, fmt.Sprintf(trendingPostsKey, window), &store).Err()
+}
+
+// aggregateTags scores tags by the decayed engagement of the posts carrying them. Only
+// posts anyone could read count, so tags never leak from restricted audiences or private authors
+func (ta *TrendingAggregator) aggregateTags(ctx context.Context, window TrendingWindow, cfg trendingWindowConfig, now time.Time) error {
+	db, ok := ta.Repo.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT t.name,
+               COUNT(*) AS post_count,
+               SUM(POWER(0.5, EXTRACT(EPOCH FROM ($2 - p.created_at)) / $3) * (1 + p.likes + p.reposts)) AS score
+        FROM post_tags pt
+        JOIN tags t ON pt.tag_id = t.id
+        JOIN posts p ON pt.post_id = p.id
+        JOIN users u ON u.id = p.user_id
+        WHERE p.created_at > $1 AND p.is_draft = FALSE AND p.deleted_at IS NULL
+          AND p.audience = 'PUBLIC' AND u.is_private = FALSE
+          AND (
+              p.audience_post_id IS NULL
+              OR EXISTS (
+                  SELECT 1 FROM posts ap JOIN users au ON au.id = ap.user_id
+                  WHERE ap.id = p.audience_post_id AND ap.audience = 'PUBLIC' AND au.is_private = FALSE
+              )
+          )
+        GROUP BY t.name
+        ORDER BY score DESC
+        LIMIT $4
+    `
+
+	rows, err := db.DB.Query(ctx, query, now.Add(-cfg.span), now, cfg.halfLife.Seconds(), trendingTagsLimit)
+	if err != nil {
+		return fmt.Errorf("error querying trending tags: %w", err)
+	}
+	defer rows.Close()
+
+	var scores []redis.Z
+	counts := make(map[string]interface{})
+	for rows.Next() {
+		var name string
+		var postCount int
+		var score float64
+		if err := rows.Scan(&name, &postCount, &score); err != nil {
+			return fmt.Errorf("error scanning trending tag: %w", err)
+		}
+		scores = append(scores, redis.Z{Score: score, Member: name})
+		counts[name] = postCount
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating trending tags: %w", err)
+	}
+
+	tagsKey := fmt.Sprintf(trendingTagsKey, window)
+	countsKey := fmt.Sprintf(trendingTagCountsKey, window)
+	pipe := ta.Repo.Redis.Client.TxPipeline()
+	pipe.Del(ctx, tagsKey, countsKey)
+	if len(scores) > 0 {
+		pipe.ZAdd(ctx, tagsKey, scores...)
+		pipe.HSet(ctx, countsKey, counts)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,306 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	viewsPendingKey = "views:pending"
+	viewsCountKey   = "views:count:%s"
+	viewsUniqueKey  = "views:unique:%s"
+	// viewsUniqueTTL lets the unique viewers of posts nobody looks at anymore expire, the
+	// count already flushed is kept by post_analytics
+	viewsUniqueTTL = 30 * 24 * time.Hour
+	// viewsDailyKey holds the unique viewers of a post for one day, for the creator stats
+	viewsDailyKey  = "views:unique:%s:%s"
+	viewsDailyTTL  = 48 * time.Hour
+	viewsDayLayout = "20060102"
+	viewsBatchSize = 500
+	// maxViewsPerRequest caps how many impressions a client can report at once
+	maxViewsPerRequest = 100
+)
+
+// RecordViews counts an impression of each post in redis. Unique viewers are kept in a
+// HyperLogLog per post, anonymous views only count towards the total. The counts reach
+// postgres when the ViewFlusher runs, failures are logged and the views are dropped.
+func (pr *PostRepo) RecordViews(ctx context.Context, postIDs []string, viewerID string) {
+	if pr.Redis == nil || pr.Redis.Client == nil || len(postIDs) == 0 {
+		return
+	}
+
+	day := time.Now().Format(viewsDayLayout)
+	pipe := pr.Redis.Client.Pipeline()
+	for _, postID := range postIDs {
+		pipe.Incr(ctx, fmt.Sprintf(viewsCountKey, postID))
+		if viewerID != "" {
+			uniqueKey := fmt.Sprintf(viewsUniqueKey, postID)
+			pipe.PFAdd(ctx, uniqueKey, viewerID)
+			pipe.Expire(ctx, uniqueKey, viewsUniqueTTL)
+			dailyKey := fmt.Sprintf(viewsDailyKey, postID, day)
+			pipe.PFAdd(ctx, dailyKey, viewerID)
+			pipe.Expire(ctx, dailyKey, viewsDailyTTL)
+		}
+		pipe.SAdd(ctx, viewsPendingKey, postID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("failed to record views: %v", err)
+	}
+}
+
+// visiblePostIDs returns the posts of postIDs the viewer can see, drafts, deleted and
+// unknown posts are left out
+func (pr *PostRepo) visiblePostIDs(ctx context.Context, postIDs []string, viewerID string) ([]string, error) {
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	query := `
+        SELECT p.id FROM posts p
+        WHERE p.id = ANY($1::uuid[]) AND p.is_draft = FALSE AND p.deleted_at IS NULL
+          AND ` + postVisibleSQL("p", 2)
+	rows, err := db.DB.Query(ctx, query, postIDs, viewerArg(viewerID))
+	if err != nil {
+		return nil, fmt.Errorf("error checking viewed posts: %w", err)
+	}
+	defer rows.Close()
+
+	visible := make([]string, 0, len(postIDs))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning viewed post: %w", err)
+		}
+		visible = append(visible, id)
+	}
+	return visible, rows.Err()
+}
+
+// FlushViews moves up to limit posts worth of pending views from redis into posts.view_count,
+// post_analytics and the daily stats of the current day. It returns the number of posts flushed.
+func (pr *PostRepo) FlushViews(ctx context.Context, limit int) (int, error) {
+	if pr.Redis == nil || pr.Redis.Client == nil {
+		return 0, nil
+	}
+	db, ok := pr.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return 0, fmt.Errorf("pr.DB does not implement database.Database")
+	}
+
+	ids, err := pr.Redis.Client.SPopN(ctx, viewsPendingKey, int64(limit)).Result()
+	if err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("error reading pending views: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	day := now.Format(viewsDayLayout)
+	pipe := pr.Redis.Client.Pipeline()
+	countCmds := make([]*redis.StringCmd, len(ids))
+	uniqueCmds := make([]*redis.IntCmd, len(ids))
+	dailyCmds := make([]*redis.IntCmd, len(ids))
+	for i, id := range ids {
+		countCmds[i] = pipe.GetDel(ctx, fmt.Sprintf(viewsCountKey, id))
+		uniqueCmds[i] = pipe.PFCount(ctx, fmt.Sprintf(viewsUniqueKey, id))
+		dailyCmds[i] = pipe.PFCount(ctx, fmt.Sprintf(viewsDailyKey, id, day))
+	}
+	// a missing counter shows up as redis.Nil on its own command
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		pr.requeueViews(ctx, ids, nil)
+		return 0, fmt.Errorf("error reading view counters: %w", err)
+	}
+
+	postIDs := make([]string, 0, len(ids))
+	views := make([]int64, 0, len(ids))
+	uniques := make([]int64, 0, len(ids))
+	dailyUniques := make([]int64, 0, len(ids))
+	for i, id := range ids {
+		count, _ := strconv.ParseInt(countCmds[i].Val(), 10, 64)
+		if count == 0 {
+			continue
+		}
+		// ids queued before they were checked can't be flushed, requeueing them would fail
+		// every later batch too
+		if _, err := uuid.Parse(id); err != nil {
+			log.Printf("dropping %d views of invalid post id %q", count, id)
+			continue
+		}
+		postIDs = append(postIDs, id)
+		views = append(views, count)
+		uniques = append(uniques, uniqueCmds[i].Val())
+		dailyUniques = append(dailyUniques, dailyCmds[i].Val())
+	}
+	if len(postIDs) == 0 {
+		return 0, nil
+	}
+
+	// the HyperLogLog holds the running unique count, so it replaces the stored value
+	query := `
+        WITH v AS (
+            SELECT * FROM unnest($1::uuid[], $2::int[], $3::int[]) AS v(post_id, views, unique_views)
+        ), p AS (
+            UPDATE posts SET view_count = COALESCE(posts.view_count, 0) + v.views
+            FROM v
+            WHERE posts.id = v.post_id
+            RETURNING posts.id, posts.view_count, COALESCE(posts.likes, 0) AS likes,
+                      COALESCE(posts.reposts, 0) AS reposts, posts.comment_count, posts.quote_count
+        )
+        INSERT INTO post_analytics (post_id, views, unique_views, shares, engagement_rate, updated_at)
+        SELECT p.id, p.view_count, v.unique_views, p.reposts + p.quote_count,
+               (p.likes + p.reposts + p.comment_count)::float / GREATEST(p.view_count, 1), $4
+        FROM p JOIN v ON v.post_id = p.id
+        ON CONFLICT (post_id) DO UPDATE SET
+            views = EXCLUDED.views,
+            unique_views = GREATEST(post_analytics.unique_views, EXCLUDED.unique_views),
+            shares = EXCLUDED.shares,
+            engagement_rate = EXCLUDED.engagement_rate,
+            updated_at = EXCLUDED.updated_at
+    `
+	// views are counted on the day they are flushed, they sit in redis for at most a flush interval
+	dailyQuery := `
+        INSERT INTO post_daily_stats (post_id, user_id, day, views, unique_views)
+        SELECT p.id, p.user_id, $4::date, v.views, v.unique_views
+        FROM unnest($1::uuid[], $2::int[], $3::int[]) AS v(post_id, views, unique_views)
+        JOIN posts p ON p.id = v.post_id
+        ON CONFLICT (post_id, day) DO UPDATE SET
+            views = post_daily_stats.views + EXCLUDED.views,
+            unique_views = GREATEST(post_daily_stats.unique_views, EXCLUDED.unique_views)
+    `
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		pr.requeueViews(ctx, postIDs, views)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	postIDs, views, uniques, dailyUniques, err = existingViews(ctx, tx, postIDs, views, uniques, dailyUniques)
+	if err != nil {
+		pr.requeueViews(ctx, postIDs, views)
+		return 0, err
+	}
+
+	if _, err = tx.Exec(ctx, query, postIDs, views, uniques, now); err != nil {
+		pr.requeueViews(ctx, postIDs, views)
+		return 0, fmt.Errorf("error flushing views: %w", err)
+	}
+	if _, err = tx.Exec(ctx, dailyQuery, postIDs, views, dailyUniques, now); err != nil {
+		pr.requeueViews(ctx, postIDs, views)
+		return 0, fmt.Errorf("error flushing daily views: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		pr.requeueViews(ctx, postIDs, views)
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(ids), nil
+}
+
+// existingViews drops the views of posts that have been purged since they were viewed, the
+// rows are locked so the posts can't go away before the flush commits
+func existingViews(ctx context.Context, tx pgx.Tx, postIDs []string, views, uniques, dailyUniques []int64) ([]string, []int64, []int64, []int64, error) {
+	rows, err := tx.Query(ctx, `SELECT id FROM posts WHERE id = ANY($1::uuid[]) FOR KEY SHARE`, postIDs)
+	if err != nil {
+		return postIDs, views, nil, nil, fmt.Errorf("error checking flushed posts: %w", err)
+	}
+	existing := make(map[string]bool, len(postIDs))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return postIDs, views, nil, nil, fmt.Errorf("error scanning flushed post: %w", err)
+		}
+		existing[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return postIDs, views, nil, nil, fmt.Errorf("error checking flushed posts: %w", err)
+	}
+
+	n := 0
+	for i, id := range postIDs {
+		if !existing[id] {
+			log.Printf("dropping %d views of missing post %s", views[i], id)
+			continue
+		}
+		postIDs[n], views[n], uniques[n], dailyUniques[n] = id, views[i], uniques[i], dailyUniques[i]
+		n++
+	}
+	return postIDs[:n], views[:n], uniques[:n], dailyUniques[:n], nil
+}
+
+// requeueViews puts views taken out of redis back so the next flush picks them up again
+func (pr *PostRepo) requeueViews(ctx context.Context, postIDs []string, views []int64) {
+	pipe := pr.Redis.Client.Pipeline()
+	for i, id := range postIDs {
+		if views != nil {
+			pipe.IncrBy(ctx, fmt.Sprintf(viewsCountKey, id), views[i])
+		}
+		pipe.SAdd(ctx, viewsPendingKey, id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("failed to requeue %d posts worth of views: %v", len(postIDs), err)
+	}
+}
+
+// ViewFlusher periodically writes the view counts buffered in redis to postgres
+type ViewFlusher struct {
+	Repo     *PostRepo
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewViewFlusher(repo *PostRepo, interval time.Duration) *ViewFlusher {
+	ctx, cancel := context.WithCancel(context.Background())
+	vf := &ViewFlusher{
+		Repo:     repo,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	go vf.run()
+	return vf
+}
+
+func (vf *ViewFlusher) Stop() {
+	vf.cancel()
+}
+
+func (vf *ViewFlusher) run() {
+	ticker := time.NewTicker(vf.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-vf.ctx.Done():
+			return
+		case <-ticker.C:
+			vf.flush()
+		}
+	}
+}
+
+func (vf *ViewFlusher) flush() {
+	for {
+		n, err := vf.Repo.FlushViews(vf.ctx, viewsBatchSize)
+		if err != nil {
+			log.Printf("failed to flush post views: %v", err)
+			return
+		}
+		if n < viewsBatchSize {
+			return
+		}
+	}
+}
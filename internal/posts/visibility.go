@@ -0,0 +1,127 @@
+package posts
+
+import (
+	"context"
+	"fmt"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+	"strconv"
+	"strings"
+)
+
+// Audience decides who can read a post besides its author
+type Audience string
+
+const (
+	AudiencePublic       Audience = "PUBLIC"
+	AudienceFollowers    Audience = "FOLLOWERS"
+	AudienceMentioned    Audience = "MENTIONED"
+	AudienceCloseFriends Audience = "CLOSE_FRIENDS"
+)
+
+func (a Audience) IsValid() bool {
+	switch a {
+	case AudiencePublic, AudienceFollowers, AudienceMentioned, AudienceCloseFriends:
+		return true
+	}
+	return false
+}
+
+// audience returns the audience picked for a new post, public when none was given
+func (in *CreatePostInput) audience() Audience {
+	if in.Audience == nil {
+		return AudiencePublic
+	}
+	return *in.Audience
+}
+
+// rowQuerier is satisfied by both the connection pool and a transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// viewerArg turns an empty viewerID, an anonymous viewer, into a NULL query argument
+func viewerArg(viewerID string) interface{} {
+	if viewerID == "" {
+		return nil
+	}
+	return viewerID
+}
+
+// authorVisibleSQL hides authors blocked in either direction, and private authors
+// from anyone but their approved followers
+const authorVisibleSQL = `
+    NOT EXISTS (
+        SELECT 1 FROM blocked_users
+        WHERE (blocker_id = {author} AND blocked_id = {viewer}) OR (blocker_id = {viewer} AND blocked_id = {author})
+    )
+    AND (
+        NOT EXISTS (SELECT 1 FROM users WHERE id = {author} AND is_private = TRUE)
+        OR EXISTS (SELECT 1 FROM follows WHERE follower_id = {viewer} AND followed_id = {author} AND status = 'ACCEPTED')
+    )`
+
+// audienceAllowsSQL checks the audience the author picked for the post
+const audienceAllowsSQL = `
+    CASE {post}.audience
+        WHEN 'FOLLOWERS' THEN EXISTS (
+            SELECT 1 FROM follows WHERE follower_id = {viewer} AND followed_id = {post}.user_id AND status = 'ACCEPTED'
+        )
+        WHEN 'MENTIONED' THEN EXISTS (SELECT 1 FROM post_mentions WHERE post_id = {post}.id AND user_id = {viewer})
+        WHEN 'CLOSE_FRIENDS' THEN EXISTS (SELECT 1 FROM close_friends WHERE user_id = {post}.user_id AND friend_id = {viewer})
+        ELSE TRUE
+    END`
+
+// postVisibleSQL returns a condition that holds when the post under alias can be read by
+// the viewer bound to the numbered query parameter. Authors always see their own posts.
+// Replies and reposts are also limited by the post named in audience_post_id, the top of
+// their thread or the reposted post. A NULL viewer is anonymous and only sees public posts.
+func postVisibleSQL(alias string, viewerParam int) string {
+	viewer := "$" + strconv.Itoa(viewerParam) + "::uuid"
+	check := func(post string) string {
+		r := strings.NewReplacer("{post}", post, "{author}", post+".user_id", "{viewer}", viewer)
+		return r.Replace(authorVisibleSQL) + "\n    AND " + r.Replace(audienceAllowsSQL)
+	}
+	return fmt.Sprintf(`(
+    %[1]s.user_id = %[2]s
+    OR (
+        %[3]s
+        AND (
+            %[1]s.audience_post_id IS NULL
+            OR EXISTS (
+                SELECT 1 FROM posts ap
+                WHERE ap.id = %[1]s.audience_post_id AND (ap.user_id = %[2]s OR (%[4]s))
+            )
+        )
+    )
+)`, alias, viewer, check(alias), check("ap"))
+}
+
+// canViewPost reports whether the viewer may read the post. Missing and deleted posts,
+// and drafts of other users, are reported as not visible.
+func canViewPost(ctx context.Context, q rowQuerier, postID string, viewerID string) (bool, error) {
+	query := `SELECT ` + postVisibleSQL("p", 2) + ` FROM posts p
+        WHERE p.id = $1 AND p.deleted_at IS NULL AND (p.is_draft = FALSE OR p.user_id = $2::uuid)`
+
+	var visible *bool
+	err := q.QueryRow(ctx, query, postID, viewerArg(viewerID)).Scan(&visible)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking post visibility: %w", err)
+	}
+	return visible != nil && *visible, nil
+}
+
+// requireVisible returns a not found error when the viewer can't read the post, so hidden
+// posts look the same as missing ones
+func requireVisible(ctx context.Context, q rowQuerier, postID string, viewerID string) error {
+	visible, err := canViewPost(ctx, q, postID, viewerID)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return errorx.New(errorx.ErrCodeNotFound, "post not found", nil)
+	}
+	return nil
+}
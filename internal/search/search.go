@@ -0,0 +1,175 @@
+package search
+
+import (
+	"context"
+	"errors"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/models"
+	"github.com/bertoxic/graphqlChat/internal/posts"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultTimeout is the shared deadline of the user, post and tag searches
+	DefaultTimeout = 2 * time.Second
+	defaultLimit   = 5
+	maxLimit       = 20
+)
+
+type HitType string
+
+const (
+	HitUser HitType = "USER"
+	HitPost HitType = "POST"
+	HitTag  HitType = "TAG"
+)
+
+// hitOrder breaks score ties in the merged ranking
+var hitOrder = map[HitType]int{HitUser: 0, HitTag: 1, HitPost: 2}
+
+// Hit is one entry of the merged ranking, only the field matching Type is set
+type Hit struct {
+	Type  HitType
+	Score float64
+	User  *models.User
+	Post  *posts.Post
+	Tag   *string
+}
+
+// Result holds the best matches of each kind and all of them merged by score. Scores are
+// between 0 and 1 so users, posts and tags can be compared.
+type Result struct {
+	Users []*models.User
+	Posts []*posts.Post
+	Tags  []string
+	Top   []*Hit
+}
+
+type UserSearcher interface {
+	TypeaheadUsers(ctx context.Context, query string, viewerID string, limit int) ([]*models.UserMatch, error)
+}
+
+type PostSearcher interface {
+	SearchPosts(ctx context.Context, query string, filter posts.PostSearchFilter, viewerID string, first int, after *string) (*posts.PostSearchPage, error)
+	SearchTags(ctx context.Context, query string, viewerID string, limit int) ([]*posts.TagMatch, error)
+}
+
+type SearchService interface {
+	SearchAll(ctx context.Context, query string, viewerID string, limit int) (*Result, error)
+}
+
+type SearchServiceImpl struct {
+	Users   UserSearcher
+	Posts   PostSearcher
+	Timeout time.Duration
+}
+
+func NewSearchServiceImpl(users UserSearcher, posts PostSearcher) *SearchServiceImpl {
+	return &SearchServiceImpl{Users: users, Posts: posts, Timeout: DefaultTimeout}
+}
+
+// typeaheadQuery treats the word being typed as a prefix, unless it is inside a quoted phrase
+func typeaheadQuery(query string) string {
+	last, _ := utf8.DecodeLastRuneInString(query)
+	if strings.Count(query, `"`)%2 == 0 && (unicode.IsLetter(last) || unicode.IsDigit(last)) {
+		return query + "*"
+	}
+	return query
+}
+
+// SearchAll runs the user, post and tag searches at the same time for what the viewer is
+// typing. Searches that fail or miss the deadline are left out of the result, an error is
+// only returned when all of them fail.
+func (s *SearchServiceImpl) SearchAll(ctx context.Context, query string, viewerID string, limit int) (*Result, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errorx.New(errorx.ErrCodeValidation, "search query is empty", nil)
+	}
+	if limit < 1 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	var (
+		wg                       sync.WaitGroup
+		users                    []*models.UserMatch
+		postPage                 *posts.PostSearchPage
+		tags                     []*posts.TagMatch
+		userErr, postErr, tagErr error
+	)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		users, userErr = s.Users.TypeaheadUsers(ctx, query, viewerID, limit)
+	}()
+	go func() {
+		defer wg.Done()
+		postPage, postErr = s.Posts.SearchPosts(ctx, typeaheadQuery(query), posts.PostSearchFilter{}, viewerID, limit, nil)
+		// a query with nothing to match in post text still finds users and tags
+		var appErr *errorx.AppError
+		if errors.As(postErr, &appErr) && appErr.Code == errorx.ErrCodeValidation {
+			postPage, postErr = &posts.PostSearchPage{}, nil
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		tags, tagErr = s.Posts.SearchTags(ctx, query, viewerID, limit)
+	}()
+	wg.Wait()
+
+	if userErr != nil && postErr != nil && tagErr != nil {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "search failed", errors.Join(userErr, postErr, tagErr))
+	}
+
+	result := &Result{Users: []*models.User{}, Posts: []*posts.Post{}, Tags: []string{}}
+	var hits []*Hit
+	if userErr != nil {
+		log.Printf("user search for %q failed: %v", query, userErr)
+	}
+	for _, match := range users {
+		user := match.User
+		result.Users = append(result.Users, &user)
+		hits = append(hits, &Hit{Type: HitUser, Score: match.Score, User: &user})
+	}
+	if postErr != nil {
+		log.Printf("post search for %q failed: %v", query, postErr)
+	} else {
+		for _, match := range postPage.Results {
+			result.Posts = append(result.Posts, match.Post)
+			// ts_rank_cd has no upper bound, rank/(rank+1) keeps the order and fits it in 0 to 1
+			hits = append(hits, &Hit{Type: HitPost, Score: match.Rank / (match.Rank + 1), Post: match.Post})
+		}
+	}
+	if tagErr != nil {
+		log.Printf("tag search for %q failed: %v", query, tagErr)
+	}
+	for _, match := range tags {
+		name := match.Name
+		result.Tags = append(result.Tags, name)
+		hits = append(hits, &Hit{Type: HitTag, Score: match.Score, Tag: &name})
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hitOrder[hits[i].Type] < hitOrder[hits[j].Type]
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	result.Top = hits
+
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package search
+
+import (
+	"context"
+	"errors"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/models"
+	"github.com/bertoxic/graphqlChat/internal/posts"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type fakeUsers struct {
+	matches []*models.UserMatch
+	err     error
+	delay   time.Duration
+}
+
+func (f fakeUsers) TypeaheadUsers(ctx context.Context, query string, viewerID string, limit int) ([]*models.UserMatch, error) {
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return f.matches, f.err
+}
+
+type fakePosts struct {
+	page     *posts.PostSearchPage
+	postErr  error
+	tags     []*posts.TagMatch
+	tagErr   error
+	gotQuery string
+}
+
+func (f *fakePosts) SearchPosts(ctx context.Context, query string, filter posts.PostSearchFilter, viewerID string, first int, after *string) (*posts.PostSearchPage, error) {
+	f.gotQuery = query
+	return f.page, f.postErr
+}
+
+func (f *fakePosts) SearchTags(ctx context.Context, query string, viewerID string, limit int) ([]*posts.TagMatch, error) {
+	return f.tags, f.tagErr
+}
+
+func TestTypeaheadQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "gol", want: "gol*"},
+		{query: "golang tip", want: "golang tip*"},
+		{query: "golang ", want: "golang "},
+		{query: `"exact phrase"`, want: `"exact phrase"`},
+		{query: `"still typ`, want: `"still typ`},
+		{query: "done!", want: "done!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			require.Equal(t, tt.want, typeaheadQuery(tt.query))
+		})
+	}
+}
+
+func TestSearchAll_MergesByScore(t *testing.T) {
+	users := fakeUsers{matches: []*models.UserMatch{
+		{User: models.User{ID: "u1", UserName: "gopher"}, Score: 0.8},
+	}}
+	postSearch := &fakePosts{
+		page: &posts.PostSearchPage{Results: []*posts.PostSearchResult{
+			{Post: &posts.Post{ID: "p1"}, Rank: 3},
+			{Post: &posts.Post{ID: "p2"}, Rank: 0.25},
+		}},
+		tags: []*posts.TagMatch{{Name: "go", Score: 1}, {Name: "gophers", Score: 0.7}},
+	}
+	service := NewSearchServiceImpl(users, postSearch)
+
+	result, err := service.SearchAll(context.Background(), " go ", "", 4)
+	require.NoError(t, err)
+	require.Equal(t, "go*", postSearch.gotQuery)
+	require.Len(t, result.Users, 1)
+	require.Len(t, result.Posts, 2)
+	require.Equal(t, []string{"go", "gophers"}, result.Tags)
+
+	var order []string
+	for _, hit := range result.Top {
+		switch hit.Type {
+		case HitUser:
+			order = append(order, hit.User.ID)
+		case HitPost:
+			order = append(order, hit.Post.ID)
+		case HitTag:
+			order = append(order, *hit.Tag)
+		}
+	}
+	require.Equal(t, []string{"go", "u1", "p1", "gophers"}, order)
+}
+
+func TestSearchAll_PartialResults(t *testing.T) {
+	users := fakeUsers{matches: []*models.UserMatch{{User: models.User{ID: "u1"}, Score: 1}}, delay: time.Second}
+	postSearch := &fakePosts{postErr: errors.New("connection reset"), tags: []*posts.TagMatch{{Name: "go", Score: 1}}}
+	service := NewSearchServiceImpl(users, postSearch)
+	service.Timeout = 20 * time.Millisecond
+
+	result, err := service.SearchAll(context.Background(), "go", "", 5)
+	require.NoError(t, err)
+	require.Empty(t, result.Users)
+	require.Empty(t, result.Posts)
+	require.Equal(t, []string{"go"}, result.Tags)
+	require.Len(t, result.Top, 1)
+}
+
+func TestSearchAll_Errors(t *testing.T) {
+	failing := &fakePosts{postErr: errors.New("down"), tagErr: errors.New("down")}
+	service := NewSearchServiceImpl(fakeUsers{err: errors.New("down")}, failing)
+
+	_, err := service.SearchAll(context.Background(), "go", "", 5)
+	require.Error(t, err)
+
+	_, err = service.SearchAll(context.Background(), "   ", "", 5)
+	var appErr *errorx.AppError
+	require.ErrorAs(t, err, &appErr)
+	require.Equal(t, errorx.ErrCodeValidation, appErr.Code)
+
+	// punctuation only can't match post text but is not an error on its own
+	noText := &fakePosts{postErr: errorx.New(errorx.ErrCodeValidation, "search query is empty", nil)}
+	result, err := NewSearchServiceImpl(fakeUsers{}, noText).SearchAll(context.Background(), "@@", "", 5)
+	require.NoError(t, err)
+	require.Empty(t, result.Top)
+}
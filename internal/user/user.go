@@ -4,7 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
 	"github.com/bertoxic/graphqlChat/internal/models"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	userSearchMaxQueryRunes = 100
+	userSearchDefaultFirst  = 20
+	userSearchMaxFirst      = 50
 )
 
 type Service struct {
@@ -23,13 +32,17 @@ type UserService interface {
 	UpdateUserDetails(ctx context.Context, userDetails models.UpdateUserInput, userID string) (*models.UserDetails, error)
 	FollowUser(ctx context.Context, followerID, followedID string) (*models.UserResponse, error)
 	UnfollowUser(ctx context.Context, followerID, followedID string) (*models.UserResponse, error)
+	GetFollowRequests(ctx context.Context, userID string, limit, offset int) ([]*models.User, error)
+	ApproveFollowRequest(ctx context.Context, userID, followerID string) (*models.UserResponse, error)
+	DeclineFollowRequest(ctx context.Context, userID, followerID string) (*models.UserResponse, error)
 	GetUserStats(ctx context.Context, userID string) (*models.UserStats, error)
 	GetUserNotifications(ctx context.Context, userID string, limit, offset int) ([]*models.Notification, error)
 	MarkNotificationAsRead(ctx context.Context, notificationID string) error
 	GetSuggestedUsers(ctx context.Context, userID string, limit int) ([]*models.User, error)
 	GetUserByID(ctx context.Context, id string) (models.User, error)
 	CheckUsernameAvailability(ctx context.Context, username string) (bool, error)
-	SearchUsers(ctx context.Context, query string, limit int) ([]*models.User, error)
+	SearchUsers(ctx context.Context, query string, viewerID string, first int, after *string) (*models.UserSearchPage, error)
+	TypeaheadUsers(ctx context.Context, query string, viewerID string, limit int) ([]*models.UserMatch, error)
 	CreateUser(ctx context.Context, user *models.User) (*models.UserResponse, error)
 	DeleteUser(ctx context.Context, userID string) (*models.UserResponse, error)
 	ResetPassword(ctx context.Context, userID, currentPassword, newPassword string) (*models.UserResponse, error)
@@ -95,6 +108,30 @@ func (s Service) FollowUser(ctx context.Context, followerID, followedID string)
 	return resp, nil
 }
 
+func (s Service) GetFollowRequests(ctx context.Context, userID string, limit, offset int) ([]*models.User, error) {
+	requests, err := s.UserRepo.GetFollowRequests(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (s Service) ApproveFollowRequest(ctx context.Context, userID, followerID string) (*models.UserResponse, error) {
+	resp, err := s.UserRepo.ApproveFollowRequest(ctx, userID, followerID)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s Service) DeclineFollowRequest(ctx context.Context, userID, followerID string) (*models.UserResponse, error) {
+	resp, err := s.UserRepo.DeclineFollowRequest(ctx, userID, followerID)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (s Service) UpdateUserDetails(ctx context.Context, userDetails models.UpdateUserInput, userID string) (*models.UserDetails, error) {
 	newUserDetails, err := s.UserRepo.UpdateUserDetails(ctx, userDetails, userID)
 	if err != nil {
@@ -162,13 +199,41 @@ func (s Service) CheckUsernameAvailability(ctx context.Context, username string)
 
 	return !exists, nil
 }
-func (s Service) SearchUsers(ctx context.Context, query string, limit int) ([]*models.User, error) {
-	search, err := s.UserRepo.SearchUsers(ctx, query, limit)
+
+// TypeaheadUsers matches the start of usernames and full names, a leading @ is ignored
+func (s Service) TypeaheadUsers(ctx context.Context, query string, viewerID string, limit int) ([]*models.UserMatch, error) {
+	prefix := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+	if prefix == "" {
+		return []*models.UserMatch{}, nil
+	}
+	matches, err := s.UserRepo.TypeaheadUsers(ctx, prefix, viewerID, limit)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to search users for %q: %w", query, err)
 	}
+	return matches, nil
+}
 
-	return search, nil
+// SearchUsers returns a page of users matching query, a leading @ is ignored
+func (s Service) SearchUsers(ctx context.Context, query string, viewerID string, first int, after *string) (*models.UserSearchPage, error) {
+	query = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+	if query == "" {
+		return &models.UserSearchPage{Users: []*models.User{}}, nil
+	}
+	if utf8.RuneCountInString(query) > userSearchMaxQueryRunes {
+		return nil, errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("search queries can be at most %d characters", userSearchMaxQueryRunes), nil)
+	}
+	if first < 1 {
+		first = userSearchDefaultFirst
+	}
+	if first > userSearchMaxFirst {
+		first = userSearchMaxFirst
+	}
+
+	page, err := s.UserRepo.SearchUsers(ctx, query, viewerID, first, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users for %q: %w", query, err)
+	}
+	return page, nil
 }
 
 func (s Service) CreateUser(ctx context.Context, user *models.User) (*models.UserResponse, error) {
@@ -2,15 +2,20 @@ package user
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"github.com/bertoxic/graphqlChat/internal/database"
 	"github.com/bertoxic/graphqlChat/internal/database/postgres"
 	errorx "github.com/bertoxic/graphqlChat/internal/error"
 	"github.com/bertoxic/graphqlChat/internal/models"
+	"github.com/bertoxic/graphqlChat/internal/utils"
+	"github.com/bertoxic/graphqlChat/internal/webhooks"
 	"github.com/jackc/pgx/v4"
 	"golang.org/x/crypto/bcrypt"
-	"log"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type UserRepo interface {
@@ -22,9 +27,13 @@ type UserRepo interface {
 	GetUsersFollowing(ctx context.Context, userID string, limit, offset int) ([]models.User, error)
 	FollowUser(ctx context.Context, followerID, followedID string) (*models.UserResponse, error)
 	UnfollowUser(ctx context.Context, followerID, followedID string) (*models.UserResponse, error)
+	GetFollowRequests(ctx context.Context, userID string, limit, offset int) ([]*models.User, error)
+	ApproveFollowRequest(ctx context.Context, userID, followerID string) (*models.UserResponse, error)
+	DeclineFollowRequest(ctx context.Context, userID, followerID string) (*models.UserResponse, error)
 	GetUserNotifications(ctx context.Context, userID string, limit, offset int) ([]models.Notification, error)
 	MarkNotificationAsRead(ctx context.Context, notificationID string) error
-	SearchUsers(ctx context.Context, query string, limit int) ([]models.User, error)
+	SearchUsers(ctx context.Context, query string, viewerID string, first int, after *string) (*models.UserSearchPage, error)
+	TypeaheadUsers(ctx context.Context, prefix string, viewerID string, limit int) ([]*models.UserMatch, error)
 	GetSuggestedUsers(ctx context.Context, userID string, limit int) ([]models.User, error)
 	CheckUsernameAvailability(ctx context.Context, username string) (bool, error)
 	GetUserByID(ctx context.Context, id string) (*models.User, error)
@@ -39,6 +48,13 @@ type UserRepo interface {
 	GetTrendingPosts(ctx context.Context, limit int) ([]models.Post, error)
 }
 
+// followStatusPending marks follows of private accounts that wait for approval
+const followStatusPending = "PENDING"
+
+// userSearchMinTrigramRunes is the shortest query matched by similarity, shorter ones share
+// too few trigrams with names and only match prefixes
+const userSearchMinTrigramRunes = 3
+
 type Repository struct {
 	DB database.DatabaseRepo
 }
@@ -111,7 +127,7 @@ func (us *Repository) GetUsersFollowers(ctx context.Context, userID string, limi
         SELECT u.id, u.username, u.email, u.full_name, u.bio, u.profile_picture_url
         FROM users u
         INNER JOIN follows f ON u.id = f.follower_id
-        WHERE f.followed_id = $1
+        WHERE f.followed_id = $1 AND f.status = 'ACCEPTED'
         ORDER BY f.created_at DESC
         LIMIT $2 OFFSET $3
     `
@@ -155,6 +171,14 @@ func (us *Repository) UpdateUserDetails(ctx context.Context, userDetails models.
 		return &models.UserDetails{}, err
 	}
 
+	// a public account has nothing left to approve
+	if userDetails.IsPrivate != nil && !*userDetails.IsPrivate {
+		_, err = tx.Exec(ctx, `UPDATE follows SET status = 'ACCEPTED', created_at = NOW() WHERE followed_id = $1 AND status = 'PENDING'`, userID)
+		if err != nil {
+			return &models.UserDetails{}, fmt.Errorf("failed to accept follow requests: %w", err)
+		}
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -212,8 +236,8 @@ func (us *Repository) GetUserStats(ctx context.Context, userID string) (*models.
 	query := `
         SELECT 
             (SELECT COUNT(*) FROM posts WHERE user_id = $1) as total_posts,
-            (SELECT COUNT(*) FROM follows WHERE followed_id = $1) as total_followers,
-            (SELECT COUNT(*) FROM follows WHERE follower_id = $1) as total_following
+            (SELECT COUNT(*) FROM follows WHERE followed_id = $1 AND status = 'ACCEPTED') as total_followers,
+            (SELECT COUNT(*) FROM follows WHERE follower_id = $1 AND status = 'ACCEPTED') as total_following
     `
 
 	var stats models.UserStats
@@ -307,7 +331,7 @@ func (us *Repository) GetUsersFollowing(ctx context.Context, userID string, limi
         SELECT u.id, u.username, u.email, u.full_name, u.bio, u.profile_picture_url
         FROM users u
         INNER JOIN follows f ON u.id = f.followed_id
-        WHERE f.follower_id = $1
+        WHERE f.follower_id = $1 AND f.status = 'ACCEPTED'
         ORDER BY f.created_at DESC
         LIMIT $2 OFFSET $3
     `
@@ -330,28 +354,170 @@ func (us *Repository) GetUsersFollowing(ctx context.Context, userID string, limi
 
 	return following, nil
 }
+
+// FollowUser follows public accounts right away, following a private account sends a
+// request the account has to approve
 func (us *Repository) FollowUser(ctx context.Context, followerID, followedID string) (*models.UserResponse, error) {
 	db, ok := us.DB.(*postgres.PostgresDBRepo)
 	if !ok {
 		return nil, errorx.New(errorx.ErrCodeDatabase, "", errorx.ErrDatabase)
 	}
 
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// xmax is 0 for rows the insert created, following again changes nothing
 	query := `
-        INSERT INTO follows (follower_id, followed_id)
-        VALUES ($1, $2)
-        ON CONFLICT (follower_id, followed_id) DO NOTHING
+        INSERT INTO follows (follower_id, followed_id, status)
+        SELECT $1, id, CASE WHEN is_private THEN 'PENDING' ELSE 'ACCEPTED' END
+        FROM users
+        WHERE id = $2
+        ON CONFLICT (follower_id, followed_id) DO UPDATE SET status = follows.status
+        RETURNING status, xmax = 0
     `
 
-	_, err := db.DB.Exec(ctx, query, followerID, followedID)
+	var status string
+	var created bool
+	err = tx.QueryRow(ctx, query, followerID, followedID).Scan(&status, &created)
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "user not found", nil)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to follow user: %w", err)
 	}
+	if created && status != followStatusPending {
+		if err = recordUserFollowedTx(ctx, tx, followerID, followedID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
+	if status == followStatusPending {
+		return &models.UserResponse{
+			Success: true,
+			Message: "Follow request sent",
+		}, nil
+	}
 	return &models.UserResponse{
 		Success: true,
 		Message: "Successfully followed user",
 	}, nil
 }
+
+// recordUserFollowedTx tells the webhooks of both accounts about an accepted follow
+func recordUserFollowedTx(ctx context.Context, tx pgx.Tx, followerID, followedID string) error {
+	return webhooks.RecordEventTx(ctx, tx, webhooks.Event{
+		Type:    webhooks.EventUserFollowed,
+		UserIDs: []string{followerID, followedID},
+		Data:    webhooks.UserFollowed{FollowerID: followerID, FollowedID: followedID},
+	})
+}
+
+// GetFollowRequests returns the users waiting for the user to approve their follow, the oldest first
+func (us *Repository) GetFollowRequests(ctx context.Context, userID string, limit, offset int) ([]*models.User, error) {
+	db, ok := us.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "", errorx.ErrDatabase)
+	}
+
+	query := `
+        SELECT u.id, u.username, u.email, u.full_name, u.bio, u.profile_picture_url
+        FROM users u
+        INNER JOIN follows f ON u.id = f.follower_id
+        WHERE f.followed_id = $1 AND f.status = 'PENDING'
+        ORDER BY f.created_at ASC
+        LIMIT $2 OFFSET $3
+    `
+
+	rows, err := db.DB.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get follow requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*models.User
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(&user.ID, &user.UserName, &user.Email, &user.FullName, &user.Bio, &user.ProfilePictureURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan follow request: %w", err)
+		}
+		requests = append(requests, &user)
+	}
+
+	return requests, nil
+}
+
+// ApproveFollowRequest lets followerID see the user's posts. The follow counts as new
+// from the moment it is approved.
+func (us *Repository) ApproveFollowRequest(ctx context.Context, userID, followerID string) (*models.UserResponse, error) {
+	db, ok := us.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "", errorx.ErrDatabase)
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+        UPDATE follows SET status = 'ACCEPTED', created_at = NOW()
+        WHERE follower_id = $1 AND followed_id = $2 AND status = 'PENDING'
+    `
+
+	tag, err := tx.Exec(ctx, query, followerID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to approve follow request: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "follow request not found", nil)
+	}
+	if err = recordUserFollowedTx(ctx, tx, followerID, userID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &models.UserResponse{
+		Success: true,
+		Message: "Follow request approved",
+	}, nil
+}
+
+func (us *Repository) DeclineFollowRequest(ctx context.Context, userID, followerID string) (*models.UserResponse, error) {
+	db, ok := us.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "", errorx.ErrDatabase)
+	}
+
+	query := `
+        DELETE FROM follows
+        WHERE follower_id = $1 AND followed_id = $2 AND status = 'PENDING'
+    `
+
+	tag, err := db.DB.Exec(ctx, query, followerID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decline follow request: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "follow request not found", nil)
+	}
+
+	return &models.UserResponse{
+		Success: true,
+		Message: "Follow request declined",
+	}, nil
+}
 func (us *Repository) UnfollowUser(ctx context.Context, followerID, followedID string) (*models.UserResponse, error) {
 	db, ok := us.DB.(*postgres.PostgresDBRepo)
 	if !ok {
@@ -380,7 +546,7 @@ func (us *Repository) GetUserNotifications(ctx context.Context, userID string, l
 	}
 
 	query := `
-        SELECT id, user_id, type, content, is_read, created_at
+        SELECT id, user_id, type, COALESCE(title, ''), COALESCE(content, ''), is_read, created_at
         FROM notifications
         WHERE user_id = $1
         ORDER BY created_at DESC
@@ -396,7 +562,7 @@ func (us *Repository) GetUserNotifications(ctx context.Context, userID string, l
 	var notifications []*models.Notification
 	for rows.Next() {
 		var notification models.Notification
-		err := rows.Scan(&notification.ID, &notification.UserID, &notification.Type, &notification.Content, &notification.IsRead, &notification.CreatedAt)
+		err := rows.Scan(&notification.ID, &notification.UserID, &notification.Type, &notification.Title, &notification.Content, &notification.IsRead, &notification.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan notification: %w", err)
 		}
@@ -424,46 +590,184 @@ func (us *Repository) MarkNotificationAsRead(ctx context.Context, notificationID
 
 	return nil
 }
-func (us *Repository) SearchUsers(ctx context.Context, query string, limit int) ([]*models.User, error) {
+func encodeSearchCursor(score float64, id string) string {
+	raw := strconv.FormatFloat(score, 'g', -1, 64) + "|" + id
+	return base64.URLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(cursor string) (float64, string, error) {
+	raw, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, "", errorx.New(errorx.ErrCodeValidation, "invalid cursor", err)
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return 0, "", errorx.New(errorx.ErrCodeValidation, "invalid cursor", nil)
+	}
+	score, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, "", errorx.New(errorx.ErrCodeValidation, "invalid cursor", err)
+	}
+	return score, parts[1], nil
+}
+
+// SearchUsers ranks users by trigram similarity of their username and full name to query, so
+// typos still match. Verified accounts and accounts the viewer follows rank higher, mutual
+// follows highest. Queries shorter than userSearchMinTrigramRunes only match prefixes.
+// Users blocked in either direction are left out, an empty viewerID is anonymous.
+func (us *Repository) SearchUsers(ctx context.Context, query string, viewerID string, first int, after *string) (*models.UserSearchPage, error) {
 	db, ok := us.DB.(*postgres.PostgresDBRepo)
 	if !ok {
 		return nil, errorx.New(errorx.ErrCodeDatabase, "", errorx.ErrDatabase)
 	}
 
+	var viewer interface{}
+	if viewerID != "" {
+		viewer = viewerID
+	}
+
+	var afterScore, afterID interface{}
+	if after != nil && *after != "" {
+		score, id, err := decodeSearchCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		afterScore, afterID = score, id
+	}
+
+	// both match conditions are served by an index, the trigram one by GIN, the prefix one by btree
+	match := `(lower(u.username) % $1 OR $1 <% lower(coalesce(u.full_name, '')) OR lower(u.username) LIKE '%' || $2 || '%')`
+	if utf8.RuneCountInString(query) < userSearchMinTrigramRunes {
+		match = `(lower(u.username) LIKE $2 || '%' OR lower(u.full_name) LIKE $2 || '%')`
+	}
+
 	sqlQuery := `
-        SELECT id, username, email, full_name, bio, profile_picture_url
-        FROM users
-        WHERE username ILIKE $1 OR full_name ILIKE $1
-        LIMIT $2
+        SELECT id, username, full_name, bio, profile_picture_url, is_verified, is_private, score
+        FROM (
+            SELECT u.id, u.username, u.full_name, u.bio, u.profile_picture_url,
+                   COALESCE(u.is_verified, FALSE) AS is_verified, u.is_private,
+                   (
+                       GREATEST(similarity(lower(u.username), $1), word_similarity($1, lower(coalesce(u.full_name, ''))))
+                       + CASE WHEN lower(u.username) = $1 THEN 1.0 WHEN lower(u.username) LIKE $2 || '%' THEN 0.3 ELSE 0 END
+                       + CASE WHEN u.is_verified THEN 0.1 ELSE 0 END
+                       + CASE WHEN fr.follower_id IS NOT NULL AND fb.follower_id IS NOT NULL THEN 0.3
+                              WHEN fr.follower_id IS NOT NULL THEN 0.2
+                              ELSE 0 END
+                   )::float8 AS score
+            FROM users u
+            LEFT JOIN follows fr ON fr.follower_id = $3::uuid AND fr.followed_id = u.id AND fr.status = 'ACCEPTED'
+            LEFT JOIN follows fb ON fb.follower_id = u.id AND fb.followed_id = $3::uuid AND fb.status = 'ACCEPTED'
+            WHERE ` + match + `
+              AND ($3::uuid IS NULL OR NOT EXISTS (
+                  SELECT 1 FROM blocked_users
+                  WHERE (blocker_id = u.id AND blocked_id = $3::uuid) OR (blocker_id = $3::uuid AND blocked_id = u.id)
+              ))
+        ) matches
+        WHERE ($4::float8 IS NULL OR (score, id) < ($4::float8, $5::uuid))
+        ORDER BY score DESC, id DESC
+        LIMIT $6
     `
 
-	rows, err := db.DB.Query(ctx, sqlQuery, "%"+query+"%", limit)
+	rows, err := db.DB.Query(ctx, sqlQuery, query, utils.EscapeLike(query), viewer, afterScore, afterID, first+1)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search users: %w", err)
 	}
 	defer rows.Close()
 
-	var users = []*models.User{}
+	page := &models.UserSearchPage{Users: []*models.User{}}
+	var scores []float64
 	for rows.Next() {
-		var user = &models.User{}
-		err := rows.Scan(&user.ID, &user.UserName, &user.Email, &user.FullName, &user.Bio, &user.ProfilePictureURL)
+		var user models.User
+		var score float64
+		err := rows.Scan(
+			&user.ID, &user.UserName, &user.FullName, &user.Bio, &user.ProfilePictureURL,
+			&user.IsVerified, &user.IsPrivate, &score,
+		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan user in search results: %w", err)
 		}
+		page.Users = append(page.Users, &user)
+		scores = append(scores, score)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
 
-		if user.ID == "" || user.UserName == "" || user.Email == "" {
-			log.Printf("User object has missing fields: %+v", user)
-		}
-		users = append(users, user)
+	if len(page.Users) > first {
+		page.Users = page.Users[:first]
+		page.HasMore = true
+		cursor := encodeSearchCursor(scores[first-1], page.Users[first-1].ID)
+		page.NextCursor = &cursor
+	}
 
-		if err := rows.Err(); err != nil {
-			return nil, fmt.Errorf("error iterating over rows: %w", err)
-		}
+	return page, nil
+}
+
+// TypeaheadUsers finds users whose username or a word of their full name starts with prefix.
+// Users blocked in either direction are left out, an empty viewerID is anonymous.
+func (us *Repository) TypeaheadUsers(ctx context.Context, prefix string, viewerID string, limit int) ([]*models.UserMatch, error) {
+	db, ok := us.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, errorx.New(errorx.ErrCodeDatabase, "", errorx.ErrDatabase)
+	}
 
+	var viewer interface{}
+	if viewerID != "" {
+		viewer = viewerID
 	}
 
-	return users, nil
+	// exact usernames rank first, then username prefixes, then full name prefixes. The LIKE
+	// patterns are matched against the expressions of the trigram indexes so they can serve them.
+	query := `
+        SELECT id, username, full_name, bio, profile_picture_url, is_private, score
+        FROM (
+            SELECT u.*,
+                   CASE
+                       WHEN lower(u.username) = $1 THEN 1.0
+                       WHEN lower(u.username) LIKE $2 || '%' THEN 0.8
+                       WHEN lower(coalesce(u.full_name, '')) LIKE $2 || '%' THEN 0.6
+                       ELSE 0.5
+                   END::float8 AS score
+            FROM users u
+            WHERE (
+                lower(u.username) LIKE $2 || '%'
+                OR lower(coalesce(u.full_name, '')) LIKE $2 || '%'
+                OR lower(coalesce(u.full_name, '')) LIKE '% ' || $2 || '%'
+            )
+              AND ($3::uuid IS NULL OR NOT EXISTS (
+                  SELECT 1 FROM blocked_users
+                  WHERE (blocker_id = u.id AND blocked_id = $3::uuid) OR (blocker_id = $3::uuid AND blocked_id = u.id)
+              ))
+        ) matches
+        ORDER BY score DESC, length(username), username
+        LIMIT $4
+    `
+
+	rows, err := db.DB.Query(ctx, query, prefix, utils.EscapeLike(prefix), viewer, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	matches := []*models.UserMatch{}
+	for rows.Next() {
+		var match models.UserMatch
+		err := rows.Scan(
+			&match.User.ID, &match.User.UserName, &match.User.FullName, &match.User.Bio,
+			&match.User.ProfilePictureURL, &match.User.IsPrivate, &match.Score,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user in search results: %w", err)
+		}
+		matches = append(matches, &match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return matches, nil
 }
+
 func (us *Repository) GetSuggestedUsers(ctx context.Context, userID string, limit int) ([]*models.User, error) {
 	db, ok := us.DB.(*postgres.PostgresDBRepo)
 	if !ok {
@@ -733,11 +1037,11 @@ func (us *Repository) GetUserLikedPosts(ctx context.Context, userID string, limi
 	}
 
 	query := `
-        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.video_url, p.audio_url, 
+        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, 
                p.is_edited, p.is_draft, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts
         FROM posts p
-        INNER JOIN post_likes l ON p.id = l.post_id
-        WHERE l.user_id = $1
+        INNER JOIN post_reactions l ON p.id = l.post_id
+        WHERE l.user_id = $1 AND l.kind = 'LIKE'
         ORDER BY l.created_at DESC
         LIMIT $2 OFFSET $3
     `
@@ -752,7 +1056,7 @@ func (us *Repository) GetUserLikedPosts(ctx context.Context, userID string, limi
 	for rows.Next() {
 		var post models.Post
 		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.VideoURL, &post.AudioURL,
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
 			&post.IsEdited, &post.IsDraft, &post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
 		)
 		if err != nil {
@@ -792,7 +1096,7 @@ func (us *Repository) GetUserBookmarkedPosts(ctx context.Context, userID string,
 	}
 
 	query := `
-        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.video_url, p.audio_url, 
+        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, 
                p.is_edited, p.is_draft, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts
         FROM posts p
         INNER JOIN bookmarks b ON p.id = b.post_id
@@ -811,7 +1115,7 @@ func (us *Repository) GetUserBookmarkedPosts(ctx context.Context, userID string,
 	for rows.Next() {
 		var post models.Post
 		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.VideoURL, &post.AudioURL,
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
 			&post.IsEdited, &post.IsDraft, &post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
 		)
 		if err != nil {
@@ -829,9 +1133,16 @@ func (us *Repository) BookmarkPost(ctx context.Context, userID, postID string) (
 		return nil, errorx.New(errorx.ErrCodeDatabase, "", errorx.ErrDatabase)
 	}
 
+	// bookmarks without a collection go to the default one, created on first use
 	query := `
-        INSERT INTO bookmarks (user_id, post_id, created_at)
-        VALUES ($1, $2, $3)
+        WITH c AS (
+            INSERT INTO bookmark_collections (user_id, name, position, is_default)
+            VALUES ($1, 'Saved', 0, TRUE)
+            ON CONFLICT (user_id) WHERE is_default = TRUE DO UPDATE SET is_default = TRUE
+            RETURNING id
+        )
+        INSERT INTO bookmarks (user_id, post_id, collection_id, created_at)
+        SELECT $1, $2, c.id, $3 FROM c
         ON CONFLICT (user_id, post_id) DO NOTHING
     `
 
@@ -875,11 +1186,11 @@ func (us *Repository) GetUserFeed(ctx context.Context, userID string, limit, off
 	}
 
 	query := `
-        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.video_url, p.audio_url, 
+        SELECT p.id, p.user_id, p.title, p.content, p.image_url, p.audio_url, 
                p.is_edited, p.is_draft, p.parent_id, p.created_at, p.updated_at, p.likes, p.reposts
         FROM posts p
         INNER JOIN follows f ON p.user_id = f.followed_id
-        WHERE f.follower_id = $1
+        WHERE f.follower_id = $1 AND f.status = 'ACCEPTED'
         ORDER BY p.created_at DESC
         LIMIT $2 OFFSET $3
     `
@@ -894,7 +1205,7 @@ func (us *Repository) GetUserFeed(ctx context.Context, userID string, limit, off
 	for rows.Next() {
 		var post models.Post
 		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.VideoURL, &post.AudioURL,
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
 			&post.IsEdited, &post.IsDraft, &post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
 		)
 		if err != nil {
@@ -913,7 +1224,7 @@ func (us *Repository) GetTrendingPosts(ctx context.Context, limit int) ([]*model
 	}
 
 	query := `
-        SELECT id, user_id, title, content, image_url, video_url, audio_url, 
+        SELECT id, user_id, title, content, image_url, audio_url, 
                is_edited, is_draft, parent_id, created_at, updated_at, likes, reposts
         FROM posts
         WHERE created_at > $1
@@ -934,7 +1245,7 @@ func (us *Repository) GetTrendingPosts(ctx context.Context, limit int) ([]*model
 	for rows.Next() {
 		var post models.Post
 		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.VideoURL, &post.AudioURL,
+			&post.ID, &post.UserID, &post.Title, &post.Content, &post.ImageURL, &post.AudioURL,
 			&post.IsEdited, &post.IsDraft, &post.ParentID, &post.CreatedAt, &post.UpdatedAt, &post.Likes, &post.Reposts,
 		)
 		if err != nil {
@@ -6,8 +6,16 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// EscapeLike escapes the LIKE wildcards in s so it only matches itself
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
 func FindDirectory(dirName string) (string, error) {
 	// Get the executable path
 	ex, err := os.Executable()
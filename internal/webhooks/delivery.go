@@ -0,0 +1,191 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// deliveryBatch is how many deliveries are claimed at once, small enough that a batch
+	// of slow receivers is done before the lease ends
+	deliveryBatch = 20
+	// deliveryLease is how long a claimed delivery is skipped by other workers
+	deliveryLease = 5 * time.Minute
+	// requestTimeout bounds a single attempt
+	requestTimeout = 10 * time.Second
+	// maxDeliveryAttempts is how often a delivery is tried before it becomes a dead letter
+	maxDeliveryAttempts = 10
+	// maxRetryDelay caps the backoff between attempts
+	maxRetryDelay = 4 * time.Hour
+	// maxErrorLength bounds the error kept in the delivery log
+	maxErrorLength = 500
+
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256, keyed with the subscription's
+	// secret, of the timestamp header, a dot and the body
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	// DeliveryHeader stays the same when a delivery is retried, receivers can skip ids they
+	// have already handled
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// Sign returns the signature header value of a payload sent at timestamp, in unix seconds
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliveryError is a response other than 2xx. 410 Gone tells us the receiver won't take
+// events anymore and is not retried.
+type deliveryError struct {
+	status int
+}
+
+func (e *deliveryError) Error() string {
+	return fmt.Sprintf("receiver answered %d %s", e.status, http.StatusText(e.status))
+}
+
+// retryAt is when a delivery is tried again after its given failed attempt, doubling the
+// wait from a minute. It is nil once the delivery should become a dead letter.
+func retryAt(now time.Time, attempts int, err error) *time.Time {
+	if refused, ok := err.(*deliveryError); ok && refused.status == http.StatusGone {
+		return nil
+	}
+	if attempts >= maxDeliveryAttempts {
+		return nil
+	}
+	delay := time.Minute << (attempts - 1)
+	if delay > maxRetryDelay || delay <= 0 {
+		delay = maxRetryDelay
+	}
+	next := now.Add(delay)
+	return &next
+}
+
+// deliver posts the payload to the subscription's URL and returns the status it answered
+// with, 0 when there was no answer
+func (s *Service) deliver(ctx context.Context, d *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	timestamp := s.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "graphqlChat-Webhooks/1.0")
+	req.Header.Set(EventHeader, string(d.EventType))
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(d.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, &deliveryError{status: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
+
+// DeliverDue posts the deliveries that are due and records how each went. It returns how
+// many were claimed.
+func (s *Service) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.store.ClaimDeliveries(ctx, deliveryBatch, deliveryLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range deliveries {
+		status, err := s.deliver(ctx, d)
+		if err == nil {
+			if err = s.store.CompleteDelivery(ctx, d.ID, status); err != nil {
+				return len(deliveries), err
+			}
+			continue
+		}
+
+		var statusCode *int
+		if status != 0 {
+			statusCode = &status
+		}
+		reason := err.Error()
+		if len(reason) > maxErrorLength {
+			reason = strings.ToValidUTF8(reason[:maxErrorLength], "")
+		}
+		next := retryAt(s.now(), d.Attempts+1, err)
+		if err = s.store.RetryDelivery(ctx, d.ID, statusCode, reason, next); err != nil {
+			return len(deliveries), err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// DeliveryWorker periodically posts due webhook deliveries, failed deliveries are retried
+// with backoff until they become dead letters
+type DeliveryWorker struct {
+	Service  *Service
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewDeliveryWorker(service *Service, interval time.Duration) *DeliveryWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	worker := &DeliveryWorker{
+		Service:  service,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	go worker.run()
+	return worker
+}
+
+func (dw *DeliveryWorker) Stop() {
+	dw.cancel()
+}
+
+func (dw *DeliveryWorker) run() {
+	ticker := time.NewTicker(dw.interval)
+	defer ticker.Stop()
+
+	dw.work()
+	for {
+		select {
+		case <-dw.ctx.Done():
+			return
+		case <-ticker.C:
+			dw.work()
+		}
+	}
+}
+
+func (dw *DeliveryWorker) work() {
+	for {
+		claimed, err := dw.Service.DeliverDue(dw.ctx)
+		if err != nil {
+			log.Printf("failed to deliver webhooks: %v", err)
+			return
+		}
+		if claimed < deliveryBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+type EventType string
+
+const (
+	EventPostCreated  EventType = "POST_CREATED"
+	EventPostLiked    EventType = "POST_LIKED"
+	EventUserFollowed EventType = "USER_FOLLOWED"
+	EventMessageSent  EventType = "MESSAGE_SENT"
+)
+
+func (t EventType) IsValid() bool {
+	switch t {
+	case EventPostCreated, EventPostLiked, EventUserFollowed, EventMessageSent:
+		return true
+	}
+	return false
+}
+
+// Event is something that happened on the platform. It is posted to the subscriptions of
+// every account in UserIDs that asked for its type.
+type Event struct {
+	Type    EventType
+	UserIDs []string
+	Data    interface{}
+}
+
+// Payload is the body posted to a subscription's URL
+type Payload struct {
+	ID        string      `json:"id"`
+	Type      EventType   `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// PostCreated is sent to the author and, for replies, to the author of the parent post
+type PostCreated struct {
+	PostID    string    `json:"post_id"`
+	UserID    string    `json:"user_id"`
+	ParentID  *string   `json:"parent_id,omitempty"`
+	Content   string    `json:"content"`
+	Audience  string    `json:"audience"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PostLiked is sent to the user who liked the post and to its author
+type PostLiked struct {
+	PostID   string `json:"post_id"`
+	UserID   string `json:"user_id"`
+	AuthorID string `json:"author_id"`
+}
+
+// UserFollowed is sent to both accounts once a follow is accepted
+type UserFollowed struct {
+	FollowerID string `json:"follower_id"`
+	FollowedID string `json:"followed_id"`
+}
+
+// MessageSent is sent to the sender and the recipient of a private message
+type MessageSent struct {
+	MessageID string    `json:"message_id"`
+	FromID    string    `json:"from_id"`
+	ToID      string    `json:"to_id"`
+	Content   string    `json:"content"`
+	Type      string    `json:"type"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// RecordEventTx queues the event for the matching subscriptions as part of the caller's
+// transaction, so that it is only delivered when the action causing it is committed
+func RecordEventTx(ctx context.Context, tx pgx.Tx, event Event) error {
+	payload, err := json.Marshal(Payload{
+		ID:        uuid.NewString(),
+		Type:      event.Type,
+		CreatedAt: time.Now().UTC(),
+		Data:      event.Data,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding webhook event: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO webhook_deliveries (subscription_id, event_type, payload)
+        SELECT id, $1, $2 FROM webhook_subscriptions
+        WHERE user_id = ANY($3::uuid[]) AND is_active = TRUE AND $1 = ANY(event_types)
+    `, string(event.Type), string(payload), uniqueIDs(event.UserIDs))
+	if err != nil {
+		return fmt.Errorf("error queueing webhook event: %w", err)
+	}
+	return nil
+}
+
+// uniqueIDs drops repeated and empty ids, such as the missing parent author of a top level post
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
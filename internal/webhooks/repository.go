@@ -0,0 +1,359 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bertoxic/graphqlChat/internal/database"
+	"github.com/bertoxic/graphqlChat/internal/database/postgres"
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/jackc/pgx/v4"
+)
+
+type Repository struct {
+	DB database.DatabaseRepo
+}
+
+func NewRepository(db database.DatabaseRepo) *Repository {
+	return &Repository{DB: db}
+}
+
+func (r *Repository) pool() (*postgres.PostgresDBRepo, error) {
+	db, ok := r.DB.(*postgres.PostgresDBRepo)
+	if !ok {
+		return nil, fmt.Errorf("r.DB does not implement database.Database")
+	}
+	return db, nil
+}
+
+const subscriptionColumns = `id, user_id, url, event_types, is_active, created_at, updated_at`
+
+func scanSubscription(row pgx.Row) (*Subscription, error) {
+	var s Subscription
+	var types []string
+	if err := row.Scan(&s.ID, &s.UserID, &s.URL, &types, &s.IsActive, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	s.EventTypes = make([]EventType, len(types))
+	for i, t := range types {
+		s.EventTypes[i] = EventType(t)
+	}
+	return &s, nil
+}
+
+func eventTypeStrings(types []EventType) []string {
+	out := make([]string, len(types))
+	for i, t := range types {
+		out[i] = string(t)
+	}
+	return out
+}
+
+// CreateSubscription adds a subscription for the user, up to maxSubscriptionsPerUser
+func (r *Repository) CreateSubscription(ctx context.Context, userID string, input SubscriptionInput) (*Subscription, error) {
+	db, err := r.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// lock the owner so concurrent creates can't go past the limit
+	_, err = tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error locking user: %w", err)
+	}
+
+	var count int
+	err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM webhook_subscriptions WHERE user_id = $1`, userID).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("error counting webhooks: %w", err)
+	}
+	if count >= maxSubscriptionsPerUser {
+		return nil, errorx.New(errorx.ErrCodeQuotaExceeded, fmt.Sprintf("at most %d webhooks can be created", maxSubscriptionsPerUser), nil)
+	}
+
+	subscription, err := scanSubscription(tx.QueryRow(ctx, `
+        INSERT INTO webhook_subscriptions (user_id, url, event_types, secret)
+        VALUES ($1, $2, $3, $4)
+        RETURNING `+subscriptionColumns,
+		userID, input.URL, eventTypeStrings(input.EventTypes), input.Secret))
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return subscription, nil
+}
+
+// UpdateSubscription changes the set fields of the user's subscription
+func (r *Repository) UpdateSubscription(ctx context.Context, userID string, subscriptionID string, input UpdateSubscriptionInput) (*Subscription, error) {
+	db, err := r.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	var types []string
+	if input.EventTypes != nil {
+		types = eventTypeStrings(input.EventTypes)
+	}
+
+	subscription, err := scanSubscription(db.DB.QueryRow(ctx, `
+        UPDATE webhook_subscriptions SET
+            url = COALESCE($3, url),
+            event_types = COALESCE($4, event_types),
+            secret = COALESCE($5, secret),
+            is_active = COALESCE($6, is_active),
+            updated_at = NOW()
+        WHERE id = $1 AND user_id = $2
+        RETURNING `+subscriptionColumns,
+		subscriptionID, userID, input.URL, types, input.Secret, input.IsActive))
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "webhook not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error updating webhook: %w", err)
+	}
+	return subscription, nil
+}
+
+func (r *Repository) DeleteSubscription(ctx context.Context, userID string, subscriptionID string) error {
+	db, err := r.pool()
+	if err != nil {
+		return err
+	}
+
+	tag, err := db.DB.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1 AND user_id = $2`, subscriptionID, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.New(errorx.ErrCodeNotFound, "webhook not found", nil)
+	}
+	return nil
+}
+
+// GetSubscriptions returns the user's subscriptions, the oldest first
+func (r *Repository) GetSubscriptions(ctx context.Context, userID string) ([]*Subscription, error) {
+	db, err := r.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query(ctx, `
+        SELECT `+subscriptionColumns+`
+        FROM webhook_subscriptions
+        WHERE user_id = $1
+        ORDER BY created_at, id
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []*Subscription{}
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// deliveryColumns selects a delivery under alias d with its status
+const deliveryColumns = `
+    d.id, d.subscription_id, d.event_type, d.payload::text,
+    CASE WHEN d.delivered_at IS NOT NULL THEN 'DELIVERED' WHEN d.dead_at IS NOT NULL THEN 'DEAD' ELSE 'PENDING' END,
+    d.attempts, d.last_status_code, d.last_error, d.last_attempt_at, d.next_attempt_at, d.delivered_at, d.dead_at, d.created_at`
+
+func scanDelivery(row pgx.Row) (*Delivery, error) {
+	var d Delivery
+	var payload string
+	var nextAttemptAt time.Time
+	err := row.Scan(
+		&d.ID, &d.SubscriptionID, &d.EventType, &payload, &d.Status,
+		&d.Attempts, &d.LastStatusCode, &d.LastError, &d.LastAttemptAt, &nextAttemptAt, &d.DeliveredAt, &d.DeadAt, &d.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = []byte(payload)
+	if d.Status == DeliveryPending {
+		d.NextAttemptAt = &nextAttemptAt
+	}
+	return &d, nil
+}
+
+// GetDeliveries returns the delivery log of the user's subscription, the latest first
+func (r *Repository) GetDeliveries(ctx context.Context, userID string, subscriptionID string, status *DeliveryStatus, limit, offset int) ([]*Delivery, error) {
+	db, err := r.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	var owned bool
+	err = db.DB.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM webhook_subscriptions WHERE id = $1 AND user_id = $2)
+    `, subscriptionID, userID).Scan(&owned)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhook: %w", err)
+	}
+	if !owned {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "webhook not found", nil)
+	}
+
+	var statusArg *string
+	if status != nil {
+		value := string(*status)
+		statusArg = &value
+	}
+
+	rows, err := db.DB.Query(ctx, `
+        SELECT `+deliveryColumns+`
+        FROM webhook_deliveries d
+        WHERE d.subscription_id = $1
+          AND ($2::text IS NULL
+            OR ($2 = 'DELIVERED' AND d.delivered_at IS NOT NULL)
+            OR ($2 = 'DEAD' AND d.dead_at IS NOT NULL)
+            OR ($2 = 'PENDING' AND d.delivered_at IS NULL AND d.dead_at IS NULL))
+        ORDER BY d.created_at DESC, d.id DESC
+        LIMIT $3 OFFSET $4
+    `, subscriptionID, statusArg, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*Delivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Redeliver makes the delivery of the user's subscription due again with no failed attempts
+func (r *Repository) Redeliver(ctx context.Context, userID string, deliveryID string) (*Delivery, error) {
+	db, err := r.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := scanDelivery(db.DB.QueryRow(ctx, `
+        UPDATE webhook_deliveries d SET
+            attempts = 0, next_attempt_at = NOW(), delivered_at = NULL, dead_at = NULL
+        FROM webhook_subscriptions s
+        WHERE s.id = d.subscription_id AND d.id = $1 AND s.user_id = $2
+        RETURNING `+deliveryColumns,
+		deliveryID, userID))
+	if err == pgx.ErrNoRows {
+		return nil, errorx.New(errorx.ErrCodeNotFound, "webhook delivery not found", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error redelivering webhook: %w", err)
+	}
+	return delivery, nil
+}
+
+// ClaimDeliveries takes up to limit due deliveries of active subscriptions. They are leased
+// so that other workers skip them until the lease ends, a delivery that is neither
+// completed nor retried in time is tried again.
+func (r *Repository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*Delivery, error) {
+	db, err := r.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query(ctx, `
+        UPDATE webhook_deliveries d SET next_attempt_at = $2
+        FROM webhook_subscriptions s
+        WHERE s.id = d.subscription_id AND d.id IN (
+            SELECT wd.id FROM webhook_deliveries wd
+            JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id
+            WHERE wd.delivered_at IS NULL AND wd.dead_at IS NULL AND wd.next_attempt_at <= NOW() AND ws.is_active = TRUE
+            ORDER BY wd.next_attempt_at
+            LIMIT $1
+            FOR UPDATE OF wd SKIP LOCKED
+        )
+        RETURNING d.id, d.subscription_id, d.event_type, d.payload::text, d.attempts, s.url, s.secret
+    `, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*Delivery{}
+	for rows.Next() {
+		d := Delivery{Status: DeliveryPending}
+		var payload string
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventType, &payload, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery: %w", err)
+		}
+		d.Payload = []byte(payload)
+		deliveries = append(deliveries, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// CompleteDelivery marks the delivery as accepted by the subscription's URL
+func (r *Repository) CompleteDelivery(ctx context.Context, deliveryID string, statusCode int) error {
+	db, err := r.pool()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec(ctx, `
+        UPDATE webhook_deliveries SET
+            delivered_at = NOW(), last_attempt_at = NOW(), last_status_code = $2, last_error = NULL
+        WHERE id = $1
+    `, deliveryID, statusCode)
+	if err != nil {
+		return fmt.Errorf("error completing webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// RetryDelivery records a failed attempt, statusCode is nil when no response came back.
+// The delivery is tried again at retryAt, or becomes a dead letter when retryAt is nil.
+func (r *Repository) RetryDelivery(ctx context.Context, deliveryID string, statusCode *int, reason string, retryAt *time.Time) error {
+	db, err := r.pool()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec(ctx, `
+        UPDATE webhook_deliveries SET
+            attempts = attempts + 1,
+            last_attempt_at = NOW(),
+            last_status_code = $2,
+            last_error = $3,
+            next_attempt_at = COALESCE($4, next_attempt_at),
+            dead_at = CASE WHEN $4::timestamp IS NULL THEN NOW() END
+        WHERE id = $1
+    `, deliveryID, statusCode, reason, retryAt)
+	if err != nil {
+		return fmt.Errorf("error recording failed webhook delivery: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	errorx "github.com/bertoxic/graphqlChat/internal/error"
+	"github.com/bertoxic/graphqlChat/internal/netutil"
+)
+
+const (
+	maxSubscriptionsPerUser = 10
+	urlMaxLength            = 2048
+	secretMinLength         = 16
+	secretMaxLength         = 256
+	deliveriesDefaultLimit  = 20
+	deliveriesMaxLimit      = 100
+)
+
+// Subscription is a URL the events of its owner's account are posted to. The secret signs
+// every payload and is never handed out again.
+type Subscription struct {
+	ID         string      `json:"id"`
+	UserID     string      `json:"user_id"`
+	URL        string      `json:"url"`
+	EventTypes []EventType `json:"event_types"`
+	IsActive   bool        `json:"is_active"`
+	Secret     string      `json:"-"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// SubscriptionInput creates a subscription
+type SubscriptionInput struct {
+	URL        string      `json:"url"`
+	EventTypes []EventType `json:"event_types"`
+	Secret     string      `json:"secret"`
+}
+
+// UpdateSubscriptionInput changes the fields that are set
+type UpdateSubscriptionInput struct {
+	URL        *string     `json:"url,omitempty"`
+	EventTypes []EventType `json:"event_types,omitempty"`
+	Secret     *string     `json:"secret,omitempty"`
+	IsActive   *bool       `json:"is_active,omitempty"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "PENDING"
+	DeliveryDelivered DeliveryStatus = "DELIVERED"
+	// DeliveryDead is a delivery given up on after its last attempt, it stays in the
+	// dead letters until it is redelivered
+	DeliveryDead DeliveryStatus = "DEAD"
+)
+
+func (s DeliveryStatus) IsValid() bool {
+	switch s {
+	case DeliveryPending, DeliveryDelivered, DeliveryDead:
+		return true
+	}
+	return false
+}
+
+// Delivery is an event posted, or waiting to be posted, to a subscription
+type Delivery struct {
+	ID             string         `json:"id"`
+	SubscriptionID string         `json:"subscription_id"`
+	EventType      EventType      `json:"event_type"`
+	Payload        []byte         `json:"payload"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"` // failed attempts so far
+	LastStatusCode *int           `json:"last_status_code,omitempty"`
+	LastError      *string        `json:"last_error,omitempty"`
+	LastAttemptAt  *time.Time     `json:"last_attempt_at,omitempty"`
+	NextAttemptAt  *time.Time     `json:"next_attempt_at,omitempty"` // Only set while pending
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	DeadAt         *time.Time     `json:"dead_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	URL            string         `json:"-"` // Set on claimed deliveries
+	Secret         string         `json:"-"`
+}
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, userID string, input SubscriptionInput) (*Subscription, error)
+	UpdateSubscription(ctx context.Context, userID string, subscriptionID string, input UpdateSubscriptionInput) (*Subscription, error)
+	DeleteSubscription(ctx context.Context, userID string, subscriptionID string) error
+	GetSubscriptions(ctx context.Context, userID string) ([]*Subscription, error)
+	GetDeliveries(ctx context.Context, userID string, subscriptionID string, status *DeliveryStatus, limit, offset int) ([]*Delivery, error)
+	Redeliver(ctx context.Context, userID string, deliveryID string) (*Delivery, error)
+}
+
+// store is what the service keeps in the database, Repository implements it
+type store interface {
+	CreateSubscription(ctx context.Context, userID string, input SubscriptionInput) (*Subscription, error)
+	UpdateSubscription(ctx context.Context, userID string, subscriptionID string, input UpdateSubscriptionInput) (*Subscription, error)
+	DeleteSubscription(ctx context.Context, userID string, subscriptionID string) error
+	GetSubscriptions(ctx context.Context, userID string) ([]*Subscription, error)
+	GetDeliveries(ctx context.Context, userID string, subscriptionID string, status *DeliveryStatus, limit, offset int) ([]*Delivery, error)
+	Redeliver(ctx context.Context, userID string, deliveryID string) (*Delivery, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*Delivery, error)
+	CompleteDelivery(ctx context.Context, deliveryID string, statusCode int) error
+	RetryDelivery(ctx context.Context, deliveryID string, statusCode *int, reason string, retryAt *time.Time) error
+}
+
+// Service manages the webhook subscriptions of users and posts their events, see
+// DeliveryWorker
+type Service struct {
+	store  store
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewService(repo *Repository) *Service {
+	return newService(repo, netutil.NewPublicClient(requestTimeout))
+}
+
+func newService(store store, client *http.Client) *Service {
+	return &Service{store: store, client: client, now: time.Now}
+}
+
+// validateURL accepts absolute http and https URLs of hosts that aren't obviously
+// internal, addresses a name resolves to are checked again when connecting
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || len(raw) > urlMaxLength || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errorx.New(errorx.ErrCodeValidation, "webhook URLs must be absolute http or https URLs", nil)
+	}
+	if u.User != nil {
+		return errorx.New(errorx.ErrCodeValidation, "webhook URLs can't contain credentials", nil)
+	}
+	if !netutil.IsPublicHost(u) {
+		return errorx.New(errorx.ErrCodeValidation, "webhook URLs must point to a public host", nil)
+	}
+	return nil
+}
+
+// validateEventTypes rejects an empty or unknown list and drops repeated types
+func validateEventTypes(types []EventType) ([]EventType, error) {
+	if len(types) == 0 {
+		return nil, errorx.New(errorx.ErrCodeValidation, "subscribe to at least one event type", nil)
+	}
+	seen := make(map[EventType]bool, len(types))
+	unique := make([]EventType, 0, len(types))
+	for _, t := range types {
+		if !t.IsValid() {
+			return nil, errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("unknown event type %q", t), nil)
+		}
+		if !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	return unique, nil
+}
+
+func validateSecret(secret string) error {
+	if len(secret) < secretMinLength || len(secret) > secretMaxLength {
+		return errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("webhook secrets must be %d to %d characters", secretMinLength, secretMaxLength), nil)
+	}
+	return nil
+}
+
+func (s *Service) CreateSubscription(ctx context.Context, userID string, input SubscriptionInput) (*Subscription, error) {
+	input.URL = strings.TrimSpace(input.URL)
+	if err := validateURL(input.URL); err != nil {
+		return nil, err
+	}
+	types, err := validateEventTypes(input.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	input.EventTypes = types
+	if err = validateSecret(input.Secret); err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.store.CreateSubscription(ctx, userID, input)
+	if err != nil {
+		return nil, serviceError("failed to create webhook", err)
+	}
+	return subscription, nil
+}
+
+func (s *Service) UpdateSubscription(ctx context.Context, userID string, subscriptionID string, input UpdateSubscriptionInput) (*Subscription, error) {
+	if input.URL != nil {
+		trimmed := strings.TrimSpace(*input.URL)
+		if err := validateURL(trimmed); err != nil {
+			return nil, err
+		}
+		input.URL = &trimmed
+	}
+	if input.EventTypes != nil {
+		types, err := validateEventTypes(input.EventTypes)
+		if err != nil {
+			return nil, err
+		}
+		input.EventTypes = types
+	}
+	if input.Secret != nil {
+		if err := validateSecret(*input.Secret); err != nil {
+			return nil, err
+		}
+	}
+
+	subscription, err := s.store.UpdateSubscription(ctx, userID, subscriptionID, input)
+	if err != nil {
+		return nil, serviceError("failed to update webhook", err)
+	}
+	return subscription, nil
+}
+
+// DeleteSubscription removes the subscription with its delivery log
+func (s *Service) DeleteSubscription(ctx context.Context, userID string, subscriptionID string) error {
+	if err := s.store.DeleteSubscription(ctx, userID, subscriptionID); err != nil {
+		return serviceError("failed to delete webhook", err)
+	}
+	return nil
+}
+
+func (s *Service) GetSubscriptions(ctx context.Context, userID string) ([]*Subscription, error) {
+	subscriptions, err := s.store.GetSubscriptions(ctx, userID)
+	if err != nil {
+		return nil, serviceError("failed to get webhooks", err)
+	}
+	return subscriptions, nil
+}
+
+// GetDeliveries returns the delivery log of the user's subscription, the latest first.
+// Filtering by DeliveryDead lists the dead letters.
+func (s *Service) GetDeliveries(ctx context.Context, userID string, subscriptionID string, status *DeliveryStatus, limit, offset int) ([]*Delivery, error) {
+	if status != nil && !status.IsValid() {
+		return nil, errorx.New(errorx.ErrCodeValidation, fmt.Sprintf("unknown delivery status %q", *status), nil)
+	}
+	if limit <= 0 {
+		limit = deliveriesDefaultLimit
+	}
+	if limit > deliveriesMaxLimit {
+		limit = deliveriesMaxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	deliveries, err := s.store.GetDeliveries(ctx, userID, subscriptionID, status, limit, offset)
+	if err != nil {
+		return nil, serviceError("failed to get webhook deliveries", err)
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a delivery of the user's subscriptions again with a fresh set of
+// attempts, such as a dead letter once the receiving end is fixed
+func (s *Service) Redeliver(ctx context.Context, userID string, deliveryID string) (*Delivery, error) {
+	delivery, err := s.store.Redeliver(ctx, userID, deliveryID)
+	if err != nil {
+		return nil, serviceError("failed to redeliver webhook", err)
+	}
+	return delivery, nil
+}
+
+// serviceError passes through errors the repository already classified and
+// reports anything else as a database failure
+func serviceError(message string, err error) error {
+	var appErr *errorx.AppError
+	if errors.As(err, &appErr) && appErr.Code != errorx.ErrCodeDatabase {
+		return err
+	}
+	return errorx.New(errorx.ErrCodeDatabase, message, err)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -19,6 +20,14 @@ type AppConfig struct {
 	Port          string
 	UserCache     string
 	TemplateCache map[string]*template.Template
+	// PostEditWindow limits how long posts stay editable, zero disables the limit
+	PostEditWindow time.Duration
+	// MediaDir is where uploaded media is stored on disk, MediaBaseURL is where it is served from
+	MediaDir     string
+	MediaBaseURL string
+	// PublicBaseURL is where the site is reached, used for links in profile feeds and
+	// for ActivityPub ids. Feeds and federation are only enabled when it is set.
+	PublicBaseURL string
 }
 
 type JWT struct {
@@ -38,7 +47,11 @@ func NewConfig(fileName, port string) (*AppConfig, error) {
 				Secret: []byte(os.Getenv("JWT_SECRET")),
 				Issuer: os.Getenv("ISSUER"),
 			},
-			Port: port,
+			Port:           port,
+			PostEditWindow: getDurationEnv("POST_EDIT_WINDOW"),
+			MediaDir:       getEnvDefault("MEDIA_DIR", "./uploads"),
+			MediaBaseURL:   getEnvDefault("MEDIA_BASE_URL", "/media"),
+			PublicBaseURL:  os.Getenv("PUBLIC_BASE_URL"),
 		}, nil
 	}
 
@@ -88,10 +101,36 @@ func NewConfig(fileName, port string) (*AppConfig, error) {
 			Secret: []byte(os.Getenv("JWT_SECRET")),
 			Issuer: os.Getenv("ISSUER"),
 		},
-		Port: port,
+		Port:           port,
+		PostEditWindow: getDurationEnv("POST_EDIT_WINDOW"),
+		MediaDir:       getEnvDefault("MEDIA_DIR", "./uploads"),
+		MediaBaseURL:   getEnvDefault("MEDIA_BASE_URL", "/media"),
+		PublicBaseURL:  os.Getenv("PUBLIC_BASE_URL"),
 	}, nil
 }
 
+// getEnvDefault returns the environment variable, or fallback when it is unset
+func getEnvDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getDurationEnv parses a duration such as "15m" from the environment, returning zero when unset or invalid
+func getDurationEnv(key string) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid duration %q for %s: %v", value, key, err)
+		return 0
+	}
+	return d
+}
+
 func getExecutablePath() (string, error) {
 	ex, err := os.Executable()
 	if err != nil {
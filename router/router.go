@@ -1,17 +1,22 @@
 package router
 
 import (
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/bertoxic/graphqlChat/graph"
 	"github.com/bertoxic/graphqlChat/graph/resolvers"
 	"github.com/bertoxic/graphqlChat/internal/app"
 	"github.com/bertoxic/graphqlChat/internal/handlers"
 	"github.com/bertoxic/graphqlChat/internal/jwt"
+	"github.com/bertoxic/graphqlChat/internal/media"
 	"github.com/bertoxic/graphqlChat/internal/middlewares"
-	"github.com/bertoxic/graphqlChat/internal/posts"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/vektah/gqlparser/v2/ast"
 	"net/http"
 	"time"
 )
@@ -23,8 +28,6 @@ func Routes(app *app.App) http.Handler {
 	mux.Use(middleware.RequestID)
 	mux.Use(middleware.Recoverer)
 	tokenService := jwt.NewTokenService(app.Config)
-	postRepo := posts.NewPostRepo(app.DB)
-	postService := posts.NewPostServiceImpl(postRepo)
 
 	mux.Use(middlewares.AuthMiddleWare(tokenService))
 	mux.Use(middleware.Timeout(time.Second * 45))
@@ -34,18 +37,62 @@ func Routes(app *app.App) http.Handler {
 	mux.Get("/register", handlers.Repo.HandleRegister)
 	mux.Get("/googleLogin", handlers.Repo.HandleGoogleLogin)
 	mux.Get("/googleCallback", handlers.Repo.HandleGoogleCallback)
+	// public profiles for feed readers, private and unknown accounts are 404, only served
+	// when a public base URL is configured
+	mux.Get("/users/{username}/feed.rss", handlers.FeedRepo.HandleRSSFeed)
+	mux.Get("/users/{username}/feed.atom", handlers.FeedRepo.HandleAtomFeed)
+	mux.Get("/users/{username}/feed.json", handlers.FeedRepo.HandleJSONFeed)
+	// ActivityPub, only served when a public base URL is configured
+	mux.Get("/.well-known/webfinger", handlers.FederationRepo.HandleWebFinger)
+	mux.Get("/users/{username}", handlers.FederationRepo.HandleActor)
+	mux.Get("/users/{username}/outbox", handlers.FederationRepo.HandleOutbox)
+	mux.Post("/users/{username}/inbox", handlers.FederationRepo.HandleInbox)
+	mux.Get("/posts/{postID}", handlers.FederationRepo.HandleNote)
+	// uploaded media, unless MEDIA_BASE_URL points at the root of another host
+	if mediaPath := media.ServePath(app.Config.MediaBaseURL); mediaPath != "" {
+		mux.Handle(mediaPath+"/*", http.StripPrefix(mediaPath+"/", media.FileServer(app.Config.MediaDir)))
+	}
 	mux.Handle("/play", playground.Handler("Graphql-chat", "/query"))
-	mux.Handle("/query", handler.NewDefaultServer(
+	mux.Handle("/query", newGraphqlServer(
 		graph.NewExecutableSchema(
 			graph.Config{
 				Resolvers: &resolvers.Resolver{
 					AuthService:     app.Services.AuthService,
 					AuthUserService: app.Services.UserAuthService,
-					PostService:     postService,
+					PostService:     app.Services.PostService,
 					UserService:     *app.Services.UserService,
+					MediaService:    app.Services.MediaService,
+					SearchService:   app.Services.SearchService,
+					WebhookService:  app.Services.Webhooks,
 				},
 			},
 		),
 	))
 	return mux
 }
+
+// newGraphqlServer sets up the same transports as handler.NewDefaultServer, with the
+// multipart limit raised so that media uploads fit
+func newGraphqlServer(es graphql.ExecutableSchema) *handler.Server {
+	srv := handler.New(es)
+
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: media.MaxRequestSize,
+		MaxMemory:     32 << 20,
+	})
+
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New[string](100),
+	})
+
+	return srv
+}